@@ -4,7 +4,10 @@
 
 package bpf
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 // Assemble converts insts into raw instructions suitable for loading
 // into a BPF virtual machine.
@@ -39,3 +42,66 @@ func Disassemble(raw []RawInstruction) (insts []Instruction, allDecoded bool) {
 	}
 	return insts, allDecoded
 }
+
+// Validate checks insts for common mistakes that would otherwise only
+// be reported when the program is loaded into or run by a BPF virtual
+// machine: accessing a scratch memory slot outside of 0-15, jumping
+// past the end of the program, or not ending the program with a RetA
+// or RetConstant instruction.
+func Validate(insts []Instruction) error {
+	if len(insts) == 0 {
+		return errors.New("one or more Instructions must be specified")
+	}
+	for i, inst := range insts {
+		if ri, ok := inst.(RawInstruction); ok {
+			inst = ri.Disassemble()
+		}
+		if err := validateInstruction(inst, len(insts)-(i+1)); err != nil {
+			return fmt.Errorf("instruction %d: %v", i+1, err)
+		}
+	}
+	last := insts[len(insts)-1]
+	if ri, ok := last.(RawInstruction); ok {
+		last = ri.Disassemble()
+	}
+	switch last.(type) {
+	case RetA, RetConstant:
+	default:
+		return errors.New("BPF program must end with RetA or RetConstant")
+	}
+	return nil
+}
+
+// validateInstruction checks inst, which is followed by remaining
+// instructions in its program.
+func validateInstruction(inst Instruction, remaining int) error {
+	switch inst := inst.(type) {
+	case LoadScratch:
+		if inst.N < 0 || inst.N > 15 {
+			return fmt.Errorf("invalid scratch slot %d", inst.N)
+		}
+	case StoreScratch:
+		if inst.N < 0 || inst.N > 15 {
+			return fmt.Errorf("invalid scratch slot %d", inst.N)
+		}
+	case Jump:
+		if remaining <= int(inst.Skip) {
+			return fmt.Errorf("cannot jump %d instructions; jumping past program bounds", inst.Skip)
+		}
+	case JumpIf:
+		if remaining <= int(inst.SkipTrue) {
+			return fmt.Errorf("cannot jump %d instructions in true case; jumping past program bounds", inst.SkipTrue)
+		}
+		if remaining <= int(inst.SkipFalse) {
+			return fmt.Errorf("cannot jump %d instructions in false case; jumping past program bounds", inst.SkipFalse)
+		}
+	case JumpIfX:
+		if remaining <= int(inst.SkipTrue) {
+			return fmt.Errorf("cannot jump %d instructions in true case; jumping past program bounds", inst.SkipTrue)
+		}
+		if remaining <= int(inst.SkipFalse) {
+			return fmt.Errorf("cannot jump %d instructions in false case; jumping past program bounds", inst.SkipFalse)
+		}
+	}
+	return nil
+}
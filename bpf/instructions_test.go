@@ -591,3 +591,69 @@ func TestString(t *testing.T) {
 		}
 	}
 }
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		insts []Instruction
+		err   string
+	}{
+		{
+			name: "valid",
+			insts: []Instruction{
+				LoadAbsolute{Off: 12, Size: 2},
+				StoreScratch{Src: RegA, N: 15},
+				JumpIf{Cond: JumpEqual, Val: 0x0800, SkipTrue: 1},
+				RetConstant{Val: 0},
+				LoadScratch{Dst: RegX, N: 0},
+				RetA{},
+			},
+		},
+		{
+			name: "empty",
+			err:  "one or more Instructions must be specified",
+		},
+		{
+			name:  "store scratch",
+			insts: []Instruction{StoreScratch{Src: RegA, N: 16}, RetA{}},
+			err:   "instruction 1: invalid scratch slot 16",
+		},
+		{
+			name:  "load scratch",
+			insts: []Instruction{LoadConstant{Dst: RegA, Val: 1}, LoadScratch{Dst: RegA, N: -1}, RetA{}},
+			err:   "instruction 2: invalid scratch slot -1",
+		},
+		{
+			name:  "jump",
+			insts: []Instruction{Jump{Skip: 1}, RetA{}},
+			err:   "instruction 1: cannot jump 1 instructions; jumping past program bounds",
+		},
+		{
+			name:  "jump if false",
+			insts: []Instruction{JumpIf{Cond: JumpEqual, SkipFalse: 2}, RetA{}},
+			err:   "instruction 1: cannot jump 2 instructions in false case; jumping past program bounds",
+		},
+		{
+			name:  "raw jump",
+			insts: []Instruction{RawInstruction{Op: opClsJump | uint16(opJumpAlways), K: 3}, RetA{}},
+			err:   "instruction 1: cannot jump 3 instructions; jumping past program bounds",
+		},
+		{
+			name:  "raw ret",
+			insts: []Instruction{LoadConstant{Dst: RegA, Val: 1}, RawInstruction{Op: opClsReturn | opRetSrcA}},
+		},
+		{
+			name:  "no ret",
+			insts: []Instruction{RetA{}, LoadConstant{Dst: RegA, Val: 1}},
+			err:   "BPF program must end with RetA or RetConstant",
+		},
+	}
+	for _, tt := range tests {
+		err := Validate(tt.insts)
+		if tt.err == "" && err != nil {
+			t.Errorf("%s: Validate() = %v, want nil", tt.name, err)
+		} else if tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("%s: Validate() = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
@@ -8,8 +8,10 @@ package ctxhttp // import "golang.org/x/net/context/ctxhttp"
 import (
 	"context"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
 )
 
@@ -69,3 +71,72 @@ func Post(ctx context.Context, client *http.Client, url string, bodyType string,
 func PostForm(ctx context.Context, client *http.Client, url string, data url.Values) (*http.Response, error) {
 	return Post(ctx, client, url, "application/x-www-form-urlencoded", strings.NewReader(data.Encode()))
 }
+
+// PostMultipart issues a POST request via the Do function with a
+// multipart/form-data body holding fields and files. Each file is sent
+// in a form field named by its key, which is also used as its file name.
+//
+// The body is streamed to the server as the files are read, so memory
+// use does not grow with their size. If ctx is done before the body has
+// been sent, writing the body stops, although a Read of one of the files
+// that is already in progress is not interrupted.
+func PostMultipart(ctx context.Context, client *http.Client, url string, fields map[string]string, files map[string]io.Reader) (*http.Response, error) {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	req, err := http.NewRequest("POST", url, pr)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	// The transport closes the request body when it is done with it,
+	// which unblocks the writing goroutine even on errors, but it may
+	// not do so promptly after ctx is done.
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			pw.CloseWithError(ctx.Err())
+		case <-stop:
+		}
+	}()
+	go func() {
+		defer testHookMultipartDone()
+		defer close(stop)
+		pw.CloseWithError(writeMultipart(mw, fields, files))
+	}()
+	return Do(ctx, client, req)
+}
+
+var testHookMultipartDone = func() {}
+
+// writeMultipart writes fields and files to mw in the order of their
+// names, and closes mw.
+func writeMultipart(mw *multipart.Writer, fields map[string]string, files map[string]io.Reader) error {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := mw.WriteField(name, fields[name]); err != nil {
+			return err
+		}
+	}
+
+	names = names[:0]
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w, err := mw.CreateFormFile(name, name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, files[name]); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
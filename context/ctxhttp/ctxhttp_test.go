@@ -12,6 +12,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -115,3 +116,78 @@ func TestCancelAfterHangingRequest(t *testing.T) {
 	case <-done:
 	}
 }
+
+func TestPostMultipart(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f, _, err := r.FormFile("upload.txt")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		io.WriteString(w, r.FormValue("name")+":")
+		io.Copy(w, f)
+	}))
+	defer ts.Close()
+
+	fields := map[string]string{"name": "gopher"}
+	files := map[string]io.Reader{"upload.txt": strings.NewReader("file contents")}
+	res, err := PostMultipart(context.Background(), nil, ts.URL, fields, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	slurp, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "gopher:file contents"; string(slurp) != want {
+		t.Errorf("body = %q; want %q", slurp, want)
+	}
+}
+
+// endlessReader returns an endless stream of zeros.
+type endlessReader struct{}
+
+func (endlessReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func TestPostMultipartCancel(t *testing.T) {
+	done := make(chan struct{})
+	defer func(old func()) { testHookMultipartDone = old }(testHookMultipartDone)
+	testHookMultipartDone = func() { close(done) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	blockServer := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.CopyN(ioutil.Discard, r.Body, 1<<10)
+		cancel()
+		<-blockServer
+	}))
+	defer ts.Close()
+	defer close(blockServer)
+
+	files := map[string]io.Reader{"zeros": endlessReader{}}
+	res, err := PostMultipart(ctx, nil, ts.URL, nil, files)
+	if err == nil {
+		res.Body.Close()
+		t.Fatal("PostMultipart returned unexpected nil error")
+	}
+	if err != context.Canceled {
+		t.Errorf("err = %v; want %v", err, context.Canceled)
+	}
+
+	select {
+	case <-time.After(5 * time.Second):
+		t.Errorf("body writer did not exit")
+	case <-done:
+	}
+}
@@ -33,6 +33,9 @@ const (
 	TypeAAAA  Type = 28
 	TypeSRV   Type = 33
 	TypeOPT   Type = 41
+	TypeSVCB  Type = 64
+	TypeHTTPS Type = 65
+	TypeCAA   Type = 257
 
 	// Question.Type
 	TypeWKS   Type = 11
@@ -53,6 +56,9 @@ var typeNames = map[Type]string{
 	TypeAAAA:  "TypeAAAA",
 	TypeSRV:   "TypeSRV",
 	TypeOPT:   "TypeOPT",
+	TypeSVCB:  "TypeSVCB",
+	TypeHTTPS: "TypeHTTPS",
+	TypeCAA:   "TypeCAA",
 	TypeWKS:   "TypeWKS",
 	TypeHINFO: "TypeHINFO",
 	TypeMINFO: "TypeMINFO",
@@ -272,6 +278,12 @@ var (
 	errNonCanonicalName   = errors.New("name is not in canonical format (it must end with a .)")
 	errStringTooLong      = errors.New("character string exceeds maximum length (255)")
 	errCompressedSRV      = errors.New("compressed name in SRV resource data")
+	errParamOutOfOrder    = errors.New("SVCB parameter keys not in strictly increasing order")
+	errParamValueLen      = errors.New("invalid SVCB parameter value length")
+	errOptionCode         = errors.New("unexpected option code")
+	errClientSubnet       = errors.New("invalid client subnet option")
+	errCookie             = errors.New("invalid cookie option")
+	errCAATag             = errors.New("invalid CAA tag")
 )
 
 // Internal constants.
@@ -728,6 +740,12 @@ func (p *Parser) AllAnswers() ([]Resource, error) {
 }
 
 // SkipAnswer skips a single Answer Resource.
+//
+// It may be called after AnswerHeader, in which case only the body of the
+// Resource is skipped, without being parsed. Together with AnswerHeader and
+// the typed body parsers such as AResource, this allows iterating over the
+// Answer section and decoding only the Resource types of interest without
+// allocating.
 func (p *Parser) SkipAnswer() error {
 	return p.skipResource(sectionAnswers)
 }
@@ -843,6 +861,27 @@ func (p *Parser) SkipAllAdditionals() error {
 	}
 }
 
+// AppendResourceBody appends the raw body of the current Resource to dst and
+// returns the extended buffer. The body is not parsed, so any domain names
+// it contains may still be compressed relative to the full message.
+//
+// One of the XXXHeader methods must have been called before calling this
+// method.
+func (p *Parser) AppendResourceBody(dst []byte) ([]byte, error) {
+	if !p.resHeaderValid {
+		return dst, ErrNotStarted
+	}
+	end := p.off + int(p.resHeader.Length)
+	if end > len(p.msg) {
+		return dst, errResourceLen
+	}
+	dst = append(dst, p.msg[p.off:end]...)
+	p.off = end
+	p.resHeaderValid = false
+	p.index++
+	return dst, nil
+}
+
 // CNAMEResource parses a single CNAMEResource.
 //
 // One of the XXXHeader methods must have been called before calling this
@@ -1023,6 +1062,112 @@ func (p *Parser) OPTResource() (OPTResource, error) {
 	return r, nil
 }
 
+// SVCBResource parses a single SVCBResource.
+//
+// One of the XXXHeader methods must have been called before calling this
+// method.
+func (p *Parser) SVCBResource() (SVCBResource, error) {
+	if !p.resHeaderValid || p.resHeader.Type != TypeSVCB {
+		return SVCBResource{}, ErrNotStarted
+	}
+	r, err := unpackSVCBResource(p.msg, p.off, p.resHeader.Length)
+	if err != nil {
+		return SVCBResource{}, err
+	}
+	p.off += int(p.resHeader.Length)
+	p.resHeaderValid = false
+	p.index++
+	return r, nil
+}
+
+// HTTPSResource parses a single HTTPSResource.
+//
+// One of the XXXHeader methods must have been called before calling this
+// method.
+func (p *Parser) HTTPSResource() (HTTPSResource, error) {
+	if !p.resHeaderValid || p.resHeader.Type != TypeHTTPS {
+		return HTTPSResource{}, ErrNotStarted
+	}
+	r, err := unpackSVCBResource(p.msg, p.off, p.resHeader.Length)
+	if err != nil {
+		return HTTPSResource{}, err
+	}
+	p.off += int(p.resHeader.Length)
+	p.resHeaderValid = false
+	p.index++
+	return HTTPSResource{r}, nil
+}
+
+// CAAResource parses a single CAAResource.
+//
+// One of the XXXHeader methods must have been called before calling this
+// method.
+func (p *Parser) CAAResource() (CAAResource, error) {
+	if !p.resHeaderValid || p.resHeader.Type != TypeCAA {
+		return CAAResource{}, ErrNotStarted
+	}
+	r, err := unpackCAAResource(p.msg, p.off, p.resHeader.Length)
+	if err != nil {
+		return CAAResource{}, err
+	}
+	p.off += int(p.resHeader.Length)
+	p.resHeaderValid = false
+	p.index++
+	return r, nil
+}
+
+// UnknownResource parses a single UnknownResource, whose Data holds a
+// copy of the resource body, exactly as many bytes as the header's
+// Length. It works for resources of any type, including those this
+// package supports.
+//
+// One of the XXXHeader methods must have been called before calling this
+// method.
+func (p *Parser) UnknownResource() (UnknownResource, error) {
+	if !p.resHeaderValid {
+		return UnknownResource{}, ErrNotStarted
+	}
+	r, err := unpackUnknownResource(p.resHeader.Type, p.msg, p.off, p.resHeader.Length)
+	if err != nil {
+		return UnknownResource{}, err
+	}
+	p.off += int(p.resHeader.Length)
+	p.resHeaderValid = false
+	p.index++
+	return r, nil
+}
+
+// UnpackResourceBody parses the body of the current Resource with
+// unpack, which lets callers support types this package does not.
+//
+// Unpack is passed the whole message, so that it can decompress domain
+// names with Name.Unpack, and the offsets of the body in it: the body
+// is msg[off:end], where end-off is the header's Length and end is
+// within msg. Unpack should not read beyond end, except to follow
+// compression pointers. Whatever it reads, the Parser then moves on to
+// end, so it stays in step with the message. If unpack returns an
+// error, UnpackResourceBody returns it and the Parser does not move,
+// as for the other XXXResource methods.
+//
+// One of the XXXHeader methods must have been called before calling this
+// method.
+func (p *Parser) UnpackResourceBody(unpack func(msg []byte, off, end int) error) error {
+	if !p.resHeaderValid {
+		return ErrNotStarted
+	}
+	end := p.off + int(p.resHeader.Length)
+	if end > len(p.msg) {
+		return errResourceLen
+	}
+	if err := unpack(p.msg, p.off, end); err != nil {
+		return err
+	}
+	p.off = end
+	p.resHeaderValid = false
+	p.index++
+	return nil
+}
+
 // Unpack parses a full Message.
 func (m *Message) Unpack(msg []byte) error {
 	var p Parser
@@ -1118,6 +1263,83 @@ func (m *Message) AppendPack(b []byte) ([]byte, error) {
 	return msg, nil
 }
 
+// Truncate drops Resources from m so that the packed Message fits in
+// maxSize bytes, such as 512 or the UDP payload size advertised by EDNS(0),
+// and returns the number of Resources dropped. If any Resource is dropped,
+// Header.Truncated is set.
+//
+// Resources are dropped whole, from the end of the Additional section
+// first, then the Authority section and finally the Answer section. The
+// Questions and any OPT Resources are always kept, so the Message may still
+// be larger than maxSize if they don't fit on their own.
+func (m *Message) Truncate(maxSize int) (int, error) {
+	msg, err := m.Pack()
+	if err != nil {
+		return 0, err
+	}
+	if len(msg) <= maxSize {
+		return 0, nil
+	}
+
+	// Repack the Message in order of importance. Names are only ever
+	// compressed using earlier names, so every prefix of the packed
+	// Resources packs to the same size on its own.
+	budget := maxSize
+	for i := range m.Additionals {
+		r := &m.Additionals[i]
+		if r.Header.Type != TypeOPT {
+			continue
+		}
+		b, err := r.pack(nil, nil, 0)
+		if err != nil {
+			return 0, &nestedError{"packing Additional", err}
+		}
+		budget -= len(b)
+	}
+	compression := map[string]int{}
+	// A maxSize smaller than the header, even negative, just drops
+	// every Resource that can be dropped.
+	capacity := maxSize
+	if capacity < headerLen {
+		capacity = headerLen
+	}
+	msg = make([]byte, headerLen, capacity)
+	for i := range m.Questions {
+		if msg, err = m.Questions[i].pack(msg, compression, 0); err != nil {
+			return 0, &nestedError{"packing Question", err}
+		}
+	}
+	fits := len(msg) <= budget
+	dropped := 0
+	keep := func(rs []Resource) []Resource {
+		kept := rs[:0]
+		for i := range rs {
+			if rs[i].Header.Type == TypeOPT {
+				kept = append(kept, rs[i])
+				continue
+			}
+			if fits {
+				// Errors were reported by Pack above.
+				msg, _ = rs[i].pack(msg, compression, 0)
+				fits = len(msg) <= budget
+			}
+			if !fits {
+				dropped++
+				continue
+			}
+			kept = append(kept, rs[i])
+		}
+		return kept
+	}
+	m.Answers = keep(m.Answers)
+	m.Authorities = keep(m.Authorities)
+	m.Additionals = keep(m.Additionals)
+	if dropped > 0 {
+		m.Header.Truncated = true
+	}
+	return dropped, nil
+}
+
 // GoString implements fmt.GoStringer.GoString.
 func (m *Message) GoString() string {
 	s := "dnsmessage.Message{Header: " + m.Header.GoString() + ", " +
@@ -1218,6 +1440,33 @@ func (b *Builder) EnableCompression() {
 	b.compression = map[string]int{}
 }
 
+// Reset makes the Builder start a new message with header h, as if it had
+// been created by NewBuilder, but keeping its compression setting and, to save
+// allocations, its storage.
+//
+// The new message is appended to buf. If buf is nil, it instead replaces the
+// previous message in the Builder's buffer, after the bytes that preceded it,
+// so the caller must be done with the message returned by Finish.
+//
+// The compression dictionary is emptied rather than carried over to the new
+// message: compression pointers are offsets within a message, so the entries
+// recorded for the previous message would be invalid in the next one.
+func (b *Builder) Reset(buf []byte, h Header) {
+	if buf == nil {
+		buf = b.msg[:b.start]
+	}
+	b.msg = buf
+	b.start = len(buf)
+	b.header = header{}
+	b.header.id, b.header.bits = h.pack()
+	var hb [headerLen]byte
+	b.msg = append(b.msg, hb[:]...)
+	b.section = sectionHeader
+	for k := range b.compression {
+		delete(b.compression, k)
+	}
+}
+
 func (b *Builder) startCheck(s section) error {
 	if b.section <= sectionNotStarted {
 		return ErrNotStarted
@@ -1557,6 +1806,102 @@ func (b *Builder) OPTResource(h ResourceHeader, r OPTResource) error {
 	return nil
 }
 
+// SVCBResource adds a single SVCBResource.
+func (b *Builder) SVCBResource(h ResourceHeader, r SVCBResource) error {
+	if err := b.checkResourceSection(); err != nil {
+		return err
+	}
+	h.Type = r.realType()
+	msg, lenOff, err := h.pack(b.msg, b.compression, b.start)
+	if err != nil {
+		return &nestedError{"ResourceHeader", err}
+	}
+	preLen := len(msg)
+	if msg, err = r.pack(msg, b.compression, b.start); err != nil {
+		return &nestedError{"SVCBResource body", err}
+	}
+	if err := h.fixLen(msg, lenOff, preLen); err != nil {
+		return err
+	}
+	if err := b.incrementSectionCount(); err != nil {
+		return err
+	}
+	b.msg = msg
+	return nil
+}
+
+// HTTPSResource adds a single HTTPSResource.
+func (b *Builder) HTTPSResource(h ResourceHeader, r HTTPSResource) error {
+	if err := b.checkResourceSection(); err != nil {
+		return err
+	}
+	h.Type = r.realType()
+	msg, lenOff, err := h.pack(b.msg, b.compression, b.start)
+	if err != nil {
+		return &nestedError{"ResourceHeader", err}
+	}
+	preLen := len(msg)
+	if msg, err = r.pack(msg, b.compression, b.start); err != nil {
+		return &nestedError{"HTTPSResource body", err}
+	}
+	if err := h.fixLen(msg, lenOff, preLen); err != nil {
+		return err
+	}
+	if err := b.incrementSectionCount(); err != nil {
+		return err
+	}
+	b.msg = msg
+	return nil
+}
+
+// CAAResource adds a single CAAResource.
+func (b *Builder) CAAResource(h ResourceHeader, r CAAResource) error {
+	if err := b.checkResourceSection(); err != nil {
+		return err
+	}
+	h.Type = r.realType()
+	msg, lenOff, err := h.pack(b.msg, b.compression, b.start)
+	if err != nil {
+		return &nestedError{"ResourceHeader", err}
+	}
+	preLen := len(msg)
+	if msg, err = r.pack(msg, b.compression, b.start); err != nil {
+		return &nestedError{"CAAResource body", err}
+	}
+	if err := h.fixLen(msg, lenOff, preLen); err != nil {
+		return err
+	}
+	if err := b.incrementSectionCount(); err != nil {
+		return err
+	}
+	b.msg = msg
+	return nil
+}
+
+// UnknownResource adds a single UnknownResource, of type r.Type.
+func (b *Builder) UnknownResource(h ResourceHeader, r UnknownResource) error {
+	if err := b.checkResourceSection(); err != nil {
+		return err
+	}
+	h.Type = r.realType()
+	msg, lenOff, err := h.pack(b.msg, b.compression, b.start)
+	if err != nil {
+		return &nestedError{"ResourceHeader", err}
+	}
+	preLen := len(msg)
+	if msg, err = r.pack(msg, b.compression, b.start); err != nil {
+		return &nestedError{"UnknownResource body", err}
+	}
+	if err := h.fixLen(msg, lenOff, preLen); err != nil {
+		return err
+	}
+	if err := b.incrementSectionCount(); err != nil {
+		return err
+	}
+	b.msg = msg
+	return nil
+}
+
 // Finish ends message building and generates a binary message.
 func (b *Builder) Finish() ([]byte, error) {
 	if b.section < sectionHeader {
@@ -1937,6 +2282,14 @@ func (n *Name) pack(msg []byte, compression map[string]int, compressionOff int)
 	return append(msg, 0), nil
 }
 
+// Unpack parses the domain name at off in msg, a whole DNS message,
+// following compression pointers, and returns the offset of the first
+// byte after the name at off. It is meant for the custom parsers of
+// Parser.UnpackResourceBody.
+func (n *Name) Unpack(msg []byte, off int) (int, error) {
+	return n.unpack(msg, off)
+}
+
 // unpack unpacks a domain name.
 func (n *Name) unpack(msg []byte, off int) (int, error) {
 	return n.unpackCompressed(msg, off, true /* allowCompression */)
@@ -2135,13 +2488,30 @@ func unpackResourceBody(msg []byte, off int, hdr ResourceHeader) (ResourceBody,
 		rb, err = unpackOPTResource(msg, off, hdr.Length)
 		r = &rb
 		name = "OPT"
+	case TypeSVCB:
+		var rb SVCBResource
+		rb, err = unpackSVCBResource(msg, off, hdr.Length)
+		r = &rb
+		name = "SVCB"
+	case TypeHTTPS:
+		var rb SVCBResource
+		rb, err = unpackSVCBResource(msg, off, hdr.Length)
+		r = &HTTPSResource{rb}
+		name = "HTTPS"
+	case TypeCAA:
+		var rb CAAResource
+		rb, err = unpackCAAResource(msg, off, hdr.Length)
+		r = &rb
+		name = "CAA"
+	default:
+		var rb UnknownResource
+		rb, err = unpackUnknownResource(hdr.Type, msg, off, hdr.Length)
+		r = &rb
+		name = "Unknown"
 	}
 	if err != nil {
 		return nil, off, &nestedError{name + " record", err}
 	}
-	if r == nil {
-		return nil, off, errors.New("invalid resource type: " + hdr.Type.String())
-	}
 	return r, off + int(hdr.Length), nil
 }
 
@@ -2585,3 +2955,478 @@ func unpackOPTResource(msg []byte, off int, length uint16) (OPTResource, error)
 	}
 	return OPTResource{opts}, nil
 }
+
+// EDNS(0) option codes.
+const (
+	OptionCodeClientSubnet uint16 = 8  // RFC 7871
+	OptionCodeCookie       uint16 = 10 // RFC 7873
+)
+
+// Option returns the first option in r with the given code, and whether
+// it is present.
+func (r *OPTResource) Option(code uint16) (Option, bool) {
+	for _, o := range r.Options {
+		if o.Code == code {
+			return o, true
+		}
+	}
+	return Option{}, false
+}
+
+// Address families used by the client subnet option.
+const (
+	clientSubnetFamilyIPv4 = 1
+	clientSubnetFamilyIPv6 = 2
+)
+
+// A ClientSubnet is the value of an EDNS(0) Client Subnet option, as
+// defined in RFC 7871.
+type ClientSubnet struct {
+	// Family is 1 for IPv4 and 2 for IPv6.
+	Family uint16
+
+	SourcePrefixLen uint8
+	ScopePrefixLen  uint8
+
+	// Address is the 4-byte IPv4 or 16-byte IPv6 address. Bits beyond
+	// SourcePrefixLen are zero when parsed and ignored when packed.
+	Address []byte
+}
+
+func (cs *ClientSubnet) addrLen() int {
+	switch cs.Family {
+	case clientSubnetFamilyIPv4:
+		return 4
+	case clientSubnetFamilyIPv6:
+		return 16
+	}
+	return 0
+}
+
+// NewClientSubnetOption returns an Option carrying cs.
+func NewClientSubnetOption(cs ClientSubnet) (Option, error) {
+	l := cs.addrLen()
+	if l == 0 || len(cs.Address) != l || int(cs.SourcePrefixLen) > l*8 || int(cs.ScopePrefixLen) > l*8 {
+		return Option{}, errClientSubnet
+	}
+	n := (int(cs.SourcePrefixLen) + 7) / 8
+	data := make([]byte, 0, 4+n)
+	data = packUint16(data, cs.Family)
+	data = append(data, cs.SourcePrefixLen, cs.ScopePrefixLen)
+	data = append(data, cs.Address[:n]...)
+	if r := cs.SourcePrefixLen % 8; r != 0 {
+		data[len(data)-1] &= 0xff << (8 - r)
+	}
+	return Option{Code: OptionCodeClientSubnet, Data: data}, nil
+}
+
+// ClientSubnet parses o as a client subnet option.
+func (o *Option) ClientSubnet() (ClientSubnet, error) {
+	if o.Code != OptionCodeClientSubnet {
+		return ClientSubnet{}, errOptionCode
+	}
+	if len(o.Data) < 4 {
+		return ClientSubnet{}, errClientSubnet
+	}
+	cs := ClientSubnet{
+		Family:          uint16(o.Data[0])<<8 | uint16(o.Data[1]),
+		SourcePrefixLen: o.Data[2],
+		ScopePrefixLen:  o.Data[3],
+	}
+	l := cs.addrLen()
+	n := (int(cs.SourcePrefixLen) + 7) / 8
+	if l == 0 || int(cs.SourcePrefixLen) > l*8 || int(cs.ScopePrefixLen) > l*8 || len(o.Data)-4 != n {
+		return ClientSubnet{}, errClientSubnet
+	}
+	cs.Address = make([]byte, l)
+	copy(cs.Address, o.Data[4:])
+	if r := cs.SourcePrefixLen % 8; r != 0 && cs.Address[n-1]&^(0xff<<(8-r)) != 0 {
+		// RFC 7871 section 6 requires the trailing bits to be zero.
+		return ClientSubnet{}, errClientSubnet
+	}
+	return cs, nil
+}
+
+// NewCookieOption returns a DNS Cookie option carrying the client cookie
+// and, if non-empty, the server cookie, which must be 8 to 32 bytes long.
+func NewCookieOption(client [8]byte, server []byte) (Option, error) {
+	if len(server) != 0 && (len(server) < 8 || len(server) > 32) {
+		return Option{}, errCookie
+	}
+	data := make([]byte, 0, len(client)+len(server))
+	data = append(data, client[:]...)
+	data = append(data, server...)
+	return Option{Code: OptionCodeCookie, Data: data}, nil
+}
+
+// Cookie parses o as a DNS Cookie option. The server cookie is empty if
+// the option only carries a client cookie.
+func (o *Option) Cookie() (client [8]byte, server []byte, err error) {
+	if o.Code != OptionCodeCookie {
+		return client, nil, errOptionCode
+	}
+	l := len(o.Data)
+	if l != 8 && (l < 16 || l > 40) {
+		return client, nil, errCookie
+	}
+	copy(client[:], o.Data)
+	if l > 8 {
+		server = make([]byte, l-8)
+		copy(server, o.Data[8:])
+	}
+	return client, server, nil
+}
+
+// An SVCBResource is an SVCB Resource record, as defined in RFC 9460.
+type SVCBResource struct {
+	// Priority is zero for AliasMode records and greater than zero for
+	// ServiceMode records.
+	Priority uint16
+	Target   Name // Not compressed as per RFC 9460.
+
+	// Params holds the SvcParams of the record, in strictly increasing
+	// order of key.
+	Params []SVCParam
+}
+
+// An HTTPSResource is an HTTPS Resource record, as defined in RFC 9460.
+// It has the same format as an SVCB Resource record.
+type HTTPSResource struct {
+	SVCBResource
+}
+
+// An SVCParamKey is the key of an SvcParam in an SVCB or HTTPS record.
+type SVCParamKey uint16
+
+const (
+	SVCParamMandatory     SVCParamKey = 0
+	SVCParamALPN          SVCParamKey = 1
+	SVCParamNoDefaultALPN SVCParamKey = 2
+	SVCParamPort          SVCParamKey = 3
+	SVCParamIPv4Hint      SVCParamKey = 4
+	SVCParamECH           SVCParamKey = 5
+	SVCParamIPv6Hint      SVCParamKey = 6
+)
+
+var svcParamKeyNames = map[SVCParamKey]string{
+	SVCParamMandatory:     "SVCParamMandatory",
+	SVCParamALPN:          "SVCParamALPN",
+	SVCParamNoDefaultALPN: "SVCParamNoDefaultALPN",
+	SVCParamPort:          "SVCParamPort",
+	SVCParamIPv4Hint:      "SVCParamIPv4Hint",
+	SVCParamECH:           "SVCParamECH",
+	SVCParamIPv6Hint:      "SVCParamIPv6Hint",
+}
+
+// String implements fmt.Stringer.String.
+func (k SVCParamKey) String() string {
+	if n, ok := svcParamKeyNames[k]; ok {
+		return n
+	}
+	return printUint16(uint16(k))
+}
+
+// GoString implements fmt.GoStringer.GoString.
+func (k SVCParamKey) GoString() string {
+	if n, ok := svcParamKeyNames[k]; ok {
+		return "dnsmessage." + n
+	}
+	return printUint16(uint16(k))
+}
+
+// An SVCParam is a key/value pair in an SVCB or HTTPS record. Value holds
+// the wire format of the value:
+//
+//	SVCParamMandatory     a list of 2-byte keys
+//	SVCParamALPN          a list of length-prefixed protocol IDs
+//	SVCParamNoDefaultALPN empty
+//	SVCParamPort          a 2-byte port number
+//	SVCParamIPv4Hint      a list of 4-byte IPv4 addresses
+//	SVCParamECH           an ECHConfigList
+//	SVCParamIPv6Hint      a list of 16-byte IPv6 addresses
+type SVCParam struct {
+	Key   SVCParamKey
+	Value []byte
+}
+
+// GoString implements fmt.GoStringer.GoString.
+func (p *SVCParam) GoString() string {
+	return "dnsmessage.SVCParam{" +
+		"Key: " + p.Key.GoString() + ", " +
+		"Value: []byte{" + printByteSlice(p.Value) + "}}"
+}
+
+// check reports whether the length of the value is valid for the key.
+// Values of unknown keys are opaque.
+func (p *SVCParam) check() error {
+	l := len(p.Value)
+	var ok bool
+	switch p.Key {
+	case SVCParamMandatory:
+		ok = l > 0 && l%2 == 0
+	case SVCParamALPN:
+		ok = l > 0
+		for i := 0; ok && i < l; i += 1 + int(p.Value[i]) {
+			ok = p.Value[i] > 0 && i+1+int(p.Value[i]) <= l
+		}
+	case SVCParamNoDefaultALPN:
+		ok = l == 0
+	case SVCParamPort:
+		ok = l == 2
+	case SVCParamIPv4Hint:
+		ok = l > 0 && l%4 == 0
+	case SVCParamECH:
+		ok = l > 0
+	case SVCParamIPv6Hint:
+		ok = l > 0 && l%16 == 0
+	default:
+		ok = true
+	}
+	if !ok {
+		return &nestedError{p.Key.String(), errParamValueLen}
+	}
+	return nil
+}
+
+// GetParam returns the value of the SvcParam with the given key, and
+// whether it is present.
+func (r *SVCBResource) GetParam(key SVCParamKey) (value []byte, ok bool) {
+	for _, p := range r.Params {
+		if p.Key == key {
+			return p.Value, true
+		}
+	}
+	return nil, false
+}
+
+// SetParam sets the value of the SvcParam with the given key, keeping
+// Params in increasing order of key.
+func (r *SVCBResource) SetParam(key SVCParamKey, value []byte) {
+	i := 0
+	for i < len(r.Params) && r.Params[i].Key < key {
+		i++
+	}
+	if i < len(r.Params) && r.Params[i].Key == key {
+		r.Params[i].Value = value
+		return
+	}
+	r.Params = append(r.Params, SVCParam{})
+	copy(r.Params[i+1:], r.Params[i:])
+	r.Params[i] = SVCParam{Key: key, Value: value}
+}
+
+// DeleteParam removes the SvcParam with the given key, reporting whether
+// it was present.
+func (r *SVCBResource) DeleteParam(key SVCParamKey) bool {
+	for i, p := range r.Params {
+		if p.Key == key {
+			r.Params = append(r.Params[:i], r.Params[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (r *SVCBResource) realType() Type {
+	return TypeSVCB
+}
+
+// pack appends the wire format of the SVCBResource to msg.
+func (r *SVCBResource) pack(msg []byte, compression map[string]int, compressionOff int) ([]byte, error) {
+	oldMsg := msg
+	msg = packUint16(msg, r.Priority)
+	msg, err := r.Target.pack(msg, nil, compressionOff)
+	if err != nil {
+		return oldMsg, &nestedError{"SVCBResource.Target", err}
+	}
+	for i := range r.Params {
+		p := &r.Params[i]
+		if i > 0 && p.Key <= r.Params[i-1].Key {
+			return oldMsg, &nestedError{"SVCBResource.Params", errParamOutOfOrder}
+		}
+		if err := p.check(); err != nil {
+			return oldMsg, &nestedError{"SVCBResource.Params", err}
+		}
+		if len(p.Value) > 0xffff {
+			return oldMsg, &nestedError{"SVCBResource.Params", errResTooLong}
+		}
+		msg = packUint16(msg, uint16(p.Key))
+		msg = packUint16(msg, uint16(len(p.Value)))
+		msg = packBytes(msg, p.Value)
+	}
+	return msg, nil
+}
+
+// GoString implements fmt.GoStringer.GoString.
+func (r *SVCBResource) GoString() string {
+	return "dnsmessage.SVCBResource{" + r.goStringFields() + "}"
+}
+
+func (r *SVCBResource) goStringFields() string {
+	s := "Priority: " + printUint16(r.Priority) + ", " +
+		"Target: " + r.Target.GoString() + ", " +
+		"Params: []dnsmessage.SVCParam{"
+	for i := range r.Params {
+		if i > 0 {
+			s += ", "
+		}
+		s += r.Params[i].GoString()
+	}
+	return s + "}"
+}
+
+func unpackSVCBResource(msg []byte, off int, length uint16) (SVCBResource, error) {
+	end := off + int(length)
+	if end > len(msg) {
+		return SVCBResource{}, errResourceLen
+	}
+	priority, off, err := unpackUint16(msg, off)
+	if err != nil {
+		return SVCBResource{}, &nestedError{"Priority", err}
+	}
+	var target Name
+	if off, err = target.unpackCompressed(msg[:end], off, false /* allowCompression */); err != nil {
+		return SVCBResource{}, &nestedError{"Target", err}
+	}
+	var params []SVCParam
+	for off < end {
+		var key, l uint16
+		if key, off, err = unpackUint16(msg[:end], off); err != nil {
+			return SVCBResource{}, &nestedError{"Params", err}
+		}
+		if l, off, err = unpackUint16(msg[:end], off); err != nil {
+			return SVCBResource{}, &nestedError{"Params", err}
+		}
+		if off+int(l) > end {
+			return SVCBResource{}, &nestedError{"Params", errCalcLen}
+		}
+		p := SVCParam{Key: SVCParamKey(key), Value: make([]byte, l)}
+		copy(p.Value, msg[off:])
+		off += int(l)
+		if len(params) > 0 && p.Key <= params[len(params)-1].Key {
+			return SVCBResource{}, &nestedError{"Params", errParamOutOfOrder}
+		}
+		if err := p.check(); err != nil {
+			return SVCBResource{}, &nestedError{"Params", err}
+		}
+		params = append(params, p)
+	}
+	return SVCBResource{priority, target, params}, nil
+}
+
+func (r *HTTPSResource) realType() Type {
+	return TypeHTTPS
+}
+
+// GoString implements fmt.GoStringer.GoString.
+func (r *HTTPSResource) GoString() string {
+	return "dnsmessage.HTTPSResource{SVCBResource: dnsmessage.SVCBResource{" + r.goStringFields() + "}}"
+}
+
+// CAAFlagCritical is the Issuer Critical flag of a CAAResource. A CA
+// must not issue a certificate for the domain if it does not understand
+// the property of a record with this flag set.
+const CAAFlagCritical uint8 = 1 << 7
+
+// A CAAResource is a CAA Resource record, as defined in RFC 8659.
+type CAAResource struct {
+	Flags uint8
+	Tag   string // property tag, such as "issue" or "iodef"
+	Value []byte
+}
+
+// Critical reports whether the Issuer Critical flag is set.
+func (r *CAAResource) Critical() bool {
+	return r.Flags&CAAFlagCritical != 0
+}
+
+func (r *CAAResource) realType() Type {
+	return TypeCAA
+}
+
+// pack appends the wire format of the CAAResource to msg.
+func (r *CAAResource) pack(msg []byte, compression map[string]int, compressionOff int) ([]byte, error) {
+	if !validCAATag(r.Tag) {
+		return msg, &nestedError{"CAAResource.Tag", errCAATag}
+	}
+	msg = append(msg, r.Flags, byte(len(r.Tag)))
+	msg = append(msg, r.Tag...)
+	return packBytes(msg, r.Value), nil
+}
+
+// GoString implements fmt.GoStringer.GoString.
+func (r *CAAResource) GoString() string {
+	return "dnsmessage.CAAResource{" +
+		"Flags: " + string(printUint8Bytes(nil, r.Flags)) + ", " +
+		"Tag: \"" + printString([]byte(r.Tag)) + "\", " +
+		"Value: []byte{" + printByteSlice(r.Value) + "}}"
+}
+
+func unpackCAAResource(msg []byte, off int, length uint16) (CAAResource, error) {
+	end := off + int(length)
+	if end > len(msg) || length < 2 {
+		return CAAResource{}, errResourceLen
+	}
+	flags := msg[off]
+	tagLen := int(msg[off+1])
+	off += 2
+	if off+tagLen > end {
+		return CAAResource{}, &nestedError{"Tag", errCalcLen}
+	}
+	tag := string(msg[off : off+tagLen])
+	if !validCAATag(tag) {
+		return CAAResource{}, &nestedError{"Tag", errCAATag}
+	}
+	off += tagLen
+	value := make([]byte, end-off)
+	copy(value, msg[off:end])
+	return CAAResource{flags, tag, value}, nil
+}
+
+// An UnknownResource is a Resource record of a type this package does
+// not otherwise parse, or a record parsed with Parser.UnknownResource.
+type UnknownResource struct {
+	Type Type
+	Data []byte // the resource body, which may contain compressed names
+}
+
+func (r *UnknownResource) realType() Type {
+	return r.Type
+}
+
+// pack appends the wire format of the UnknownResource to msg.
+func (r *UnknownResource) pack(msg []byte, compression map[string]int, compressionOff int) ([]byte, error) {
+	return packBytes(msg, r.Data), nil
+}
+
+// GoString implements fmt.GoStringer.GoString.
+func (r *UnknownResource) GoString() string {
+	return "dnsmessage.UnknownResource{" +
+		"Type: " + r.Type.GoString() + ", " +
+		"Data: []byte{" + printByteSlice(r.Data) + "}}"
+}
+
+func unpackUnknownResource(recordType Type, msg []byte, off int, length uint16) (UnknownResource, error) {
+	end := off + int(length)
+	if end > len(msg) {
+		return UnknownResource{}, errResourceLen
+	}
+	data := make([]byte, length)
+	copy(data, msg[off:end])
+	return UnknownResource{recordType, data}, nil
+}
+
+// validCAATag reports whether tag is a non-empty string of at most 255
+// ASCII letters and digits.
+func validCAATag(tag string) bool {
+	if len(tag) == 0 || len(tag) > 255 {
+		return false
+	}
+	for i := 0; i < len(tag); i++ {
+		c := tag[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
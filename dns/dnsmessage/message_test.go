@@ -6,6 +6,7 @@ package dnsmessage
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
@@ -325,6 +326,13 @@ func TestResourceNotStarted(t *testing.T) {
 		{"SRVResource", func(p *Parser) error { _, err := p.SRVResource(); return err }},
 		{"AResource", func(p *Parser) error { _, err := p.AResource(); return err }},
 		{"AAAAResource", func(p *Parser) error { _, err := p.AAAAResource(); return err }},
+		{"SVCBResource", func(p *Parser) error { _, err := p.SVCBResource(); return err }},
+		{"HTTPSResource", func(p *Parser) error { _, err := p.HTTPSResource(); return err }},
+		{"CAAResource", func(p *Parser) error { _, err := p.CAAResource(); return err }},
+		{"UnknownResource", func(p *Parser) error { _, err := p.UnknownResource(); return err }},
+		{"UnpackResourceBody", func(p *Parser) error {
+			return p.UnpackResourceBody(func([]byte, int, int) error { return nil })
+		}},
 	}
 
 	for _, test := range tests {
@@ -665,6 +673,10 @@ func TestBuilderResourceError(t *testing.T) {
 		{"AResource", func(b *Builder) error { return b.AResource(ResourceHeader{}, AResource{}) }},
 		{"AAAAResource", func(b *Builder) error { return b.AAAAResource(ResourceHeader{}, AAAAResource{}) }},
 		{"OPTResource", func(b *Builder) error { return b.OPTResource(ResourceHeader{}, OPTResource{}) }},
+		{"SVCBResource", func(b *Builder) error { return b.SVCBResource(ResourceHeader{}, SVCBResource{}) }},
+		{"HTTPSResource", func(b *Builder) error { return b.HTTPSResource(ResourceHeader{}, HTTPSResource{}) }},
+		{"CAAResource", func(b *Builder) error { return b.CAAResource(ResourceHeader{}, CAAResource{}) }},
+		{"UnknownResource", func(b *Builder) error { return b.UnknownResource(ResourceHeader{}, UnknownResource{}) }},
 	}
 
 	envs := []struct {
@@ -695,6 +707,77 @@ func TestFinishError(t *testing.T) {
 	}
 }
 
+func TestBuilderReset(t *testing.T) {
+	msgs := []Message{
+		{
+			Header: Header{ID: 1, Response: true},
+			Questions: []Question{
+				{Name: MustNewName("www.example.com."), Type: TypeA, Class: ClassINET},
+				{Name: MustNewName("mail.example.com."), Type: TypeA, Class: ClassINET},
+			},
+		},
+		{
+			Header: Header{ID: 2},
+			Questions: []Question{
+				{Name: MustNewName("foo.example.org."), Type: TypeAAAA, Class: ClassINET},
+				{Name: MustNewName("bar.example.com."), Type: TypeAAAA, Class: ClassINET},
+			},
+		},
+	}
+	build := func(b *Builder, m Message) []byte {
+		t.Helper()
+		if err := b.StartQuestions(); err != nil {
+			t.Fatal("Builder.StartQuestions() =", err)
+		}
+		for _, q := range m.Questions {
+			if err := b.Question(q); err != nil {
+				t.Fatalf("Builder.Question(%#v) = %v", q, err)
+			}
+		}
+		out, err := b.Finish()
+		if err != nil {
+			t.Fatal("Builder.Finish() =", err)
+		}
+		return out
+	}
+
+	b := NewBuilder(nil, msgs[0].Header)
+	b.EnableCompression()
+	first := build(&b, msgs[0])
+	for i, m := range msgs {
+		want, err := m.Pack()
+		if err != nil {
+			t.Fatal("Message.Pack() =", err)
+		}
+		b.Reset(nil, m.Header)
+		got := build(&b, m)
+		if !bytes.Equal(got, want) {
+			t.Errorf("message %d after Reset(nil, ...):\ngot  %x\nwant %x", i, got, want)
+		}
+		if &got[0] != &first[0] {
+			t.Errorf("message %d after Reset(nil, ...) does not reuse the buffer", i)
+		}
+
+	}
+
+	// A prefix, such as the length of a message sent over TCP, does not
+	// affect compression pointers, and is kept by Reset(nil, ...).
+	want, err := msgs[1].Pack()
+	if err != nil {
+		t.Fatal("Message.Pack() =", err)
+	}
+	b.Reset([]byte{0, 0}, msgs[0].Header)
+	first = build(&b, msgs[0])
+	b.Reset(nil, msgs[1].Header)
+	got := build(&b, msgs[1])
+	if !bytes.Equal(got[:2], []byte{0, 0}) || !bytes.Equal(got[2:], want) {
+		t.Errorf("message after Reset with prefix:\ngot  %x\nwant 0000%x", got, want)
+	}
+	if &got[0] != &first[0] {
+		t.Error("message after Reset(nil, ...) does not reuse the buffer")
+	}
+}
+
 func TestBuilder(t *testing.T) {
 	msg := largeTestMsg()
 	want, err := msg.Pack()
@@ -1017,6 +1100,193 @@ func TestOptionPackUnpack(t *testing.T) {
 	}
 }
 
+func TestClientSubnetOption(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		cs   ClientSubnet
+		data []byte
+		want ClientSubnet
+	}{
+		{
+			"IPv4 /24",
+			ClientSubnet{Family: 1, SourcePrefixLen: 24, Address: []byte{192, 0, 2, 77}},
+			[]byte{0, 1, 24, 0, 192, 0, 2},
+			ClientSubnet{Family: 1, SourcePrefixLen: 24, Address: []byte{192, 0, 2, 0}},
+		},
+		{
+			"IPv4 /20",
+			ClientSubnet{Family: 1, SourcePrefixLen: 20, ScopePrefixLen: 16, Address: []byte{198, 51, 255, 1}},
+			[]byte{0, 1, 20, 16, 198, 51, 0xf0},
+			ClientSubnet{Family: 1, SourcePrefixLen: 20, ScopePrefixLen: 16, Address: []byte{198, 51, 0xf0, 0}},
+		},
+		{
+			"IPv6 /0",
+			ClientSubnet{Family: 2, Address: make([]byte, 16)},
+			[]byte{0, 2, 0, 0},
+			ClientSubnet{Family: 2, Address: make([]byte, 16)},
+		},
+	} {
+		o, err := NewClientSubnetOption(tt.cs)
+		if err != nil {
+			t.Errorf("%s: NewClientSubnetOption() = %v", tt.name, err)
+			continue
+		}
+		if o.Code != OptionCodeClientSubnet || !bytes.Equal(o.Data, tt.data) {
+			t.Errorf("%s: got option %#v, want data %v", tt.name, o, tt.data)
+		}
+		got, err := o.ClientSubnet()
+		if err != nil {
+			t.Errorf("%s: Option.ClientSubnet() = %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	for _, cs := range []ClientSubnet{
+		{Family: 3, Address: []byte{1, 2, 3, 4}},
+		{Family: 1, SourcePrefixLen: 33, Address: []byte{1, 2, 3, 4}},
+		{Family: 2, SourcePrefixLen: 8, Address: []byte{1, 2, 3, 4}},
+	} {
+		if _, err := NewClientSubnetOption(cs); err == nil {
+			t.Errorf("NewClientSubnetOption(%+v) succeeded, want error", cs)
+		}
+	}
+	for _, data := range [][]byte{
+		{0, 1, 24},
+		{0, 1, 24, 0, 192, 0},
+		{0, 1, 20, 0, 198, 51, 0xff},
+	} {
+		o := Option{Code: OptionCodeClientSubnet, Data: data}
+		if _, err := o.ClientSubnet(); err == nil {
+			t.Errorf("Option.ClientSubnet() for %v succeeded, want error", data)
+		}
+	}
+}
+
+func TestCookieOption(t *testing.T) {
+	client := [8]byte{1, 2, 3, 4, 5, 6, 7, 8}
+	server := []byte{9, 10, 11, 12, 13, 14, 15, 16}
+	o, err := NewCookieOption(client, server)
+	if err != nil {
+		t.Fatal("NewCookieOption() =", err)
+	}
+
+	var hdr ResourceHeader
+	if err := hdr.SetEDNS0(1232, 0xfe0|RCodeSuccess, true); err != nil {
+		t.Fatal("ResourceHeader.SetEDNS0() =", err)
+	}
+	m := Message{Additionals: []Resource{{hdr, &OPTResource{Options: []Option{o}}}}}
+	w, err := m.Pack()
+	if err != nil {
+		t.Fatal("Message.Pack() =", err)
+	}
+	if err := m.Unpack(w); err != nil {
+		t.Fatal("Message.Unpack() =", err)
+	}
+	rh := m.Additionals[0].Header
+	if !rh.DNSSECAllowed() {
+		t.Error("DNSSECAllowed() = false, want true")
+	}
+	if got, want := rh.ExtendedRCode(RCodeSuccess), 0xfe0|RCodeSuccess; got != want {
+		t.Errorf("ExtendedRCode() = %v, want %v", got, want)
+	}
+	opt, ok := m.Additionals[0].Body.(*OPTResource).Option(OptionCodeCookie)
+	if !ok {
+		t.Fatal("OPTResource.Option(OptionCodeCookie) not found")
+	}
+	gotClient, gotServer, err := opt.Cookie()
+	if err != nil {
+		t.Fatal("Option.Cookie() =", err)
+	}
+	if gotClient != client || !bytes.Equal(gotServer, server) {
+		t.Errorf("Option.Cookie() = %v, %v, want %v, %v", gotClient, gotServer, client, server)
+	}
+
+	if _, err := NewCookieOption(client, []byte{1, 2, 3}); err == nil {
+		t.Error("NewCookieOption() with 3-byte server cookie succeeded, want error")
+	}
+	bad := Option{Code: OptionCodeCookie, Data: make([]byte, 12)}
+	if _, _, err := bad.Cookie(); err == nil {
+		t.Error("Option.Cookie() with 12-byte data succeeded, want error")
+	}
+	if _, _, err := (&Option{Code: OptionCodeClientSubnet}).Cookie(); err != errOptionCode {
+		t.Errorf("Option.Cookie() for wrong code = %v, want %v", err, errOptionCode)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	name := MustNewName("foo.bar.example.com.")
+	newMsg := func() Message {
+		m := Message{
+			Header:    Header{Response: true},
+			Questions: []Question{{Name: name, Type: TypeA, Class: ClassINET}},
+		}
+		for i := 0; i < 40; i++ {
+			m.Answers = append(m.Answers, Resource{
+				ResourceHeader{Name: name, Type: TypeA, Class: ClassINET},
+				&AResource{[4]byte{192, 0, 2, byte(i)}},
+			})
+		}
+		m.Authorities = []Resource{{
+			ResourceHeader{Name: name, Type: TypeNS, Class: ClassINET},
+			&NSResource{MustNewName("ns1.example.com.")},
+		}}
+		var opt ResourceHeader
+		if err := opt.SetEDNS0(512, RCodeSuccess, false); err != nil {
+			t.Fatal("ResourceHeader.SetEDNS0() =", err)
+		}
+		m.Additionals = []Resource{
+			{opt, &OPTResource{}},
+			{ResourceHeader{Name: name, Type: TypeTXT, Class: ClassINET}, &TXTResource{[]string{"extra"}}},
+		}
+		return m
+	}
+
+	m := newMsg()
+	full, err := m.Pack()
+	if err != nil {
+		t.Fatal("Message.Pack() =", err)
+	}
+	if n, err := m.Truncate(len(full)); n != 0 || err != nil || m.Header.Truncated {
+		t.Fatalf("Truncate(%d) = %d, %v (Truncated = %t), want 0, nil (false)", len(full), n, err, m.Header.Truncated)
+	}
+
+	for _, size := range []int{512, 300, 100, 12, 5, 0, -1} {
+		m := newMsg()
+		n, err := m.Truncate(size)
+		if err != nil {
+			t.Fatalf("Truncate(%d) = %v", size, err)
+		}
+		if got := 43 - len(m.Answers) - len(m.Authorities) - len(m.Additionals); n != got {
+			t.Errorf("Truncate(%d) = %d, but %d Resources were dropped", size, n, got)
+		}
+		if !m.Header.Truncated {
+			t.Errorf("Truncate(%d): Truncated = false, want true", size)
+		}
+		if len(m.Questions) != 1 {
+			t.Errorf("Truncate(%d): got %d Questions, want 1", size, len(m.Questions))
+		}
+		if len(m.Additionals) == 0 || m.Additionals[0].Header.Type != TypeOPT {
+			t.Errorf("Truncate(%d): OPT Resource was dropped", size)
+		}
+		if len(m.Answers) < 40 && len(m.Authorities)+len(m.Additionals) > 1 {
+			t.Errorf("Truncate(%d): dropped Answers before less important sections", size)
+		}
+		b, err := m.Pack()
+		if err != nil {
+			t.Fatalf("Truncate(%d): Message.Pack() = %v", size, err)
+		}
+		if size >= 100 && len(b) > size {
+			t.Errorf("Truncate(%d): packed to %d bytes", size, len(b))
+		}
+		if size >= 100 && len(m.Answers) == 0 {
+			t.Errorf("Truncate(%d): dropped all Answers", size)
+		}
+	}
+}
+
 // TestGoString tests that Message.GoString produces Go code that compiles to
 // reproduce the Message.
 //
@@ -1162,6 +1432,120 @@ func TestParsingAllocs(t *testing.T) {
 	}
 }
 
+func benchmarkParsingAnswersSetup() ([]byte, error) {
+	name := MustNewName("foo.bar.example.com.")
+	msg := Message{
+		Header:    Header{Response: true, Authoritative: true},
+		Questions: []Question{{Name: name, Type: TypeA, Class: ClassINET}},
+	}
+	for i := 0; i < 8; i++ {
+		msg.Answers = append(msg.Answers,
+			Resource{ResourceHeader{Name: name, Type: TypeA, Class: ClassINET}, &AResource{[4]byte{192, 0, 2, byte(i)}}},
+			Resource{ResourceHeader{Name: name, Type: TypeTXT, Class: ClassINET}, &TXTResource{[]string{"skipped"}}},
+		)
+	}
+	return msg.Pack()
+}
+
+// benchmarkParsingAnswers decodes only the A records of the Answer section,
+// skipping the others, and returns the number of A records found.
+func benchmarkParsingAnswers(tb testing.TB, buf []byte) int {
+	var p Parser
+	if _, err := p.Start(buf); err != nil {
+		tb.Fatal("Parser.Start(non-nil) =", err)
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		tb.Fatal("Parser.SkipAllQuestions() =", err)
+	}
+	n := 0
+	for {
+		h, err := p.AnswerHeader()
+		if err == ErrSectionDone {
+			break
+		}
+		if err != nil {
+			tb.Fatal("Parser.AnswerHeader() =", err)
+		}
+		if h.Type != TypeA {
+			if err := p.SkipAnswer(); err != nil {
+				tb.Fatal("Parser.SkipAnswer() =", err)
+			}
+			continue
+		}
+		if _, err := p.AResource(); err != nil {
+			tb.Fatal("Parser.AResource() =", err)
+		}
+		n++
+	}
+	return n
+}
+
+func BenchmarkParsingAnswers(b *testing.B) {
+	buf, err := benchmarkParsingAnswersSetup()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		benchmarkParsingAnswers(b, buf)
+	}
+}
+
+func TestParsingAnswersAllocs(t *testing.T) {
+	buf, err := benchmarkParsingAnswersSetup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := benchmarkParsingAnswers(t, buf); n != 8 {
+		t.Fatalf("got %d A records, want 8", n)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { benchmarkParsingAnswers(t, buf) }); allocs > 0.5 {
+		t.Errorf("allocations during parsing: got = %f, want ~0", allocs)
+	}
+}
+
+func TestAppendResourceBody(t *testing.T) {
+	buf, err := benchmarkParsingAnswersSetup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p Parser
+	if _, err := p.Start(buf); err != nil {
+		t.Fatal("Parser.Start(non-nil) =", err)
+	}
+	if _, err := p.AppendResourceBody(nil); err != ErrNotStarted {
+		t.Fatalf("Parser.AppendResourceBody() before header = %v, want %v", err, ErrNotStarted)
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		t.Fatal("Parser.SkipAllQuestions() =", err)
+	}
+	dst := make([]byte, 0, 64)
+	for i := 0; i < 2; i++ {
+		if _, err := p.AnswerHeader(); err != nil {
+			t.Fatal("Parser.AnswerHeader() =", err)
+		}
+		if dst, err = p.AppendResourceBody(dst); err != nil {
+			t.Fatal("Parser.AppendResourceBody() =", err)
+		}
+	}
+	want := []byte{192, 0, 2, 0, 7, 's', 'k', 'i', 'p', 'p', 'e', 'd'}
+	if !bytes.Equal(dst, want) {
+		t.Errorf("got bodies %v, want %v", dst, want)
+	}
+	if _, err := p.AnswerHeader(); err != nil {
+		t.Fatal("Parser.AnswerHeader() =", err)
+	}
+	r, err := p.AResource()
+	if err != nil {
+		t.Fatal("Parser.AResource() =", err)
+	}
+	if r.A != [4]byte{192, 0, 2, 1} {
+		t.Errorf("got A = %v, want 192.0.2.1", r.A)
+	}
+}
+
 func benchmarkBuildingSetup() (Name, []byte) {
 	name := MustNewName("foo.bar.example.com.")
 	buf := make([]byte, 0, packStartingCap)
@@ -1452,6 +1836,326 @@ func largeTestMsg() Message {
 	}
 }
 
+func TestSVCBResource(t *testing.T) {
+	name := MustNewName("_dns.example.com.")
+	svcb := SVCBResource{
+		Priority: 1,
+		Target:   MustNewName("svc.example.com."),
+		Params: []SVCParam{
+			{Key: SVCParamALPN, Value: []byte("\x02h2\x03dot")},
+			{Key: SVCParamPort, Value: []byte{0x03, 0x55}},
+			{Key: SVCParamIPv4Hint, Value: []byte{192, 0, 2, 1, 192, 0, 2, 2}},
+			{Key: 65000, Value: []byte("opaque")},
+		},
+	}
+	https := HTTPSResource{SVCBResource{
+		Priority: 0,
+		Target:   MustNewName("alias.example.com."),
+	}}
+	msg := Message{
+		Header: Header{Response: true},
+		Answers: []Resource{
+			{ResourceHeader{Name: name, Type: TypeSVCB, Class: ClassINET}, &svcb},
+			{ResourceHeader{Name: name, Type: TypeHTTPS, Class: ClassINET}, &https},
+		},
+	}
+	buf, err := msg.Pack()
+	if err != nil {
+		t.Fatal("Message.Pack() =", err)
+	}
+	var got Message
+	if err := got.Unpack(buf); err != nil {
+		t.Fatal("Message.Unpack() =", err)
+	}
+	for i := range got.Answers {
+		got.Answers[i].Header.Length = msg.Answers[i].Header.Length
+	}
+	if !reflect.DeepEqual(got.Answers, msg.Answers) {
+		t.Errorf("got = %#v\nwant = %#v", got.Answers, msg.Answers)
+	}
+
+	var p Parser
+	if _, err := p.Start(buf); err != nil {
+		t.Fatal("Parser.Start() =", err)
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		t.Fatal("Parser.SkipAllQuestions() =", err)
+	}
+	if _, err := p.AnswerHeader(); err != nil {
+		t.Fatal("Parser.AnswerHeader() =", err)
+	}
+	r, err := p.SVCBResource()
+	if err != nil {
+		t.Fatal("Parser.SVCBResource() =", err)
+	}
+	if v, ok := r.GetParam(SVCParamPort); !ok || !bytes.Equal(v, []byte{0x03, 0x55}) {
+		t.Errorf("GetParam(SVCParamPort) = %v, %t", v, ok)
+	}
+	if _, err := p.AnswerHeader(); err != nil {
+		t.Fatal("Parser.AnswerHeader() =", err)
+	}
+	if _, err := p.HTTPSResource(); err != nil {
+		t.Fatal("Parser.HTTPSResource() =", err)
+	}
+
+	tests := []struct {
+		name   string
+		params []SVCParam
+		want   error
+	}{
+		{"out of order", []SVCParam{{Key: SVCParamPort, Value: []byte{0, 1}}, {Key: SVCParamALPN, Value: []byte("\x02h2")}}, errParamOutOfOrder},
+		{"duplicate", []SVCParam{{Key: SVCParamPort, Value: []byte{0, 1}}, {Key: SVCParamPort, Value: []byte{0, 2}}}, errParamOutOfOrder},
+		{"port", []SVCParam{{Key: SVCParamPort, Value: []byte{0}}}, errParamValueLen},
+		{"no-default-alpn", []SVCParam{{Key: SVCParamNoDefaultALPN, Value: []byte{0}}}, errParamValueLen},
+		{"ipv4hint", []SVCParam{{Key: SVCParamIPv4Hint, Value: []byte{1, 2, 3}}}, errParamValueLen},
+		{"ipv6hint", []SVCParam{{Key: SVCParamIPv6Hint, Value: make([]byte, 15)}}, errParamValueLen},
+		{"mandatory", []SVCParam{{Key: SVCParamMandatory, Value: []byte{0}}}, errParamValueLen},
+		{"alpn", []SVCParam{{Key: SVCParamALPN, Value: []byte("\x03h2")}}, errParamValueLen},
+	}
+	for _, tt := range tests {
+		r := SVCBResource{Priority: 1, Target: MustNewName("."), Params: tt.params}
+		if _, err := r.pack(nil, nil, 0); err == nil || !strings.HasSuffix(err.Error(), tt.want.Error()) {
+			t.Errorf("%s: pack() = %v, want %v", tt.name, err, tt.want)
+		}
+
+		// Build the invalid wire format by hand and check that it is
+		// rejected when unpacking too.
+		b := packUint16(nil, 1)
+		b = append(b, 0)
+		for _, p := range tt.params {
+			b = packUint16(b, uint16(p.Key))
+			b = packUint16(b, uint16(len(p.Value)))
+			b = append(b, p.Value...)
+		}
+		if _, err := unpackSVCBResource(b, 0, uint16(len(b))); err == nil || !strings.HasSuffix(err.Error(), tt.want.Error()) {
+			t.Errorf("%s: unpackSVCBResource() = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestCAAResource(t *testing.T) {
+	name := MustNewName("example.com.")
+	issue := CAAResource{Flags: 0, Tag: "issue", Value: []byte("ca.example.net; account=230123")}
+	critical := CAAResource{Flags: CAAFlagCritical, Tag: "tbs", Value: []byte("Unknown")}
+	msg := Message{
+		Header: Header{Response: true},
+		Answers: []Resource{
+			{ResourceHeader{Name: name, Type: TypeCAA, Class: ClassINET}, &issue},
+			{ResourceHeader{Name: name, Type: TypeCAA, Class: ClassINET}, &critical},
+		},
+	}
+	buf, err := msg.Pack()
+	if err != nil {
+		t.Fatal("Message.Pack() =", err)
+	}
+	var got Message
+	if err := got.Unpack(buf); err != nil {
+		t.Fatal("Message.Unpack() =", err)
+	}
+	for i := range got.Answers {
+		got.Answers[i].Header.Length = msg.Answers[i].Header.Length
+	}
+	if !reflect.DeepEqual(got.Answers, msg.Answers) {
+		t.Errorf("got = %#v\nwant = %#v", got.Answers, msg.Answers)
+	}
+
+	b := NewBuilder(nil, Header{Response: true})
+	if err := b.StartAnswers(); err != nil {
+		t.Fatal("Builder.StartAnswers() =", err)
+	}
+	for _, a := range msg.Answers {
+		if err := b.CAAResource(a.Header, *a.Body.(*CAAResource)); err != nil {
+			t.Fatalf("Builder.CAAResource(%#v) = %v", a, err)
+		}
+	}
+	if buf, err = b.Finish(); err != nil {
+		t.Fatal("Builder.Finish() =", err)
+	}
+	var p Parser
+	if _, err := p.Start(buf); err != nil {
+		t.Fatal("Parser.Start() =", err)
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		t.Fatal("Parser.SkipAllQuestions() =", err)
+	}
+	for _, want := range []CAAResource{issue, critical} {
+		if _, err := p.AnswerHeader(); err != nil {
+			t.Fatal("Parser.AnswerHeader() =", err)
+		}
+		r, err := p.CAAResource()
+		if err != nil {
+			t.Fatal("Parser.CAAResource() =", err)
+		}
+		if !reflect.DeepEqual(r, want) {
+			t.Errorf("Parser.CAAResource() = %#v, want %#v", r, want)
+		}
+		if r.Critical() != (want.Flags == CAAFlagCritical) {
+			t.Errorf("%#v.Critical() = %t", r, r.Critical())
+		}
+	}
+
+	for _, tag := range []string{"", "is-sue", "iss ue", strings.Repeat("a", 256)} {
+		r := CAAResource{Tag: tag}
+		if _, err := r.pack(nil, nil, 0); err == nil || !strings.HasSuffix(err.Error(), errCAATag.Error()) {
+			t.Errorf("CAAResource{Tag: %q}.pack() = %v, want %v", tag, err, errCAATag)
+		}
+		if len(tag) > 255 {
+			continue
+		}
+		b := append([]byte{0, byte(len(tag))}, tag...)
+		if _, err := unpackCAAResource(b, 0, uint16(len(b))); err == nil || !strings.HasSuffix(err.Error(), errCAATag.Error()) {
+			t.Errorf("unpackCAAResource(%q) = %v, want %v", tag, err, errCAATag)
+		}
+	}
+	if _, err := unpackCAAResource([]byte{0, 6, 'i', 's', 's'}, 0, 5); err == nil {
+		t.Error("unpackCAAResource() with truncated tag succeeded")
+	}
+}
+
+func TestUnknownResource(t *testing.T) {
+	const typeCustom Type = 65280 // private use
+	name := MustNewName("example.com.")
+	b := NewBuilder(nil, Header{Response: true})
+	b.EnableCompression()
+	if err := b.StartAnswers(); err != nil {
+		t.Fatal("Builder.StartAnswers() =", err)
+	}
+	h := ResourceHeader{Name: name, Class: ClassINET}
+	// The custom body is a uint16 followed by a name, compressed here as
+	// a pointer to the name of the first record, at offset 12.
+	custom := UnknownResource{Type: typeCustom, Data: []byte{0, 42, 0xC0, 12}}
+	if err := b.UnknownResource(h, custom); err != nil {
+		t.Fatal("Builder.UnknownResource() =", err)
+	}
+	a := AResource{[4]byte{192, 0, 2, 1}}
+	if err := b.AResource(h, a); err != nil {
+		t.Fatal("Builder.AResource() =", err)
+	}
+	buf, err := b.Finish()
+	if err != nil {
+		t.Fatal("Builder.Finish() =", err)
+	}
+
+	var msg Message
+	if err := msg.Unpack(buf); err != nil {
+		t.Fatal("Message.Unpack() =", err)
+	}
+	if len(msg.Answers) != 2 {
+		t.Fatalf("got %d answers, want 2", len(msg.Answers))
+	}
+	if got, ok := msg.Answers[0].Body.(*UnknownResource); !ok || !reflect.DeepEqual(*got, custom) {
+		t.Errorf("got first answer %#v, want %#v", msg.Answers[0].Body, &custom)
+	}
+
+	var p Parser
+	if _, err := p.Start(buf); err != nil {
+		t.Fatal("Parser.Start() =", err)
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		t.Fatal("Parser.SkipAllQuestions() =", err)
+	}
+	if _, err := p.AnswerHeader(); err != nil {
+		t.Fatal("Parser.AnswerHeader() =", err)
+	}
+	var gotPref uint16
+	var gotName Name
+	err = p.UnpackResourceBody(func(msg []byte, off, end int) error {
+		if end-off != len(custom.Data) {
+			t.Errorf("got body of %d bytes, want %d", end-off, len(custom.Data))
+		}
+		gotPref = uint16(msg[off])<<8 | uint16(msg[off+1])
+		n, err := gotName.Unpack(msg, off+2)
+		if n != end {
+			t.Errorf("Name.Unpack() returned offset %d, want %d", n, end)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal("Parser.UnpackResourceBody() =", err)
+	}
+	if gotPref != 42 || gotName != name {
+		t.Errorf("got custom body %d, %v, want 42, %v", gotPref, gotName, name)
+	}
+	// UnknownResource returns the raw body of any type.
+	if _, err := p.AnswerHeader(); err != nil {
+		t.Fatal("Parser.AnswerHeader() =", err)
+	}
+	want := UnknownResource{Type: TypeA, Data: a.A[:]}
+	if got, err := p.UnknownResource(); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Parser.UnknownResource() = %#v, %v, want %#v", got, err, want)
+	}
+	if _, err := p.AnswerHeader(); err != ErrSectionDone {
+		t.Errorf("Parser.AnswerHeader() after the last answer = %v, want %v", err, ErrSectionDone)
+	}
+
+	// A body that reads too little still leaves the Parser at the
+	// next resource, while one that fails leaves it in place.
+	if _, err := p.Start(buf); err != nil {
+		t.Fatal("Parser.Start() =", err)
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		t.Fatal("Parser.SkipAllQuestions() =", err)
+	}
+	if _, err := p.AnswerHeader(); err != nil {
+		t.Fatal("Parser.AnswerHeader() =", err)
+	}
+	errCustom := errors.New("custom")
+	if err := p.UnpackResourceBody(func([]byte, int, int) error { return errCustom }); err != errCustom {
+		t.Errorf("Parser.UnpackResourceBody() = %v, want %v", err, errCustom)
+	}
+	if err := p.UnpackResourceBody(func([]byte, int, int) error { return nil }); err != nil {
+		t.Fatal("Parser.UnpackResourceBody() =", err)
+	}
+	if _, err := p.AnswerHeader(); err != nil {
+		t.Fatal("Parser.AnswerHeader() =", err)
+	}
+	if got, err := p.AResource(); err != nil || got != a {
+		t.Errorf("Parser.AResource() = %v, %v, want %v", got, err, a)
+	}
+
+	// A body extending beyond the message is rejected.
+	if _, err := p.Start(buf[:len(buf)-1]); err != nil {
+		t.Fatal("Parser.Start() =", err)
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		t.Fatal("Parser.SkipAllQuestions() =", err)
+	}
+	if err := p.SkipAnswer(); err != nil {
+		t.Fatal("Parser.SkipAnswer() =", err)
+	}
+	if _, err := p.AnswerHeader(); err != nil {
+		t.Fatal("Parser.AnswerHeader() =", err)
+	}
+	if _, err := p.UnknownResource(); err != errResourceLen {
+		t.Errorf("Parser.UnknownResource() of truncated body = %v, want %v", err, errResourceLen)
+	}
+	if err := p.UnpackResourceBody(func([]byte, int, int) error { return nil }); err != errResourceLen {
+		t.Errorf("Parser.UnpackResourceBody() of truncated body = %v, want %v", err, errResourceLen)
+	}
+}
+
+func TestSVCBResourceParams(t *testing.T) {
+	var r SVCBResource
+	r.SetParam(SVCParamPort, []byte{0, 53})
+	r.SetParam(SVCParamALPN, []byte("\x02h3"))
+	r.SetParam(SVCParamIPv6Hint, make([]byte, 16))
+	r.SetParam(SVCParamALPN, []byte("\x02h2"))
+	want := []SVCParam{
+		{Key: SVCParamALPN, Value: []byte("\x02h2")},
+		{Key: SVCParamPort, Value: []byte{0, 53}},
+		{Key: SVCParamIPv6Hint, Value: make([]byte, 16)},
+	}
+	if !reflect.DeepEqual(r.Params, want) {
+		t.Fatalf("Params = %#v, want %#v", r.Params, want)
+	}
+	if !r.DeleteParam(SVCParamPort) || r.DeleteParam(SVCParamPort) {
+		t.Error("DeleteParam(SVCParamPort) did not delete exactly once")
+	}
+	if _, ok := r.GetParam(SVCParamPort); ok {
+		t.Error("GetParam(SVCParamPort) found deleted param")
+	}
+}
+
 // This package is imported by the standard library net package
 // and therefore must not use fmt. We'll catch a mistake when vendored
 // into the standard library, but this test catches the mistake earlier.
@@ -17,11 +17,16 @@
 // atom.Div, calling atom.Div.String will return "div", and atom.Div != 0.
 package atom // import "golang.org/x/net/html/atom"
 
+import "sync"
+
 // Atom is an integer code for a string. The zero value maps to "".
 type Atom uint32
 
 // String returns the atom's name.
 func (a Atom) String() string {
+	if a&runtimeBit != 0 {
+		return runtimeString(a)
+	}
 	start := uint32(a >> 8)
 	n := uint32(a & 0xff)
 	if start+n > uint32(len(atomText)) {
@@ -76,3 +81,61 @@ func String(s []byte) string {
 	}
 	return string(s)
 }
+
+// runtimeBit is set in the atoms assigned by Intern, which index
+// runtimeNames. It lies above the generated range.
+const runtimeBit Atom = 1 << 31
+
+var (
+	runtimeMu    sync.RWMutex
+	runtimeAtoms map[string]Atom
+	runtimeNames []string
+)
+
+// Intern returns the atom whose name is s. If there is no such atom in the
+// generated table, Intern assigns a new atom to s, above the generated range,
+// and returns it; later calls with the same string return the same atom, and
+// its String method returns s. Intern returns zero for the empty string.
+//
+// Atoms assigned by Intern depend on the order of the calls, so they are only
+// meaningful within the current process and must not be stored or compared
+// across processes. Lookup does not return them, so the tokenizer and parser
+// never set them in the DataAtom fields of the html package.
+//
+// Intern is safe for concurrent use.
+func Intern(s string) Atom {
+	if a := Lookup([]byte(s)); a != 0 || s == "" {
+		return a
+	}
+	runtimeMu.RLock()
+	a, ok := runtimeAtoms[s]
+	runtimeMu.RUnlock()
+	if ok {
+		return a
+	}
+	runtimeMu.Lock()
+	defer runtimeMu.Unlock()
+	if a, ok := runtimeAtoms[s]; ok {
+		return a
+	}
+	if len(runtimeNames) >= int(runtimeBit-1) {
+		panic("atom: too many interned strings")
+	}
+	if runtimeAtoms == nil {
+		runtimeAtoms = make(map[string]Atom)
+	}
+	a = runtimeBit | Atom(len(runtimeNames))
+	runtimeAtoms[s] = a
+	runtimeNames = append(runtimeNames, s)
+	return a
+}
+
+func runtimeString(a Atom) string {
+	i := int(a &^ runtimeBit)
+	runtimeMu.RLock()
+	defer runtimeMu.RUnlock()
+	if i >= len(runtimeNames) {
+		return ""
+	}
+	return runtimeNames[i]
+}
@@ -107,3 +107,38 @@ func BenchmarkLookup(b *testing.B) {
 		}
 	}
 }
+
+func TestIntern(t *testing.T) {
+	if a := Intern(""); a != 0 {
+		t.Errorf("Intern(\"\") = %#x, want 0", uint32(a))
+	}
+	for _, s := range testAtomList {
+		if a, want := Intern(s), Lookup([]byte(s)); a != want {
+			t.Errorf("Intern(%q) = %#x, want %#x", s, uint32(a), uint32(want))
+		}
+	}
+	names := []string{"my-widget", "x:node", "DIV", "a-very-long-custom-element-name-beyond-maxAtomLen"}
+	atoms := make(map[Atom]string)
+	for _, s := range names {
+		a := Intern(s)
+		if a == 0 || a&runtimeBit == 0 {
+			t.Errorf("Intern(%q) = %#x, want a runtime atom", s, uint32(a))
+		}
+		if got := a.String(); got != s {
+			t.Errorf("Intern(%q).String() = %q", s, got)
+		}
+		if b := Intern(s); b != a {
+			t.Errorf("Intern(%q) = %#x, then %#x", s, uint32(a), uint32(b))
+		}
+		if b := Lookup([]byte(s)); b != 0 {
+			t.Errorf("Lookup(%q) = %#x after Intern, want 0", s, uint32(b))
+		}
+		if prev, ok := atoms[a]; ok {
+			t.Errorf("Intern(%q) = Intern(%q) = %#x", s, prev, uint32(a))
+		}
+		atoms[a] = s
+	}
+	if got := (runtimeBit | 0xffffff).String(); got != "" {
+		t.Errorf("unassigned runtime atom String() = %q, want empty", got)
+	}
+}
@@ -128,6 +128,9 @@ func NewReader(r io.Reader, contentType string) (io.Reader, error) {
 // UTF-8. It uses Lookup to find the encoding that corresponds to label, and
 // returns an error if Lookup returns nil. It is suitable for use as
 // encoding/xml.Decoder's CharsetReader function.
+//
+// Unlike NewReader, it does not examine the content, so a byte order mark or
+// <meta> element declaring a different encoding is ignored.
 func NewReaderLabel(label string, input io.Reader) (io.Reader, error) {
 	e, _ := Lookup(label)
 	if e == nil {
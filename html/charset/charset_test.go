@@ -195,6 +195,27 @@ func TestReader(t *testing.T) {
 	}
 }
 
+func TestReaderLabelIgnoresMeta(t *testing.T) {
+	// The declared charset is wrong; the content is windows-1252.
+	const s = "<meta charset=\"utf-8\"><p>r\xe9sum\xe9</p>"
+
+	r, err := NewReaderLabel("windows-1252", strings.NewReader(s))
+	if err != nil {
+		t.Fatalf("NewReaderLabel: %v", err)
+	}
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if want := "<meta charset=\"utf-8\"><p>résumé</p>"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, err := NewReaderLabel("no-such-charset", strings.NewReader(s)); err == nil {
+		t.Error("unknown charset: got nil error, want non-nil")
+	}
+}
+
 var metaTestCases = []struct {
 	meta, want string
 }{
@@ -5,6 +5,8 @@
 package html
 
 import (
+	"strings"
+
 	"golang.org/x/net/html/atom"
 )
 
@@ -124,6 +126,78 @@ func (n *Node) RemoveChild(c *Node) {
 	c.NextSibling = nil
 }
 
+// GetAttribute returns the value of the attribute of n with the given
+// namespace and key, and whether there is one. Keys are matched exactly;
+// the parser lowercases the keys of attributes in HTML content. Most
+// attributes have an empty namespace; in foreign content, some have a
+// namespace such as "xlink" or "xml".
+func (n *Node) GetAttribute(namespace, key string) (val string, ok bool) {
+	for _, a := range n.Attr {
+		if a.Namespace == namespace && a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// SetAttribute sets the value of the attribute of n with the given
+// namespace and key to val, appending the attribute to n.Attr if it is
+// absent. Keys are matched as by GetAttribute.
+func (n *Node) SetAttribute(namespace, key, val string) {
+	for i := range n.Attr {
+		if a := &n.Attr[i]; a.Namespace == namespace && a.Key == key {
+			a.Val = val
+			return
+		}
+	}
+	n.Attr = append(n.Attr, Attribute{Namespace: namespace, Key: key, Val: val})
+}
+
+// TextContent returns the text of n, like the DOM's textContent attribute:
+// the Data of a text or comment node, or the concatenated Data of the
+// text nodes descending from any other node, in document order.
+func (n *Node) TextContent() string {
+	return n.text(false)
+}
+
+// InnerText is like TextContent but skips the content of the script,
+// style and template elements below n, which is not rendered as text.
+// Unlike the DOM's innerText attribute, it does not apply any CSS
+// layout: whitespace is returned as it is, and no line breaks are added
+// between blocks.
+func (n *Node) InnerText() string {
+	return n.text(true)
+}
+
+func (n *Node) text(visibleOnly bool) string {
+	switch n.Type {
+	case TextNode, CommentNode:
+		return n.Data
+	}
+	var b strings.Builder
+	var walk func(*Node)
+	walk = func(n *Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			switch c.Type {
+			case TextNode:
+				b.WriteString(c.Data)
+			case ElementNode:
+				if visibleOnly {
+					switch c.DataAtom {
+					case atom.Script, atom.Style, atom.Template:
+						continue
+					}
+				}
+				walk(c)
+			case DocumentNode:
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
 // reparentChildren reparents all of src's child nodes to dst.
 func reparentChildren(dst, src *Node) {
 	for {
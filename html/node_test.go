@@ -6,6 +6,8 @@ package html
 
 import (
 	"fmt"
+	"strings"
+	"testing"
 )
 
 // checkTreeConsistency checks that a node and its descendants are all
@@ -144,3 +146,75 @@ func checkNodeConsistency(n *Node) error {
 
 	return nil
 }
+
+func TestTextContent(t *testing.T) {
+	const src = `<!DOCTYPE html><html><head><title>T</title><style>p{}</style></head>` +
+		`<body><p>Hello, <b>wor</b>ld!</p><!-- c --><script>var x;</script>` +
+		`<template><i>hidden</i></template> <pre>  a&lt;b  </pre></body></html>`
+	doc, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := doc.TextContent(), "Tp{}Hello, world!var x;hidden   a<b  "; got != want {
+		t.Errorf("TextContent() = %q, want %q", got, want)
+	}
+	if got, want := doc.InnerText(), "THello, world!   a<b  "; got != want {
+		t.Errorf("InnerText() = %q, want %q", got, want)
+	}
+
+	p := doc.LastChild.LastChild.FirstChild // html > body > p
+	if got, want := p.TextContent(), "Hello, world!"; got != want {
+		t.Errorf("p.TextContent() = %q, want %q", got, want)
+	}
+	comment := p.NextSibling
+	if got, want := comment.TextContent(), " c "; got != want {
+		t.Errorf("comment.TextContent() = %q, want %q", got, want)
+	}
+	script := comment.NextSibling
+	if got, want := script.InnerText(), "var x;"; got != want {
+		t.Errorf("script.InnerText() = %q, want %q", got, want)
+	}
+}
+
+func TestAttribute(t *testing.T) {
+	doc, err := Parse(strings.NewReader(`<a HREF="/x" id=a><svg><use xlink:href="#y" href="#z"></use></svg></a>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := doc.LastChild.LastChild.FirstChild // html > body > a
+	use := a.FirstChild.FirstChild
+
+	for _, tt := range []struct {
+		n              *Node
+		namespace, key string
+		want           string
+		wantOK         bool
+	}{
+		{a, "", "href", "/x", true},
+		{a, "", "HREF", "", false},
+		{a, "", "title", "", false},
+		{use, "xlink", "href", "#y", true},
+		{use, "", "href", "#z", true},
+		{use, "xml", "href", "", false},
+	} {
+		got, ok := tt.n.GetAttribute(tt.namespace, tt.key)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("<%s>.GetAttribute(%q, %q) = %q, %t, want %q, %t", tt.n.Data, tt.namespace, tt.key, got, ok, tt.want, tt.wantOK)
+		}
+	}
+
+	a.SetAttribute("", "href", "/y")
+	a.SetAttribute("", "title", "t")
+	use.SetAttribute("xlink", "href", "#w")
+	if got, want := renderString(t, a), `<a href="/y" id="a" title="t"><svg><use xlink:href="#w" href="#z"></use></svg></a>`; got != want {
+		t.Errorf("after SetAttribute, got %s, want %s", got, want)
+	}
+}
+
+func renderString(t *testing.T, n *Node) string {
+	var b strings.Builder
+	if err := Render(&b, n); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
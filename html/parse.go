@@ -2291,7 +2291,10 @@ func (p *parser) parse() error {
 	var err error
 	for err != io.EOF {
 		// CDATA sections are allowed only in foreign content.
-		n := p.oe.top()
+		var n *Node
+		if len(p.oe) > 0 {
+			n = p.adjustedCurrentNode()
+		}
 		p.tokenizer.AllowCDATA(n != nil && n.Namespace != "")
 		// Read and parse the next token.
 		p.tokenizer.Next()
@@ -2324,7 +2327,9 @@ func Parse(r io.Reader) (*Node, error) {
 
 // ParseFragment parses a fragment of HTML and returns the nodes that were
 // found. If the fragment is the InnerHTML for an existing element, pass that
-// element in context.
+// element in context. For an SVG or MathML element, the context's Namespace
+// must be "svg" or "math"; ParseOptionContext constructs such a context from
+// the element's name.
 //
 // It has the same intricacies as Parse.
 func ParseFragment(r io.Reader, context *Node) ([]*Node, error) {
@@ -2344,6 +2349,34 @@ func ParseOptionEnableScripting(enable bool) ParseOption {
 	}
 }
 
+// ParseOptionContext sets the context element of a fragment to the element
+// with the given name in namespace, which is "" for HTML, "svg" or "math".
+// It replaces the context passed to ParseFragmentWithOptions, and has no
+// effect on ParseWithOptions.
+//
+// The name is matched case-insensitively, so that "foreignobject" names the
+// SVG foreignObject element.
+func ParseOptionContext(name, namespace string) ParseOption {
+	if namespace == "svg" {
+		if x := svgTagNameAdjustments[strings.ToLower(name)]; x != "" {
+			name = x
+		}
+	} else {
+		name = strings.ToLower(name)
+	}
+	return func(p *parser) {
+		if !p.fragment {
+			return
+		}
+		p.context = &Node{
+			Type:      ElementNode,
+			DataAtom:  a.Lookup([]byte(name)),
+			Data:      name,
+			Namespace: namespace,
+		}
+	}
+}
+
 // ParseWithOptions is like Parse, with options.
 func ParseWithOptions(r io.Reader, opts ...ParseOption) (*Node, error) {
 	p := &parser{
@@ -2368,6 +2401,19 @@ func ParseWithOptions(r io.Reader, opts ...ParseOption) (*Node, error) {
 
 // ParseFragmentWithOptions is like ParseFragment, with options.
 func ParseFragmentWithOptions(r io.Reader, context *Node, opts ...ParseOption) ([]*Node, error) {
+	p := &parser{
+		doc: &Node{
+			Type: DocumentNode,
+		},
+		scripting: true,
+		fragment:  true,
+		context:   context,
+	}
+	for _, f := range opts {
+		f(p)
+	}
+	context = p.context
+
 	contextTag := ""
 	if context != nil {
 		if context.Type != ElementNode {
@@ -2379,26 +2425,19 @@ func ParseFragmentWithOptions(r io.Reader, context *Node, opts ...ParseOption) (
 		if context.DataAtom != a.Lookup([]byte(context.Data)) {
 			return nil, fmt.Errorf("html: inconsistent Node: DataAtom=%q, Data=%q", context.DataAtom, context.Data)
 		}
+		switch context.Namespace {
+		case "", "svg", "math":
+		default:
+			return nil, fmt.Errorf("html: ParseFragment in unsupported namespace %q", context.Namespace)
+		}
 		contextTag = context.DataAtom.String()
 	}
-	p := &parser{
-		doc: &Node{
-			Type: DocumentNode,
-		},
-		scripting: true,
-		fragment:  true,
-		context:   context,
-	}
 	if context != nil && context.Namespace != "" {
 		p.tokenizer = NewTokenizer(r)
 	} else {
 		p.tokenizer = NewTokenizerFragment(r, contextTag)
 	}
 
-	for _, f := range opts {
-		f(p)
-	}
-
 	root := &Node{
 		Type:     ElementNode,
 		DataAtom: a.Html,
@@ -454,6 +454,62 @@ func TestParseFragmentWithNilContext(t *testing.T) {
 	ParseFragment(strings.NewReader("<p>hello</p>"), nil)
 }
 
+func TestParseOptionContext(t *testing.T) {
+	tests := []struct {
+		name, namespace string
+		text            string
+		want            string
+	}{
+		{
+			"svg", "svg",
+			`<circle r="1"/><g><rect/></g>`,
+			"| <svg circle>\n|   r=\"1\"\n| <svg g>\n|   <svg rect>\n",
+		},
+		{
+			"svg", "svg",
+			`<![CDATA[a<b]]>`,
+			"| \"a<b\"\n",
+		},
+		{
+			"foreignobject", "svg",
+			`<p>x</p>`,
+			"| <p>\n|   \"x\"\n",
+		},
+		{
+			"math", "math",
+			`<mi>x</mi>`,
+			"| <math mi>\n|   \"x\"\n",
+		},
+		{
+			"TD", "",
+			`<svg><path/></svg>`,
+			"| <svg svg>\n|   <svg path>\n",
+		},
+	}
+	for _, tc := range tests {
+		ctx := ParseOptionContext(tc.name, tc.namespace)
+		nodes, err := ParseFragmentWithOptions(strings.NewReader(tc.text), nil, ctx)
+		if err != nil {
+			t.Errorf("%s %s: %q: %v", tc.namespace, tc.name, tc.text, err)
+			continue
+		}
+		var b bytes.Buffer
+		for _, n := range nodes {
+			if err := dumpLevel(&b, n, 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := b.String(); got != tc.want {
+			t.Errorf("%s %s: %q:\ngot:\n%s\nwant:\n%s", tc.namespace, tc.name, tc.text, got, tc.want)
+		}
+	}
+
+	ctx := ParseOptionContext("svg", "xhtml")
+	if _, err := ParseFragmentWithOptions(strings.NewReader("<g/>"), nil, ctx); err == nil {
+		t.Error("unsupported namespace: got nil error, want non-nil")
+	}
+}
+
 func BenchmarkParser(b *testing.B) {
 	buf, err := ioutil.ReadFile("testdata/go1.html")
 	if err != nil {
@@ -9,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 )
 
@@ -43,11 +44,29 @@ type writer interface {
 // Another example is that the programmatic equivalent of "a<head>b</head>c"
 // becomes "<html><head><head/><body>abc</body></html>".
 func Render(w io.Writer, n *Node) error {
+	return RenderWithOptions(w, n, RenderOptions{XHTMLCompatible: true})
+}
+
+// RenderOptions controls the output of RenderWithOptions.
+type RenderOptions struct {
+	// SortAttributes renders the attributes of each element sorted by
+	// namespace and key, instead of in the order they appear in the tree.
+	SortAttributes bool
+
+	// XHTMLCompatible renders void elements such as <br> with a trailing
+	// slash, as in <br/>.
+	XHTMLCompatible bool
+}
+
+// RenderWithOptions renders the parse tree n to the given writer, as
+// Render does, with the differences selected by opts. Render is equivalent
+// to RenderWithOptions with opts.XHTMLCompatible set.
+func RenderWithOptions(w io.Writer, n *Node, opts RenderOptions) error {
 	if x, ok := w.(writer); ok {
-		return render(x, n)
+		return render(x, n, &opts)
 	}
 	buf := bufio.NewWriter(w)
-	if err := render(buf, n); err != nil {
+	if err := render(buf, n, &opts); err != nil {
 		return err
 	}
 	return buf.Flush()
@@ -57,15 +76,15 @@ func Render(w io.Writer, n *Node) error {
 // has been rendered. No more end tags should be rendered after that.
 var plaintextAbort = errors.New("html: internal error (plaintext abort)")
 
-func render(w writer, n *Node) error {
-	err := render1(w, n)
+func render(w writer, n *Node, opts *RenderOptions) error {
+	err := render1(w, n, opts)
 	if err == plaintextAbort {
 		err = nil
 	}
 	return err
 }
 
-func render1(w writer, n *Node) error {
+func render1(w writer, n *Node, opts *RenderOptions) error {
 	// Render non-element nodes; these are the easy cases.
 	switch n.Type {
 	case ErrorNode:
@@ -74,7 +93,7 @@ func render1(w writer, n *Node) error {
 		return escape(w, n.Data)
 	case DocumentNode:
 		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			if err := render1(w, c); err != nil {
+			if err := render1(w, c, opts); err != nil {
 				return err
 			}
 		}
@@ -148,7 +167,17 @@ func render1(w writer, n *Node) error {
 	if _, err := w.WriteString(n.Data); err != nil {
 		return err
 	}
-	for _, a := range n.Attr {
+	attr := n.Attr
+	if opts.SortAttributes {
+		attr = append([]Attribute(nil), attr...)
+		sort.SliceStable(attr, func(i, j int) bool {
+			if attr[i].Namespace != attr[j].Namespace {
+				return attr[i].Namespace < attr[j].Namespace
+			}
+			return attr[i].Key < attr[j].Key
+		})
+	}
+	for _, a := range attr {
 		if err := w.WriteByte(' '); err != nil {
 			return err
 		}
@@ -177,8 +206,11 @@ func render1(w writer, n *Node) error {
 		if n.FirstChild != nil {
 			return fmt.Errorf("html: void element <%s> has child nodes", n.Data)
 		}
-		_, err := w.WriteString("/>")
-		return err
+		if opts.XHTMLCompatible {
+			_, err := w.WriteString("/>")
+			return err
+		}
+		return w.WriteByte('>')
 	}
 	if err := w.WriteByte('>'); err != nil {
 		return err
@@ -203,7 +235,7 @@ func render1(w writer, n *Node) error {
 					return err
 				}
 			} else {
-				if err := render1(w, c); err != nil {
+				if err := render1(w, c, opts); err != nil {
 					return err
 				}
 			}
@@ -215,7 +247,7 @@ func render1(w writer, n *Node) error {
 		}
 	default:
 		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			if err := render1(w, c); err != nil {
+			if err := render1(w, c, opts); err != nil {
 				return err
 			}
 		}
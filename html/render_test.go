@@ -6,6 +6,7 @@ package html
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 )
 
@@ -164,3 +165,54 @@ func TestRenderer(t *testing.T) {
 		t.Errorf("got vs want:\n%s\n%s\n", got, want)
 	}
 }
+
+func TestRenderWithOptions(t *testing.T) {
+	doc, err := Parse(strings.NewReader(`<p id="A" class="x" xml:lang="en" data-b="1">a<br>b<img src="i" alt=""></p>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := doc.FirstChild.LastChild.FirstChild // html > body > p
+	p.Attr = append(p.Attr, Attribute{Namespace: "xlink", Key: "href", Val: "#"})
+
+	testCases := []struct {
+		opts RenderOptions
+		want string
+	}{{
+		RenderOptions{},
+		`<p id="A" class="x" xml:lang="en" data-b="1" xlink:href="#">a<br>b<img src="i" alt=""></p>`,
+	}, {
+		RenderOptions{XHTMLCompatible: true},
+		`<p id="A" class="x" xml:lang="en" data-b="1" xlink:href="#">a<br/>b<img src="i" alt=""/></p>`,
+	}, {
+		RenderOptions{SortAttributes: true},
+		`<p class="x" data-b="1" id="A" xml:lang="en" xlink:href="#">a<br>b<img alt="" src="i"></p>`,
+	}, {
+		RenderOptions{SortAttributes: true, XHTMLCompatible: true},
+		`<p class="x" data-b="1" id="A" xml:lang="en" xlink:href="#">a<br/>b<img alt="" src="i"/></p>`,
+	}}
+	for _, tc := range testCases {
+		var b bytes.Buffer
+		if err := RenderWithOptions(&b, p, tc.opts); err != nil {
+			t.Errorf("%+v: %v", tc.opts, err)
+			continue
+		}
+		if got := b.String(); got != tc.want {
+			t.Errorf("%+v:\ngot  %s\nwant %s", tc.opts, got, tc.want)
+		}
+	}
+	if got := p.Attr[0].Key; got != "id" {
+		t.Errorf("SortAttributes modified the tree: first attribute is %q, want %q", got, "id")
+	}
+
+	// Render is RenderWithOptions with XHTMLCompatible set.
+	var b1, b2 bytes.Buffer
+	if err := Render(&b1, doc); err != nil {
+		t.Fatal(err)
+	}
+	if err := RenderWithOptions(&b2, doc, RenderOptions{XHTMLCompatible: true}); err != nil {
+		t.Fatal(err)
+	}
+	if b1.String() != b2.String() {
+		t.Errorf("Render and RenderWithOptions differ:\n%s\n%s", b1.String(), b2.String())
+	}
+}
@@ -169,6 +169,11 @@ type Tokenizer struct {
 	convertNUL bool
 	// allowCDATA is whether CDATA sections are allowed in the current context.
 	allowCDATA bool
+	// line and col are the zero-based line and byte column of the start of
+	// the current token. afterCR is whether the byte before it was a '\r',
+	// so that a "\r\n" split across two tokens counts as one line break.
+	line, col int
+	afterCR   bool
 }
 
 // AllowCDATA sets whether or not the tokenizer recognizes <![CDATA[foo]]> as
@@ -948,6 +953,7 @@ func (z *Tokenizer) readTagAttrVal() {
 
 // Next scans the next token and returns its type.
 func (z *Tokenizer) Next() TokenType {
+	z.advancePosition()
 	z.raw.start = z.raw.end
 	z.data.start = z.raw.end
 	z.data.end = z.raw.end
@@ -1065,6 +1071,30 @@ loop:
 	return z.tt
 }
 
+// advancePosition moves the position past the current token's raw bytes.
+// "\r\n", "\r" and "\n" each end a line.
+func (z *Tokenizer) advancePosition() {
+	for _, c := range z.buf[z.raw.start:z.raw.end] {
+		switch {
+		case c == '\n' && z.afterCR:
+			// The line break was counted at the '\r'.
+		case c == '\n' || c == '\r':
+			z.line++
+			z.col = 0
+		default:
+			z.col++
+		}
+		z.afterCR = c == '\r'
+	}
+}
+
+// Position returns the line and column of the start of the current token,
+// as returned by the most recent call to Next. Lines and columns start at 1,
+// and columns count bytes, not runes.
+func (z *Tokenizer) Position() (line, col int) {
+	return z.line + 1, z.col + 1
+}
+
 // Raw returns the unmodified text of the current token. Calling Next, Token,
 // Text, TagName or TagAttr may change the contents of the returned slice.
 //
@@ -12,6 +12,7 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 type tokenTest struct {
@@ -553,6 +554,41 @@ func TestPassthrough(t *testing.T) {
 	}
 }
 
+func TestPosition(t *testing.T) {
+	const input = "<p>ab\n<b\nclass=x>c\r\n</b>\r<!-- d\n-->\r</p>\n<br>\u00e9<i>"
+	type pos struct {
+		tt        TokenType
+		line, col int
+	}
+	want := []pos{
+		{StartTagToken, 1, 1}, // <p>
+		{TextToken, 1, 4},     // ab\n
+		{StartTagToken, 2, 1}, // <b\nclass=x>
+		{TextToken, 3, 9},     // c\r\n
+		{EndTagToken, 4, 1},   // </b>
+		{TextToken, 4, 5},     // \r
+		{CommentToken, 5, 1},  // <!-- d\n-->
+		{TextToken, 6, 4},     // \r
+		{EndTagToken, 7, 1},   // </p>
+		{TextToken, 7, 5},     // \n
+		{StartTagToken, 8, 1}, // <br>
+		{TextToken, 8, 5},     // \u00e9
+		{StartTagToken, 8, 7}, // <i>
+		{ErrorToken, 8, 10},
+	}
+	// Reading one byte at a time exercises the buffer management in readByte.
+	for _, r := range []io.Reader{strings.NewReader(input), iotest.OneByteReader(strings.NewReader(input))} {
+		z := NewTokenizer(r)
+		for i, w := range want {
+			tt := z.Next()
+			line, col := z.Position()
+			if got := (pos{tt, line, col}); got != w {
+				t.Errorf("token #%d (%q): got %v, want %v", i, z.Raw(), got, w)
+			}
+		}
+	}
+}
+
 func TestBufAPI(t *testing.T) {
 	s := "0<a>1</a>2<b>3<a>4<a>5</a>6</b>7</a>8<a/>9"
 	z := NewTokenizer(bytes.NewBufferString(s))
@@ -5,7 +5,10 @@
 package httpguts
 
 import (
+	"errors"
+	"fmt"
 	"net"
+	"strconv"
 	"strings"
 	"unicode/utf8"
 
@@ -344,3 +347,142 @@ func PunycodeHostPort(v string) (string, error) {
 	}
 	return net.JoinHostPort(host, port), nil
 }
+
+// ValidAndNormalizeHost is like ValidAndNormalizeSchemeHost with the
+// "http" scheme, whose default port is 80.
+func ValidAndNormalizeHost(host string) (string, error) {
+	return ValidAndNormalizeSchemeHost("http", host)
+}
+
+// ValidAndNormalizeSchemeHost validates host, the value of a Host
+// header or of an HTTP/2 :authority pseudo-header, for a request with
+// the given scheme and returns it in normal form.
+//
+// Unlike ValidHostHeader, it follows the authority syntax of RFC 3986
+// Section 3.2, restricted to what HTTP servers accept:
+//
+//	host = ( "[" IPv6address "]" / IPv4address / name ) [ ":" port ]
+//
+// where a name is a sequence of dot-separated labels of letters,
+// digits, hyphens and underscores. A host with userinfo, as in
+// "user:pass@example.com", is rejected, as are IPv6 zones and IPvFuture
+// literals.
+//
+// The normal form is lowercase, with internationalized names converted
+// to Punycode, a single trailing dot removed, and no port if the port
+// is empty or the default port of scheme ("http" or "https").
+func ValidAndNormalizeSchemeHost(scheme, host string) (string, error) {
+	if host == "" {
+		return "", errors.New("httpguts: empty host")
+	}
+	if strings.IndexByte(host, '@') >= 0 {
+		return "", fmt.Errorf("httpguts: host %q contains userinfo", host)
+	}
+	name, port, err := splitAuthority(host)
+	if err != nil {
+		return "", err
+	}
+	if port != "" {
+		n, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			return "", fmt.Errorf("httpguts: invalid port in host %q", host)
+		}
+		port = strconv.FormatUint(n, 10)
+		if scheme == "http" && n == 80 || scheme == "https" && n == 443 {
+			port = ""
+		}
+	}
+	if strings.HasPrefix(name, "[") {
+		ip := name[1 : len(name)-1]
+		if strings.IndexByte(ip, '%') >= 0 {
+			return "", fmt.Errorf("httpguts: IPv6 zone in host %q", host)
+		}
+		if p := net.ParseIP(ip); p == nil || strings.IndexByte(ip, ':') < 0 {
+			return "", fmt.Errorf("httpguts: invalid IPv6 address in host %q", host)
+		}
+		name = strings.ToLower(name)
+	} else {
+		if name, err = normalizeHostName(name); err != nil {
+			return "", fmt.Errorf("httpguts: invalid host %q: %v", host, err)
+		}
+	}
+	if port == "" {
+		return name, nil
+	}
+	return name + ":" + port, nil
+}
+
+// splitAuthority splits host into its host name or bracketed IPv6
+// address and its port, which is empty if missing.
+func splitAuthority(host string) (name, port string, err error) {
+	if strings.HasPrefix(host, "[") {
+		i := strings.IndexByte(host, ']')
+		if i < 0 {
+			return "", "", fmt.Errorf("httpguts: missing ']' in host %q", host)
+		}
+		name, rest := host[:i+1], host[i+1:]
+		switch {
+		case rest == "":
+			return name, "", nil
+		case rest[0] == ':':
+			return name, rest[1:], nil
+		}
+		return "", "", fmt.Errorf("httpguts: invalid host %q", host)
+	}
+	if i := strings.LastIndexByte(host, ':'); i >= 0 {
+		if strings.IndexByte(host[:i], ':') >= 0 {
+			return "", "", fmt.Errorf("httpguts: unbracketed IPv6 address in host %q", host)
+		}
+		return host[:i], host[i+1:], nil
+	}
+	return host, "", nil
+}
+
+// normalizeHostName validates the host name or IPv4 address name and
+// returns it lowercased, in Punycode and without a trailing dot.
+func normalizeHostName(name string) (string, error) {
+	if !isASCII(name) {
+		var err error
+		if name, err = idna.Lookup.ToASCII(name); err != nil {
+			return "", err
+		}
+	}
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if name == "" || len(name) > 253 {
+		return "", errors.New("invalid length")
+	}
+	labels := strings.Split(name, ".")
+	numeric := true
+	for _, l := range labels {
+		if l == "" || len(l) > 63 {
+			return "", errors.New("invalid label length")
+		}
+		if l[0] == '-' || l[len(l)-1] == '-' {
+			return "", errors.New("label begins or ends with a hyphen")
+		}
+		for i := 0; i < len(l); i++ {
+			b := l[i]
+			switch {
+			case '0' <= b && b <= '9':
+			case 'a' <= b && b <= 'z', b == '-', b == '_':
+				numeric = false
+			default:
+				return "", fmt.Errorf("invalid byte %q", b)
+			}
+		}
+	}
+	if numeric {
+		// Only a valid dotted-decimal IPv4 address may consist of
+		// digits alone. Leading zeros are rejected since some
+		// resolvers read them as octal.
+		if len(labels) != 4 || net.ParseIP(name) == nil {
+			return "", errors.New("invalid IPv4 address")
+		}
+		for _, l := range labels {
+			if len(l) > 1 && l[0] == '0' {
+				return "", errors.New("invalid IPv4 address")
+			}
+		}
+	}
+	return name, nil
+}
@@ -117,3 +117,63 @@ func TestPunycodeHostPort(t *testing.T) {
 		}
 	}
 }
+
+func TestValidAndNormalizeHost(t *testing.T) {
+	tests := []struct {
+		scheme, in string
+		want       string // empty means error
+	}{
+		{"http", "Example.COM", "example.com"},
+		{"http", "example.com.", "example.com"},
+		{"http", "example.com:80", "example.com"},
+		{"http", "example.com:", "example.com"},
+		{"http", "example.com:0080", "example.com"},
+		{"http", "example.com:443", "example.com:443"},
+		{"https", "example.com:443", "example.com"},
+		{"https", "example.com:80", "example.com:80"},
+		{"http", "my_host.internal:8080", "my_host.internal:8080"},
+		{"http", "bücher.de", "xn--bcher-kva.de"},
+		{"http", "192.0.2.1:8080", "192.0.2.1:8080"},
+		{"http", "[2001:DB8::1]:80", "[2001:db8::1]"},
+		{"http", "[::1]", "[::1]"},
+
+		{"http", "", ""},
+		{"http", "user:pass@example.com", ""},
+		{"http", "user@example.com", ""},
+		{"http", "example.com..", ""},
+		{"http", ".example.com", ""},
+		{"http", "exa mple.com", ""},
+		{"http", "-example.com", ""},
+		{"http", "example-.com", ""},
+		{"http", "example.com:http", ""},
+		{"http", "example.com:65536", ""},
+		{"http", "example.com:-1", ""},
+		{"http", "192.0.2.256", ""},
+		{"http", "192.0.2", ""},
+		{"http", "192.0.2.01", ""},
+		{"http", "2001:db8::1", ""},
+		{"http", "[2001:db8::1", ""},
+		{"http", "[2001:db8::1]x", ""},
+		{"http", "[192.0.2.1]", ""},
+		{"http", "[fe80::1%25eth0]", ""},
+		{"http", "[v1.fe80::a+en1]", ""},
+		{"http", "example.com/path", ""},
+	}
+	for _, tt := range tests {
+		got, err := ValidAndNormalizeSchemeHost(tt.scheme, tt.in)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("ValidAndNormalizeSchemeHost(%q, %q) = %q, want error", tt.scheme, tt.in, got)
+			}
+			continue
+		}
+		if got != tt.want || err != nil {
+			t.Errorf("ValidAndNormalizeSchemeHost(%q, %q) = %q, %v, want %q, nil", tt.scheme, tt.in, got, err, tt.want)
+		}
+		if tt.scheme == "http" {
+			if got, err := ValidAndNormalizeHost(tt.in); got != tt.want || err != nil {
+				t.Errorf("ValidAndNormalizeHost(%q) = %q, %v, want %q, nil", tt.in, got, err, tt.want)
+			}
+		}
+	}
+}
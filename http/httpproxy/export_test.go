@@ -4,10 +4,19 @@
 
 package httpproxy
 
+import "net"
+
 func ExportUseProxy(cfg *Config, host string) bool {
 	cfg1 := &config{
 		Config: *cfg,
 	}
 	cfg1.init()
-	return cfg1.useProxy(host)
+	if len(host) == 0 {
+		return true
+	}
+	h, port, err := net.SplitHostPort(host)
+	if err != nil {
+		return false
+	}
+	return cfg1.useProxy(h, port)
 }
@@ -35,6 +35,14 @@ type Config struct {
 	// HTTPS requests unless overridden by NoProxy.
 	HTTPSProxy string
 
+	// ProxyForScheme maps URL schemes, such as "ws" or "ftp", to the
+	// proxy URLs used for requests with that scheme unless
+	// overridden by NoProxy. An entry takes precedence over
+	// HTTPProxy and HTTPSProxy; an entry with an empty value
+	// disables proxying for its scheme. The keys are case
+	// insensitive. Values are parsed like HTTPProxy.
+	ProxyForScheme map[string]string
+
 	// NoProxy represents the NO_PROXY or no_proxy environment
 	// variable. It specifies a string that contains comma-separated values
 	// specifying hosts that should be excluded from proxying. Each value is
@@ -70,13 +78,26 @@ type config struct {
 	// httpProxy is the parsed URL of the HTTPProxy if defined.
 	httpProxy *url.URL
 
-	// ipMatchers represent all values in the NoProxy that are IP address
-	// prefixes or an IP address in CIDR notation.
-	ipMatchers []matcher
+	// schemeProxies holds the parsed URLs of ProxyForScheme, keyed
+	// by lowercase scheme. A nil URL means no proxy.
+	schemeProxies map[string]*url.URL
+
+	// noProxyAll is set if NoProxy contains "*".
+	noProxyAll bool
+
+	// noProxyIPs holds the NoProxy entries that are IP addresses,
+	// keyed by their 16-byte form.
+	noProxyIPs map[[net.IPv6len]byte]portSet
+
+	// noProxyCIDR4 and noProxyCIDR6 hold the NoProxy entries that are
+	// IPv4 and IPv6 addresses in CIDR notation.
+	noProxyCIDR4, noProxyCIDR6 cidrSet
 
-	// domainMatchers represent all values in the NoProxy that are a domain
-	// name or hostname & domain name
-	domainMatchers []matcher
+	// noProxyHosts holds the NoProxy entries that match a host name
+	// exactly, and noProxySuffixes those that match the host names
+	// ending in them, which start with a dot.
+	noProxyHosts    map[string]portSet
+	noProxySuffixes map[string]portSet
 }
 
 // FromEnvironment returns a Config instance populated from the
@@ -96,6 +117,31 @@ func FromEnvironment() *Config {
 	}
 }
 
+// FromEnvironmentForSchemes is like FromEnvironment but also sets
+// ProxyForScheme from the environment variables <SCHEME>_PROXY (or the
+// lowercase versions thereof) for each of the given schemes, for
+// instance FTP_PROXY for "ftp". Schemes whose variables are unset or
+// empty are omitted. The "http" and "https" schemes are ignored, as
+// they are covered by HTTPProxy and HTTPSProxy.
+func FromEnvironmentForSchemes(schemes ...string) *Config {
+	cfg := FromEnvironment()
+	for _, scheme := range schemes {
+		scheme = strings.ToLower(scheme)
+		if scheme == "http" || scheme == "https" {
+			continue
+		}
+		v := getEnvAny(strings.ToUpper(scheme)+"_PROXY", scheme+"_proxy")
+		if v == "" {
+			continue
+		}
+		if cfg.ProxyForScheme == nil {
+			cfg.ProxyForScheme = make(map[string]string)
+		}
+		cfg.ProxyForScheme[scheme] = v
+	}
+	return cfg
+}
+
 func getEnvAny(names ...string) string {
 	for _, n := range names {
 		if val := os.Getenv(n); val != "" {
@@ -109,6 +155,11 @@ func getEnvAny(names ...string) string {
 // a given request URL. Changing the contents of cfg will not affect
 // proxy functions created earlier.
 //
+// The NoProxy rules are compiled once, when ProxyFunc is called, so the
+// returned function should be reused rather than recreated for every
+// request. It matches a host against the rules without allocating, in
+// time independent of the number of IP address and domain entries.
+//
 // A nil URL and nil error are returned if no proxy is defined in the
 // environment, or a proxy should not be used for the given request, as
 // defined by NO_PROXY.
@@ -126,7 +177,9 @@ func (cfg *Config) ProxyFunc() func(reqURL *url.URL) (*url.URL, error) {
 
 func (cfg *config) proxyForURL(reqURL *url.URL) (*url.URL, error) {
 	var proxy *url.URL
-	if reqURL.Scheme == "https" {
+	if p, ok := cfg.schemeProxies[strings.ToLower(reqURL.Scheme)]; ok {
+		proxy = p
+	} else if reqURL.Scheme == "https" {
 		proxy = cfg.httpsProxy
 	} else if reqURL.Scheme == "http" {
 		proxy = cfg.httpProxy
@@ -137,7 +190,7 @@ func (cfg *config) proxyForURL(reqURL *url.URL) (*url.URL, error) {
 	if proxy == nil {
 		return nil, nil
 	}
-	if !cfg.useProxy(canonicalAddr(reqURL)) {
+	if !cfg.useProxy(canonicalHostPort(reqURL)) {
 		return nil, nil
 	}
 
@@ -167,38 +220,46 @@ func parseProxy(proxy string) (*url.URL, error) {
 	return proxyURL, nil
 }
 
-// useProxy reports whether requests to addr should use a proxy,
-// according to the NO_PROXY or no_proxy environment variable.
-// addr is always a canonicalAddr with a host and port.
-func (cfg *config) useProxy(addr string) bool {
-	if len(addr) == 0 {
-		return true
-	}
-	host, port, err := net.SplitHostPort(addr)
-	if err != nil {
-		return false
-	}
+// useProxy reports whether requests to host and port should use a
+// proxy, according to the NO_PROXY or no_proxy environment variable.
+func (cfg *config) useProxy(host, port string) bool {
 	if host == "localhost" {
 		return false
 	}
-	ip := net.ParseIP(host)
+	var ip net.IP
+	if maybeIP(host) {
+		ip = net.ParseIP(host)
+	}
 	if ip != nil {
 		if ip.IsLoopback() {
 			return false
 		}
 	}
+	if cfg.noProxyAll {
+		return false
+	}
 
-	addr = strings.ToLower(strings.TrimSpace(host))
+	host = strings.ToLower(strings.TrimSpace(host))
 
 	if ip != nil {
-		for _, m := range cfg.ipMatchers {
-			if m.match(addr, port, ip) {
+		var key [net.IPv6len]byte
+		copy(key[:], ip.To16())
+		if cfg.noProxyIPs[key].match(port) {
+			return false
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			if cfg.noProxyCIDR4.contains(ip4) {
 				return false
 			}
+		} else if cfg.noProxyCIDR6.contains(ip) {
+			return false
 		}
 	}
-	for _, m := range cfg.domainMatchers {
-		if m.match(addr, port, ip) {
+	if cfg.noProxyHosts[host].match(port) {
+		return false
+	}
+	for i := 0; i < len(host); i++ {
+		if host[i] == '.' && cfg.noProxySuffixes[host[i:]].match(port) {
 			return false
 		}
 	}
@@ -212,6 +273,14 @@ func (c *config) init() {
 	if parsed, err := parseProxy(c.HTTPSProxy); err == nil {
 		c.httpsProxy = parsed
 	}
+	if len(c.ProxyForScheme) > 0 {
+		c.schemeProxies = make(map[string]*url.URL, len(c.ProxyForScheme))
+		for scheme, p := range c.ProxyForScheme {
+			// As for HTTPProxy, an invalid proxy URL means no proxy.
+			parsed, _ := parseProxy(p)
+			c.schemeProxies[strings.ToLower(scheme)] = parsed
+		}
+	}
 
 	for _, p := range strings.Split(c.NoProxy, ",") {
 		p = strings.ToLower(strings.TrimSpace(p))
@@ -220,14 +289,20 @@ func (c *config) init() {
 		}
 
 		if p == "*" {
-			c.ipMatchers = []matcher{allMatch{}}
-			c.domainMatchers = []matcher{allMatch{}}
+			c.noProxyAll = true
 			return
 		}
 
 		// IPv4/CIDR, IPv6/CIDR
 		if _, pnet, err := net.ParseCIDR(p); err == nil {
-			c.ipMatchers = append(c.ipMatchers, cidrMatch{cidr: pnet})
+			// As for net.IPNet.Contains, networks of IPv4-mapped
+			// IPv6 addresses are IPv4 networks.
+			ones, bits := pnet.Mask.Size()
+			if ip4 := pnet.IP.To4(); ip4 != nil {
+				c.noProxyCIDR4.add(ip4, ones-(bits-8*net.IPv4len))
+			} else {
+				c.noProxyCIDR6.add(pnet.IP, ones)
+			}
 			continue
 		}
 
@@ -246,7 +321,12 @@ func (c *config) init() {
 		}
 		// IPv4, IPv6
 		if pip := net.ParseIP(phost); pip != nil {
-			c.ipMatchers = append(c.ipMatchers, ipMatch{ip: pip, port: pport})
+			if c.noProxyIPs == nil {
+				c.noProxyIPs = make(map[[net.IPv6len]byte]portSet)
+			}
+			var key [net.IPv6len]byte
+			copy(key[:], pip.To16())
+			c.noProxyIPs[key] = append(c.noProxyIPs[key], pport)
 			continue
 		}
 
@@ -262,12 +342,17 @@ func (c *config) init() {
 		if strings.HasPrefix(phost, "*.") {
 			phost = phost[1:]
 		}
-		matchHost := false
 		if phost[0] != '.' {
-			matchHost = true
+			if c.noProxyHosts == nil {
+				c.noProxyHosts = make(map[string]portSet)
+			}
+			c.noProxyHosts[phost] = append(c.noProxyHosts[phost], pport)
 			phost = "." + phost
 		}
-		c.domainMatchers = append(c.domainMatchers, domainMatch{host: phost, port: pport, matchHost: matchHost})
+		if c.noProxySuffixes == nil {
+			c.noProxySuffixes = make(map[string]portSet)
+		}
+		c.noProxySuffixes[phost] = append(c.noProxySuffixes[phost], pport)
 	}
 }
 
@@ -275,19 +360,23 @@ var portMap = map[string]string{
 	"http":   "80",
 	"https":  "443",
 	"socks5": "1080",
+	"ws":     "80",
+	"wss":    "443",
+	"ftp":    "21",
 }
 
-// canonicalAddr returns url.Host but always with a ":port" suffix
-func canonicalAddr(url *url.URL) string {
-	addr := url.Hostname()
-	if v, err := idnaASCII(addr); err == nil {
-		addr = v
+// canonicalHostPort returns the host of url, in ASCII, and its port,
+// which is the default port of the scheme if url has none.
+func canonicalHostPort(url *url.URL) (host, port string) {
+	host = url.Hostname()
+	if v, err := idnaASCII(host); err == nil {
+		host = v
 	}
-	port := url.Port()
+	port = url.Port()
 	if port == "" {
 		port = portMap[url.Scheme]
 	}
-	return net.JoinHostPort(addr, port)
+	return host, port
 }
 
 // Given a string of the form "host", "host:port", or "[ipv6::address]:port",
@@ -310,59 +399,94 @@ func idnaASCII(v string) (string, error) {
 	return idna.Lookup.ToASCII(v)
 }
 
-func isASCII(s string) bool {
+// maybeIP reports whether s might be an IP address. Checking it first
+// avoids calling net.ParseIP, which allocates on failure, for most host
+// names.
+func maybeIP(s string) bool {
+	if strings.IndexByte(s, ':') >= 0 {
+		return true
+	}
 	for i := 0; i < len(s); i++ {
-		if s[i] >= utf8.RuneSelf {
+		if s[i] != '.' && (s[i] < '0' || s[i] > '9') {
 			return false
 		}
 	}
 	return true
 }
 
-// matcher represents the matching rule for a given value in the NO_PROXY list
-type matcher interface {
-	// match returns true if the host and optional port or ip and optional port
-	// are allowed
-	match(host, port string, ip net.IP) bool
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
 }
 
-// allMatch matches on all possible inputs
-type allMatch struct{}
+// portSet holds the ports of the NoProxy entries for one address or
+// domain. The empty port stands for all ports.
+type portSet []string
 
-func (a allMatch) match(host, port string, ip net.IP) bool {
-	return true
+func (s portSet) match(port string) bool {
+	for _, p := range s {
+		if p == "" || p == port {
+			return true
+		}
+	}
+	return false
 }
 
-type cidrMatch struct {
-	cidr *net.IPNet
+// cidrSet is a set of networks of a single address family. An address
+// is looked up once per distinct prefix length, masked to that length.
+type cidrSet struct {
+	prefixes []int
+	nets     map[cidrKey]bool
 }
 
-func (m cidrMatch) match(host, port string, ip net.IP) bool {
-	return m.cidr.Contains(ip)
+type cidrKey struct {
+	ip   [net.IPv6len]byte
+	ones int
 }
 
-type ipMatch struct {
-	ip   net.IP
-	port string
+func (s *cidrSet) add(ip net.IP, ones int) {
+	if s.nets == nil {
+		s.nets = make(map[cidrKey]bool)
+	}
+	s.nets[maskedKey(ip, ones)] = true
+	for _, p := range s.prefixes {
+		if p == ones {
+			return
+		}
+	}
+	s.prefixes = append(s.prefixes, ones)
 }
 
-func (m ipMatch) match(host, port string, ip net.IP) bool {
-	if m.ip.Equal(ip) {
-		return m.port == "" || m.port == port
+// contains reports whether ip, of the same length as the networks of
+// s, is in one of them.
+func (s *cidrSet) contains(ip net.IP) bool {
+	for _, ones := range s.prefixes {
+		if s.nets[maskedKey(ip, ones)] {
+			return true
+		}
 	}
 	return false
 }
 
-type domainMatch struct {
-	host string
-	port string
-
-	matchHost bool
-}
-
-func (m domainMatch) match(host, port string, ip net.IP) bool {
-	if strings.HasSuffix(host, m.host) || (m.matchHost && host == m.host[1:]) {
-		return m.port == "" || m.port == port
+// maskedKey returns the key of ip with all but its first ones bits
+// cleared.
+func maskedKey(ip net.IP, ones int) cidrKey {
+	k := cidrKey{ones: ones}
+	n := copy(k.ip[:], ip)
+	for i := 0; i < n; i++ {
+		switch {
+		case ones >= 8:
+			ones -= 8
+		case ones > 0:
+			k.ip[i] &^= 0xff >> uint(ones)
+			ones = 0
+		default:
+			k.ip[i] = 0
+		}
 	}
-	return false
+	return k
 }
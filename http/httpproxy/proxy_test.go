@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -40,6 +41,10 @@ func (t proxyForURLTest) String() string {
 		space()
 		fmt.Fprintf(&buf, "https_proxy=%q", t.cfg.HTTPSProxy)
 	}
+	if len(t.cfg.ProxyForScheme) > 0 {
+		space()
+		fmt.Fprintf(&buf, "proxy_for_scheme=%q", t.cfg.ProxyForScheme)
+	}
 	if t.cfg.NoProxy != "" {
 		space()
 		fmt.Fprintf(&buf, "no_proxy=%q", t.cfg.NoProxy)
@@ -178,6 +183,55 @@ var proxyForURLTests = []proxyForURLTest{{
 	},
 	req:  "http://example.com/",
 	want: "http://proxy",
+}, {
+	cfg: httpproxy.Config{
+		HTTPProxy:      "http.proxy.tld",
+		ProxyForScheme: map[string]string{"ws": "ws.proxy.tld", "wss": "https://wss.proxy.tld"},
+	},
+	req:  "ws://example.com/",
+	want: "http://ws.proxy.tld",
+}, {
+	cfg: httpproxy.Config{
+		HTTPSProxy:     "secure.proxy.tld",
+		ProxyForScheme: map[string]string{"ws": "ws.proxy.tld", "wss": "https://wss.proxy.tld"},
+	},
+	req:  "wss://example.com/",
+	want: "https://wss.proxy.tld",
+}, {
+	cfg: httpproxy.Config{
+		ProxyForScheme: map[string]string{"FTP": "socks5://ftp.proxy.tld"},
+	},
+	req:  "ftp://example.com/",
+	want: "socks5://ftp.proxy.tld",
+}, {
+	// ProxyForScheme takes precedence over HTTPProxy.
+	cfg: httpproxy.Config{
+		HTTPProxy:      "http.proxy.tld",
+		ProxyForScheme: map[string]string{"http": "other.proxy.tld"},
+	},
+	want: "http://other.proxy.tld",
+}, {
+	// An empty entry disables proxying for its scheme.
+	cfg: httpproxy.Config{
+		HTTPSProxy:     "secure.proxy.tld",
+		ProxyForScheme: map[string]string{"https": ""},
+	},
+	req:  "https://example.com/",
+	want: "<nil>",
+}, {
+	cfg: httpproxy.Config{
+		HTTPProxy:      "http.proxy.tld",
+		ProxyForScheme: map[string]string{"ws": "ws.proxy.tld"},
+	},
+	req:  "ftp://example.com/",
+	want: "<nil>",
+}, {
+	cfg: httpproxy.Config{
+		NoProxy:        "example.com:443",
+		ProxyForScheme: map[string]string{"wss": "wss.proxy.tld"},
+	},
+	req:  "wss://example.com/",
+	want: "<nil>",
 }}
 
 func testProxyForURL(t *testing.T, tt proxyForURLTest) {
@@ -232,7 +286,7 @@ func TestFromEnvironment(t *testing.T) {
 		HTTPSProxy: "httpsproxy",
 		NoProxy:    "noproxy",
 	}
-	if *got != want {
+	if !reflect.DeepEqual(*got, want) {
 		t.Errorf("unexpected proxy config, got %#v want %#v", got, want)
 	}
 }
@@ -249,7 +303,7 @@ func TestFromEnvironmentWithRequestMethod(t *testing.T) {
 		NoProxy:    "noproxy",
 		CGI:        true,
 	}
-	if *got != want {
+	if !reflect.DeepEqual(*got, want) {
 		t.Errorf("unexpected proxy config, got %#v want %#v", got, want)
 	}
 }
@@ -265,7 +319,7 @@ func TestFromEnvironmentLowerCase(t *testing.T) {
 		HTTPSProxy: "httpsproxy",
 		NoProxy:    "noproxy",
 	}
-	if *got != want {
+	if !reflect.DeepEqual(*got, want) {
 		t.Errorf("unexpected proxy config, got %#v want %#v", got, want)
 	}
 }
@@ -308,6 +362,33 @@ var UseProxyTests = []struct {
 
 var noProxy = "foobar.com, .barbaz.net, *.wildcard.io, 192.168.1.1, 192.168.1.2:81, 192.168.1.3:80, 10.0.0.0/30, 2001:db8::52:0:1, [2001:db8::52:0:2]:443, [2001:db8::52:0:3]:80, 2002:db8:a::45/64"
 
+func TestFromEnvironmentForSchemes(t *testing.T) {
+	os.Setenv("HTTP_PROXY", "httpproxy")
+	os.Setenv("HTTPS_PROXY", "httpsproxy")
+	os.Setenv("NO_PROXY", "noproxy")
+	os.Setenv("REQUEST_METHOD", "")
+	os.Setenv("FTP_PROXY", "ftpproxy")
+	os.Setenv("ws_proxy", "wsproxy")
+	os.Setenv("WSS_PROXY", "")
+	defer os.Unsetenv("FTP_PROXY")
+	defer os.Unsetenv("ws_proxy")
+	got := httpproxy.FromEnvironmentForSchemes("ftp", "WS", "wss", "http")
+	want := httpproxy.Config{
+		HTTPProxy:      "httpproxy",
+		HTTPSProxy:     "httpsproxy",
+		NoProxy:        "noproxy",
+		ProxyForScheme: map[string]string{"ftp": "ftpproxy", "ws": "wsproxy"},
+	}
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("unexpected proxy config, got %#v want %#v", got, want)
+	}
+
+	// FromEnvironment ignores the variables of other schemes.
+	if got := httpproxy.FromEnvironment(); got.ProxyForScheme != nil {
+		t.Errorf("FromEnvironment set ProxyForScheme to %q", got.ProxyForScheme)
+	}
+}
+
 func TestUseProxy(t *testing.T) {
 	cfg := &httpproxy.Config{
 		NoProxy: noProxy,
@@ -319,6 +400,38 @@ func TestUseProxy(t *testing.T) {
 	}
 }
 
+func TestUseProxyCIDR(t *testing.T) {
+	tests := []struct {
+		noProxy string
+		host    string
+		match   bool
+	}{
+		{"10.0.0.0/8", "10.1.2.3", false},
+		{"10.0.0.0/8", "11.0.0.1", true},
+		{"192.168.0.0/30", "192.168.0.3", false},
+		{"192.168.0.0/30", "192.168.0.4", true},
+		{"2001:db8::/32", "[2001:db8:1::1]", false},
+		{"2001:db8::/32", "[2001:db9::1]", true},
+		{"2001:db8::/32", "10.1.2.3", true},
+		{"::ffff:10.0.0.0/104", "10.1.2.3", false},
+		{"10.0.0.0/8", "[2001:db8::1]", true},
+
+		// Host names are not resolved; they are only matched as domains.
+		{"10.0.0.0/8", "example.com", true},
+		{"10.0.0.0/8, example.com", "example.com", false},
+		{"example.com, 10.0.0.0/8, 2001:db8::/32", "10.255.255.255", false},
+		{"example.com, 10.0.0.0/8, 2001:db8::/32", "[2001:db8::ff]", false},
+		{"example.com, 10.0.0.0/8, 2001:db8::/32", "172.16.0.1", true},
+		{"example.com, 10.0.0.0/8, 2001:db8::/32", "www.example.org", true},
+	}
+	for _, tt := range tests {
+		cfg := &httpproxy.Config{NoProxy: tt.noProxy}
+		if got := httpproxy.ExportUseProxy(cfg, tt.host+":80"); got != tt.match {
+			t.Errorf("NoProxy %q: useProxy(%v) = %v, want %v", tt.noProxy, tt.host, got, tt.match)
+		}
+	}
+}
+
 func TestInvalidNoProxy(t *testing.T) {
 	cfg := &httpproxy.Config{
 		NoProxy: ":1",
@@ -361,3 +474,43 @@ func BenchmarkProxyForURL(b *testing.B) {
 		})
 	}
 }
+
+func BenchmarkProxyForURLLongNoProxy(b *testing.B) {
+	var entries []string
+	for i := 0; i < 25; i++ {
+		entries = append(entries,
+			fmt.Sprintf("10.%d.0.1", i),
+			fmt.Sprintf("172.%d.0.0/16", i),
+			fmt.Sprintf("host%d.example.com", i),
+			fmt.Sprintf(".domain%d.example.org", i))
+	}
+	cfg := &httpproxy.Config{
+		HTTPProxy: "http://proxy.example.org",
+		NoProxy:   strings.Join(entries, ","),
+	}
+	proxyFunc := cfg.ProxyFunc()
+	for _, test := range []struct {
+		name, host string
+		match      bool
+	}{
+		{"IP", "10.24.0.1", false},
+		{"CIDR", "172.24.1.2", false},
+		{"Host", "host24.example.com", false},
+		{"Suffix", "www.domain24.example.org", false},
+		{"NoMatchIP", "192.168.0.1", true},
+		{"NoMatchHost", "www.example.net", true},
+	} {
+		u, err := url.Parse("http://" + test.host)
+		if err != nil {
+			b.Fatalf("parsed failed: %s", test.host)
+		}
+		b.Run(test.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				if au, e := proxyFunc(u); e != nil || test.match != (au != nil) {
+					b.Fatalf("useProxy(%v) = %v, want %v", test.host, au != nil, test.match)
+				}
+			}
+		})
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"crypto/tls"
 	"net/http"
 	"sync"
+	"time"
 )
 
 // ClientConnPool manages a pool of HTTP/2 client connections.
@@ -23,6 +24,7 @@ type ClientConnPool interface {
 type clientConnPoolIdleCloser interface {
 	ClientConnPool
 	closeIdleConnections()
+	closeConnectionsIdleSince(t time.Time)
 }
 
 var (
@@ -253,6 +255,16 @@ func (p *clientConnPool) closeIdleConnections() {
 	}
 }
 
+func (p *clientConnPool) closeConnectionsIdleSince(t time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, vv := range p.conns {
+		for _, cc := range vv {
+			cc.closeIfIdleSince(t)
+		}
+	}
+}
+
 func filterOutClientConn(in []*ClientConn, exclude *ClientConn) []*ClientConn {
 	out := in[:0]
 	for _, v := range in {
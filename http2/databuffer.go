@@ -57,6 +57,30 @@ func putDataBufferChunk(p []byte) {
 	panic(fmt.Sprintf("unexpected buffer len=%v", len(p)))
 }
 
+// A BufferPool supplies the buffers that hold the payloads of received
+// DATA frames until they are read from a request or response body.
+// Implementations, typically backed by a sync.Pool, must be safe for
+// concurrent use.
+//
+// A buffer returned by Get is owned by the package until it is passed
+// to Put, which happens once it has been filled and every byte in it
+// has been read. Body Read calls copy the data into the caller's slice,
+// so pooled buffers never escape to handlers or callers. Buffers not
+// yet returned when a stream is reset or its body is closed are dropped
+// rather than returned to the pool.
+type BufferPool interface {
+	// Get returns a buffer of non-zero length, ideally at least
+	// size bytes long. The whole length of the buffer is used.
+	// The size is at most 16KB. If Get returns an empty buffer,
+	// the package allocates one itself, which is later passed to
+	// Put like the others.
+	Get(size int) []byte
+
+	// Put returns a buffer obtained from Get to the pool. The
+	// package does not access the buffer afterwards.
+	Put(buf []byte)
+}
+
 // dataBuffer is an io.ReadWriter backed by a list of data chunks.
 // Each dataBuffer is used to read DATA frames on a single stream.
 // The buffer is divided into chunks so the server can limit the
@@ -64,10 +88,11 @@ func putDataBufferChunk(p []byte) {
 // request body size on any single stream.
 type dataBuffer struct {
 	chunks   [][]byte
-	r        int   // next byte to read is chunks[0][r]
-	w        int   // next byte to write is chunks[len(chunks)-1][w]
-	size     int   // total buffered bytes
-	expected int64 // we expect at least this many bytes in future Write calls (ignored if <= 0)
+	r        int        // next byte to read is chunks[0][r]
+	w        int        // next byte to write is chunks[len(chunks)-1][w]
+	size     int        // total buffered bytes
+	expected int64      // we expect at least this many bytes in future Write calls (ignored if <= 0)
+	pool     BufferPool // if nil, chunks come from dataChunkPools
 }
 
 var errReadEmpty = errors.New("read from empty dataBuffer")
@@ -88,7 +113,7 @@ func (b *dataBuffer) Read(p []byte) (int, error) {
 		b.size -= n
 		// If the first chunk has been consumed, advance to the next chunk.
 		if b.r == len(b.chunks[0]) {
-			putDataBufferChunk(b.chunks[0])
+			b.putChunk(b.chunks[0])
 			end := len(b.chunks) - 1
 			copy(b.chunks[:end], b.chunks[1:])
 			b.chunks[end] = nil
@@ -139,8 +164,32 @@ func (b *dataBuffer) lastChunkOrAlloc(want int64) []byte {
 			return last
 		}
 	}
-	chunk := getDataBufferChunk(want)
+	chunk := b.getChunk(want)
 	b.chunks = append(b.chunks, chunk)
 	b.w = 0
 	return chunk
 }
+
+func (b *dataBuffer) getChunk(size int64) []byte {
+	if b.pool == nil {
+		return getDataBufferChunk(size)
+	}
+	if max := int64(dataChunkSizeClasses[len(dataChunkSizeClasses)-1]); size > max {
+		size = max
+	}
+	chunk := b.pool.Get(int(size))
+	if len(chunk) == 0 {
+		// Panicking here would take down the connection's serve
+		// loop, and with it the process.
+		return getDataBufferChunk(size)
+	}
+	return chunk
+}
+
+func (b *dataBuffer) putChunk(p []byte) {
+	if b.pool == nil {
+		putDataBufferChunk(p)
+		return
+	}
+	b.pool.Put(p)
+}
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"fmt"
 	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -153,3 +154,83 @@ func TestDataBufferWriteAfterPartialRead(t *testing.T) {
 		return b
 	})
 }
+
+// A countingBufferPool is a BufferPool that records the buffers it hands
+// out, to check that each of them is returned.
+type countingBufferPool struct {
+	mu   sync.Mutex
+	gets int
+	puts int
+	out  map[*byte]bool // first byte of the buffers not yet returned
+}
+
+func (p *countingBufferPool) Get(size int) []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	buf := make([]byte, size)
+	if p.out == nil {
+		p.out = make(map[*byte]bool)
+	}
+	p.out[&buf[0]] = true
+	p.gets++
+	return buf
+}
+
+func (p *countingBufferPool) Put(buf []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.out[&buf[0]] {
+		panic("Put of a buffer not obtained from Get")
+	}
+	delete(p.out, &buf[0])
+	p.puts++
+}
+
+func (p *countingBufferPool) counts() (gets, puts int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.gets, p.puts
+}
+
+func TestDataBufferPool(t *testing.T) {
+	pool := new(countingBufferPool)
+	b := &dataBuffer{expected: 1 << 20, pool: pool}
+	want := bytes.Repeat([]byte("abcdefgh"), 5*1024)
+	if _, err := b.Write(want); err != nil {
+		t.Fatal(err)
+	}
+	for _, chunk := range b.chunks {
+		if len(chunk) > 16<<10 {
+			t.Errorf("chunk of %d bytes; want at most %d", len(chunk), 16<<10)
+		}
+	}
+	got := make([]byte, len(want))
+	if n, err := b.Read(got); n != len(want) || err != nil {
+		t.Fatalf("Read() = %v, %v; want %v, nil", n, err, len(want))
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Read data differs from written data")
+	}
+	// The partially filled last chunk is kept for later writes.
+	if gets, puts := pool.counts(); gets != 3 || puts != 2 {
+		t.Errorf("pool Get called %v times and Put %v times; want 3 and 2", gets, puts)
+	}
+}
+
+// An emptyBufferPool is a BufferPool whose Get returns empty buffers.
+type emptyBufferPool struct{}
+
+func (emptyBufferPool) Get(size int) []byte { return nil }
+func (emptyBufferPool) Put(buf []byte)      {}
+
+func TestDataBufferPoolEmptyBuffer(t *testing.T) {
+	b := &dataBuffer{expected: 1 << 20, pool: emptyBufferPool{}}
+	want := []byte("abcdefgh")
+	if _, err := b.Write(want); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, len(want))
+	if n, err := b.Read(got); n != len(want) || err != nil || !bytes.Equal(got, want) {
+		t.Errorf("Read() = %q, %v; want %q, nil", got[:n], err, want)
+	}
+}
@@ -311,6 +311,14 @@ type Framer struct {
 	// If the limit is hit, MetaHeadersFrame.Truncated is set true.
 	MaxHeaderListSize uint32
 
+	// MaxContinuationFrames is the maximum number of CONTINUATION
+	// frames that may follow a HEADERS frame. It's used only if
+	// ReadMetaHeaders is set; 0 means no limit. A header block with
+	// more CONTINUATION frames is a ConnectionError with code
+	// ErrCodeEnhanceYourCalm, reported before the excess frames are
+	// decoded and regardless of MaxHeaderListSize.
+	MaxContinuationFrames int
+
 	// TODO: track which type of frame & with which flags was sent
 	// last. Then return an error (unless AllowIllegalWrites) if
 	// we're in the middle of a header block and a
@@ -453,6 +461,26 @@ func (fr *Framer) SetMaxReadFrameSize(v uint32) {
 	fr.maxReadSize = v
 }
 
+// SetDebugWriter causes the Framer to write a one-line description of
+// each frame it reads or writes to w, including the frame's type, flags,
+// stream ID and length. Each line is written with a single Write call.
+// A nil w restores the default behavior, which logs frames to the
+// standard logger only if GODEBUG contains "http2debug=2".
+func (fr *Framer) SetDebugWriter(w io.Writer) {
+	if w == nil {
+		fr.logReads = logFrameReads
+		fr.logWrites = logFrameWrites
+		fr.debugReadLoggerf = log.Printf
+		fr.debugWriteLoggerf = log.Printf
+		return
+	}
+	lg := log.New(w, "", log.LstdFlags|log.Lmicroseconds)
+	fr.logReads = true
+	fr.logWrites = true
+	fr.debugReadLoggerf = lg.Printf
+	fr.debugWriteLoggerf = lg.Printf
+}
+
 // ErrorDetail returns a more detailed error of the last error
 // returned by Framer.ReadFrame. For instance, if ReadFrame
 // returns a StreamError with code PROTOCOL_ERROR, ErrorDetail
@@ -1401,6 +1429,10 @@ type MetaHeadersFrame struct {
 	// and Fields is incomplete. The hpack decoder state is still
 	// valid, however.
 	Truncated bool
+
+	// wireLen is the total length of the HEADERS and CONTINUATION
+	// frames, including their frame headers.
+	wireLen int64
 }
 
 // PseudoValue returns the given pseudo header field's value.
@@ -1444,7 +1476,7 @@ func (mh *MetaHeadersFrame) checkPseudos() error {
 	pf := mh.PseudoFields()
 	for i, hf := range pf {
 		switch hf.Name {
-		case ":method", ":path", ":scheme", ":authority":
+		case ":method", ":path", ":scheme", ":authority", ":protocol":
 			isRequest = true
 		case ":status":
 			isResponse = true
@@ -1531,7 +1563,9 @@ func (fr *Framer) readMetaFrame(hf *HeadersFrame) (*MetaHeadersFrame, error) {
 	defer hdec.SetEmitFunc(func(hf hpack.HeaderField) {})
 
 	var hc headersOrContinuation = hf
+	var continuations int
 	for {
+		mh.wireLen += frameHeaderLen + int64(hc.(Frame).Header().Length)
 		frag := hc.HeaderBlockFragment()
 		if _, err := hdec.Write(frag); err != nil {
 			return nil, ConnectionError(ErrCodeCompression)
@@ -1545,6 +1579,10 @@ func (fr *Framer) readMetaFrame(hf *HeadersFrame) (*MetaHeadersFrame, error) {
 		} else {
 			hc = f.(*ContinuationFrame) // guaranteed by checkFrameOrder
 		}
+		continuations++
+		if max := fr.MaxContinuationFrames; max > 0 && continuations > max {
+			return nil, fr.connError(ErrCodeEnhanceYourCalm, "too many CONTINUATION frames")
+		}
 	}
 
 	mh.HeadersFrame.headerFragBuf = nil
@@ -47,6 +47,34 @@ func TestFrameTypeString(t *testing.T) {
 	}
 }
 
+func TestFramerSetDebugWriter(t *testing.T) {
+	fr, _ := testFramer()
+	var log bytes.Buffer
+	fr.SetDebugWriter(&log)
+	if err := fr.WriteData(3, true, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fr.ReadFrame(); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines of debug output; want 2:\n%s", len(lines), log.String())
+	}
+	for i, want := range []string{"wrote DATA flags=END_STREAM stream=3 len=5", "read DATA flags=END_STREAM stream=3 len=5"} {
+		if !strings.Contains(lines[i], want) {
+			t.Errorf("line %d = %q; want it to contain %q", i, lines[i], want)
+		}
+	}
+
+	log.Reset()
+	fr.SetDebugWriter(nil)
+	fr.WriteData(3, true, nil)
+	if !logFrameWrites && log.Len() != 0 {
+		t.Errorf("got debug output after SetDebugWriter(nil): %q", log.String())
+	}
+}
+
 func TestWriteRST(t *testing.T) {
 	fr, buf := testFramer()
 	var streamID uint32 = 1<<24 + 2<<16 + 3<<8 + 4
@@ -916,6 +944,8 @@ func TestMetaFrameHeader(t *testing.T) {
 		want              interface{} // *MetaHeaderFrame or error
 		wantErrReason     string
 		maxHeaderListSize uint32
+
+		maxContinuationFrames int
 	}{
 		0: {
 			name: "single_headers",
@@ -1053,13 +1083,36 @@ func TestMetaFrameHeader(t *testing.T) {
 			want:          streamError(1, ErrCodeProtocol),
 			wantErrReason: "invalid header field value \"bad_null\\x00\"",
 		},
+		13: {
+			name: "continuation_limit",
+			w: func(f *Framer) {
+				var he hpackEncoder
+				all := he.encodeHeaderRaw(t, ":method", "GET", ":path", "/", "foo", "bar")
+				write(f, all[:2], all[2:4], all[4:])
+			},
+			want:                  want(noFlags, 2, ":method", "GET", ":path", "/", "foo", "bar"),
+			maxContinuationFrames: 2,
+		},
+		14: {
+			name: "too_many_continuations",
+			w: func(f *Framer) {
+				var he hpackEncoder
+				all := he.encodeHeaderRaw(t, ":method", "GET", ":path", "/", "foo", "bar")
+				write(f, all[:1], all[1:2], all[2:3], all[3:])
+			},
+			want:                  ConnectionError(ErrCodeEnhanceYourCalm),
+			wantErrReason:         "too many CONTINUATION frames",
+			maxContinuationFrames: 2,
+		},
 	}
 	for i, tt := range tests {
 		buf := new(bytes.Buffer)
 		f := NewFramer(buf, buf)
 		f.ReadMetaHeaders = hpack.NewDecoder(initialHeaderTableSize, nil)
 		f.MaxHeaderListSize = tt.maxHeaderListSize
+		f.MaxContinuationFrames = tt.maxContinuationFrames
 		tt.w(f)
+		written := buf.Len()
 
 		name := tt.name
 		if name == "" {
@@ -1078,6 +1131,13 @@ func TestMetaFrameHeader(t *testing.T) {
 				se.Cause = nil
 				got = se
 			}
+		} else if mh, ok := got.(*MetaHeadersFrame); ok {
+			// The test table above predates the wireLen field, which
+			// must account for everything written.
+			if mh.wireLen != int64(written) {
+				t.Errorf("%s: wireLen = %d; want %d", name, mh.wireLen, written)
+			}
+			mh.wireLen = 0
 		}
 		if !reflect.DeepEqual(got, tt.want) {
 			if mhg, ok := got.(*MetaHeadersFrame); ok {
@@ -22,6 +22,7 @@ import (
 	"net/textproto"
 	"os"
 	"strings"
+	"time"
 
 	"golang.org/x/net/http/httpguts"
 	"golang.org/x/net/http2"
@@ -50,6 +51,83 @@ func init() {
 type h2cHandler struct {
 	Handler http.Handler
 	s       *http2.Server
+
+	// connSem limits the number of concurrently served h2c
+	// connections. It is nil if the number is unlimited.
+	connSem chan struct{}
+
+	// sniffTimeout bounds how long a hijacked connection may take to
+	// send the remainder of the h2c preamble. Zero means no limit.
+	sniffTimeout time.Duration
+
+	// errorLog logs connections closed by the sniff timeout. If nil,
+	// the log package's standard logger is used.
+	errorLog *log.Logger
+}
+
+// defaultSniffTimeout is the default value of the sniff timeout. See
+// WithSniffTimeout.
+const defaultSniffTimeout = 5 * time.Second
+
+// A HandlerOption configures the Handler returned by NewHandlerWithOpts.
+type HandlerOption func(*h2cHandler)
+
+// WithMaxConcurrentConnections limits the number of h2c connections the
+// handler serves at a time to n. Once the limit is reached, further attempts
+// to start an h2c connection, either by Upgrade or with prior knowledge, are
+// rejected with a 503 (Service Unavailable) response. A slot is released when
+// the connection's ServeConn returns. If n is zero or negative, the number of
+// connections is unlimited.
+func WithMaxConcurrentConnections(n int) HandlerOption {
+	return func(h *h2cHandler) {
+		if n > 0 {
+			h.connSem = make(chan struct{}, n)
+		} else {
+			h.connSem = nil
+		}
+	}
+}
+
+// WithSniffTimeout sets how long the handler waits for a client to send the
+// part of the h2c preamble that follows the HTTP/1 request: the client preface
+// after an Upgrade, or the remainder of the preface with prior knowledge. The
+// deadline is extended each time the client makes progress, so a slow client
+// that keeps sending is not cut off. If the timeout expires, the connection is
+// closed. A zero or negative d disables the timeout. The default is 5 seconds.
+//
+// The timeout is not applied to Unix domain sockets or to connections that do
+// not support deadlines.
+func WithSniffTimeout(d time.Duration) HandlerOption {
+	return func(h *h2cHandler) {
+		if d < 0 {
+			d = 0
+		}
+		h.sniffTimeout = d
+	}
+}
+
+// WithErrorLog sets the logger for connections that the handler closes
+// because the sniff timeout expired, which are logged once each. By default,
+// and if l is nil, the log package's standard logger is used.
+func WithErrorLog(l *log.Logger) HandlerOption {
+	return func(h *h2cHandler) {
+		h.errorLog = l
+	}
+}
+
+// WithMaxConcurrentStreams sets the number of concurrent streams each h2c
+// client may have open at a time, overriding the MaxConcurrentStreams field
+// of the http2.Server passed to NewHandlerWithOpts. The caller's Server is
+// not modified.
+func WithMaxConcurrentStreams(n uint32) HandlerOption {
+	return func(h *h2cHandler) {
+		var s http2.Server
+		if h.s != nil {
+			s = *h.s
+		}
+		s.MaxConcurrentStreams = n
+		h.s = &s
+	}
 }
 
 // NewHandler returns an http.Handler that wraps h, intercepting any h2c
@@ -63,9 +141,55 @@ type h2cHandler struct {
 // understands HTTP/2 except for the h2c part of it.)
 func NewHandler(h http.Handler, s *http2.Server) http.Handler {
 	return &h2cHandler{
-		Handler: h,
-		s:       s,
+		Handler:      h,
+		s:            s,
+		sniffTimeout: defaultSniffTimeout,
+	}
+}
+
+// NewHandlerWithOpts is like NewHandler, but allows the returned Handler to
+// be configured with opts.
+func NewHandlerWithOpts(h http.Handler, s *http2.Server, opts ...HandlerOption) http.Handler {
+	hh := &h2cHandler{
+		Handler:      h,
+		s:            s,
+		sniffTimeout: defaultSniffTimeout,
+	}
+	for _, opt := range opts {
+		opt(hh)
+	}
+	return hh
+}
+
+// acquireConn reserves a slot for a new h2c connection. It reports false if
+// the connection limit has been reached.
+func (s h2cHandler) acquireConn() bool {
+	if s.connSem == nil {
+		return true
+	}
+	select {
+	case s.connSem <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// releaseConn releases a slot reserved by acquireConn.
+func (s h2cHandler) releaseConn() {
+	if s.connSem != nil {
+		<-s.connSem
+	}
+}
+
+// rejectConn responds to an h2c connection attempt that exceeds the
+// connection limit.
+func (s h2cHandler) rejectConn(w http.ResponseWriter) {
+	if http2VerboseLogs {
+		log.Print("h2c: rejecting h2c connection: too many concurrent connections.")
 	}
+	w.Header().Set("Connection", "close")
+	http.Error(w, "h2c: too many concurrent connections", http.StatusServiceUnavailable)
 }
 
 // ServeHTTP implement the h2c support that is enabled by h2c.GetH2CHandler.
@@ -75,7 +199,12 @@ func (s h2cHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		if http2VerboseLogs {
 			log.Print("h2c: attempting h2c with prior knowledge.")
 		}
-		conn, err := initH2CWithPriorKnowledge(w)
+		if !s.acquireConn() {
+			s.rejectConn(w)
+			return
+		}
+		defer s.releaseConn()
+		conn, err := initH2CWithPriorKnowledge(w, s.sniffTimeout, s.errorLog)
 		if err != nil {
 			if http2VerboseLogs {
 				log.Printf("h2c: error h2c with prior knowledge: %v", err)
@@ -88,11 +217,19 @@ func (s h2cHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// Handle Upgrade to h2c (RFC 7540 Section 3.2)
-	if conn, err := h2cUpgrade(w, r); err == nil {
-		defer conn.Close()
+	if isH2CUpgrade(r.Header) {
+		if !s.acquireConn() {
+			s.rejectConn(w)
+			return
+		}
+		if conn, err := h2cUpgrade(w, r, s.sniffTimeout, s.errorLog); err == nil {
+			defer s.releaseConn()
+			defer conn.Close()
 
-		s.s.ServeConn(conn, &http2.ServeConnOpts{Handler: s.Handler})
-		return
+			s.s.ServeConn(conn, &http2.ServeConnOpts{Handler: s.Handler})
+			return
+		}
+		s.releaseConn()
 	}
 
 	s.Handler.ServeHTTP(w, r)
@@ -104,7 +241,7 @@ func (s h2cHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // All we have to do is look for the client preface that is suppose to be part
 // of the body, and reforward the client preface on the net.Conn this function
 // creates.
-func initH2CWithPriorKnowledge(w http.ResponseWriter) (net.Conn, error) {
+func initH2CWithPriorKnowledge(w http.ResponseWriter, sniffTimeout time.Duration, errorLog *log.Logger) (net.Conn, error) {
 	hijacker, ok := w.(http.Hijacker)
 	if !ok {
 		panic("Hijack not supported.")
@@ -113,12 +250,17 @@ func initH2CWithPriorKnowledge(w http.ResponseWriter) (net.Conn, error) {
 	if err != nil {
 		panic(fmt.Sprintf("Hijack failed: %v", err))
 	}
+	clearDeadlines(conn)
 
 	const expectedBody = "SM\r\n\r\n"
 
+	sr := newSniffReader(conn, rw, sniffTimeout)
 	buf := make([]byte, len(expectedBody))
-	n, err := io.ReadFull(rw, buf)
+	n, err := io.ReadFull(sr, buf)
+	sr.stop()
 	if err != nil {
+		logSniffTimeout(errorLog, conn, err)
+		conn.Close()
 		return nil, fmt.Errorf("could not read from the buffer: %s", err)
 	}
 
@@ -142,6 +284,78 @@ func initH2CWithPriorKnowledge(w http.ResponseWriter) (net.Conn, error) {
 	return nil, errors.New("invalid client preface")
 }
 
+// supportsDeadlines reports whether deadlines can be relied upon for c. Unix
+// domain sockets are treated as local peers and, like conns whose
+// SetReadDeadline fails, are served without deadline-based timeouts.
+func supportsDeadlines(c net.Conn) bool {
+	if _, ok := c.(*net.UnixConn); ok {
+		return false
+	}
+	if a := c.LocalAddr(); a != nil && strings.HasPrefix(a.Network(), "unix") {
+		return false
+	}
+	return c.SetReadDeadline(time.Time{}) == nil
+}
+
+// sniffReader reads the h2c preamble from a hijacked conn, enforcing the
+// sniff timeout. The read deadline is pushed back whenever data arrives,
+// so only a client that stops sending is timed out.
+type sniffReader struct {
+	conn    net.Conn
+	r       io.Reader
+	timeout time.Duration // zero if the conn has no deadline
+}
+
+func newSniffReader(conn net.Conn, r io.Reader, timeout time.Duration) *sniffReader {
+	if timeout <= 0 || !supportsDeadlines(conn) {
+		timeout = 0
+	}
+	sr := &sniffReader{conn: conn, r: r, timeout: timeout}
+	if sr.timeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(sr.timeout))
+	}
+	return sr
+}
+
+func (r *sniffReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 && r.timeout > 0 {
+		r.conn.SetReadDeadline(time.Now().Add(r.timeout))
+	}
+	return n, err
+}
+
+// stop removes the read deadline set by the sniffReader.
+func (r *sniffReader) stop() {
+	if r.timeout > 0 {
+		r.conn.SetReadDeadline(time.Time{})
+	}
+}
+
+// logSniffTimeout logs to l, or to the standard logger if l is nil, why c is
+// being closed if err is a timeout from reading the h2c preamble.
+func logSniffTimeout(l *log.Logger, c net.Conn, err error) {
+	ne, ok := err.(net.Error)
+	if !ok || !ne.Timeout() {
+		return
+	}
+	const format = "h2c: closing connection from %v: timed out waiting for client preface"
+	if l != nil {
+		l.Printf(format, c.RemoteAddr())
+	} else {
+		log.Printf(format, c.RemoteAddr())
+	}
+}
+
+// clearDeadlines removes any deadlines the HTTP/1 server left on the hijacked
+// conn c. Not every net.Conn supports deadlines, so errors are ignored and
+// such conns are still served.
+func clearDeadlines(c net.Conn) {
+	if err := c.SetDeadline(time.Time{}); err != nil && http2VerboseLogs {
+		log.Printf("h2c: error clearing deadlines: %v", err)
+	}
+}
+
 // drainClientPreface reads a single instance of the HTTP/2 client preface from
 // the supplied reader.
 func drainClientPreface(r io.Reader) error {
@@ -158,7 +372,7 @@ func drainClientPreface(r io.Reader) error {
 }
 
 // h2cUpgrade establishes a h2c connection using the HTTP/1 upgrade (Section 3.2).
-func h2cUpgrade(w http.ResponseWriter, r *http.Request) (net.Conn, error) {
+func h2cUpgrade(w http.ResponseWriter, r *http.Request, sniffTimeout time.Duration, errorLog *log.Logger) (net.Conn, error) {
 	if !isH2CUpgrade(r.Header) {
 		return nil, errors.New("non-conforming h2c headers")
 	}
@@ -177,6 +391,7 @@ func h2cUpgrade(w http.ResponseWriter, r *http.Request) (net.Conn, error) {
 	if err != nil {
 		return nil, fmt.Errorf("hijack failed: %v", err)
 	}
+	clearDeadlines(conn)
 
 	rw.Write([]byte("HTTP/1.1 101 Switching Protocols\r\n" +
 		"Connection: Upgrade\r\n" +
@@ -185,7 +400,12 @@ func h2cUpgrade(w http.ResponseWriter, r *http.Request) (net.Conn, error) {
 
 	// A conforming client will now send an H2 client preface which need to drain
 	// since we already sent this.
-	if err := drainClientPreface(rw); err != nil {
+	sr := newSniffReader(conn, rw, sniffTimeout)
+	err = drainClientPreface(sr)
+	sr.stop()
+	if err != nil {
+		logSniffTimeout(errorLog, conn, err)
+		conn.Close()
 		return nil, err
 	}
 
@@ -7,12 +7,21 @@ package h2c
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
 )
 
 func TestSettingsAckSwallowWriter(t *testing.T) {
@@ -43,6 +52,208 @@ func TestSettingsAckSwallowWriter(t *testing.T) {
 	}
 }
 
+func TestMaxConcurrentConnections(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler called for rejected h2c connection")
+	})
+	h := NewHandlerWithOpts(handler, &http2.Server{}, WithMaxConcurrentConnections(1)).(*h2cHandler)
+	if !h.acquireConn() {
+		t.Fatal("could not acquire first connection slot")
+	}
+	defer h.releaseConn()
+
+	upgrade := httptest.NewRequest("GET", "/", nil)
+	upgrade.Header.Set("Connection", "Upgrade, HTTP2-Settings")
+	upgrade.Header.Set("Upgrade", "h2c")
+	upgrade.Header.Set("HTTP2-Settings", "AAMAAABkAARAAAAAAAIAAAAA")
+
+	prior := httptest.NewRequest("PRI", "*", nil)
+	prior.Proto = "HTTP/2.0"
+	prior.Header = http.Header{}
+
+	for _, req := range []*http.Request{upgrade, prior} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s request: status = %d; want %d", req.Method, rec.Code, http.StatusServiceUnavailable)
+		}
+	}
+}
+
+func TestMaxConcurrentStreams(t *testing.T) {
+	s := &http2.Server{MaxConcurrentStreams: 10}
+	h := NewHandlerWithOpts(http.NotFoundHandler(), s, WithMaxConcurrentStreams(5)).(*h2cHandler)
+	if got := h.s.MaxConcurrentStreams; got != 5 {
+		t.Errorf("MaxConcurrentStreams = %d; want 5", got)
+	}
+	if s.MaxConcurrentStreams != 10 {
+		t.Errorf("caller's Server was modified: MaxConcurrentStreams = %d; want 10", s.MaxConcurrentStreams)
+	}
+}
+
+// oneConnListener is a net.Listener that returns a single conn.
+type oneConnListener struct {
+	conn net.Conn
+	once sync.Once
+	done chan struct{}
+}
+
+func newOneConnListener(c net.Conn) *oneConnListener {
+	return &oneConnListener{conn: c, done: make(chan struct{})}
+}
+
+func (l *oneConnListener) Accept() (net.Conn, error) {
+	if c := l.conn; c != nil {
+		l.conn = nil
+		return c, nil
+	}
+	<-l.done
+	return nil, errors.New("listener closed")
+}
+
+func (l *oneConnListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *oneConnListener) Addr() net.Addr {
+	return &net.UnixAddr{Name: "h2c-test", Net: "unix"}
+}
+
+// noDeadlineConn is a net.Conn that does not support deadlines.
+type noDeadlineConn struct {
+	net.Conn
+}
+
+var errNoDeadline = errors.New("deadlines not supported")
+
+func (noDeadlineConn) SetDeadline(time.Time) error      { return errNoDeadline }
+func (noDeadlineConn) SetReadDeadline(time.Time) error  { return errNoDeadline }
+func (noDeadlineConn) SetWriteDeadline(time.Time) error { return errNoDeadline }
+
+func TestPriorKnowledgeWithoutDeadlines(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		wrap func(net.Conn) net.Conn
+	}{
+		{"pipe", func(c net.Conn) net.Conn { return c }},
+		{"no-deadlines", func(c net.Conn) net.Conn { return noDeadlineConn{c} }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			called := make(chan bool, 1)
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called <- true
+				io.WriteString(w, "ok")
+			})
+			srvConn, cliConn := net.Pipe()
+			defer cliConn.Close()
+			ln := newOneConnListener(tt.wrap(srvConn))
+			hs := &http.Server{
+				Handler:     NewHandler(handler, &http2.Server{}),
+				ReadTimeout: time.Minute,
+			}
+			go hs.Serve(ln)
+			defer ln.Close()
+
+			go func() {
+				io.WriteString(cliConn, http2.ClientPreface)
+				fr := http2.NewFramer(cliConn, nil)
+				fr.WriteSettings()
+				var hbuf bytes.Buffer
+				enc := hpack.NewEncoder(&hbuf)
+				enc.WriteField(hpack.HeaderField{Name: ":method", Value: "GET"})
+				enc.WriteField(hpack.HeaderField{Name: ":scheme", Value: "http"})
+				enc.WriteField(hpack.HeaderField{Name: ":authority", Value: "example.com"})
+				enc.WriteField(hpack.HeaderField{Name: ":path", Value: "/"})
+				fr.WriteHeaders(http2.HeadersFrameParam{
+					StreamID:      1,
+					BlockFragment: hbuf.Bytes(),
+					EndStream:     true,
+					EndHeaders:    true,
+				})
+			}()
+			go io.Copy(ioutil.Discard, cliConn)
+
+			select {
+			case <-called:
+			case <-time.After(10 * time.Second):
+				t.Fatal("handler was not invoked")
+			}
+		})
+	}
+}
+
+// chanWriter sends each write to a channel.
+type chanWriter chan string
+
+func (w chanWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+func TestSniffTimeout(t *testing.T) {
+	const timeout = 200 * time.Millisecond
+	logs := make(chanWriter, 10)
+	ts := httptest.NewServer(NewHandlerWithOpts(http.NotFoundHandler(), &http2.Server{},
+		WithSniffTimeout(timeout), WithErrorLog(log.New(logs, "", 0))))
+	defer ts.Close()
+
+	dial := func() net.Conn {
+		c, err := net.Dial("tcp", ts.Listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.SetDeadline(time.Now().Add(10 * time.Second))
+		return c
+	}
+
+	t.Run("stalled", func(t *testing.T) {
+		c := dial()
+		defer c.Close()
+		io.WriteString(c, "PRI * HTTP/2.0\r\n\r\nS")
+		start := time.Now()
+		if _, err := ioutil.ReadAll(c); err != nil {
+			t.Fatalf("reading from stalled conn: %v", err)
+		}
+		if d := time.Since(start); d < timeout {
+			t.Errorf("conn closed after %v; want at least %v", d, timeout)
+		}
+		select {
+		case msg := <-logs:
+			if !strings.Contains(msg, "timed out waiting for client preface") {
+				t.Errorf("logged %q; want sniff timeout", msg)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("sniff timeout not logged")
+		}
+	})
+
+	t.Run("slow", func(t *testing.T) {
+		c := dial()
+		defer c.Close()
+		io.WriteString(c, "PRI * HTTP/2.0\r\n\r\n")
+		for _, b := range []byte("SM\r\n\r\n") {
+			time.Sleep(timeout / 2)
+			if _, err := c.Write([]byte{b}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		fr := http2.NewFramer(nil, c)
+		f, err := fr.ReadFrame()
+		if err != nil {
+			t.Fatalf("reading server SETTINGS: %v", err)
+		}
+		if f.Header().Type != http2.FrameSettings {
+			t.Errorf("first frame = %v; want SETTINGS", f.Header().Type)
+		}
+		select {
+		case msg := <-logs:
+			t.Errorf("slow conn logged %q", msg)
+		default:
+		}
+	})
+}
+
 func ExampleNewHandler() {
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprint(w, "Hello world")
@@ -48,37 +48,42 @@ func NewEncoder(w io.Writer) *Encoder {
 // This function may also produce bytes for "Header Table Size Update"
 // if necessary. If produced, it is done before encoding f.
 func (e *Encoder) WriteField(f HeaderField) error {
-	e.buf = e.buf[:0]
+	e.buf = e.AppendField(e.buf[:0], f)
+	n, err := e.w.Write(e.buf)
+	if err == nil && n != len(e.buf) {
+		err = io.ErrShortWrite
+	}
+	return err
+}
 
+// AppendField appends the encoding of f to dst and returns the extended
+// buffer. Like WriteField, it may first append a "Header Table Size
+// Update" and it updates the encoder's dynamic table, so fields encoded
+// by any mix of AppendField and WriteField calls form a valid sequence.
+// AppendField does not write to e's underlying Writer, which may be nil
+// if only AppendField is used.
+func (e *Encoder) AppendField(dst []byte, f HeaderField) []byte {
 	if e.tableSizeUpdate {
 		e.tableSizeUpdate = false
 		if e.minSize < e.dynTab.maxSize {
-			e.buf = appendTableSize(e.buf, e.minSize)
+			dst = appendTableSize(dst, e.minSize)
 		}
 		e.minSize = uint32Max
-		e.buf = appendTableSize(e.buf, e.dynTab.maxSize)
+		dst = appendTableSize(dst, e.dynTab.maxSize)
 	}
 
 	idx, nameValueMatch := e.searchTable(f)
 	if nameValueMatch {
-		e.buf = appendIndexed(e.buf, idx)
-	} else {
-		indexing := e.shouldIndex(f)
-		if indexing {
-			e.dynTab.add(f)
-		}
-
-		if idx == 0 {
-			e.buf = appendNewName(e.buf, f, indexing)
-		} else {
-			e.buf = appendIndexedName(e.buf, f, idx, indexing)
-		}
+		return appendIndexed(dst, idx)
 	}
-	n, err := e.w.Write(e.buf)
-	if err == nil && n != len(e.buf) {
-		err = io.ErrShortWrite
+	indexing := e.shouldIndex(f)
+	if indexing {
+		e.dynTab.add(f)
 	}
-	return err
+	if idx == 0 {
+		return appendNewName(dst, f, indexing)
+	}
+	return appendIndexedName(dst, f, idx, indexing)
 }
 
 // searchTable searches f in both stable and dynamic header tables.
@@ -90,6 +90,41 @@ func TestEncoderWriteField(t *testing.T) {
 	}
 }
 
+func TestEncoderAppendField(t *testing.T) {
+	var buf bytes.Buffer
+	we := NewEncoder(&buf)
+	ae := NewEncoder(nil)
+	ae.SetMaxDynamicTableSize(2048)
+	we.SetMaxDynamicTableSize(2048)
+
+	hdrs := []HeaderField{
+		pair(":method", "GET"),
+		pair(":authority", "www.example.com"),
+		pair("custom-key", "custom-value"),
+		pair("custom-key", "custom-value"),
+		{Name: "password", Value: "secret", Sensitive: true},
+	}
+	var dst []byte
+	for i := 0; i < 2; i++ {
+		buf.Reset()
+		dst = dst[:0]
+		for _, hf := range hdrs {
+			if err := we.WriteField(hf); err != nil {
+				t.Fatal(err)
+			}
+			dst = ae.AppendField(dst, hf)
+		}
+		if !bytes.Equal(dst, buf.Bytes()) {
+			t.Errorf("%d. AppendField = %x; WriteField = %x", i, dst, buf.Bytes())
+		}
+	}
+
+	prefix := []byte("prefix")
+	if got := NewEncoder(nil).AppendField(prefix, pair(":method", "GET")); !bytes.Equal(got, []byte("prefix\x82")) {
+		t.Errorf("AppendField with prefix = %q; want %q", got, "prefix\x82")
+	}
+}
+
 func TestEncoderSearchTable(t *testing.T) {
 	e := NewEncoder(nil)
 
@@ -150,6 +150,20 @@ func (d *Decoder) SetMaxDynamicTableSize(v uint32) {
 	d.dynTab.setMaxSize(v)
 }
 
+// DynamicTableSize returns the current size of the decoder's dynamic
+// table in bytes, as defined by RFC 7541 Section 4.1.
+func (d *Decoder) DynamicTableSize() int { return int(d.dynTab.size) }
+
+// MaxDynamicTableSize returns the current maximum size of the decoder's
+// dynamic table in bytes, as last set by the encoder or by
+// SetMaxDynamicTableSize.
+func (d *Decoder) MaxDynamicTableSize() int { return int(d.dynTab.maxSize) }
+
+// DynamicTableEvictions returns the number of entries evicted from the
+// decoder's dynamic table since the Decoder was created. A rapidly
+// growing count may indicate a peer abusing header compression.
+func (d *Decoder) DynamicTableEvictions() uint64 { return d.dynTab.table.evictCount }
+
 // SetAllowedMaxDynamicTableSize sets the upper bound that the encoded
 // stream (via dynamic table size updates) may set the maximum size
 // to.
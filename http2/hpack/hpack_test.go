@@ -69,6 +69,31 @@ func TestDynamicTableSizeEvict(t *testing.T) {
 	}
 }
 
+func TestDecoderDynamicTableStats(t *testing.T) {
+	d := NewDecoder(4096, nil)
+	if got, want := d.MaxDynamicTableSize(), 4096; got != want {
+		t.Errorf("MaxDynamicTableSize = %d; want %d", got, want)
+	}
+	d.dynTab.add(pair("blake", "eats pizza"))
+	d.dynTab.add(pair("foo", "bar"))
+	if got, want := d.DynamicTableSize(), 15+32+6+32; got != want {
+		t.Errorf("DynamicTableSize = %d; want %d", got, want)
+	}
+	if got := d.DynamicTableEvictions(); got != 0 {
+		t.Errorf("DynamicTableEvictions = %d; want 0", got)
+	}
+	d.SetMaxDynamicTableSize(6 + 32)
+	if got, want := d.MaxDynamicTableSize(), 6+32; got != want {
+		t.Errorf("after SetMaxDynamicTableSize, MaxDynamicTableSize = %d; want %d", got, want)
+	}
+	if got, want := d.DynamicTableSize(), 6+32; got != want {
+		t.Errorf("after SetMaxDynamicTableSize, DynamicTableSize = %d; want %d", got, want)
+	}
+	if got := d.DynamicTableEvictions(); got != 1 {
+		t.Errorf("after SetMaxDynamicTableSize, DynamicTableEvictions = %d; want 1", got)
+	}
+}
+
 func TestDecoderDecode(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -139,6 +139,10 @@ func (s Setting) Valid() error {
 		if s.Val < 16384 || s.Val > 1<<24-1 {
 			return ConnectionError(ErrCodeProtocol)
 		}
+	case SettingEnableConnectProtocol:
+		if s.Val != 1 && s.Val != 0 {
+			return ConnectionError(ErrCodeProtocol)
+		}
 	}
 	return nil
 }
@@ -154,6 +158,9 @@ const (
 	SettingInitialWindowSize    SettingID = 0x4
 	SettingMaxFrameSize         SettingID = 0x5
 	SettingMaxHeaderListSize    SettingID = 0x6
+
+	// SettingEnableConnectProtocol is defined by RFC 8441.
+	SettingEnableConnectProtocol SettingID = 0x8
 )
 
 var settingName = map[SettingID]string{
@@ -163,6 +170,8 @@ var settingName = map[SettingID]string{
 	SettingInitialWindowSize:    "INITIAL_WINDOW_SIZE",
 	SettingMaxFrameSize:         "MAX_FRAME_SIZE",
 	SettingMaxHeaderListSize:    "MAX_HEADER_LIST_SIZE",
+
+	SettingEnableConnectProtocol: "ENABLE_CONNECT_PROTOCOL",
 }
 
 func (s SettingID) String() string {
@@ -244,6 +253,7 @@ type bufferedWriter struct {
 	_  incomparable
 	w  io.Writer     // immutable
 	bw *bufio.Writer // non-nil when data is buffered
+	n  int64         // bytes accepted by Write so far
 }
 
 func newBufferedWriter(w io.Writer) *bufferedWriter {
@@ -277,7 +287,9 @@ func (w *bufferedWriter) Write(p []byte) (n int, err error) {
 		bw.Reset(w.w)
 		w.bw = bw
 	}
-	return w.bw.Write(p)
+	n, err = w.bw.Write(p)
+	w.n += int64(n)
+	return n, err
 }
 
 func (w *bufferedWriter) Flush() error {
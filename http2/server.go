@@ -57,6 +57,9 @@ const (
 	handlerChunkWriteSize  = 4 << 10
 	defaultMaxStreams      = 250 // TODO: make this 100 as the GFE seems to?
 	maxQueuedControlFrames = 10000
+
+	defaultMaxResetStreamsPerMinute = 1000
+	defaultMaxContinuationFrames    = 64
 )
 
 var (
@@ -130,6 +133,141 @@ type Server struct {
 	// If nil, a default scheduler is chosen.
 	NewWriteScheduler func() WriteScheduler
 
+	// CountError, if non-nil, is called on HTTP/2 server errors.
+	// It's intended to increment a metric for monitoring, such
+	// as an expvar or Prometheus metric.
+	// The errType consists of only ASCII word characters.
+	CountError func(errType string)
+
+	// EnableExtendedConnect, if true, enables the extended CONNECT
+	// method defined in RFC 8441, which is used to bootstrap
+	// WebSockets and other protocols over an HTTP/2 stream. The
+	// server advertises SETTINGS_ENABLE_CONNECT_PROTOCOL and accepts
+	// CONNECT requests carrying a :protocol pseudo-header. The
+	// value of :protocol is available to handlers as the
+	// ":protocol" key of Request.Header.
+	EnableExtendedConnect bool
+
+	// FrameDebugWriter, if non-nil, receives a one-line description
+	// of each frame read or written on the server's connections.
+	// See Framer.SetDebugWriter. It must be safe for concurrent use
+	// if the server handles more than one connection.
+	FrameDebugWriter io.Writer
+
+	// ExtraSettings are additional settings, such as those of
+	// experimental or vendor extensions, that the server sends in
+	// its initial SETTINGS frame. Settings the Server manages
+	// itself (those defined by RFC 7540 and RFC 8441) are ignored.
+	ExtraSettings []Setting
+
+	// OnUnknownSetting, if non-nil, is called for each setting with
+	// an unknown identifier received from a client. Per the spec,
+	// the setting is otherwise ignored, and still acknowledged.
+	// It is called from the connection's serving goroutine and
+	// should not block.
+	OnUnknownSetting func(id SettingID, val uint32)
+
+	// MaxResetStreamsPerMinute limits the rate at which a client may
+	// reset the streams it opened, to protect against the "Rapid
+	// Reset" attack (CVE-2023-44487) in which a client opens and
+	// immediately cancels streams faster than the server can clean
+	// up after their handlers. A client may reset this many streams
+	// in a burst, and regains one reset every 60/n seconds; a client
+	// that exceeds the limit is sent a GOAWAY with error code
+	// ENHANCE_YOUR_CALM and disconnected.
+	// If zero, a default of 1000 is used. If negative, there is no
+	// limit.
+	MaxResetStreamsPerMinute int
+
+	// MaxContinuationFrames limits the number of CONTINUATION frames
+	// a client may send after a HEADERS frame, to protect against the
+	// "CONTINUATION flood" attack (CVE-2024-27316) in which a client
+	// sends a header block that never ends. A client that exceeds
+	// the limit is sent a GOAWAY with error code ENHANCE_YOUR_CALM
+	// and disconnected, even if the header block is smaller than
+	// MaxHeaderBytes.
+	// If zero, a default of 64 is used. If negative, there is no
+	// limit.
+	MaxContinuationFrames int
+
+	// OnStreamOpen, if non-nil, is called when a stream is opened,
+	// either by the client's HEADERS frame or by a server push,
+	// before the stream's handler is started. It is called for
+	// streams that never reach a handler, such as requests
+	// rejected for malformed headers, but not for HEADERS frames
+	// refused before a stream is opened, as when the client
+	// exceeds MaxConcurrentStreams.
+	// The context is the stream's, which the request's context
+	// is derived from.
+	// It is called from the connection's serving goroutine and
+	// should not block.
+	OnStreamOpen func(ctx context.Context, info StreamInfo)
+
+	// OnStreamClose, if non-nil, is called when a stream is
+	// closed, however it ended. Its info includes the stream's
+	// byte counts and final state. The context is the same as
+	// for OnStreamOpen, and may be canceled.
+	// It is called from the connection's serving goroutine and
+	// should not block.
+	OnStreamClose func(ctx context.Context, info StreamInfo)
+
+	// DataBufferPool, if non-nil, supplies the buffers that hold
+	// request body data until the handler reads it. See BufferPool
+	// for the ownership of the buffers.
+	DataBufferPool BufferPool
+
+	// MaxConnHeaderBytes, if positive, limits the total size of the
+	// decoded request headers of the open streams of a connection,
+	// counted as for MaxHeaderBytes. Unlike MaxHeaderBytes, which
+	// bounds a single request, it bounds the header memory a client
+	// can pin by opening many streams with large headers. A HEADERS
+	// frame opening a stream that would exceed the limit is refused
+	// with a RST_STREAM of error code REFUSED_STREAM, and the client
+	// may retry once other streams have closed.
+	// If zero or negative, there is no limit.
+	MaxConnHeaderBytes int64
+
+	// DrainTimeout, if positive, makes graceful shutdowns, as started
+	// by the Shutdown method of the net/http Server, use two GOAWAY
+	// frames: a first one with the maximum last stream ID, which
+	// tells the client to stop opening streams while still
+	// accepting those already in flight, then, after about a round
+	// trip, the final one with the ID of the last stream accepted.
+	// The connection is then closed once its open streams have
+	// completed, or when DrainTimeout has elapsed since the final
+	// GOAWAY, whichever happens first.
+	// If zero or negative, a single GOAWAY is sent and the
+	// connection waits for its open streams to complete without a
+	// time limit.
+	DrainTimeout time.Duration
+
+	// DisableExpectContinueHandling, if true, leaves requests with
+	// an "Expect: 100-continue" header to the handler: the header
+	// is kept in the request, and no 100 Continue response is sent
+	// when the handler first reads the body. The handler, such as
+	// a proxy relaying the response of an upstream server, writes
+	// the interim response itself with WriteHeader(100), or a
+	// final response such as 417 Expectation Failed. Reads of the
+	// body block until the client sends it.
+	//
+	// With the option set, WriteHeader with a 1xx status other than
+	// 101 sends an informational response at once, which may be
+	// followed by others before the final response. WriteHeader
+	// panics on 101 (Switching Protocols), which HTTP/2 forbids.
+	// Otherwise a 1xx status is used as the final status.
+	DisableExpectContinueHandling bool
+
+	// MaxQueuedControlFrames limits the number of control frames,
+	// such as the acknowledgments of a client's PING and SETTINGS
+	// frames and RST_STREAM frames, that may be queued for writing
+	// on a connection. A client that keeps making the server send
+	// control frames without reading them, as in the "Ping flood"
+	// and "Settings flood" attacks (CVE-2019-9512, CVE-2019-9515),
+	// is disconnected once the limit is exceeded.
+	// If zero, a default of 10000 is used. If negative, there is no
+	// limit.
+	MaxQueuedControlFrames int
+
 	// Internal state. This is a pointer (rather than embedded directly)
 	// so that we don't embed a Mutex in this struct, which will make the
 	// struct non-copyable, which might break some callers.
@@ -164,12 +302,34 @@ func (s *Server) maxConcurrentStreams() uint32 {
 	return defaultMaxStreams
 }
 
+func (s *Server) maxResetStreamsPerMinute() int {
+	if v := s.MaxResetStreamsPerMinute; v != 0 {
+		return v
+	}
+	return defaultMaxResetStreamsPerMinute
+}
+
+func (s *Server) maxContinuationFrames() int {
+	switch v := s.MaxContinuationFrames; {
+	case v > 0:
+		return v
+	case v < 0:
+		return 0 // no limit
+	}
+	return defaultMaxContinuationFrames
+}
+
 // maxQueuedControlFrames is the maximum number of control frames like
 // SETTINGS, PING and RST_STREAM that will be queued for writing before
-// the connection is closed to prevent memory exhaustion attacks.
+// the connection is closed to prevent memory exhaustion attacks, or 0
+// for no limit.
 func (s *Server) maxQueuedControlFrames() int {
-	// TODO: if anybody asks, add a Server field, and remember to define the
-	// behavior of negative values.
+	switch v := s.MaxQueuedControlFrames; {
+	case v > 0:
+		return v
+	case v < 0:
+		return 0 // no limit
+	}
 	return maxQueuedControlFrames
 }
 
@@ -319,6 +479,14 @@ type ServeConnOpts struct {
 	// requests. If nil, BaseConfig.Handler is used. If BaseConfig
 	// or BaseConfig.Handler is nil, http.DefaultServeMux is used.
 	Handler http.Handler
+
+	// FallbackHandler, if non-nil, serves connections that do not
+	// begin with the HTTP/2 client preface as HTTP/1.x instead of
+	// closing them. ServeConn then reads the preface before
+	// writing its initial SETTINGS frame. The HTTP/1.x connection
+	// uses the timeouts, MaxHeaderBytes and ErrorLog of BaseConfig.
+	// Its requests do not have Request.TLS set.
+	FallbackHandler http.Handler
 }
 
 func (o *ServeConnOpts) context() context.Context {
@@ -335,6 +503,13 @@ func (o *ServeConnOpts) baseConfig() *http.Server {
 	return new(http.Server)
 }
 
+func (o *ServeConnOpts) fallbackHandler() http.Handler {
+	if o != nil {
+		return o.FallbackHandler
+	}
+	return nil
+}
+
 func (o *ServeConnOpts) handler() http.Handler {
 	if o != nil {
 		if o.Handler != nil {
@@ -362,6 +537,20 @@ func (o *ServeConnOpts) handler() http.Handler {
 //
 // The opts parameter is optional. If nil, default values are used.
 func (s *Server) ServeConn(c net.Conn, opts *ServeConnOpts) {
+	prefaceRead := false
+	if h := opts.fallbackHandler(); h != nil {
+		prefix, isH2, err := sniffPreface(c)
+		if err != nil {
+			c.Close()
+			return
+		}
+		if !isH2 {
+			serveFallback(c, prefix, h, opts.baseConfig())
+			return
+		}
+		prefaceRead = true
+	}
+
 	baseCtx, cancel := serverConnBaseContext(c, opts)
 	defer cancel()
 
@@ -387,6 +576,10 @@ func (s *Server) ServeConn(c net.Conn, opts *ServeConnOpts) {
 		headerTableSize:             initialHeaderTableSize,
 		serveG:                      newGoroutineLock(),
 		pushEnabled:                 true,
+		prefaceRead:                 prefaceRead,
+	}
+	if n := s.maxResetStreamsPerMinute(); n > 0 {
+		sc.resetLimit = newResetLimiter(n, time.Now())
 	}
 
 	s.state.registerConn(sc)
@@ -417,7 +610,11 @@ func (s *Server) ServeConn(c net.Conn, opts *ServeConnOpts) {
 	fr := NewFramer(sc.bw, c)
 	fr.ReadMetaHeaders = hpack.NewDecoder(initialHeaderTableSize, nil)
 	fr.MaxHeaderListSize = sc.maxHeaderListSize()
+	fr.MaxContinuationFrames = s.maxContinuationFrames()
 	fr.SetMaxReadFrameSize(s.maxReadFrameSize())
+	if s.FrameDebugWriter != nil {
+		fr.SetDebugWriter(s.FrameDebugWriter)
+	}
 	sc.framer = fr
 
 	if tc, ok := c.(connectionStater); ok {
@@ -472,6 +669,81 @@ func (s *Server) ServeConn(c net.Conn, opts *ServeConnOpts) {
 	sc.serve()
 }
 
+// PeerSettingsContextKey is a context key. It can be used in the
+// handlers of a Server to access the settings the client had sent as of
+// the start of the request. The associated value will be of type
+// PeerSettings.
+var PeerSettingsContextKey = &contextKey{"http2-peer-settings"}
+
+// contextKey is a value for use with context.WithValue. It's used as
+// a pointer so it fits in an interface{} without allocation.
+type contextKey struct {
+	name string
+}
+
+func (k *contextKey) String() string { return "http2 context value " + k.name }
+
+// PeerSettings are the values of the settings defined by RFC 7540 that
+// a client has sent, or their initial values if it has not sent them.
+type PeerSettings struct {
+	HeaderTableSize      uint32
+	EnablePush           bool
+	MaxConcurrentStreams uint32 // math.MaxUint32 means no limit
+	InitialWindowSize    uint32
+	MaxFrameSize         uint32
+	MaxHeaderListSize    uint32 // zero means no limit
+}
+
+// PeerSettingsFromContext returns the client settings stored in the
+// context of a request by the Server.
+func PeerSettingsFromContext(ctx context.Context) (PeerSettings, bool) {
+	ps, ok := ctx.Value(PeerSettingsContextKey).(PeerSettings)
+	return ps, ok
+}
+
+// StreamInfo describes a stream of a server connection, as passed to
+// the Server's OnStreamOpen and OnStreamClose callbacks.
+type StreamInfo struct {
+	StreamID uint32
+	Method   string // the :method pseudo-header
+	Path     string // the :path pseudo-header
+	Pushed   bool   // whether the stream was opened by a server push
+
+	// The remaining fields are only set for OnStreamClose.
+
+	BytesReceived int64 // request body bytes received, excluding padding
+	BytesSent     int64 // response body bytes written in DATA frames
+
+	// The following fields count whole frames as sent on the
+	// wire, including frame headers and padding: HEADERS and
+	// CONTINUATION frames, for the request or response headers,
+	// trailers and any 100 Continue response, and DATA frames.
+	// Frames received after the stream was reset but before it
+	// was closed are counted too.
+	HeaderBytesIn  int64
+	DataBytesIn    int64
+	HeaderBytesOut int64
+	DataBytesOut   int64
+
+	// Err is nil if the stream completed normally. Otherwise, it
+	// is a StreamError if the stream was reset by either
+	// endpoint, or the reason the stream ended otherwise, such as
+	// the connection being closed or the handler panicking.
+	Err error
+}
+
+func (sc *serverConn) peerSettings() PeerSettings {
+	sc.serveG.check()
+	return PeerSettings{
+		HeaderTableSize:      sc.headerTableSize,
+		EnablePush:           sc.pushEnabled,
+		MaxConcurrentStreams: sc.clientMaxStreams,
+		InitialWindowSize:    uint32(sc.initialStreamSendWindowSize),
+		MaxFrameSize:         uint32(sc.maxFrameSize),
+		MaxHeaderListSize:    sc.peerMaxHeaderListSize,
+	}
+}
+
 func serverConnBaseContext(c net.Conn, opts *ServeConnOpts) (ctx context.Context, cancel func()) {
 	ctx, cancel = context.WithCancel(opts.context())
 	ctx = context.WithValue(ctx, http.LocalAddrContextKey, c.LocalAddr())
@@ -481,6 +753,86 @@ func serverConnBaseContext(c net.Conn, opts *ServeConnOpts) (ctx context.Context
 	return
 }
 
+// sniffPreface reads from c for as long as what it has read is a prefix
+// of the client preface. It returns the bytes read, and whether they are
+// the whole preface.
+func sniffPreface(c net.Conn) (prefix []byte, isH2 bool, err error) {
+	c.SetReadDeadline(time.Now().Add(prefaceTimeout))
+	defer c.SetReadDeadline(time.Time{})
+	buf := make([]byte, len(ClientPreface))
+	n := 0
+	for n < len(buf) {
+		m, err := c.Read(buf[n:])
+		if !bytes.Equal(buf[n:n+m], clientPreface[n:n+m]) {
+			return buf[:n+m], false, nil
+		}
+		n += m
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	return buf, true, nil
+}
+
+// serveFallback serves c, whose first bytes were prefix, as an
+// HTTP/1.x connection with h. It returns when c is closed.
+func serveFallback(c net.Conn, prefix []byte, h http.Handler, base *http.Server) {
+	hs := &http.Server{
+		Handler:           h,
+		ReadTimeout:       base.ReadTimeout,
+		ReadHeaderTimeout: base.ReadHeaderTimeout,
+		WriteTimeout:      base.WriteTimeout,
+		IdleTimeout:       base.IdleTimeout,
+		MaxHeaderBytes:    base.MaxHeaderBytes,
+		ErrorLog:          base.ErrorLog,
+	}
+	fc := &fallbackConn{Conn: c, prefix: prefix, closed: make(chan struct{})}
+	hs.Serve(&fallbackListener{c: fc})
+	<-fc.closed
+}
+
+// A fallbackConn is a net.Conn whose first reads return the bytes
+// read by sniffPreface.
+type fallbackConn struct {
+	net.Conn
+	prefix    []byte
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func (c *fallbackConn) Read(p []byte) (int, error) {
+	if len(c.prefix) > 0 {
+		n := copy(p, c.prefix)
+		c.prefix = c.prefix[n:]
+		return n, nil
+	}
+	return c.Conn.Read(p)
+}
+
+func (c *fallbackConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return c.Conn.Close()
+}
+
+// A fallbackListener is a net.Listener that accepts a single
+// connection, then blocks until that connection is closed.
+type fallbackListener struct {
+	c        *fallbackConn
+	accepted bool
+}
+
+func (l *fallbackListener) Accept() (net.Conn, error) {
+	if !l.accepted {
+		l.accepted = true
+		return l.c, nil
+	}
+	<-l.c.closed
+	return nil, errors.New("http2: fallback connection closed")
+}
+
+func (l *fallbackListener) Close() error   { return nil }
+func (l *fallbackListener) Addr() net.Addr { return l.c.LocalAddr() }
+
 func (sc *serverConn) rejectConn(err ErrCode, debug string) {
 	sc.vlogf("http2: server rejecting conn: %v, %s", err, debug)
 	// ignoring errors. hanging up anyway.
@@ -513,6 +865,7 @@ type serverConn struct {
 	// Everything following is owned by the serve loop; use serveG.check():
 	serveG                      goroutineLock // used to verify funcs are on serve()
 	pushEnabled                 bool
+	prefaceRead                 bool // the preface was read by ServeConn
 	sawFirstSettings            bool // got the initial SETTINGS frame after the preface
 	needToSendSettingsAck       bool
 	unackedSettings             int    // how many SETTINGS have we sent without ACKs?
@@ -521,6 +874,7 @@ type serverConn struct {
 	advMaxStreams               uint32 // our SETTINGS_MAX_CONCURRENT_STREAMS advertised the client
 	curClientStreams            uint32 // number of open streams initiated by the client
 	curPushedStreams            uint32 // number of open streams initiated by server push
+	curHeaderBytes              int64  // decoded request header bytes of open streams
 	maxClientStreamID           uint32 // max ever seen from client (odd), or 0 if there have been no client requests
 	maxPushPromiseID            uint32 // ID of the last push promise (even), or 0 if there have been no pushes
 	streams                     map[uint32]*stream
@@ -536,8 +890,12 @@ type serverConn struct {
 	inFrameScheduleLoop         bool              // whether we're in the scheduleFrameWrite loop
 	needToSendGoAway            bool              // we need to schedule a GOAWAY frame write
 	goAwayCode                  ErrCode
-	shutdownTimer               *time.Timer // nil until used
-	idleTimer                   *time.Timer // nil if unused
+	shutdownTimer               *time.Timer   // nil until used
+	drainTimer                  *time.Timer   // nil unless draining with Server.DrainTimeout
+	needToSendDrainGoAway       bool          // we need to schedule the first GOAWAY of a drain
+	drainFinal                  bool          // the final GOAWAY of a drain was scheduled
+	idleTimer                   *time.Timer   // nil if unused
+	resetLimit                  *resetLimiter // nil if client resets are unlimited
 
 	// Owned by the writeFrameAsync goroutine:
 	headerWriteBuf bytes.Buffer
@@ -583,16 +941,26 @@ type stream struct {
 	// owned by serverConn's serve loop:
 	bodyBytes        int64 // body bytes seen so far
 	declBodyBytes    int64 // or -1 if undeclared
+	headerBytes      int64 // decoded request header bytes, counted against MaxConnHeaderBytes
 	flow             flow  // limits writing from Handler to client
 	inflow           flow  // what the client is allowed to POST/etc to us
 	state            streamState
 	resetQueued      bool        // RST_STREAM queued for write; set by sc.resetStream
 	gotTrailerHeader bool        // HEADER frame for trailers was seen
-	wroteHeaders     bool        // whether we wrote headers (not status 100)
+	wroteHeaders     bool        // whether we wrote headers (not status 1xx)
 	writeDeadline    *time.Timer // nil if unused
 
 	trailer    http.Header // accumulated trailers
 	reqTrailer http.Header // handler's Request.Trailer
+
+	method, path string // for StreamInfo; set when the stream is opened
+	sentBytes    int64  // DATA payload bytes written
+
+	// Frame bytes read and written on the stream, for StreamInfo.
+	headerBytesIn, dataBytesIn   int64
+	headerBytesOut, dataBytesOut int64
+
+	closeErr error // why the stream was closed; set before cw is closed
 }
 
 func (sc *serverConn) Framer() *Framer  { return sc.framer }
@@ -635,6 +1003,13 @@ func (sc *serverConn) setConnState(state http.ConnState) {
 	}
 }
 
+// countError reports errType to the Server's CountError hook, if any.
+func (sc *serverConn) countError(errType string) {
+	if sc.srv.CountError != nil {
+		sc.srv.CountError(errType)
+	}
+}
+
 func (sc *serverConn) vlogf(format string, args ...interface{}) {
 	if VerboseLogs {
 		sc.logf(format, args...)
@@ -764,6 +1139,7 @@ type frameWriteResult struct {
 	_   incomparable
 	wr  FrameWriteRequest // what was written (or attempted)
 	err error             // result of the writeFrame call
+	n   int64             // bytes written by the writeFrame call
 }
 
 // writeFrameAsync runs in its own goroutine and writes a single frame
@@ -771,8 +1147,15 @@ type frameWriteResult struct {
 // At most one goroutine can be running writeFrameAsync at a time per
 // serverConn.
 func (sc *serverConn) writeFrameAsync(wr FrameWriteRequest) {
+	sc.wroteFrameCh <- sc.writeFrameCounted(wr)
+}
+
+// writeFrameCounted writes wr's frames and reports how many bytes
+// that took. It runs wherever the frame is written.
+func (sc *serverConn) writeFrameCounted(wr FrameWriteRequest) frameWriteResult {
+	n := sc.bw.n
 	err := wr.write.writeFrame(sc)
-	sc.wroteFrameCh <- frameWriteResult{wr: wr, err: err}
+	return frameWriteResult{wr: wr, err: err, n: sc.bw.n - n}
 }
 
 func (sc *serverConn) closeAllStreamsOnConnClose() {
@@ -787,6 +1170,9 @@ func (sc *serverConn) stopShutdownTimer() {
 	if t := sc.shutdownTimer; t != nil {
 		t.Stop()
 	}
+	if t := sc.drainTimer; t != nil {
+		t.Stop()
+	}
 }
 
 func (sc *serverConn) notePanic() {
@@ -816,13 +1202,22 @@ func (sc *serverConn) serve() {
 		sc.vlogf("http2: server connection from %v on %p", sc.conn.RemoteAddr(), sc.hs)
 	}
 
+	settings := writeSettings{
+		{SettingMaxFrameSize, sc.srv.maxReadFrameSize()},
+		{SettingMaxConcurrentStreams, sc.advMaxStreams},
+		{SettingMaxHeaderListSize, sc.maxHeaderListSize()},
+		{SettingInitialWindowSize, uint32(sc.srv.initialStreamRecvWindowSize())},
+	}
+	if sc.srv.EnableExtendedConnect {
+		settings = append(settings, Setting{SettingEnableConnectProtocol, 1})
+	}
+	for _, s := range sc.srv.ExtraSettings {
+		if _, known := settingName[s.ID]; !known {
+			settings = append(settings, s)
+		}
+	}
 	sc.writeFrame(FrameWriteRequest{
-		write: writeSettings{
-			{SettingMaxFrameSize, sc.srv.maxReadFrameSize()},
-			{SettingMaxConcurrentStreams, sc.advMaxStreams},
-			{SettingMaxHeaderListSize, sc.maxHeaderListSize()},
-			{SettingInitialWindowSize, uint32(sc.srv.initialStreamRecvWindowSize())},
-		},
+		write: settings,
 	})
 	sc.unackedSettings++
 
@@ -893,6 +1288,14 @@ func (sc *serverConn) serve() {
 					return
 				case gracefulShutdownMsg:
 					sc.startGracefulShutdownInternal()
+				case drainTimerMsg:
+					if sc.drainFinal {
+						sc.vlogf("http2: drain timeout; closing conn from %v with %d open streams", sc.conn.RemoteAddr(), sc.curOpenStreams())
+						return
+					}
+					sc.drainFinal = true
+					sc.goAway(ErrCodeNo)
+					sc.drainTimer = time.AfterFunc(sc.srv.DrainTimeout, sc.onDrainTimer)
 				default:
 					panic("unknown timer")
 				}
@@ -906,7 +1309,7 @@ func (sc *serverConn) serve() {
 		// If the peer is causing us to generate a lot of control frames,
 		// but not reading them from us, assume they are trying to make us
 		// run out of memory.
-		if sc.queuedControlFrames > sc.srv.maxQueuedControlFrames() {
+		if max := sc.srv.maxQueuedControlFrames(); max > 0 && sc.queuedControlFrames > max {
 			sc.vlogf("http2: too many control frames in send queue, closing connection")
 			return
 		}
@@ -938,11 +1341,13 @@ var (
 	idleTimerMsg        = new(serverMessage)
 	shutdownTimerMsg    = new(serverMessage)
 	gracefulShutdownMsg = new(serverMessage)
+	drainTimerMsg       = new(serverMessage)
 )
 
 func (sc *serverConn) onSettingsTimer() { sc.sendServeMsg(settingsTimerMsg) }
 func (sc *serverConn) onIdleTimer()     { sc.sendServeMsg(idleTimerMsg) }
 func (sc *serverConn) onShutdownTimer() { sc.sendServeMsg(shutdownTimerMsg) }
+func (sc *serverConn) onDrainTimer()    { sc.sendServeMsg(drainTimerMsg) }
 
 func (sc *serverConn) sendServeMsg(msg interface{}) {
 	sc.serveG.checkNotOn() // NOT
@@ -958,6 +1363,9 @@ var errPrefaceTimeout = errors.New("timeout waiting for client preface")
 // returns errPrefaceTimeout on timeout, or an error if the greeting
 // is invalid.
 func (sc *serverConn) readPreface() error {
+	if sc.prefaceRead {
+		return nil
+	}
 	errc := make(chan error, 1)
 	go func() {
 		// Read the client preface
@@ -1095,9 +1503,11 @@ func (sc *serverConn) writeFrame(wr FrameWriteRequest) {
 
 	// Don't send a 100-continue response if we've already sent headers.
 	// See golang.org/issue/14030.
-	switch wr.write.(type) {
+	switch w := wr.write.(type) {
 	case *writeResHeaders:
-		wr.stream.wroteHeaders = true
+		if w.httpResCode < 100 || w.httpResCode > 199 {
+			wr.stream.wroteHeaders = true
+		}
 	case write100ContinueHeadersFrame:
 		if wr.stream.wroteHeaders {
 			// We do not need to notify wr.done because this frame is
@@ -1161,8 +1571,7 @@ func (sc *serverConn) startFrameWrite(wr FrameWriteRequest) {
 	sc.needsFrameFlush = true
 	if wr.write.staysWithinBuffer(sc.bw.Available()) {
 		sc.writingFrameAsync = false
-		err := wr.write.writeFrame(sc)
-		sc.wroteFrame(frameWriteResult{wr: wr, err: err})
+		sc.wroteFrame(sc.writeFrameCounted(wr))
 	} else {
 		sc.writingFrameAsync = true
 		go sc.writeFrameAsync(wr)
@@ -1185,6 +1594,15 @@ func (sc *serverConn) wroteFrame(res frameWriteResult) {
 	sc.writingFrameAsync = false
 
 	wr := res.wr
+	if st := wr.stream; st != nil && res.err == nil {
+		switch w := wr.write.(type) {
+		case *writeData:
+			st.sentBytes += int64(len(w.p))
+			st.dataBytesOut += res.n
+		case *writeResHeaders, write100ContinueHeadersFrame:
+			st.headerBytesOut += res.n
+		}
+	}
 
 	if writeEndsStream(wr.write) {
 		st := wr.stream
@@ -1247,6 +1665,18 @@ func (sc *serverConn) scheduleFrameWrite() {
 	}
 	sc.inFrameScheduleLoop = true
 	for !sc.writingFrameAsync {
+		if sc.needToSendDrainGoAway {
+			sc.needToSendDrainGoAway = false
+			if !sc.inGoAway {
+				sc.startFrameWrite(FrameWriteRequest{
+					write: &writeGoAway{
+						maxStreamID: 1<<31 - 1,
+						code:        ErrCodeNo,
+					},
+				})
+				continue
+			}
+		}
 		if sc.needToSendGoAway {
 			sc.needToSendGoAway = false
 			sc.startFrameWrite(FrameWriteRequest{
@@ -1310,6 +1740,18 @@ func (sc *serverConn) startGracefulShutdown() {
 var goAwayTimeout = 1 * time.Second
 
 func (sc *serverConn) startGracefulShutdownInternal() {
+	if sc.srv.DrainTimeout > 0 && !sc.inGoAway {
+		if sc.drainTimer != nil {
+			return // already draining
+		}
+		// Send a first GOAWAY that accepts all the streams the
+		// client may have opened before receiving it. The final
+		// GOAWAY follows after about a round trip.
+		sc.needToSendDrainGoAway = true
+		sc.drainTimer = time.AfterFunc(goAwayTimeout, sc.onDrainTimer)
+		sc.scheduleFrameWrite()
+		return
+	}
 	sc.goAway(ErrCodeNo)
 }
 
@@ -1499,10 +1941,42 @@ func (sc *serverConn) processResetStream(f *RSTStreamFrame) error {
 	if st != nil {
 		st.cancelCtx()
 		sc.closeStream(st, streamError(f.StreamID, f.ErrCode))
+		if sc.resetLimit != nil && !st.isPushed() && !sc.resetLimit.allow(time.Now()) {
+			sc.countError("reset_flood")
+			return ConnectionError(ErrCodeEnhanceYourCalm)
+		}
 	}
 	return nil
 }
 
+// A resetLimiter is a token bucket limiting the rate at which a client
+// resets streams. It holds up to n tokens, and regains n per minute.
+type resetLimiter struct {
+	max    float64
+	tokens float64
+	last   time.Time // when tokens was last updated
+}
+
+func newResetLimiter(n int, now time.Time) *resetLimiter {
+	return &resetLimiter{max: float64(n), tokens: float64(n), last: now}
+}
+
+// allow takes a token at time now, and reports whether one was available.
+func (l *resetLimiter) allow(now time.Time) bool {
+	if d := now.Sub(l.last); d > 0 {
+		l.tokens += l.max * d.Minutes()
+		if l.tokens > l.max {
+			l.tokens = l.max
+		}
+		l.last = now
+	}
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
 func (sc *serverConn) closeStream(st *stream, err error) {
 	sc.serveG.check()
 	if st.state == stateIdle || st.state == stateClosed {
@@ -1517,6 +1991,7 @@ func (sc *serverConn) closeStream(st *stream, err error) {
 	} else {
 		sc.curClientStreams--
 	}
+	sc.curHeaderBytes -= st.headerBytes
 	delete(sc.streams, st.id)
 	if len(sc.streams) == 0 {
 		sc.setConnState(http.StateIdle)
@@ -1534,8 +2009,48 @@ func (sc *serverConn) closeStream(st *stream, err error) {
 
 		p.CloseWithError(err)
 	}
+	st.closeErr = err
 	st.cw.Close() // signals Handler's CloseNotifier, unblocks writes, etc
 	sc.writeSched.CloseStream(st.id)
+	if f := sc.srv.OnStreamClose; f != nil {
+		info := st.info()
+		info.BytesReceived = st.bodyBytes
+		info.BytesSent = st.sentBytes
+		info.HeaderBytesIn = st.headerBytesIn
+		info.DataBytesIn = st.dataBytesIn
+		info.HeaderBytesOut = st.headerBytesOut
+		info.DataBytesOut = st.dataBytesOut
+		switch err := err.(type) {
+		case StreamError:
+			if err.Code != ErrCodeNo {
+				info.Err = err
+			}
+		default:
+			if err != errHandlerComplete {
+				info.Err = err
+			}
+		}
+		f(st.ctx, info)
+	}
+}
+
+// opened records the method and path of the new stream st and reports it
+// to the Server's OnStreamOpen callback.
+func (sc *serverConn) opened(st *stream, method, path string) {
+	sc.serveG.check()
+	st.method, st.path = method, path
+	if f := sc.srv.OnStreamOpen; f != nil {
+		f(st.ctx, st.info())
+	}
+}
+
+func (st *stream) info() StreamInfo {
+	return StreamInfo{
+		StreamID: st.id,
+		Method:   st.method,
+		Path:     st.path,
+		Pushed:   st.isPushed(),
+	}
 }
 
 func (sc *serverConn) processSettings(f *SettingsFrame) error {
@@ -1588,6 +2103,8 @@ func (sc *serverConn) processSetting(s Setting) error {
 		sc.maxFrameSize = int32(s.Val) // the maximum valid s.Val is < 2^31
 	case SettingMaxHeaderListSize:
 		sc.peerMaxHeaderListSize = s.Val
+	case SettingEnableConnectProtocol:
+		// Only meaningful when sent by a server.
 	default:
 		// Unknown setting: "An endpoint that receives a SETTINGS
 		// frame with any unknown or unsupported identifier MUST
@@ -1595,6 +2112,9 @@ func (sc *serverConn) processSetting(s Setting) error {
 		if VerboseLogs {
 			sc.vlogf("http2: server ignoring unknown setting %v", s)
 		}
+		if fn := sc.srv.OnUnknownSetting; fn != nil {
+			fn(s.ID, s.Val)
+		}
 	}
 	return nil
 }
@@ -1639,6 +2159,9 @@ func (sc *serverConn) processData(f *DataFrame) error {
 	// with a stream error (Section 5.4.2) of type STREAM_CLOSED."
 	id := f.Header().StreamID
 	state, st := sc.state(id)
+	if st != nil {
+		st.dataBytesIn += frameHeaderLen + int64(f.Length)
+	}
 	if id == 0 || state == stateIdle {
 		// Section 5.1: "Receiving any frame other than HEADERS
 		// or PRIORITY on a stream in this state MUST be
@@ -1788,6 +2311,7 @@ func (sc *serverConn) processHeaders(f *MetaHeadersFrame) error {
 	// open, let it process its own HEADERS frame (trailers at this
 	// point, if it's valid).
 	if st := sc.streams[f.StreamID]; st != nil {
+		st.headerBytesIn += f.wireLen
 		if st.resetQueued {
 			// We're sending RST_STREAM to close the stream, so don't bother
 			// processing this frame.
@@ -1836,11 +2360,25 @@ func (sc *serverConn) processHeaders(f *MetaHeadersFrame) error {
 		return streamError(id, ErrCodeRefusedStream)
 	}
 
+	var headerBytes int64
+	for _, hf := range f.Fields {
+		headerBytes += int64(hf.Size())
+	}
+	if max := sc.srv.MaxConnHeaderBytes; max > 0 && sc.curHeaderBytes+headerBytes > max {
+		sc.vlogf("http2: server refusing stream %d from %v: open streams' headers would exceed %d bytes", id, sc.conn.RemoteAddr(), max)
+		sc.countError("conn_header_bytes")
+		return streamError(id, ErrCodeRefusedStream)
+	}
+
 	initialState := stateOpen
 	if f.StreamEnded() {
 		initialState = stateHalfClosedRemote
 	}
 	st := sc.newStream(id, 0, initialState)
+	st.headerBytes = headerBytes
+	st.headerBytesIn = f.wireLen
+	sc.curHeaderBytes += headerBytes
+	sc.opened(st, f.PseudoValue("method"), f.PseudoValue("path"))
 
 	if f.HasPriority() {
 		if err := checkPriority(f.StreamID, f.Priority); err != nil {
@@ -1863,6 +2401,8 @@ func (sc *serverConn) processHeaders(f *MetaHeadersFrame) error {
 	handler := sc.handler.ServeHTTP
 	if f.Truncated {
 		// Their header list was too long. Send a 431 error.
+		sc.vlogf("http2: server rejecting stream %d from %v: header list exceeds %d bytes", id, sc.conn.RemoteAddr(), sc.maxHeaderListSize())
+		sc.countError("header_list_too_large")
 		handler = handleHeaderListTooLong
 	} else if err := checkValidHTTP2RequestHeaders(req.Header); err != nil {
 		handler = new400Handler(err)
@@ -1941,7 +2481,8 @@ func (sc *serverConn) newStream(id, pusherID uint32, state streamState) *stream
 		panic("internal error: cannot create stream with id 0")
 	}
 
-	ctx, cancelCtx := context.WithCancel(sc.baseCtx)
+	ctx := context.WithValue(sc.baseCtx, PeerSettingsContextKey, sc.peerSettings())
+	ctx, cancelCtx := context.WithCancel(ctx)
 	st := &stream{
 		sc:        sc,
 		id:        id,
@@ -1980,9 +2521,20 @@ func (sc *serverConn) newWriterAndRequest(st *stream, f *MetaHeadersFrame) (*res
 		scheme:    f.PseudoValue("scheme"),
 		authority: f.PseudoValue("authority"),
 		path:      f.PseudoValue("path"),
+		protocol:  f.PseudoValue("protocol"),
+	}
+
+	if rp.protocol != "" {
+		// RFC 8441 Section 4: the :protocol pseudo-header is only
+		// valid on CONNECT requests, and only if the server sent
+		// SETTINGS_ENABLE_CONNECT_PROTOCOL. An extended CONNECT
+		// request otherwise carries all the regular pseudo-headers.
+		if !sc.srv.EnableExtendedConnect || rp.method != "CONNECT" || rp.authority == "" {
+			return nil, nil, streamError(f.StreamID, ErrCodeProtocol)
+		}
 	}
 
-	isConnect := rp.method == "CONNECT"
+	isConnect := rp.method == "CONNECT" && rp.protocol == ""
 	if isConnect {
 		if rp.path != "" || rp.scheme != "" || rp.authority == "" {
 			return nil, nil, streamError(f.StreamID, ErrCodeProtocol)
@@ -2030,7 +2582,7 @@ func (sc *serverConn) newWriterAndRequest(st *stream, f *MetaHeadersFrame) (*res
 			req.ContentLength = -1
 		}
 		req.Body.(*requestBody).pipe = &pipe{
-			b: &dataBuffer{expected: req.ContentLength},
+			b: &dataBuffer{expected: req.ContentLength, pool: sc.srv.DataBufferPool},
 		}
 	}
 	return rw, req, nil
@@ -2039,6 +2591,7 @@ func (sc *serverConn) newWriterAndRequest(st *stream, f *MetaHeadersFrame) (*res
 type requestParam struct {
 	method                  string
 	scheme, authority, path string
+	protocol                string // :protocol of an extended CONNECT request
 	header                  http.Header
 }
 
@@ -2050,7 +2603,7 @@ func (sc *serverConn) newWriterAndRequestNoBody(st *stream, rp requestParam) (*r
 		tlsState = sc.tlsState
 	}
 
-	needsContinue := rp.header.Get("Expect") == "100-continue"
+	needsContinue := rp.header.Get("Expect") == "100-continue" && !sc.srv.DisableExpectContinueHandling
 	if needsContinue {
 		rp.header.Del("Expect")
 	}
@@ -2078,9 +2631,13 @@ func (sc *serverConn) newWriterAndRequestNoBody(st *stream, rp requestParam) (*r
 	}
 	delete(rp.header, "Trailer")
 
+	if rp.protocol != "" {
+		rp.header.Set(":protocol", rp.protocol)
+	}
+
 	var url_ *url.URL
 	var requestURI string
-	if rp.method == "CONNECT" {
+	if rp.method == "CONNECT" && rp.protocol == "" {
 		url_ = &url.URL{Host: rp.authority}
 		requestURI = rp.authority // mimic HTTP/1 server behavior
 	} else {
@@ -2635,6 +3192,26 @@ func (w *responseWriter) WriteHeader(code int) {
 func (rws *responseWriterState) writeHeader(code int) {
 	if !rws.wroteHeader {
 		checkWriteHeaderCode(code)
+		if code >= 100 && code <= 199 && rws.conn.srv.DisableExpectContinueHandling {
+			// Informational responses are sent at once and may
+			// be followed by others before the final response.
+			// RFC 7540 Section 8.1.1 forbids 101 (Switching
+			// Protocols) in HTTP/2.
+			if code == http.StatusSwitchingProtocols {
+				panic("WriteHeader called with status 101 over HTTP/2")
+			}
+			h := rws.handlerHeader
+			if _, ok := h["Content-Length"]; ok {
+				h = cloneHeader(h)
+				h.Del("Content-Length")
+			}
+			rws.conn.writeHeaders(rws.stream, &writeResHeaders{
+				streamID:    rws.stream.id,
+				httpResCode: code,
+				h:           h,
+			})
+			return
+		}
 		rws.wroteHeader = true
 		rws.status = code
 		if len(rws.handlerHeader) > 0 {
@@ -2720,6 +3297,112 @@ var (
 var _ http.Pusher = (*responseWriter)(nil)
 
 func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	var o PushStreamOptions
+	if opts != nil {
+		o.Method, o.Header = opts.Method, opts.Header
+	}
+	_, err := w.push(target, &o)
+	return err
+}
+
+// A StreamPusher is implemented by the http.ResponseWriter passed to the
+// handlers of a Server. It gives more control over server push than
+// http.Pusher.
+type StreamPusher interface {
+	// PushStream initiates an HTTP/2 server push of target, as
+	// http.Pusher.Push does, and returns the promised stream once
+	// its PUSH_PROMISE frame has been written. If opts is nil, the
+	// default options are used.
+	PushStream(target string, opts *PushStreamOptions) (*PushedStream, error)
+}
+
+var _ StreamPusher = (*responseWriter)(nil)
+
+// PushStreamOptions describes the promised request of a PushStream call.
+type PushStreamOptions struct {
+	// Method specifies the method of the promised request.
+	// If empty, "GET" is used.
+	Method string
+
+	// Header specifies additional headers of the promised request.
+	// They cannot include pseudo headers, nor headers that are only
+	// meaningful for requests with a body.
+	Header http.Header
+
+	// ValidMethod, if non-nil, reports whether Method may be
+	// promised. It replaces the default check, which allows only GET
+	// and HEAD, the methods RFC 7540 Section 8.2 permits.
+	ValidMethod func(method string) bool
+}
+
+// A PushedStream is a stream promised by StreamPusher.PushStream.
+// Its methods may be called concurrently.
+type PushedStream struct {
+	// StreamID is the identifier of the promised stream.
+	StreamID uint32
+
+	sc *serverConn
+	st *stream
+}
+
+// Done returns a channel that is closed when the promised stream is
+// closed, either because its response was sent or because it was reset.
+func (p *PushedStream) Done() <-chan struct{} {
+	return p.st.cw
+}
+
+// Err returns nil while the promised stream is open or if its response
+// was sent in full. Otherwise it returns the reason the stream was
+// closed, which is a StreamError when the stream was reset by the
+// client, for instance with ErrCodeRefusedStream or ErrCodeCancel, or
+// by Cancel.
+func (p *PushedStream) Err() error {
+	select {
+	case <-p.st.cw:
+	default:
+		return nil
+	}
+	switch err := p.st.closeErr.(type) {
+	case StreamError:
+		if err.Code == ErrCodeNo {
+			return nil
+		}
+		return err
+	default:
+		if err == errHandlerComplete {
+			return nil
+		}
+		return err
+	}
+}
+
+// Cancel resets the promised stream with ErrCodeCancel, if it is still
+// open, and cancels the context of the request serving it.
+func (p *PushedStream) Cancel() {
+	select {
+	case <-p.st.cw:
+		return
+	default:
+	}
+	p.st.cancelCtx()
+	p.sc.writeFrameFromHandler(FrameWriteRequest{write: streamError(p.StreamID, ErrCodeCancel)})
+}
+
+func (w *responseWriter) PushStream(target string, opts *PushStreamOptions) (*PushedStream, error) {
+	if opts == nil {
+		opts = new(PushStreamOptions)
+	}
+	o := *opts // do not modify the caller's options
+	st, err := w.push(target, &o)
+	if err != nil {
+		return nil, err
+	}
+	return &PushedStream{StreamID: st.id, sc: st.sc, st: st}, nil
+}
+
+// push initiates a push of target and returns the promised stream once
+// its PUSH_PROMISE frame is written. It fills in the defaults of opts.
+func (w *responseWriter) push(target string, opts *PushStreamOptions) (*stream, error) {
 	st := w.rws.stream
 	sc := st.sc
 	sc.serveG.checkNotOn()
@@ -2727,11 +3410,7 @@ func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
 	// No recursive pushes: "PUSH_PROMISE frames MUST only be sent on a peer-initiated stream."
 	// http://tools.ietf.org/html/rfc7540#section-6.6
 	if st.isPushed() {
-		return ErrRecursivePush
-	}
-
-	if opts == nil {
-		opts = new(http.PushOptions)
+		return nil, ErrRecursivePush
 	}
 
 	// Default options.
@@ -2749,25 +3428,25 @@ func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
 	// Validate the request.
 	u, err := url.Parse(target)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if u.Scheme == "" {
 		if !strings.HasPrefix(target, "/") {
-			return fmt.Errorf("target must be an absolute URL or an absolute path: %q", target)
+			return nil, fmt.Errorf("target must be an absolute URL or an absolute path: %q", target)
 		}
 		u.Scheme = wantScheme
 		u.Host = w.rws.req.Host
 	} else {
 		if u.Scheme != wantScheme {
-			return fmt.Errorf("cannot push URL with scheme %q from request with scheme %q", u.Scheme, wantScheme)
+			return nil, fmt.Errorf("cannot push URL with scheme %q from request with scheme %q", u.Scheme, wantScheme)
 		}
 		if u.Host == "" {
-			return errors.New("URL must have a host")
+			return nil, errors.New("URL must have a host")
 		}
 	}
 	for k := range opts.Header {
 		if strings.HasPrefix(k, ":") {
-			return fmt.Errorf("promised request headers cannot include pseudo header %q", k)
+			return nil, fmt.Errorf("promised request headers cannot include pseudo header %q", k)
 		}
 		// These headers are meaningful only if the request has a body,
 		// but PUSH_PROMISE requests cannot have a body.
@@ -2775,18 +3454,22 @@ func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
 		// Also disallow Host, since the promised URL must be absolute.
 		switch strings.ToLower(k) {
 		case "content-length", "content-encoding", "trailer", "te", "expect", "host":
-			return fmt.Errorf("promised request headers cannot include %q", k)
+			return nil, fmt.Errorf("promised request headers cannot include %q", k)
 		}
 	}
 	if err := checkValidHTTP2RequestHeaders(opts.Header); err != nil {
-		return err
+		return nil, err
 	}
 
-	// The RFC effectively limits promised requests to GET and HEAD:
-	// "Promised requests MUST be cacheable [GET, HEAD, or POST], and MUST be safe [GET or HEAD]"
-	// http://tools.ietf.org/html/rfc7540#section-8.2
-	if opts.Method != "GET" && opts.Method != "HEAD" {
-		return fmt.Errorf("method %q must be GET or HEAD", opts.Method)
+	if opts.ValidMethod != nil {
+		if !opts.ValidMethod(opts.Method) {
+			return nil, fmt.Errorf("method %q cannot be promised", opts.Method)
+		}
+	} else if opts.Method != "GET" && opts.Method != "HEAD" {
+		// The RFC effectively limits promised requests to GET and HEAD:
+		// "Promised requests MUST be cacheable [GET, HEAD, or POST], and MUST be safe [GET or HEAD]"
+		// http://tools.ietf.org/html/rfc7540#section-8.2
+		return nil, fmt.Errorf("method %q must be GET or HEAD", opts.Method)
 	}
 
 	msg := &startPushRequest{
@@ -2799,20 +3482,23 @@ func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
 
 	select {
 	case <-sc.doneServing:
-		return errClientDisconnected
+		return nil, errClientDisconnected
 	case <-st.cw:
-		return errStreamClosed
+		return nil, errStreamClosed
 	case sc.serveMsgCh <- msg:
 	}
 
 	select {
 	case <-sc.doneServing:
-		return errClientDisconnected
+		return nil, errClientDisconnected
 	case <-st.cw:
-		return errStreamClosed
+		return nil, errStreamClosed
 	case err := <-msg.done:
 		errChanPool.Put(msg.done)
-		return err
+		if err != nil {
+			return nil, err
+		}
+		return msg.promised, nil
 	}
 }
 
@@ -2822,6 +3508,8 @@ type startPushRequest struct {
 	url    *url.URL
 	header http.Header
 	done   chan error
+
+	promised *stream // set by the serve goroutine once the PUSH_PROMISE is written
 }
 
 func (sc *serverConn) startPush(msg *startPushRequest) {
@@ -2875,6 +3563,8 @@ func (sc *serverConn) startPush(msg *startPushRequest) {
 		// we start in "half closed (remote)" for simplicity.
 		// See further comments at the definition of stateHalfClosedRemote.
 		promised := sc.newStream(promisedID, msg.parent.id, stateHalfClosedRemote)
+		msg.promised = promised
+		sc.opened(promised, msg.method, msg.url.RequestURI())
 		rw, req, err := sc.newWriterAndRequestNoBody(promised, requestParam{
 			method:    msg.method,
 			scheme:    msg.url.Scheme,
@@ -517,3 +517,103 @@ func TestServer_Push_RejectAfterGoAway(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestServer_PushStream_ClientReset(t *testing.T) {
+	errc := make(chan error, 1)
+	st := newServerTester(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/pushed" {
+			<-r.Context().Done()
+			return
+		}
+		p, err := w.(StreamPusher).PushStream("/pushed", nil)
+		if err != nil {
+			errc <- err
+			return
+		}
+		if p.StreamID != 2 {
+			errc <- fmt.Errorf("promised stream ID = %d, want 2", p.StreamID)
+			return
+		}
+		select {
+		case <-p.Done():
+		case <-time.After(5 * time.Second):
+			errc <- errors.New("timeout waiting for promised stream to be reset")
+			return
+		}
+		if got, want := p.Err(), streamError(2, ErrCodeRefusedStream); got != want {
+			errc <- fmt.Errorf("Err() = %v, want %v", got, want)
+			return
+		}
+		errc <- nil
+	})
+	defer st.Close()
+	st.greet()
+	getSlash(st)
+	st.wantPushPromise()
+	if err := st.fr.WriteRSTStream(2, ErrCodeRefusedStream); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestServer_PushStream_Cancel(t *testing.T) {
+	errc := make(chan error, 1)
+	st := newServerTester(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/pushed" {
+			<-r.Context().Done()
+			return
+		}
+		p, err := w.(StreamPusher).PushStream("/pushed", nil)
+		if err != nil {
+			errc <- err
+			return
+		}
+		p.Cancel()
+		<-p.Done()
+		if got, want := p.Err(), streamError(2, ErrCodeCancel); got != want {
+			errc <- fmt.Errorf("Err() = %v, want %v", got, want)
+			return
+		}
+		errc <- nil
+	})
+	defer st.Close()
+	st.greet()
+	getSlash(st)
+	st.wantPushPromise()
+	st.wantRSTStream(2, ErrCodeCancel)
+	if err := <-errc; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestServer_PushStream_ValidMethod(t *testing.T) {
+	methodc := make(chan string, 1)
+	errc := make(chan error, 1)
+	st := newServerTester(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/pushed" {
+			methodc <- r.Method
+			return
+		}
+		pusher := w.(StreamPusher)
+		if _, err := pusher.PushStream("/pushed", &PushStreamOptions{Method: "POST"}); err == nil {
+			errc <- errors.New("PushStream with POST succeeded without ValidMethod")
+			return
+		}
+		_, err := pusher.PushStream("/pushed", &PushStreamOptions{
+			Method:      "POST",
+			ValidMethod: func(method string) bool { return method == "POST" },
+		})
+		errc <- err
+	})
+	defer st.Close()
+	st.greet()
+	getSlash(st)
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if got := <-methodc; got != "POST" {
+		t.Errorf("promised request method = %q, want POST", got)
+	}
+}
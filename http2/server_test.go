@@ -5,6 +5,7 @@
 package http2
 
 import (
+	"bufio"
 	"bytes"
 	"compress/gzip"
 	"compress/zlib"
@@ -16,6 +17,7 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
+	"math"
 	"net"
 	"net/http"
 	"net/http/httptest"
@@ -682,6 +684,40 @@ func TestServer_Request_Get(t *testing.T) {
 	})
 }
 
+func TestServer_Request_PeerSettings(t *testing.T) {
+	testServerRequest(t, func(st *serverTester) {
+		if err := st.fr.WriteSettings(
+			Setting{SettingMaxFrameSize, 32 << 10},
+			Setting{SettingInitialWindowSize, 1000},
+			Setting{SettingEnablePush, 0},
+		); err != nil {
+			t.Fatal(err)
+		}
+		st.wantSettingsAck()
+		st.writeHeaders(HeadersFrameParam{
+			StreamID:      1,
+			BlockFragment: st.encodeHeader(),
+			EndStream:     true,
+			EndHeaders:    true,
+		})
+	}, func(r *http.Request) {
+		got, ok := PeerSettingsFromContext(r.Context())
+		if !ok {
+			t.Fatal("PeerSettingsFromContext returned false")
+		}
+		want := PeerSettings{
+			HeaderTableSize:      initialHeaderTableSize,
+			EnablePush:           false,
+			MaxConcurrentStreams: math.MaxUint32,
+			InitialWindowSize:    1000,
+			MaxFrameSize:         32 << 10,
+		}
+		if got != want {
+			t.Errorf("PeerSettingsFromContext = %+v; want %+v", got, want)
+		}
+	})
+}
+
 func TestServer_Request_Get_PathSlashes(t *testing.T) {
 	testServerRequest(t, func(st *serverTester) {
 		st.writeHeaders(HeadersFrameParam{
@@ -812,6 +848,48 @@ func TestServer_Request_Post_Body_ContentLength_TooSmall(t *testing.T) {
 		})
 }
 
+func TestServer_Request_Post_Body_DataBufferPool(t *testing.T) {
+	pool := new(countingBufferPool)
+	content := strings.Repeat("a", 40<<10)
+	gotBody := make(chan string, 1)
+	st := newServerTester(t, func(w http.ResponseWriter, r *http.Request) {
+		all, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		gotBody <- string(all)
+	}, func(s *Server) {
+		s.DataBufferPool = pool
+	})
+	defer st.Close()
+
+	st.greet()
+	st.writeHeaders(HeadersFrameParam{
+		StreamID:      1, // clients send odd numbers
+		BlockFragment: st.encodeHeader(":method", "POST"),
+		EndStream:     false, // to say DATA frames are coming
+		EndHeaders:    true,
+	})
+	for i := 0; i < len(content); i += 16 << 10 {
+		end := i + 16<<10
+		if end > len(content) {
+			end = len(content)
+		}
+		st.writeData(1, end == len(content), []byte(content[i:end]))
+	}
+	select {
+	case got := <-gotBody:
+		if got != content {
+			t.Errorf("handler read %d bytes; want %d", len(got), len(content))
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for request")
+	}
+	if gets, puts := pool.counts(); gets == 0 || puts != gets {
+		t.Errorf("pool Get called %v times and Put %v times; want the same non-zero count", gets, puts)
+	}
+}
+
 func testBodyContents(t *testing.T, wantContentLength int64, wantBody string, write func(st *serverTester)) {
 	testServerRequest(t, write, func(r *http.Request) {
 		if r.Method != "POST" {
@@ -1136,6 +1214,122 @@ func TestServer_Request_Connect_InvalidScheme(t *testing.T) {
 	})
 }
 
+func TestServer_Request_ExtendedConnect(t *testing.T) {
+	gotReq := make(chan bool, 1)
+	st := newServerTester(t, func(w http.ResponseWriter, r *http.Request) {
+		if g, w := r.Method, "CONNECT"; g != w {
+			t.Errorf("Method = %q; want %q", g, w)
+		}
+		if g, w := r.Header.Get(":protocol"), "websocket"; g != w {
+			t.Errorf(":protocol = %q; want %q", g, w)
+		}
+		if g, w := r.URL.Path, "/chat"; g != w {
+			t.Errorf("URL.Path = %q; want %q", g, w)
+		}
+		if g, w := r.Host, "example.com"; g != w {
+			t.Errorf("Host = %q; want %q", g, w)
+		}
+		gotReq <- true
+	}, func(s *Server) {
+		s.EnableExtendedConnect = true
+	})
+	defer st.Close()
+
+	var advertised bool
+	st.greetAndCheckSettings(func(s Setting) error {
+		if s.ID == SettingEnableConnectProtocol && s.Val == 1 {
+			advertised = true
+		}
+		return nil
+	})
+	if !advertised {
+		t.Errorf("server didn't advertise SETTINGS_ENABLE_CONNECT_PROTOCOL")
+	}
+	st.writeHeaders(HeadersFrameParam{
+		StreamID: 1,
+		BlockFragment: st.encodeHeaderRaw(
+			":method", "CONNECT",
+			":protocol", "websocket",
+			":scheme", "https",
+			":path", "/chat",
+			":authority", "example.com",
+		),
+		EndStream:  false,
+		EndHeaders: true,
+	})
+	select {
+	case <-gotReq:
+	case <-time.After(2 * time.Second):
+		t.Error("timeout waiting for request")
+	}
+}
+
+func TestServer_Request_ExtendedConnect_NotEnabled(t *testing.T) {
+	testServerRejectsStream(t, ErrCodeProtocol, func(st *serverTester) {
+		st.writeHeaders(HeadersFrameParam{
+			StreamID: 1,
+			BlockFragment: st.encodeHeaderRaw(
+				":method", "CONNECT",
+				":protocol", "websocket",
+				":scheme", "https",
+				":path", "/chat",
+				":authority", "example.com",
+			),
+			EndStream:  true,
+			EndHeaders: true,
+		})
+	})
+}
+
+func TestServer_ExtraSettings(t *testing.T) {
+	type setting struct {
+		id  SettingID
+		val uint32
+	}
+	gotUnknown := make(chan setting, 1)
+	st := newServerTester(t, nil, func(s *Server) {
+		s.ExtraSettings = []Setting{
+			{ID: 0xf000, Val: 42},
+			{ID: SettingMaxFrameSize, Val: 1 << 20}, // ignored: managed by the Server
+		}
+		s.OnUnknownSetting = func(id SettingID, val uint32) {
+			gotUnknown <- setting{id, val}
+		}
+	})
+	defer st.Close()
+
+	var gotExtra bool
+	var maxFrameSizes int
+	st.greetAndCheckSettings(func(s Setting) error {
+		switch s.ID {
+		case 0xf000:
+			gotExtra = s.Val == 42
+		case SettingMaxFrameSize:
+			maxFrameSizes++
+		}
+		return nil
+	})
+	if !gotExtra {
+		t.Errorf("server didn't send extra setting")
+	}
+	if maxFrameSizes != 1 {
+		t.Errorf("server sent %d MAX_FRAME_SIZE settings; want 1", maxFrameSizes)
+	}
+
+	if err := st.fr.WriteSettings(Setting{ID: 0xf001, Val: 7}); err != nil {
+		t.Fatal(err)
+	}
+	st.wantSettingsAck()
+	select {
+	case got := <-gotUnknown:
+		if want := (setting{0xf001, 7}); got != want {
+			t.Errorf("OnUnknownSetting called with %v; want %v", got, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("OnUnknownSetting not called")
+	}
+}
+
 func TestServer_Ping(t *testing.T) {
 	st := newServerTester(t, nil)
 	defer st.Close()
@@ -1167,13 +1361,24 @@ func TestServer_MaxQueuedControlFrames(t *testing.T) {
 		t.Skip("skipping in short mode")
 	}
 
-	st := newServerTester(t, nil)
+	for _, max := range []int{0, 100} {
+		t.Run(fmt.Sprint(max), func(t *testing.T) {
+			testServerMaxQueuedControlFrames(t, max)
+		})
+	}
+}
+
+func testServerMaxQueuedControlFrames(t *testing.T, max int) {
+	st := newServerTester(t, nil, func(s *Server) {
+		s.MaxQueuedControlFrames = max
+	})
 	defer st.Close()
 	st.greet()
 
 	const extraPings = 500000 // enough to fill the TCP buffers
 
-	for i := 0; i < maxQueuedControlFrames+extraPings; i++ {
+	limit := (&Server{MaxQueuedControlFrames: max}).maxQueuedControlFrames()
+	for i := 0; i < limit+extraPings; i++ {
 		pingData := [8]byte{1, 2, 3, 4, 5, 6, 7, 8}
 		if err := st.fr.WritePing(false, pingData); err != nil {
 			if i == 0 {
@@ -1422,6 +1627,352 @@ func testServer_RSTStream_Unblocks_Header_Write(t *testing.T) {
 	unblockHandler <- true
 }
 
+func TestServer_RSTStream_Flood(t *testing.T) {
+	var errTypes []string
+	st := newServerTester(t, func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}, func(s *Server) {
+		s.MaxResetStreamsPerMinute = 3
+		s.CountError = func(errType string) { errTypes = append(errTypes, errType) }
+	})
+	defer st.Close()
+
+	st.greet()
+	for id := uint32(1); id <= 7; id += 2 {
+		st.writeHeaders(HeadersFrameParam{
+			StreamID:      id,
+			BlockFragment: st.encodeHeader(":method", "POST"),
+			EndStream:     false, // keep it open
+			EndHeaders:    true,
+		})
+		if err := st.fr.WriteRSTStream(id, ErrCodeCancel); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gf := st.wantGoAway()
+	if gf.ErrCode != ErrCodeEnhanceYourCalm {
+		t.Errorf("GOAWAY error = %v; want %v", gf.ErrCode, ErrCodeEnhanceYourCalm)
+	}
+	st.awaitIdle()
+	if len(errTypes) != 1 || errTypes[0] != "reset_flood" {
+		t.Errorf("CountError calls = %q; want [reset_flood]", errTypes)
+	}
+}
+
+func TestServer_ContinuationFlood(t *testing.T) {
+	st := newServerTester(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler called for endless header block")
+	}, func(s *Server) {
+		s.MaxContinuationFrames = 3
+	})
+	defer st.Close()
+
+	st.greet()
+	st.writeHeaders(HeadersFrameParam{
+		StreamID:      1,
+		BlockFragment: st.encodeHeader(),
+		EndStream:     true,
+		EndHeaders:    false,
+	})
+	// Each CONTINUATION frame is tiny, so the header block stays far
+	// below the header list size limit.
+	for i := 0; i < 4; i++ {
+		if err := st.fr.WriteContinuation(1, false, encodeHeaderNoImplicit(t, "foo", "bar")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gf := st.wantGoAway()
+	if gf.ErrCode != ErrCodeEnhanceYourCalm {
+		t.Errorf("GOAWAY error = %v; want %v", gf.ErrCode, ErrCodeEnhanceYourCalm)
+	}
+}
+
+func TestServer_MaxConnHeaderBytes(t *testing.T) {
+	release := make(chan struct{})
+	var errTypes []string
+	st := newServerTester(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/block" {
+			<-release
+		}
+	}, func(s *Server) {
+		s.MaxConnHeaderBytes = 2000
+		s.CountError = func(errType string) {
+			errTypes = append(errTypes, errType)
+		}
+	})
+	defer st.Close()
+
+	big := strings.Repeat("a", 1000)
+	st.greet()
+	st.writeHeaders(HeadersFrameParam{
+		StreamID:      1,
+		BlockFragment: st.encodeHeader(":path", "/block", "x-big", big),
+		EndStream:     true,
+		EndHeaders:    true,
+	})
+	// Together with stream 1's, these headers exceed the limit.
+	st.writeHeaders(HeadersFrameParam{
+		StreamID:      3,
+		BlockFragment: st.encodeHeader("x-big", big),
+		EndStream:     true,
+		EndHeaders:    true,
+	})
+	st.wantRSTStream(3, ErrCodeRefusedStream)
+	if want := []string{"conn_header_bytes"}; !reflect.DeepEqual(errTypes, want) {
+		t.Errorf("counted errors %q; want %q", errTypes, want)
+	}
+
+	// Once stream 1 is done, its header bytes are released.
+	close(release)
+	if hf := st.wantHeaders(); hf.StreamID != 1 {
+		t.Fatalf("got HEADERS for stream %v; want 1", hf.StreamID)
+	}
+	st.writeHeaders(HeadersFrameParam{
+		StreamID:      5,
+		BlockFragment: st.encodeHeader("x-big", big),
+		EndStream:     true,
+		EndHeaders:    true,
+	})
+	if hf := st.wantHeaders(); hf.StreamID != 5 {
+		t.Fatalf("got HEADERS for stream %v; want 5", hf.StreamID)
+	}
+}
+
+func TestServer_StreamCallbacks(t *testing.T) {
+	type event struct {
+		open bool
+		info StreamInfo
+		ok   bool // whether ctx carries the peer settings
+	}
+	events := make(chan event, 10)
+	st := newServerTester(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" && r.URL.Path == "/upload" {
+			ioutil.ReadAll(r.Body)
+			io.WriteString(w, "hello")
+			return
+		}
+		<-r.Context().Done()
+	}, func(s *Server) {
+		s.OnStreamOpen = func(ctx context.Context, info StreamInfo) {
+			_, ok := PeerSettingsFromContext(ctx)
+			events <- event{true, info, ok}
+		}
+		s.OnStreamClose = func(ctx context.Context, info StreamInfo) {
+			_, ok := PeerSettingsFromContext(ctx)
+			events <- event{false, info, ok}
+		}
+	})
+	defer st.Close()
+	st.greet()
+
+	wantEvent := func(want event) {
+		t.Helper()
+		got := <-events
+		if !got.ok {
+			t.Errorf("stream %d: context lacks peer settings", got.info.StreamID)
+		}
+		got.ok = false
+		// Frame byte counts are checked by TestServer_StreamCallbacksFrameBytes.
+		got.info.HeaderBytesIn, got.info.DataBytesIn = 0, 0
+		got.info.HeaderBytesOut, got.info.DataBytesOut = 0, 0
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got event %+v; want %+v", got, want)
+		}
+	}
+
+	// A request that completes normally.
+	st.writeHeaders(HeadersFrameParam{
+		StreamID:      1,
+		BlockFragment: st.encodeHeader(":method", "POST", ":path", "/upload"),
+		EndHeaders:    true,
+	})
+	wantEvent(event{open: true, info: StreamInfo{StreamID: 1, Method: "POST", Path: "/upload"}})
+	st.writeData(1, true, []byte("abc"))
+	st.wantWindowUpdate(0, 3)
+	st.wantHeaders()
+	st.wantData()
+	wantEvent(event{info: StreamInfo{StreamID: 1, Method: "POST", Path: "/upload", BytesReceived: 3, BytesSent: 5}})
+
+	// A request reset by the client.
+	st.writeHeaders(HeadersFrameParam{
+		StreamID:      3,
+		BlockFragment: st.encodeHeader(":path", "/wait"),
+		EndStream:     true,
+		EndHeaders:    true,
+	})
+	wantEvent(event{open: true, info: StreamInfo{StreamID: 3, Method: "GET", Path: "/wait"}})
+	if err := st.fr.WriteRSTStream(3, ErrCodeCancel); err != nil {
+		t.Fatal(err)
+	}
+	wantEvent(event{info: StreamInfo{StreamID: 3, Method: "GET", Path: "/wait", Err: streamError(3, ErrCodeCancel)}})
+
+	// A request rejected before reaching the handler.
+	st.writeHeaders(HeadersFrameParam{
+		StreamID:      5,
+		BlockFragment: st.encodeHeaderRaw(":method", "GET", ":scheme", "https", ":authority", "example.com"),
+		EndStream:     true,
+		EndHeaders:    true,
+	})
+	wantEvent(event{open: true, info: StreamInfo{StreamID: 5, Method: "GET"}})
+	st.wantRSTStream(5, ErrCodeProtocol)
+	wantEvent(event{info: StreamInfo{StreamID: 5, Method: "GET", Err: streamError(5, ErrCodeProtocol)}})
+}
+
+func TestServer_StreamCallbacksFrameBytes(t *testing.T) {
+	infoc := make(chan StreamInfo, 10)
+	st := newServerTester(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/upload" {
+			ioutil.ReadAll(r.Body)
+			io.WriteString(w, "hello")
+			return
+		}
+		<-r.Context().Done()
+	}, func(s *Server) {
+		s.OnStreamClose = func(ctx context.Context, info StreamInfo) {
+			infoc <- info
+		}
+	})
+	defer st.Close()
+	st.greet()
+
+	// A request whose header block spans a padded HEADERS frame and a
+	// CONTINUATION frame, with a padded body.
+	hbf := st.encodeHeader(":method", "POST", ":path", "/upload")
+	st.writeHeaders(HeadersFrameParam{
+		StreamID:      1,
+		BlockFragment: hbf[:2],
+		PadLength:     4,
+	})
+	if err := st.fr.WriteContinuation(1, true, hbf[2:]); err != nil {
+		t.Fatal(err)
+	}
+	st.writeDataPadded(1, true, []byte("abc"), make([]byte, 2))
+	var headerBytesOut, dataBytesOut int64
+	for {
+		f, err := st.readFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch f := f.(type) {
+		case *HeadersFrame:
+			headerBytesOut += frameHeaderLen + int64(f.Length)
+		case *DataFrame:
+			dataBytesOut += frameHeaderLen + int64(f.Length)
+		}
+		if f.Header().Flags.Has(FlagDataEndStream) && f.Header().StreamID == 1 {
+			break
+		}
+	}
+	info := <-infoc
+	want := StreamInfo{
+		StreamID:       1,
+		Method:         "POST",
+		Path:           "/upload",
+		BytesReceived:  3,
+		BytesSent:      5,
+		HeaderBytesIn:  2*frameHeaderLen + 1 + 4 + int64(len(hbf)),
+		DataBytesIn:    frameHeaderLen + 1 + 3 + 2,
+		HeaderBytesOut: headerBytesOut,
+		DataBytesOut:   dataBytesOut,
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("got %+v; want %+v", info, want)
+	}
+
+	// DATA frames on a stream the client then resets are counted.
+	hbf = st.encodeHeader(":method", "POST", ":path", "/wait")
+	st.writeHeaders(HeadersFrameParam{
+		StreamID:      3,
+		BlockFragment: hbf,
+		EndHeaders:    true,
+	})
+	st.writeData(3, false, []byte("abcdef"))
+	if err := st.fr.WriteRSTStream(3, ErrCodeCancel); err != nil {
+		t.Fatal(err)
+	}
+	info = <-infoc
+	if want := frameHeaderLen + int64(len(hbf)); info.HeaderBytesIn != want {
+		t.Errorf("reset stream HeaderBytesIn = %d; want %d", info.HeaderBytesIn, want)
+	}
+	if want := int64(frameHeaderLen + 6); info.DataBytesIn != want {
+		t.Errorf("reset stream DataBytesIn = %d; want %d", info.DataBytesIn, want)
+	}
+	if info.HeaderBytesOut != 0 || info.DataBytesOut != 0 {
+		t.Errorf("reset stream wrote %d header bytes and %d data bytes; want none", info.HeaderBytesOut, info.DataBytesOut)
+	}
+}
+
+// countingWriteScheduler is a WriteScheduler that counts the streams
+// it opens and the frames it pops.
+type countingWriteScheduler struct {
+	WriteScheduler
+	opened, popped int32
+}
+
+func (ws *countingWriteScheduler) OpenStream(streamID uint32, options OpenStreamOptions) {
+	atomic.AddInt32(&ws.opened, 1)
+	ws.WriteScheduler.OpenStream(streamID, options)
+}
+
+func (ws *countingWriteScheduler) Pop() (FrameWriteRequest, bool) {
+	wr, ok := ws.WriteScheduler.Pop()
+	if ok {
+		atomic.AddInt32(&ws.popped, 1)
+	}
+	return wr, ok
+}
+
+func TestServer_NewWriteScheduler(t *testing.T) {
+	ws := &countingWriteScheduler{WriteScheduler: NewRandomWriteScheduler()}
+	st := newServerTester(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello")
+	}, func(s *Server) {
+		s.NewWriteScheduler = func() WriteScheduler { return ws }
+	})
+	defer st.Close()
+	st.greet()
+	st.bodylessReq1()
+	st.wantHeaders()
+	st.wantData()
+	if n := atomic.LoadInt32(&ws.opened); n != 1 {
+		t.Errorf("scheduler opened %d streams; want 1", n)
+	}
+	if n := atomic.LoadInt32(&ws.popped); n < 2 {
+		t.Errorf("scheduler popped %d frames; want at least the response's 2", n)
+	}
+}
+
+func TestResetLimiter(t *testing.T) {
+	now := time.Now()
+	l := newResetLimiter(60, now)
+	for i := 0; i < 60; i++ {
+		if !l.allow(now) {
+			t.Fatalf("reset %d of burst not allowed", i)
+		}
+	}
+	if l.allow(now) {
+		t.Fatal("reset beyond burst allowed")
+	}
+	// One reset is regained per second.
+	now = now.Add(time.Second)
+	if !l.allow(now) {
+		t.Fatal("reset after a second not allowed")
+	}
+	if l.allow(now) {
+		t.Fatal("second reset after a second allowed")
+	}
+	// Tokens do not accumulate beyond the burst size.
+	now = now.Add(time.Hour)
+	for i := 0; i < 60; i++ {
+		if !l.allow(now) {
+			t.Fatalf("reset %d after an hour not allowed", i)
+		}
+	}
+	if l.allow(now) {
+		t.Fatal("reset beyond burst allowed after an hour")
+	}
+}
+
 func TestServer_DeadConn_Unblocks_Read(t *testing.T) {
 	testServerPostUnblock(t,
 		func(w http.ResponseWriter, r *http.Request) (err error) {
@@ -2330,6 +2881,134 @@ func TestServer_Response_Automatic100Continue(t *testing.T) {
 	})
 }
 
+func TestServer_DisableExpectContinueHandling(t *testing.T) {
+	const msg = "foo"
+	const reply = "bar"
+	errc := make(chan error, 1)
+	st := newServerTester(t, func(w http.ResponseWriter, r *http.Request) {
+		errc <- func() error {
+			if v := r.Header.Get("Expect"); v != "100-continue" {
+				return fmt.Errorf("Expect header = %q; want 100-continue", v)
+			}
+			if r.URL.Path == "/reject" {
+				w.WriteHeader(http.StatusExpectationFailed)
+				return nil
+			}
+			w.Header().Set("X-Upstream", "1")
+			w.WriteHeader(http.StatusContinue)
+			buf := make([]byte, len(msg))
+			if n, err := io.ReadFull(r.Body, buf); err != nil || string(buf) != msg {
+				return fmt.Errorf("ReadFull = %q, %v; want %q, nil", buf[:n], err, msg)
+			}
+			_, err := io.WriteString(w, reply)
+			return err
+		}()
+	}, func(s *Server) {
+		s.DisableExpectContinueHandling = true
+	})
+	defer st.Close()
+	st.greet()
+
+	st.writeHeaders(HeadersFrameParam{
+		StreamID:      1,
+		BlockFragment: st.encodeHeader(":method", "POST", ":path", "/reject", "expect", "100-continue"),
+		EndHeaders:    true,
+	})
+	hf := st.wantHeaders()
+	if got, want := st.decodeHeader(hf.HeaderBlockFragment())[0], [2]string{":status", "417"}; got != want {
+		t.Errorf("first header = %v; want %v", got, want)
+	}
+	// The unread request body is refused.
+	st.wantRSTStream(1, ErrCodeNo)
+	if err := <-errc; err != nil {
+		t.Error(err)
+	}
+
+	st.writeHeaders(HeadersFrameParam{
+		StreamID:      3,
+		BlockFragment: st.encodeHeader(":method", "POST", "expect", "100-continue"),
+		EndHeaders:    true,
+	})
+	hf = st.wantHeaders()
+	if hf.StreamEnded() {
+		t.Fatal("unexpected END_STREAM flag")
+	}
+	goth := st.decodeHeader(hf.HeaderBlockFragment())
+	wanth := [][2]string{
+		{":status", "100"},
+		{"x-upstream", "1"},
+	}
+	if !reflect.DeepEqual(goth, wanth) {
+		t.Fatalf("Got headers %v; want %v", goth, wanth)
+	}
+	st.writeData(3, true, []byte(msg))
+	st.wantWindowUpdate(0, uint32(len(msg)))
+	hf = st.wantHeaders()
+	if got, want := st.decodeHeader(hf.HeaderBlockFragment())[0], [2]string{":status", "200"}; got != want {
+		t.Errorf("first header = %v; want %v", got, want)
+	}
+	if df := st.wantData(); string(df.Data()) != reply {
+		t.Errorf("Client read %q; want %q", df.Data(), reply)
+	}
+	if err := <-errc; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestServer_DisableExpectContinueHandling_101(t *testing.T) {
+	errc := make(chan error, 1)
+	st := newServerTester(t, func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if recover() == nil {
+				errc <- errors.New("WriteHeader(101) did not panic")
+				return
+			}
+			errc <- nil
+			w.WriteHeader(http.StatusOK)
+		}()
+		w.WriteHeader(http.StatusSwitchingProtocols)
+	}, func(s *Server) {
+		s.DisableExpectContinueHandling = true
+	})
+	defer st.Close()
+	st.greet()
+	st.writeHeaders(HeadersFrameParam{
+		StreamID:      1,
+		BlockFragment: st.encodeHeader(),
+		EndStream:     true,
+		EndHeaders:    true,
+	})
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	hf := st.wantHeaders()
+	if got, want := st.decodeHeader(hf.HeaderBlockFragment())[0], [2]string{":status", "200"}; got != want {
+		t.Errorf("first header = %v; want %v", got, want)
+	}
+}
+
+// Without DisableExpectContinueHandling, a 1xx status is the final status.
+func TestServer_Response_1xxFinalStatus(t *testing.T) {
+	testServerResponse(t, func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusContinue)
+		return nil
+	}, func(st *serverTester) {
+		st.writeHeaders(HeadersFrameParam{
+			StreamID:      1,
+			BlockFragment: st.encodeHeader(),
+			EndStream:     true,
+			EndHeaders:    true,
+		})
+		hf := st.wantHeaders()
+		if !hf.StreamEnded() {
+			t.Fatal("want END_STREAM flag")
+		}
+		if got, want := st.decodeHeader(hf.HeaderBlockFragment())[0], [2]string{":status", "100"}; got != want {
+			t.Errorf("first header = %v; want %v", got, want)
+		}
+	})
+}
+
 func TestServer_HandlerWriteErrorOnDisconnect(t *testing.T) {
 	errc := make(chan error, 1)
 	testServerResponse(t, func(w http.ResponseWriter, r *http.Request) error {
@@ -2846,6 +3525,53 @@ func TestServerDoS_MaxHeaderListSize(t *testing.T) {
 	}
 }
 
+func TestServer_Headers_TooLarge_CountError(t *testing.T) {
+	var (
+		mu         sync.Mutex
+		errTypes   []string
+		handlerRan bool
+	)
+	st := newServerTester(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		handlerRan = true
+		mu.Unlock()
+	}, func(ts *httptest.Server) {
+		ts.Config.MaxHeaderBytes = 1 << 10
+	}, func(s *Server) {
+		s.CountError = func(errType string) {
+			mu.Lock()
+			errTypes = append(errTypes, errType)
+			mu.Unlock()
+		}
+	})
+	defer st.Close()
+	st.greet()
+
+	// Each cookie fits within the limit, but together they don't.
+	cookie := strings.Repeat("a", 512)
+	st.writeHeaders(HeadersFrameParam{
+		StreamID:      1,
+		BlockFragment: st.encodeHeader("cookie", cookie, "cookie", cookie, "cookie", cookie),
+		EndStream:     true,
+		EndHeaders:    true,
+	})
+	h := st.wantHeaders()
+	headers := st.decodeHeader(h.HeaderBlockFragment())
+	if len(headers) == 0 || headers[0] != [2]string{":status", "431"} {
+		t.Errorf("response headers = %q; want :status 431", headers)
+	}
+	st.wantData()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if handlerRan {
+		t.Error("handler ran for request with too large headers")
+	}
+	if want := []string{"header_list_too_large"}; !reflect.DeepEqual(errTypes, want) {
+		t.Errorf("CountError calls = %q; want %q", errTypes, want)
+	}
+}
+
 func TestServer_Response_Stream_With_Missing_Trailer(t *testing.T) {
 	testServerResponse(t, func(w http.ResponseWriter, r *http.Request) error {
 		w.Header().Set("Trailer", "test-trailer")
@@ -3554,6 +4280,76 @@ type connStateConn struct {
 
 func (c connStateConn) ConnectionState() tls.ConnectionState { return c.cs }
 
+func TestServeConnFallbackHandler(t *testing.T) {
+	var s Server
+	c1, c2 := net.Pipe()
+	serveDone := make(chan struct{})
+	go func() {
+		defer close(serveDone)
+		s.ServeConn(c1, &ServeConnOpts{
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				t.Errorf("HTTP/2 handler called for %v request", r.Proto)
+			}),
+			FallbackHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, r.Proto+" "+r.URL.Path)
+			}),
+		})
+	}()
+	defer c2.Close()
+
+	io.WriteString(c2, "GET /foo HTTP/1.1\r\nHost: example.com\r\nConnection: close\r\n\r\n")
+	res, err := http.ReadResponse(bufio.NewReader(c2), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(body), "HTTP/1.1 /foo"; got != want {
+		t.Errorf("body = %q; want %q", got, want)
+	}
+	select {
+	case <-serveDone:
+	case <-time.After(5 * time.Second):
+		t.Error("ServeConn did not return after the HTTP/1.1 connection was closed")
+	}
+}
+
+func TestServeConnFallbackHandlerHTTP2(t *testing.T) {
+	var s Server
+	c1, c2 := net.Pipe()
+	go s.ServeConn(c1, &ServeConnOpts{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, r.Proto)
+		}),
+		FallbackHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Errorf("fallback handler called for %v request", r.Proto)
+		}),
+	})
+	defer c2.Close()
+
+	tr := &Transport{}
+	defer tr.CloseIdleConnections()
+	cc, err := tr.NewClientConn(c2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest("GET", "https://example.com/", nil)
+	res, err := cc.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(body), "HTTP/2.0"; got != want {
+		t.Errorf("body = %q; want %q", got, want)
+	}
+}
+
 // golang.org/issue/12737 -- handle any net.Conn, not just
 // *tls.Conn.
 func TestServerHandleCustomConn(t *testing.T) {
@@ -4115,6 +4911,51 @@ func TestServerGracefulShutdown(t *testing.T) {
 	}
 }
 
+func TestServerGracefulShutdownDrainTimeout(t *testing.T) {
+	handlerStarted := make(chan struct{})
+	unblock := make(chan struct{})
+	defer close(unblock)
+	st := newServerTester(t, func(w http.ResponseWriter, r *http.Request) {
+		close(handlerStarted)
+		<-unblock // a long poll outliving the drain
+	}, func(s *Server) {
+		s.DrainTimeout = 50 * time.Millisecond
+	})
+	defer st.Close()
+
+	st.greet()
+	st.bodylessReq1()
+	<-handlerStarted
+	st.sc.startGracefulShutdown()
+
+	for _, want := range []uint32{1<<31 - 1, 1} {
+		ga := st.wantGoAway()
+		if ga.ErrCode != ErrCodeNo {
+			t.Errorf("GOAWAY error = %v; want ErrCodeNo", ga.ErrCode)
+		}
+		if ga.LastStreamID != want {
+			t.Errorf("GOAWAY LastStreamID = %v; want %v", ga.LastStreamID, want)
+		}
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		fr, err := st.fr.ReadFrame()
+		if err == nil {
+			err = fmt.Errorf("got frame of type %T", fr)
+		}
+		errc <- err
+	}()
+	select {
+	case err := <-errc:
+		if err != io.EOF {
+			t.Errorf("ReadFrame = %v; want io.EOF", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("timeout waiting for disconnect after drain timeout")
+	}
+}
+
 // Issue 31753: don't sniff when Content-Encoding is set
 func TestContentEncodingNoSniffing(t *testing.T) {
 	type resp struct {
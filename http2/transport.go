@@ -57,6 +57,13 @@ const (
 //
 // A Transport internally caches connections to servers. It is safe
 // for concurrent use by multiple goroutines.
+//
+// A CONNECT request whose Header contains a ":protocol" key is sent
+// as an extended CONNECT request (RFC 8441), with the key's value as
+// the :protocol pseudo-header. The request and response bodies then
+// carry the tunneled protocol's bytes in each direction. Such a
+// request fails if the server has not advertised support for
+// extended CONNECT with SETTINGS_ENABLE_CONNECT_PROTOCOL.
 type Transport struct {
 	// DialTLS specifies an optional dial function for creating
 	// TLS connections for requests.
@@ -98,6 +105,20 @@ type Transport struct {
 	// to mean no limit.
 	MaxHeaderListSize uint32
 
+	// InitialSettings, if non-nil, replaces the SETTINGS the
+	// Transport sends at the start of each connection, which
+	// otherwise disable server push and set the initial window
+	// size and the MaxHeaderListSize. It is meant for testing how
+	// servers react to other settings, such as small windows or
+	// frame sizes.
+	//
+	// Settings for which Setting.Valid reports an error make
+	// new connections fail. Omitted settings take their default
+	// values from the spec, which the Transport then honors;
+	// since it does not support server push, leaving push
+	// enabled makes any PUSH_PROMISE a connection error.
+	InitialSettings []Setting
+
 	// StrictMaxConcurrentStreams controls whether the server's
 	// SETTINGS_MAX_CONCURRENT_STREAMS should be respected
 	// globally. If false, new TCP connections are created to the
@@ -121,6 +142,61 @@ type Transport struct {
 	// Defaults to 15s.
 	PingTimeout time.Duration
 
+	// KeepAlivePingInterval, if non-zero, is the interval at which a
+	// health check using ping frame is carried out, regardless of other
+	// traffic on the connection. This keeps connections alive through
+	// NATs and load balancers that drop idle flows. If an ack is not
+	// received within PingTimeout, the connection is closed.
+	// A health check triggered by ReadIdleTimeout while another is in
+	// progress is skipped, so the two do not double-ping.
+	KeepAlivePingInterval time.Duration
+
+	// ConnPoolKeyFunc, if non-nil, returns the key under which the
+	// connection used for req is pooled, in place of the default
+	// host:port derived from req.URL. Requests with the same key
+	// share connections, regardless of their authority. The key is
+	// also the address dialed when a new connection is needed, so
+	// it should be of the form "host:port", and its host is the TLS
+	// ServerName verified on that connection unless
+	// TLSClientConfig.ServerName is set. The :authority sent for
+	// each request is still derived from req.Host or req.URL.Host.
+	// ConnPoolKeyFunc is not used for connections obtained from a
+	// net/http Transport configured with ConfigureTransport.
+	ConnPoolKeyFunc func(req *http.Request) string
+
+	// OnGoAway, if non-nil, is called when a GOAWAY frame is received
+	// from the server, before the connection is marked dead. It is
+	// called from the connection's read loop and should not block.
+	OnGoAway func(GoAwayInfo)
+
+	// FrameDebugWriter, if non-nil, receives a one-line description
+	// of each frame read or written on the Transport's connections.
+	// See Framer.SetDebugWriter. It must be safe for concurrent use
+	// if the Transport has more than one connection.
+	FrameDebugWriter io.Writer
+
+	// ExpectALPN, if non-empty, is the protocol that must have been
+	// negotiated with ALPN on each connection, typically "h2".
+	// Connections with another negotiated protocol, including those
+	// returned by DialTLS or passed to NewClientConn, and those
+	// without TLS, are closed with an error before any request is
+	// sent on them. This catches TLS-terminating middleboxes that
+	// silently fall back to HTTP/1.1.
+	ExpectALPN string
+
+	// DataBufferPool, if non-nil, supplies the buffers that hold
+	// response body data until the caller reads it. See BufferPool
+	// for the ownership of the buffers.
+	DataBufferPool BufferPool
+
+	// OnNewClientConn, if non-nil, is called with each ClientConn
+	// the Transport establishes, including those created by
+	// NewClientConn, once the client preface has been sent and
+	// before any request is sent on it. It is a single place to
+	// instrument new connections, for instance with their
+	// ClientConn.State, without wrapping DialTLS.
+	OnNewClientConn func(*ClientConn)
+
 	// t1, if non-nil, is the standard library Transport using
 	// this transport. Its settings are used (but not its
 	// RoundTrip method, etc).
@@ -237,6 +313,8 @@ type ClientConn struct {
 	readerDone chan struct{} // closed on error
 	readerErr  error         // set before readerDone is closed
 
+	healthChecking int32 // atomic; 1 while a health check ping is in flight
+
 	idleTimeout time.Duration // or 0 for never
 	idleTimer   *time.Timer
 
@@ -244,9 +322,12 @@ type ClientConn struct {
 	cond            *sync.Cond // hold mu; broadcast on flow/closed changes
 	flow            flow       // our conn-level flow control quota (cs.flow is per stream)
 	inflow          flow       // peer's conn-level flow control
+	streamInflow    int32      // stream-level flow control window we advertised
 	closing         bool
 	closed          bool
 	wantSettingsAck bool                     // we sent a SETTINGS frame and haven't heard back
+	seenSettings    bool                     // whether we've received the server's initial SETTINGS
+	seenSettingsc   chan struct{}            // closed when seenSettings is set
 	goAway          *GoAwayFrame             // if non-nil, the GoAwayFrame we received
 	goAwayDebug     string                   // goAway frame's debug data, retained as a string
 	streams         map[uint32]*clientStream // client-initiated
@@ -264,6 +345,8 @@ type ClientConn struct {
 	peerMaxHeaderListSize uint64
 	initialWindowSize     uint32
 
+	extendedConnectAllowed bool // server sent SETTINGS_ENABLE_CONNECT_PROTOCOL=1
+
 	hbuf    bytes.Buffer // HPACK encoder writes into this
 	henc    *hpack.Encoder
 	freeBuf [][]byte
@@ -467,6 +550,9 @@ func (t *Transport) RoundTripOpt(req *http.Request, opt RoundTripOpt) (*http.Res
 	}
 
 	addr := authorityAddr(req.URL.Scheme, req.URL.Host)
+	if t.ConnPoolKeyFunc != nil && t.t1 == nil {
+		addr = t.ConnPoolKeyFunc(req)
+	}
 	for retry := 0; ; retry++ {
 		cc, err := t.connPool().GetClientConn(req, addr)
 		if err != nil {
@@ -509,6 +595,16 @@ func (t *Transport) CloseIdleConnections() {
 	}
 }
 
+// CloseConnectionsIdleSince closes the connections which have no
+// requests in flight and have not been used since t, including those
+// which have never been used. More recently used connections are left
+// open.
+func (t *Transport) CloseConnectionsIdleSince(since time.Time) {
+	if cp, ok := t.connPool().(clientConnPoolIdleCloser); ok {
+		cp.closeConnectionsIdleSince(since)
+	}
+}
+
 var (
 	errClientConnClosed    = errors.New("http2: client conn is closed")
 	errClientConnUnusable  = errors.New("http2: client conn not usable")
@@ -638,6 +734,22 @@ func (t *Transport) NewClientConn(c net.Conn) (*ClientConn, error) {
 }
 
 func (t *Transport) newClientConn(c net.Conn, singleUse bool) (*ClientConn, error) {
+	if want := t.ExpectALPN; want != "" {
+		var got string
+		if cs, ok := c.(connectionStater); ok {
+			got = cs.ConnectionState().NegotiatedProtocol
+		}
+		if got != want {
+			c.Close()
+			return nil, fmt.Errorf("http2: negotiated ALPN protocol %q; want %q", got, want)
+		}
+	}
+	for _, s := range t.InitialSettings {
+		if err := s.Valid(); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("http2: invalid Transport.InitialSettings %v: %v", s, err)
+		}
+	}
 	cc := &ClientConn{
 		t:                     t,
 		tconn:                 c,
@@ -647,9 +759,11 @@ func (t *Transport) newClientConn(c net.Conn, singleUse bool) (*ClientConn, erro
 		initialWindowSize:     65535,              // spec default
 		maxConcurrentStreams:  1000,               // "infinite", per spec. 1000 seems good enough.
 		peerMaxHeaderListSize: 0xffffffffffffffff, // "infinite", per spec. Use 2^64-1 instead.
+		streamInflow:          transportDefaultStreamFlow,
 		streams:               make(map[uint32]*clientStream),
 		singleUse:             singleUse,
 		wantSettingsAck:       true,
+		seenSettingsc:         make(chan struct{}),
 		pings:                 make(map[[8]byte]chan struct{}),
 	}
 	if d := t.idleConnTimeout(); d != 0 {
@@ -670,6 +784,9 @@ func (t *Transport) newClientConn(c net.Conn, singleUse bool) (*ClientConn, erro
 	cc.fr = NewFramer(cc.bw, cc.br)
 	cc.fr.ReadMetaHeaders = hpack.NewDecoder(initialHeaderTableSize, nil)
 	cc.fr.MaxHeaderListSize = t.maxHeaderListSize()
+	if t.FrameDebugWriter != nil {
+		cc.fr.SetDebugWriter(t.FrameDebugWriter)
+	}
 
 	// TODO: SetMaxDynamicTableSize, SetMaxDynamicTableSizeLimit on
 	// henc in response to SETTINGS frames?
@@ -691,6 +808,22 @@ func (t *Transport) newClientConn(c net.Conn, singleUse bool) (*ClientConn, erro
 	if max := t.maxHeaderListSize(); max != 0 {
 		initialSettings = append(initialSettings, Setting{ID: SettingMaxHeaderListSize, Val: max})
 	}
+	if t.InitialSettings != nil {
+		initialSettings = t.InitialSettings
+		cc.streamInflow = initialWindowSize
+		for _, s := range initialSettings {
+			switch s.ID {
+			case SettingInitialWindowSize:
+				cc.streamInflow = int32(s.Val)
+			case SettingMaxFrameSize:
+				cc.fr.SetMaxReadFrameSize(s.Val)
+			case SettingHeaderTableSize:
+				cc.fr.ReadMetaHeaders = hpack.NewDecoder(s.Val, nil)
+			case SettingMaxHeaderListSize:
+				cc.fr.MaxHeaderListSize = s.Val
+			}
+		}
+	}
 
 	cc.bw.Write(clientPreface)
 	cc.fr.WriteSettings(initialSettings...)
@@ -703,10 +836,36 @@ func (t *Transport) newClientConn(c net.Conn, singleUse bool) (*ClientConn, erro
 	}
 
 	go cc.readLoop()
+	if d := t.KeepAlivePingInterval; d > 0 {
+		go cc.keepAlive(d)
+	}
+	if t.OnNewClientConn != nil {
+		t.OnNewClientConn(cc)
+	}
 	return cc, nil
 }
 
+// keepAlive runs a health check every d until the connection's
+// read loop exits.
+func (cc *ClientConn) keepAlive(d time.Duration) {
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			cc.healthCheck()
+		case <-cc.readerDone:
+			return
+		}
+	}
+}
+
 func (cc *ClientConn) healthCheck() {
+	if !atomic.CompareAndSwapInt32(&cc.healthChecking, 0, 1) {
+		// Another health check is already in progress.
+		return
+	}
+	defer atomic.StoreInt32(&cc.healthChecking, 0)
 	pingTimeout := cc.t.pingTimeout()
 	// We don't need to periodically ping in the health check, because the readLoop of ClientConn will
 	// trigger the healthCheck again if there is no frame received.
@@ -745,6 +904,36 @@ func (cc *ClientConn) setGoAway(f *GoAwayFrame) {
 	}
 }
 
+// NegotiatedProtocol returns the protocol negotiated with ALPN on the
+// connection, or the empty string if none was or if the connection
+// does not use TLS.
+func (cc *ClientConn) NegotiatedProtocol() string {
+	if cc.tlsState == nil {
+		return ""
+	}
+	return cc.tlsState.NegotiatedProtocol
+}
+
+// State returns the TLS connection state of the connection, as it was
+// when the connection was established. It returns the zero value if the
+// connection does not use TLS.
+func (cc *ClientConn) State() tls.ConnectionState {
+	if cc.tlsState == nil {
+		return tls.ConnectionState{}
+	}
+	return *cc.tlsState
+}
+
+// ReservedStreams returns the number of streams of the connection that
+// count against the server's SETTINGS_MAX_CONCURRENT_STREAMS limit:
+// those opened by requests that have not completed yet. Requests
+// waiting for a stream to become available are not counted.
+func (cc *ClientConn) ReservedStreams() int {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return len(cc.streams)
+}
+
 // CanTakeNewRequest reports whether the connection can take a new request,
 // meaning it has not been closed or received or sent a GOAWAY.
 func (cc *ClientConn) CanTakeNewRequest() bool {
@@ -753,6 +942,58 @@ func (cc *ClientConn) CanTakeNewRequest() bool {
 	return cc.canTakeNewRequestLocked()
 }
 
+// FlowControlStats is a snapshot of the flow control windows of a
+// ClientConn. See ClientConn.FlowControlStats.
+type FlowControlStats struct {
+	// ConnInflow is the number of DATA bytes the server may send
+	// on the connection before the client sends a WINDOW_UPDATE.
+	ConnInflow int32
+
+	// ConnOutflow is the number of DATA bytes the client may send
+	// on the connection before the server sends a WINDOW_UPDATE.
+	ConnOutflow int32
+
+	// ActiveStreams is the number of active streams.
+	ActiveStreams int
+
+	// StreamInflow and StreamOutflow are the sums of the
+	// stream-level receive and send windows of all active streams.
+	StreamInflow  int64
+	StreamOutflow int64
+
+	// MinStreamInflow and MinStreamOutflow are the smallest
+	// stream-level receive and send windows of any active stream.
+	// They are zero if there are no active streams.
+	MinStreamInflow  int32
+	MinStreamOutflow int32
+}
+
+// FlowControlStats returns a snapshot of the connection-level flow control
+// windows of cc and an aggregate of the stream-level windows of its active
+// streams.
+func (cc *ClientConn) FlowControlStats() FlowControlStats {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	st := FlowControlStats{
+		ConnInflow:    cc.inflow.n,
+		ConnOutflow:   cc.flow.n,
+		ActiveStreams: len(cc.streams),
+	}
+	first := true
+	for _, cs := range cc.streams {
+		st.StreamInflow += int64(cs.inflow.n)
+		st.StreamOutflow += int64(cs.flow.n)
+		if first || cs.inflow.n < st.MinStreamInflow {
+			st.MinStreamInflow = cs.inflow.n
+		}
+		if first || cs.flow.n < st.MinStreamOutflow {
+			st.MinStreamOutflow = cs.flow.n
+		}
+		first = false
+	}
+	return st
+}
+
 // clientConnIdleState describes the suitability of a client
 // connection to initiate a new RoundTrip request.
 type clientConnIdleState struct {
@@ -814,8 +1055,14 @@ func (cc *ClientConn) onIdleTimeout() {
 }
 
 func (cc *ClientConn) closeIfIdle() {
+	cc.closeIfIdleSince(time.Time{})
+}
+
+// closeIfIdleSince closes cc if it has no active streams and has not
+// been used since t. A zero t matches any idle connection.
+func (cc *ClientConn) closeIfIdleSince(t time.Time) {
 	cc.mu.Lock()
-	if len(cc.streams) > 0 {
+	if len(cc.streams) > 0 || !t.IsZero() && !cc.lastActive.Before(t) {
 		cc.mu.Unlock()
 		return
 	}
@@ -1011,6 +1258,61 @@ func checkConnHeaders(req *http.Request) error {
 	return nil
 }
 
+// priorityContextKey is the context key for a request's stream priority.
+// See WithRequestPriority.
+type priorityContextKey struct{}
+
+// WithRequestPriority returns a copy of ctx that carries the stream
+// priority p. When a request using the returned context is sent by a
+// Transport, p is sent in the request's HEADERS frame. p.StreamDep
+// refers to another stream on the same connection; zero means the
+// stream depends on the root of the dependency tree. A zero
+// PriorityParam sends no priority information, leaving the stream
+// with the default priority.
+func WithRequestPriority(ctx context.Context, p PriorityParam) context.Context {
+	return context.WithValue(ctx, priorityContextKey{}, p)
+}
+
+// requestPriority returns the stream priority set on req's context
+// with WithRequestPriority, or the zero PriorityParam if none was set.
+func requestPriority(req *http.Request) PriorityParam {
+	p, _ := req.Context().Value(priorityContextKey{}).(PriorityParam)
+	return p
+}
+
+var errExtendedConnectNotSupported = errors.New("http2: server does not support extended CONNECT (SETTINGS_ENABLE_CONNECT_PROTOCOL not advertised)")
+
+// extendedConnectProtocol returns the :protocol pseudo-header requested
+// for req, or the empty string if req is not an extended CONNECT request.
+func extendedConnectProtocol(req *http.Request) string {
+	if vv := req.Header[":protocol"]; len(vv) > 0 {
+		return vv[0]
+	}
+	return ""
+}
+
+// awaitExtendedConnect waits for the server's initial SETTINGS frame
+// and reports whether the server permits extended CONNECT requests.
+func (cc *ClientConn) awaitExtendedConnect(req *http.Request) error {
+	if req.Method != "CONNECT" {
+		return errors.New("http2: :protocol pseudo-header is only valid on CONNECT requests")
+	}
+	select {
+	case <-cc.seenSettingsc:
+	case <-cc.readerDone:
+		return errClientConnClosed
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+	cc.mu.Lock()
+	allowed := cc.extendedConnectAllowed
+	cc.mu.Unlock()
+	if !allowed {
+		return errExtendedConnectNotSupported
+	}
+	return nil
+}
+
 // actualContentLength returns a sanitized version of
 // req.ContentLength, where 0 actually means zero (not unknown) and -1
 // means unknown.
@@ -1033,6 +1335,11 @@ func (cc *ClientConn) roundTrip(req *http.Request) (res *http.Response, gotErrAf
 	if err := checkConnHeaders(req); err != nil {
 		return nil, false, err
 	}
+	if extendedConnectProtocol(req) != "" {
+		if err := cc.awaitExtendedConnect(req); err != nil {
+			return nil, false, err
+		}
+	}
 	if cc.idleTimer != nil {
 		cc.idleTimer.Stop()
 	}
@@ -1101,7 +1408,7 @@ func (cc *ClientConn) roundTrip(req *http.Request) (res *http.Response, gotErrAf
 
 	cc.wmu.Lock()
 	endStream := !hasBody && !hasTrailers
-	werr := cc.writeHeaders(cs.ID, endStream, int(cc.maxFrameSize), hdrs)
+	werr := cc.writeHeaders(cs.ID, endStream, int(cc.maxFrameSize), requestPriority(req), hdrs)
 	cc.wmu.Unlock()
 	traceWroteHeaders(cs.trace)
 	cc.mu.Unlock()
@@ -1265,7 +1572,7 @@ func (cc *ClientConn) awaitOpenSlotForRequest(req *http.Request) error {
 }
 
 // requires cc.wmu be held
-func (cc *ClientConn) writeHeaders(streamID uint32, endStream bool, maxFrameSize int, hdrs []byte) error {
+func (cc *ClientConn) writeHeaders(streamID uint32, endStream bool, maxFrameSize int, prio PriorityParam, hdrs []byte) error {
 	first := true // first frame written (HEADERS is first, then CONTINUATION)
 	for len(hdrs) > 0 && cc.werr == nil {
 		chunk := hdrs
@@ -1280,6 +1587,7 @@ func (cc *ClientConn) writeHeaders(streamID uint32, endStream bool, maxFrameSize
 				BlockFragment: chunk,
 				EndStream:     endStream,
 				EndHeaders:    endHeaders,
+				Priority:      prio,
 			})
 			first = false
 		} else {
@@ -1422,7 +1730,7 @@ func (cs *clientStream) writeRequestBody(body io.Reader, bodyCloser io.Closer) (
 	// Two ways to send END_STREAM: either with trailers, or
 	// with an empty DATA frame.
 	if len(trls) > 0 {
-		err = cc.writeHeaders(cs.ID, true, maxFrameSize, trls)
+		err = cc.writeHeaders(cs.ID, true, maxFrameSize, PriorityParam{}, trls)
 	} else {
 		err = cc.fr.WriteData(cs.ID, true, nil)
 	}
@@ -1479,8 +1787,9 @@ func (cc *ClientConn) encodeHeaders(req *http.Request, addGzipHeader bool, trail
 		return nil, err
 	}
 
+	protocol := extendedConnectProtocol(req)
 	var path string
-	if req.Method != "CONNECT" {
+	if req.Method != "CONNECT" || protocol != "" {
 		path = req.URL.RequestURI()
 		if !validPseudoPath(path) {
 			orig := path
@@ -1499,6 +1808,9 @@ func (cc *ClientConn) encodeHeaders(req *http.Request, addGzipHeader bool, trail
 	// potentially pollute our hpack state. (We want to be able to
 	// continue to reuse the hpack encoder for future requests)
 	for k, vv := range req.Header {
+		if k == ":protocol" && protocol != "" {
+			continue
+		}
 		if !httpguts.ValidHeaderFieldName(k) {
 			return nil, fmt.Errorf("invalid HTTP header name %q", k)
 		}
@@ -1521,19 +1833,23 @@ func (cc *ClientConn) encodeHeaders(req *http.Request, addGzipHeader bool, trail
 			m = http.MethodGet
 		}
 		f(":method", m)
-		if req.Method != "CONNECT" {
+		if req.Method != "CONNECT" || protocol != "" {
 			f(":path", path)
 			f(":scheme", req.URL.Scheme)
 		}
+		if protocol != "" {
+			f(":protocol", protocol)
+		}
 		if trailers != "" {
 			f("trailer", trailers)
 		}
 
 		var didUA bool
 		for k, vv := range req.Header {
-			if strings.EqualFold(k, "host") || strings.EqualFold(k, "content-length") {
+			if strings.EqualFold(k, "host") || strings.EqualFold(k, "content-length") || k == ":protocol" {
 				// Host is :authority, already sent.
 				// Content-Length is automatic, set below.
+				// :protocol is a pseudo-header, already sent.
 				continue
 			} else if strings.EqualFold(k, "connection") || strings.EqualFold(k, "proxy-connection") ||
 				strings.EqualFold(k, "transfer-encoding") || strings.EqualFold(k, "upgrade") ||
@@ -1697,7 +2013,7 @@ func (cc *ClientConn) newStream() *clientStream {
 	}
 	cs.flow.add(int32(cc.initialWindowSize))
 	cs.flow.setConnFlow(&cc.flow)
-	cs.inflow.add(transportDefaultStreamFlow)
+	cs.inflow.add(cc.streamInflow)
 	cs.inflow.setConnFlow(&cc.inflow)
 	cc.nextStreamID += 2
 	cc.streams[cs.ID] = cs
@@ -2048,7 +2364,7 @@ func (rl *clientConnReadLoop) handleResponse(cs *clientStream, f *MetaHeadersFra
 		return res, nil
 	}
 
-	cs.bufPipe = pipe{b: &dataBuffer{expected: res.ContentLength}}
+	cs.bufPipe = pipe{b: &dataBuffer{expected: res.ContentLength, pool: rl.cc.t.DataBufferPool}}
 	cs.bytesRemain = res.ContentLength
 	res.Body = transportResponseBody{cs}
 	go cs.awaitRequestCancel(cs.req)
@@ -2142,8 +2458,12 @@ func (b transportResponseBody) Read(p []byte) (n int, err error) {
 		// consumed by the client) when computing flow control for this
 		// stream.
 		v := int(cs.inflow.available()) + cs.bufPipe.Len()
-		if v < transportDefaultStreamFlow-transportDefaultStreamMinRefresh {
-			streamAdd = int32(transportDefaultStreamFlow - v)
+		minRefresh := int32(transportDefaultStreamMinRefresh)
+		if half := cc.streamInflow / 2; half < minRefresh {
+			minRefresh = half
+		}
+		if v < int(cc.streamInflow-minRefresh) {
+			streamAdd = cc.streamInflow - int32(v)
 			cs.inflow.add(streamAdd)
 		}
 	}
@@ -2320,8 +2640,34 @@ func (cs *clientStream) copyTrailers() {
 	}
 }
 
+// GoAwayInfo describes a GOAWAY frame received from a server.
+// See Transport.OnGoAway.
+type GoAwayInfo struct {
+	// Conn is the connection the GOAWAY was received on.
+	Conn *ClientConn
+
+	// LastStreamID is the highest-numbered stream the server
+	// might have processed.
+	LastStreamID uint32
+
+	// ErrCode is the error code sent by the server. ErrCodeNo
+	// indicates a graceful shutdown.
+	ErrCode ErrCode
+
+	// DebugData is the opaque debug data sent by the server.
+	DebugData []byte
+}
+
 func (rl *clientConnReadLoop) processGoAway(f *GoAwayFrame) error {
 	cc := rl.cc
+	if fn := cc.t.OnGoAway; fn != nil {
+		fn(GoAwayInfo{
+			Conn:         cc,
+			LastStreamID: f.LastStreamID,
+			ErrCode:      f.ErrCode,
+			DebugData:    append([]byte(nil), f.DebugData()...),
+		})
+	}
 	cc.t.connPool().MarkDead(cc)
 	if f.ErrCode != 0 {
 		// TODO: deal with GOAWAY more. particularly the error code
@@ -2352,6 +2698,16 @@ func (rl *clientConnReadLoop) processSettings(f *SettingsFrame) error {
 			cc.maxConcurrentStreams = s.Val
 		case SettingMaxHeaderListSize:
 			cc.peerMaxHeaderListSize = uint64(s.Val)
+		case SettingEnableConnectProtocol:
+			if err := s.Valid(); err != nil {
+				return err
+			}
+			// RFC 8441 Section 3: a server MUST NOT send a value of 0
+			// after previously sending a value of 1.
+			if s.Val == 0 && cc.extendedConnectAllowed {
+				return ConnectionError(ErrCodeProtocol)
+			}
+			cc.extendedConnectAllowed = s.Val == 1
 		case SettingInitialWindowSize:
 			// Values above the maximum flow-control
 			// window size of 2^31-1 MUST be treated as a
@@ -2380,6 +2736,10 @@ func (rl *clientConnReadLoop) processSettings(f *SettingsFrame) error {
 	if err != nil {
 		return err
 	}
+	if !cc.seenSettings {
+		cc.seenSettings = true
+		close(cc.seenSettingsc)
+	}
 
 	cc.wmu.Lock()
 	defer cc.wmu.Unlock()
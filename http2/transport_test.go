@@ -133,6 +133,148 @@ func TestTransportH2c(t *testing.T) {
 	}
 }
 
+func TestTransportConnPoolKeyFunc(t *testing.T) {
+	hosts := make(chan string, 2)
+	st := newServerTester(t, func(w http.ResponseWriter, r *http.Request) {
+		hosts <- r.Host
+	}, optOnlyServer)
+	defer st.Close()
+
+	var dials int32
+	var dialAddr atomic.Value
+	tr := &Transport{
+		TLSClientConfig: tlsConfigInsecure,
+		DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			dialAddr.Store(addr)
+			return tls.Dial(network, st.ts.Listener.Addr().String(), cfg)
+		},
+		ConnPoolKeyFunc: func(req *http.Request) string {
+			return "upstream.tld:443"
+		},
+	}
+	defer tr.CloseIdleConnections()
+
+	for _, host := range []string{"a.tld", "b.tld"} {
+		req, _ := http.NewRequest("GET", "https://"+host+"/", nil)
+		res, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if got := <-hosts; got != host {
+			t.Errorf("server saw Host %q; want %q", got, host)
+		}
+	}
+	if got := atomic.LoadInt32(&dials); got != 1 {
+		t.Errorf("got %d dials; want 1", got)
+	}
+	if got := dialAddr.Load(); got != "upstream.tld:443" {
+		t.Errorf("dialed %v; want upstream.tld:443", got)
+	}
+}
+
+// ConnPoolKeyFunc is ignored for a Transport configured by
+// ConfigureTransports, whose connections net/http pools by address.
+func TestTransportConnPoolKeyFuncConfigureTransports(t *testing.T) {
+	st := newServerTester(t, func(w http.ResponseWriter, r *http.Request) {}, optOnlyServer)
+	defer st.Close()
+
+	t1 := &http.Transport{TLSClientConfig: tlsConfigInsecure}
+	t2, err := ConfigureTransports(t1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t2.ConnPoolKeyFunc = func(req *http.Request) string {
+		return "upstream.tld:443"
+	}
+	defer t1.CloseIdleConnections()
+
+	c := &http.Client{Transport: t1, Timeout: 5 * time.Second}
+	res, err := c.Get(st.ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.ProtoMajor != 2 {
+		t.Errorf("got HTTP/%d; want HTTP/2", res.ProtoMajor)
+	}
+}
+
+func TestTransportExtendedConnect(t *testing.T) {
+	st := newServerTester(t, func(w http.ResponseWriter, r *http.Request) {
+		if g, w := r.Header.Get(":protocol"), "echo"; g != w {
+			t.Errorf(":protocol = %q; want %q", g, w)
+		}
+		w.WriteHeader(200)
+		w.(http.Flusher).Flush()
+		buf := make([]byte, 32)
+		for {
+			n, err := r.Body.Read(buf)
+			if n > 0 {
+				w.Write(buf[:n])
+				w.(http.Flusher).Flush()
+			}
+			if err != nil {
+				return
+			}
+		}
+	}, optOnlyServer, func(s *Server) {
+		s.EnableExtendedConnect = true
+	})
+	defer st.Close()
+
+	tr := &Transport{TLSClientConfig: tlsConfigInsecure}
+	defer tr.CloseIdleConnections()
+
+	pr, pw := io.Pipe()
+	req, err := http.NewRequest("CONNECT", st.ts.URL+"/echo", pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(":protocol", "echo")
+	res, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Fatalf("StatusCode = %v; want 200", res.StatusCode)
+	}
+	for _, msg := range []string{"hello", "world"} {
+		if _, err := io.WriteString(pw, msg); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, len(msg))
+		if _, err := io.ReadFull(res.Body, buf); err != nil {
+			t.Fatal(err)
+		}
+		if string(buf) != msg {
+			t.Errorf("read %q; want %q", buf, msg)
+		}
+	}
+	pw.Close()
+}
+
+func TestTransportExtendedConnectNotSupported(t *testing.T) {
+	st := newServerTester(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler called for unsupported extended CONNECT")
+	}, optOnlyServer)
+	defer st.Close()
+
+	tr := &Transport{TLSClientConfig: tlsConfigInsecure}
+	defer tr.CloseIdleConnections()
+
+	req, err := http.NewRequest("CONNECT", st.ts.URL+"/echo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(":protocol", "echo")
+	if _, err := tr.RoundTrip(req); err != errExtendedConnectNotSupported {
+		t.Errorf("RoundTrip error = %v; want %v", err, errExtendedConnectNotSupported)
+	}
+}
+
 func TestTransport(t *testing.T) {
 	const body = "sup"
 	st := newServerTester(t, func(w http.ResponseWriter, r *http.Request) {
@@ -242,6 +384,49 @@ func TestTransportReusesConn_ConnClose(t *testing.T) {
 	}
 }
 
+func TestTransportCloseConnectionsIdleSince(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+	st1 := newServerTester(t, handler, optOnlyServer)
+	defer st1.Close()
+	st2 := newServerTester(t, handler, optOnlyServer)
+	defer st2.Close()
+	tr := &Transport{
+		TLSClientConfig: tlsConfigInsecure,
+	}
+	defer tr.CloseIdleConnections()
+
+	get := func(url string) {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+	get(st1.ts.URL)
+	time.Sleep(10 * time.Millisecond)
+	since := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	get(st2.ts.URL)
+
+	tr.CloseConnectionsIdleSince(since)
+	addr2 := authorityAddr("https", strings.TrimPrefix(st2.ts.URL, "https://"))
+	if err := retry(50, 10*time.Millisecond, func() error {
+		cp := tr.connPool().(*clientConnPool)
+		cp.mu.Lock()
+		defer cp.mu.Unlock()
+		if len(cp.conns) != 1 || len(cp.conns[addr2]) != 1 {
+			return fmt.Errorf("conns = %v; want only a conn to %v", cp.conns, addr2)
+		}
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+}
+
 // Tests that the Transport only keeps one pending dial open per destination address.
 // https://golang.org/issue/13397
 func TestTransportGroupsPendingDials(t *testing.T) {
@@ -2767,6 +2952,140 @@ func testTransportUsesGoAwayDebugError(t *testing.T, failMidBody bool) {
 	ct.run()
 }
 
+func TestTransportRequestPriority(t *testing.T) {
+	ct := newClientTester(t)
+	want := PriorityParam{StreamDep: 0, Exclusive: true, Weight: 200}
+	ct.client = func() error {
+		req, _ := http.NewRequest("GET", "https://dummy.tld/", nil)
+		req = req.WithContext(WithRequestPriority(req.Context(), want))
+		res, err := ct.tr.RoundTrip(req)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		return nil
+	}
+	ct.server = func() error {
+		ct.greet()
+		hf, err := ct.firstHeaders()
+		if err != nil {
+			return err
+		}
+		if !hf.HasPriority() {
+			t.Errorf("HEADERS frame has no priority")
+		}
+		if hf.Priority != want {
+			t.Errorf("Priority = %+v; want %+v", hf.Priority, want)
+		}
+		var buf bytes.Buffer
+		enc := hpack.NewEncoder(&buf)
+		enc.WriteField(hpack.HeaderField{Name: ":status", Value: "200"})
+		return ct.fr.WriteHeaders(HeadersFrameParam{
+			StreamID:      hf.StreamID,
+			EndHeaders:    true,
+			EndStream:     true,
+			BlockFragment: buf.Bytes(),
+		})
+	}
+	ct.run()
+}
+
+func TestTransportOnGoAway(t *testing.T) {
+	ct := newClientTester(t)
+	gotGoAway := make(chan GoAwayInfo, 1)
+	ct.tr.OnGoAway = func(info GoAwayInfo) {
+		gotGoAway <- info
+	}
+	const goAwayDebugData = "draining"
+
+	ct.client = func() error {
+		req, _ := http.NewRequest("GET", "https://dummy.tld/", nil)
+		_, err := ct.tr.RoundTrip(req)
+		if err == nil {
+			return errors.New("RoundTrip succeeded; want GOAWAY error")
+		}
+		select {
+		case info := <-gotGoAway:
+			if info.Conn == nil {
+				t.Errorf("GoAwayInfo.Conn is nil")
+			}
+			if info.LastStreamID != 0 || info.ErrCode != ErrCodeEnhanceYourCalm || string(info.DebugData) != goAwayDebugData {
+				t.Errorf("GoAwayInfo = {LastStreamID: %v, ErrCode: %v, DebugData: %q}; want {0, %v, %q}",
+					info.LastStreamID, info.ErrCode, info.DebugData, ErrCodeEnhanceYourCalm, goAwayDebugData)
+			}
+		default:
+			t.Errorf("OnGoAway was not called")
+		}
+		return nil
+	}
+	ct.server = func() error {
+		ct.greet()
+		if _, err := ct.firstHeaders(); err != nil {
+			return err
+		}
+		if err := ct.fr.WriteGoAway(0, ErrCodeEnhanceYourCalm, []byte(goAwayDebugData)); err != nil {
+			return err
+		}
+		ct.sc.Close()
+		return nil
+	}
+	ct.run()
+}
+
+func TestClientConnFlowControlStats(t *testing.T) {
+	ct := newClientTester(t)
+	clientDone := make(chan struct{})
+	ct.client = func() error {
+		defer close(clientDone)
+		cc, err := ct.tr.NewClientConn(ct.cc)
+		if err != nil {
+			return err
+		}
+		want := FlowControlStats{
+			ConnInflow:  transportDefaultConnFlow + initialWindowSize,
+			ConnOutflow: initialWindowSize,
+		}
+		if got := cc.FlowControlStats(); got != want {
+			t.Errorf("before request: FlowControlStats = %+v; want %+v", got, want)
+		}
+
+		req, _ := http.NewRequest("GET", "https://dummy.tld/", nil)
+		res, err := cc.RoundTrip(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		want.ActiveStreams = 1
+		want.StreamInflow = transportDefaultStreamFlow
+		want.StreamOutflow = initialWindowSize
+		want.MinStreamInflow = transportDefaultStreamFlow
+		want.MinStreamOutflow = initialWindowSize
+		if got := cc.FlowControlStats(); got != want {
+			t.Errorf("during request: FlowControlStats = %+v; want %+v", got, want)
+		}
+		return nil
+	}
+	ct.server = func() error {
+		ct.greet()
+		hf, err := ct.firstHeaders()
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		enc := hpack.NewEncoder(&buf)
+		enc.WriteField(hpack.HeaderField{Name: ":status", Value: "200"})
+		ct.fr.WriteHeaders(HeadersFrameParam{
+			StreamID:      hf.StreamID,
+			EndHeaders:    true,
+			EndStream:     false,
+			BlockFragment: buf.Bytes(),
+		})
+		<-clientDone
+		return nil
+	}
+	ct.run()
+}
+
 func testTransportReturnsUnusedFlowControl(t *testing.T, oneDataFrame bool) {
 	ct := newClientTester(t)
 
@@ -3391,6 +3710,70 @@ func TestTransportCloseAfterLostPing(t *testing.T) {
 	ct.run()
 }
 
+func TestTransportKeepAlivePing(t *testing.T) {
+	const interval = 50 * time.Millisecond
+	clientDone := make(chan struct{})
+	ct := newClientTester(t)
+	ct.tr.KeepAlivePingInterval = interval
+	ct.client = func() error {
+		defer ct.cc.(*net.TCPConn).CloseWrite()
+		defer close(clientDone)
+		if _, err := ct.tr.NewClientConn(ct.cc); err != nil {
+			return err
+		}
+		time.Sleep(10 * interval)
+		return nil
+	}
+	ct.server = func() error {
+		ct.greet()
+		pings := 0
+		for {
+			f, err := ct.fr.ReadFrame()
+			if err != nil {
+				select {
+				case <-clientDone:
+				default:
+					return err
+				}
+				if pings < 3 {
+					return fmt.Errorf("got %d pings; want at least 3", pings)
+				}
+				return nil
+			}
+			if pf, ok := f.(*PingFrame); ok && !pf.IsAck() {
+				pings++
+				if err := ct.fr.WritePing(true, pf.Data); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	ct.run()
+}
+
+func TestTransportCloseAfterLostKeepAlivePing(t *testing.T) {
+	clientDone := make(chan struct{})
+	ct := newClientTester(t)
+	ct.tr.PingTimeout = 100 * time.Millisecond
+	ct.tr.KeepAlivePingInterval = 100 * time.Millisecond
+	ct.client = func() error {
+		defer ct.cc.(*net.TCPConn).CloseWrite()
+		defer close(clientDone)
+		req, _ := http.NewRequest("GET", "https://dummy.tld/", nil)
+		_, err := ct.tr.RoundTrip(req)
+		if err == nil || !strings.Contains(err.Error(), "client connection lost") {
+			return fmt.Errorf("expected to get error about \"connection lost\", got %v", err)
+		}
+		return nil
+	}
+	ct.server = func() error {
+		ct.greet()
+		<-clientDone
+		return nil
+	}
+	ct.run()
+}
+
 func TestTransportPingWhenReading(t *testing.T) {
 	testCases := []struct {
 		name                   string
@@ -4838,6 +5221,100 @@ func TestTransportRoundtripCloseOnWriteError(t *testing.T) {
 	}
 }
 
+func TestTransportExpectALPN(t *testing.T) {
+	st := newServerTester(t, func(w http.ResponseWriter, r *http.Request) {}, optOnlyServer)
+	defer st.Close()
+
+	tr := &Transport{TLSClientConfig: tlsConfigInsecure, ExpectALPN: "h2"}
+	defer tr.CloseIdleConnections()
+	cc, err := tr.dialClientConn(st.ts.Listener.Addr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cc.NegotiatedProtocol(); got != "h2" {
+		t.Errorf("NegotiatedProtocol() = %q; want h2", got)
+	}
+}
+
+func TestTransportOnNewClientConn(t *testing.T) {
+	release := make(chan struct{})
+	st := newServerTester(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}, optOnlyServer)
+	defer st.Close()
+
+	var conns []*ClientConn
+	tr := &Transport{
+		TLSClientConfig: tlsConfigInsecure,
+		OnNewClientConn: func(cc *ClientConn) {
+			conns = append(conns, cc)
+		},
+	}
+	defer tr.CloseIdleConnections()
+	cc, err := tr.dialClientConn(st.ts.Listener.Addr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conns) != 1 || conns[0] != cc {
+		t.Fatalf("OnNewClientConn called with %v; want [%p]", conns, cc)
+	}
+	state := cc.State()
+	if !state.HandshakeComplete || state.NegotiatedProtocol != "h2" {
+		t.Errorf("State() = %+v; want a completed handshake negotiating h2", state)
+	}
+	if n := cc.ReservedStreams(); n != 0 {
+		t.Errorf("ReservedStreams() = %v before any request; want 0", n)
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		req, _ := http.NewRequest("GET", st.ts.URL, nil)
+		res, err := cc.RoundTrip(req)
+		if err == nil {
+			res.Body.Close()
+		}
+		errc <- err
+	}()
+	waitCondition(5*time.Second, 10*time.Millisecond, func() bool {
+		return cc.ReservedStreams() == 1
+	})
+	if n := cc.ReservedStreams(); n != 1 {
+		t.Errorf("ReservedStreams() = %v with a request in flight; want 1", n)
+	}
+	close(release)
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTransportExpectALPNFallback(t *testing.T) {
+	// An HTTP/1.1-only server, as behind a middlebox that does not
+	// speak HTTP/2.
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	var dialed net.Conn
+	tr := &Transport{
+		ExpectALPN: "h2",
+		DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+			cfg.InsecureSkipVerify = true
+			cfg.NextProtos = append(cfg.NextProtos, "http/1.1")
+			c, err := tls.Dial(network, addr, cfg)
+			dialed = c
+			return c, err
+		},
+	}
+	defer tr.CloseIdleConnections()
+	req, _ := http.NewRequest("GET", ts.URL, nil)
+	_, err := tr.RoundTrip(req)
+	if err == nil || !strings.Contains(err.Error(), "ALPN") {
+		t.Fatalf("RoundTrip error = %v; want ALPN mismatch", err)
+	}
+	if _, err := dialed.Write([]byte("x")); err == nil {
+		t.Error("connection with unexpected ALPN protocol was not closed")
+	}
+}
+
 // Issue 31192: A failed request may be retried if the body has not been read
 // already. If the request body has started to be sent, one must wait until it
 // is completed.
@@ -4918,3 +5395,55 @@ func TestTransportServerResetStreamAtHeaders(t *testing.T) {
 	}
 	res.Body.Close()
 }
+
+func TestTransportInitialSettings(t *testing.T) {
+	const bodySize = 1 << 16
+	st := newServerTester(t, func(w http.ResponseWriter, r *http.Request) {
+		ps, _ := PeerSettingsFromContext(r.Context())
+		if ps.InitialWindowSize != 100 || ps.MaxFrameSize != 16384 {
+			t.Errorf("server saw peer settings %+v; want an initial window size of 100 and the default frame size", ps)
+		}
+		w.Write(bytes.Repeat([]byte("a"), bodySize))
+	}, optOnlyServer)
+	defer st.Close()
+
+	tr := &Transport{
+		TLSClientConfig: tlsConfigInsecure,
+		InitialSettings: []Setting{
+			{ID: SettingEnablePush, Val: 0},
+			{ID: SettingInitialWindowSize, Val: 100},
+			{ID: SettingMaxFrameSize, Val: 16384},
+		},
+	}
+	defer tr.CloseIdleConnections()
+	req, _ := http.NewRequest("GET", st.ts.URL, nil)
+	res, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	n, err := io.Copy(ioutil.Discard, res.Body)
+	if err != nil || n != bodySize {
+		t.Fatalf("read %v bytes of body, error %v; want %v bytes", n, err, bodySize)
+	}
+}
+
+func TestTransportInitialSettingsInvalid(t *testing.T) {
+	st := newServerTester(t, func(w http.ResponseWriter, r *http.Request) {}, optOnlyServer)
+	defer st.Close()
+
+	tr := &Transport{
+		TLSClientConfig: tlsConfigInsecure,
+		InitialSettings: []Setting{{ID: SettingMaxFrameSize, Val: 100}},
+	}
+	defer tr.CloseIdleConnections()
+	req, _ := http.NewRequest("GET", st.ts.URL, nil)
+	res, err := tr.RoundTrip(req)
+	if err == nil {
+		res.Body.Close()
+		t.Fatal("RoundTrip succeeded; want an error for the invalid MAX_FRAME_SIZE")
+	}
+	if !strings.Contains(err.Error(), "InitialSettings") {
+		t.Errorf("RoundTrip error = %v; want it to mention InitialSettings", err)
+	}
+}
@@ -127,6 +127,11 @@ var parseFns = map[Type]func(int, Type, []byte) (MessageBody, error){
 	ipv6.ICMPTypeTimeExceeded:           parseTimeExceeded,
 	ipv6.ICMPTypeParameterProblem:       parseParamProb,
 
+	ipv6.ICMPTypeRouterSolicitation:    parseRouterSolicitation,
+	ipv6.ICMPTypeRouterAdvertisement:   parseRouterAdvertisement,
+	ipv6.ICMPTypeNeighborSolicitation:  parseNeighborSolicitation,
+	ipv6.ICMPTypeNeighborAdvertisement: parseNeighborAdvertisement,
+
 	ipv6.ICMPTypeEchoRequest:         parseEcho,
 	ipv6.ICMPTypeEchoReply:           parseEcho,
 	ipv6.ICMPTypeExtendedEchoRequest: parseExtendedEchoRequest,
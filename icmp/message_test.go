@@ -155,10 +155,137 @@ func TestMarshalAndParseMessage(t *testing.T) {
 						State: 5 /* Probe */, Active: true, IPv6: true,
 					},
 				},
+				{
+					Type: ipv6.ICMPTypeRouterSolicitation, Code: 0,
+					Body: &icmp.RouterSolicitation{
+						Options: []icmp.NDOption{
+							&icmp.LinkLayerAddrOption{
+								Addr: net.HardwareAddr{0, 1, 2, 3, 4, 5},
+							},
+						},
+					},
+				},
+				{
+					Type: ipv6.ICMPTypeRouterAdvertisement, Code: 0,
+					Body: &icmp.RouterAdvertisement{
+						HopLimit: 64, Managed: true, RouterLifetime: 1800,
+						ReachableTime: 30000, RetransTimer: 1000,
+						Options: []icmp.NDOption{
+							&icmp.LinkLayerAddrOption{
+								Addr: net.HardwareAddr{0, 1, 2, 3, 4, 5},
+							},
+							&icmp.MTUOption{MTU: 1500},
+							&icmp.PrefixInfoOption{
+								PrefixLen: 64, OnLink: true, Autonomous: true,
+								ValidLifetime: 1<<32 - 1, PreferredLifetime: 604800,
+								Prefix: net.ParseIP("2001:db8::"),
+							},
+							&icmp.RawNDOption{
+								Type: 25, // Recursive DNS Server
+								Data: append([]byte{0, 0, 0, 0, 0x0e, 0x10}, net.ParseIP("2001:db8::53")...),
+							},
+						},
+					},
+				},
+				{
+					Type: ipv6.ICMPTypeNeighborSolicitation, Code: 0,
+					Body: &icmp.NeighborSolicitation{
+						Target: net.ParseIP("fe80::1"),
+					},
+				},
+				{
+					Type: ipv6.ICMPTypeNeighborAdvertisement, Code: 0,
+					Body: &icmp.NeighborAdvertisement{
+						Router: true, Solicited: true, Override: true,
+						Target: net.ParseIP("fe80::1"),
+						Options: []icmp.NDOption{
+							&icmp.LinkLayerAddrOption{
+								Target: true,
+								Addr:   net.HardwareAddr{0, 1, 2, 3, 4, 5},
+							},
+						},
+					},
+				},
 			})
 	})
 }
 
+func TestParseNDOptions(t *testing.T) {
+	for i, tt := range []struct {
+		b    []byte
+		opts []icmp.NDOption
+		raw  bool
+	}{
+		// Messages with options of zero length are kept raw.
+		{b: []byte{1, 0, 0, 0, 0, 0, 0, 0}, raw: true},
+		// Messages with options exceeding them are kept raw.
+		{b: []byte{1, 2, 0, 0, 0, 0, 0, 0}, raw: true},
+		{b: []byte{1}, raw: true},
+		// An MTU option of the wrong length is kept raw.
+		{
+			b: []byte{5, 2, 0, 0, 0, 0, 5, 0xdc, 0, 0, 0, 0, 0, 0, 0, 0},
+			opts: []icmp.NDOption{
+				&icmp.RawNDOption{Type: 5, Data: []byte{0, 0, 0, 0, 5, 0xdc, 0, 0, 0, 0, 0, 0, 0, 0}},
+			},
+		},
+		// An unknown option is kept raw.
+		{
+			b: []byte{200, 1, 1, 2, 3, 4, 5, 6, 5, 1, 0, 0, 0, 0, 5, 0xdc},
+			opts: []icmp.NDOption{
+				&icmp.RawNDOption{Type: 200, Data: []byte{1, 2, 3, 4, 5, 6}},
+				&icmp.MTUOption{MTU: 1500},
+			},
+		},
+	} {
+		b := append([]byte{byte(ipv6.ICMPTypeRouterSolicitation), 0, 0, 0, 0, 0, 0, 0}, tt.b...)
+		m, err := icmp.ParseMessage(iana.ProtocolIPv6ICMP, b)
+		if err != nil {
+			t.Errorf("#%d: %v", i, err)
+			continue
+		}
+		if tt.raw {
+			if want := (&icmp.RawBody{Data: b[4:]}); !reflect.DeepEqual(m.Body, want) {
+				t.Errorf("#%d: got %#v; want %#v", i, m.Body, want)
+			}
+			continue
+		}
+		if got := m.Body.(*icmp.RouterSolicitation).Options; !reflect.DeepEqual(got, tt.opts) {
+			t.Errorf("#%d: got %#v; want %#v", i, got, tt.opts)
+		}
+	}
+
+	// A message shorter than the fixed part of its body is invalid.
+	b := []byte{byte(ipv6.ICMPTypeNeighborSolicitation), 0, 0, 0, 0, 0, 0, 0}
+	if m, err := icmp.ParseMessage(iana.ProtocolIPv6ICMP, b); err == nil {
+		t.Errorf("got %v; want an error", m.Body)
+	}
+}
+
+func TestMarshalNilNDOptions(t *testing.T) {
+	mtu := &icmp.MTUOption{MTU: 1500}
+	want, err := (&icmp.Message{
+		Type: ipv6.ICMPTypeRouterSolicitation, Code: 0,
+		Body: &icmp.RouterSolicitation{Options: []icmp.NDOption{mtu}},
+	}).Marshal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Nil options, whether untyped or typed, are skipped.
+	got, err := (&icmp.Message{
+		Type: ipv6.ICMPTypeRouterSolicitation, Code: 0,
+		Body: &icmp.RouterSolicitation{Options: []icmp.NDOption{
+			nil, mtu, (*icmp.LinkLayerAddrOption)(nil), (*icmp.PrefixInfoOption)(nil),
+			(*icmp.MTUOption)(nil), (*icmp.RawNDOption)(nil),
+		}},
+	}).Marshal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got %#v; want %#v", got, want)
+	}
+}
+
 func TestMarshalAndParseRawMessage(t *testing.T) {
 	t.Run("RawBody", func(t *testing.T) {
 		for i, tt := range []struct {
@@ -0,0 +1,415 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package icmp
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+// Neighbor Discovery option types, as defined in RFC 4861.
+const (
+	ndOptSourceLinkLayerAddr = 1
+	ndOptTargetLinkLayerAddr = 2
+	ndOptPrefixInfo          = 3
+	ndOptMTU                 = 5
+)
+
+// A RouterSolicitation represents an ICMPv6 router solicitation
+// message body.
+type RouterSolicitation struct {
+	Options []NDOption // options
+}
+
+// Len implements the Len method of MessageBody interface.
+func (p *RouterSolicitation) Len(proto int) int {
+	if p == nil {
+		return 0
+	}
+	return 4 + ndOptionsLen(p.Options)
+}
+
+// Marshal implements the Marshal method of MessageBody interface.
+func (p *RouterSolicitation) Marshal(proto int) ([]byte, error) {
+	b := make([]byte, 4, 4+ndOptionsLen(p.Options))
+	return marshalNDOptions(b, p.Options)
+}
+
+// parseRouterSolicitation parses b as an ICMPv6 router solicitation
+// message body.
+func parseRouterSolicitation(proto int, _ Type, b []byte) (MessageBody, error) {
+	if len(b) < 4 {
+		return nil, errMessageTooShort
+	}
+	opts, err := parseNDOptions(b[4:])
+	if err != nil {
+		// Messages with malformed options are still returned, as a
+		// RawBody, for the benefit of tools that inspect traffic.
+		return parseRawBody(proto, b)
+	}
+	return &RouterSolicitation{Options: opts}, nil
+}
+
+// A RouterAdvertisement represents an ICMPv6 router advertisement
+// message body.
+type RouterAdvertisement struct {
+	HopLimit       int        // current hop limit, or 0 if unspecified
+	Managed        bool       // managed address configuration flag
+	Other          bool       // other configuration flag
+	RouterLifetime int        // router lifetime in seconds
+	ReachableTime  int        // reachable time in milliseconds
+	RetransTimer   int        // retransmission timer in milliseconds
+	Options        []NDOption // options
+}
+
+// Len implements the Len method of MessageBody interface.
+func (p *RouterAdvertisement) Len(proto int) int {
+	if p == nil {
+		return 0
+	}
+	return 12 + ndOptionsLen(p.Options)
+}
+
+// Marshal implements the Marshal method of MessageBody interface.
+func (p *RouterAdvertisement) Marshal(proto int) ([]byte, error) {
+	b := make([]byte, 12, 12+ndOptionsLen(p.Options))
+	b[0] = byte(p.HopLimit)
+	if p.Managed {
+		b[1] |= 0x80
+	}
+	if p.Other {
+		b[1] |= 0x40
+	}
+	binary.BigEndian.PutUint16(b[2:4], uint16(p.RouterLifetime))
+	binary.BigEndian.PutUint32(b[4:8], uint32(p.ReachableTime))
+	binary.BigEndian.PutUint32(b[8:12], uint32(p.RetransTimer))
+	return marshalNDOptions(b, p.Options)
+}
+
+// parseRouterAdvertisement parses b as an ICMPv6 router advertisement
+// message body.
+func parseRouterAdvertisement(proto int, _ Type, b []byte) (MessageBody, error) {
+	if len(b) < 12 {
+		return nil, errMessageTooShort
+	}
+	p := &RouterAdvertisement{
+		HopLimit:       int(b[0]),
+		Managed:        b[1]&0x80 != 0,
+		Other:          b[1]&0x40 != 0,
+		RouterLifetime: int(binary.BigEndian.Uint16(b[2:4])),
+		ReachableTime:  int(binary.BigEndian.Uint32(b[4:8])),
+		RetransTimer:   int(binary.BigEndian.Uint32(b[8:12])),
+	}
+	var err error
+	if p.Options, err = parseNDOptions(b[12:]); err != nil {
+		return parseRawBody(proto, b)
+	}
+	return p, nil
+}
+
+// A NeighborSolicitation represents an ICMPv6 neighbor solicitation
+// message body.
+type NeighborSolicitation struct {
+	Target  net.IP     // target address
+	Options []NDOption // options
+}
+
+// Len implements the Len method of MessageBody interface.
+func (p *NeighborSolicitation) Len(proto int) int {
+	if p == nil {
+		return 0
+	}
+	return 4 + net.IPv6len + ndOptionsLen(p.Options)
+}
+
+// Marshal implements the Marshal method of MessageBody interface.
+func (p *NeighborSolicitation) Marshal(proto int) ([]byte, error) {
+	b := make([]byte, 4+net.IPv6len, 4+net.IPv6len+ndOptionsLen(p.Options))
+	copy(b[4:], p.Target.To16())
+	return marshalNDOptions(b, p.Options)
+}
+
+// parseNeighborSolicitation parses b as an ICMPv6 neighbor
+// solicitation message body.
+func parseNeighborSolicitation(proto int, _ Type, b []byte) (MessageBody, error) {
+	if len(b) < 4+net.IPv6len {
+		return nil, errMessageTooShort
+	}
+	p := &NeighborSolicitation{Target: make(net.IP, net.IPv6len)}
+	copy(p.Target, b[4:4+net.IPv6len])
+	var err error
+	if p.Options, err = parseNDOptions(b[4+net.IPv6len:]); err != nil {
+		return parseRawBody(proto, b)
+	}
+	return p, nil
+}
+
+// A NeighborAdvertisement represents an ICMPv6 neighbor advertisement
+// message body.
+type NeighborAdvertisement struct {
+	Router    bool       // router flag
+	Solicited bool       // solicited flag
+	Override  bool       // override flag
+	Target    net.IP     // target address
+	Options   []NDOption // options
+}
+
+// Len implements the Len method of MessageBody interface.
+func (p *NeighborAdvertisement) Len(proto int) int {
+	if p == nil {
+		return 0
+	}
+	return 4 + net.IPv6len + ndOptionsLen(p.Options)
+}
+
+// Marshal implements the Marshal method of MessageBody interface.
+func (p *NeighborAdvertisement) Marshal(proto int) ([]byte, error) {
+	b := make([]byte, 4+net.IPv6len, 4+net.IPv6len+ndOptionsLen(p.Options))
+	if p.Router {
+		b[0] |= 0x80
+	}
+	if p.Solicited {
+		b[0] |= 0x40
+	}
+	if p.Override {
+		b[0] |= 0x20
+	}
+	copy(b[4:], p.Target.To16())
+	return marshalNDOptions(b, p.Options)
+}
+
+// parseNeighborAdvertisement parses b as an ICMPv6 neighbor
+// advertisement message body.
+func parseNeighborAdvertisement(proto int, _ Type, b []byte) (MessageBody, error) {
+	if len(b) < 4+net.IPv6len {
+		return nil, errMessageTooShort
+	}
+	p := &NeighborAdvertisement{
+		Router:    b[0]&0x80 != 0,
+		Solicited: b[0]&0x40 != 0,
+		Override:  b[0]&0x20 != 0,
+		Target:    make(net.IP, net.IPv6len),
+	}
+	copy(p.Target, b[4:4+net.IPv6len])
+	var err error
+	if p.Options, err = parseNDOptions(b[4+net.IPv6len:]); err != nil {
+		return parseRawBody(proto, b)
+	}
+	return p, nil
+}
+
+// An NDOption represents an ICMPv6 Neighbor Discovery option.
+type NDOption interface {
+	// Len returns the length of the option in bytes, including
+	// the type and length fields and any padding.
+	Len() int
+
+	// Marshal returns the binary encoding of the option.
+	Marshal() ([]byte, error)
+}
+
+// A LinkLayerAddrOption represents a source or target link-layer
+// address option.
+type LinkLayerAddrOption struct {
+	Target bool             // target rather than source address
+	Addr   net.HardwareAddr // link-layer address, including any padding when parsed
+}
+
+// Len implements the Len method of NDOption interface.
+func (o *LinkLayerAddrOption) Len() int {
+	if o == nil {
+		return 0
+	}
+	return ndOptionLen(len(o.Addr))
+}
+
+// Marshal implements the Marshal method of NDOption interface.
+func (o *LinkLayerAddrOption) Marshal() ([]byte, error) {
+	if o == nil {
+		return nil, nil
+	}
+	typ := ndOptSourceLinkLayerAddr
+	if o.Target {
+		typ = ndOptTargetLinkLayerAddr
+	}
+	return marshalNDOption(typ, o.Addr)
+}
+
+// A PrefixInfoOption represents a prefix information option.
+type PrefixInfoOption struct {
+	PrefixLen         int    // prefix length
+	OnLink            bool   // on-link flag
+	Autonomous        bool   // autonomous address configuration flag
+	ValidLifetime     uint32 // valid lifetime in seconds, all ones for infinity
+	PreferredLifetime uint32 // preferred lifetime in seconds, all ones for infinity
+	Prefix            net.IP // prefix
+}
+
+// Len implements the Len method of NDOption interface.
+func (o *PrefixInfoOption) Len() int {
+	if o == nil {
+		return 0
+	}
+	return 32
+}
+
+// Marshal implements the Marshal method of NDOption interface.
+func (o *PrefixInfoOption) Marshal() ([]byte, error) {
+	if o == nil {
+		return nil, nil
+	}
+	b := make([]byte, 32)
+	b[0], b[1] = ndOptPrefixInfo, 4
+	b[2] = byte(o.PrefixLen)
+	if o.OnLink {
+		b[3] |= 0x80
+	}
+	if o.Autonomous {
+		b[3] |= 0x40
+	}
+	binary.BigEndian.PutUint32(b[4:8], o.ValidLifetime)
+	binary.BigEndian.PutUint32(b[8:12], o.PreferredLifetime)
+	copy(b[16:], o.Prefix.To16())
+	return b, nil
+}
+
+// An MTUOption represents an MTU option.
+type MTUOption struct {
+	MTU int // maximum transmission unit of the link
+}
+
+// Len implements the Len method of NDOption interface.
+func (o *MTUOption) Len() int {
+	if o == nil {
+		return 0
+	}
+	return 8
+}
+
+// Marshal implements the Marshal method of NDOption interface.
+func (o *MTUOption) Marshal() ([]byte, error) {
+	if o == nil {
+		return nil, nil
+	}
+	b := make([]byte, 8)
+	b[0], b[1] = ndOptMTU, 1
+	binary.BigEndian.PutUint32(b[4:8], uint32(o.MTU))
+	return b, nil
+}
+
+// A RawNDOption represents an option of a type not otherwise
+// modeled, or of a modeled type but with an unexpected length, which
+// is kept as is.
+type RawNDOption struct {
+	Type int    // option type
+	Data []byte // data following the length field, including any padding
+}
+
+// Len implements the Len method of NDOption interface.
+func (o *RawNDOption) Len() int {
+	if o == nil {
+		return 0
+	}
+	return ndOptionLen(len(o.Data))
+}
+
+// Marshal implements the Marshal method of NDOption interface.
+func (o *RawNDOption) Marshal() ([]byte, error) {
+	if o == nil {
+		return nil, nil
+	}
+	return marshalNDOption(o.Type, o.Data)
+}
+
+// ndOptionLen returns the length of an option carrying n bytes of
+// data, padded to a multiple of 8 bytes.
+func ndOptionLen(n int) int {
+	return (2 + n + 7) &^ 7
+}
+
+// marshalNDOption returns the binary encoding of an option of type
+// typ carrying data.
+func marshalNDOption(typ int, data []byte) ([]byte, error) {
+	l := ndOptionLen(len(data))
+	if l/8 > 255 {
+		return nil, errInvalidBody
+	}
+	b := make([]byte, l)
+	b[0], b[1] = byte(typ), byte(l/8)
+	copy(b[2:], data)
+	return b, nil
+}
+
+func ndOptionsLen(opts []NDOption) int {
+	var l int
+	for _, o := range opts {
+		if o != nil {
+			l += o.Len()
+		}
+	}
+	return l
+}
+
+// marshalNDOptions appends the binary encoding of opts to b, skipping
+// nil options.
+func marshalNDOptions(b []byte, opts []NDOption) ([]byte, error) {
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		ob, err := o.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, ob...)
+	}
+	return b, nil
+}
+
+// parseNDOptions parses b as a sequence of Neighbor Discovery
+// options.
+func parseNDOptions(b []byte) ([]NDOption, error) {
+	var opts []NDOption
+	for len(b) > 0 {
+		if len(b) < 2 {
+			return nil, errMessageTooShort
+		}
+		l := int(b[1]) * 8
+		if l == 0 {
+			return nil, errInvalidBody
+		}
+		if len(b) < l {
+			return nil, errMessageTooShort
+		}
+		var o NDOption
+		switch typ := int(b[0]); {
+		case typ == ndOptSourceLinkLayerAddr || typ == ndOptTargetLinkLayerAddr:
+			lo := &LinkLayerAddrOption{Target: typ == ndOptTargetLinkLayerAddr}
+			lo.Addr = make(net.HardwareAddr, l-2)
+			copy(lo.Addr, b[2:l])
+			o = lo
+		case typ == ndOptPrefixInfo && l == 32:
+			po := &PrefixInfoOption{
+				PrefixLen:         int(b[2]),
+				OnLink:            b[3]&0x80 != 0,
+				Autonomous:        b[3]&0x40 != 0,
+				ValidLifetime:     binary.BigEndian.Uint32(b[4:8]),
+				PreferredLifetime: binary.BigEndian.Uint32(b[8:12]),
+				Prefix:            make(net.IP, net.IPv6len),
+			}
+			copy(po.Prefix, b[16:32])
+			o = po
+		case typ == ndOptMTU && l == 8:
+			o = &MTUOption{MTU: int(binary.BigEndian.Uint32(b[4:8]))}
+		default:
+			ro := &RawNDOption{Type: typ, Data: make([]byte, l-2)}
+			copy(ro.Data, b[2:l])
+			o = ro
+		}
+		opts = append(opts, o)
+		b = b[l:]
+	}
+	return opts, nil
+}
@@ -0,0 +1,270 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package icmp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"time"
+
+	"golang.org/x/net/internal/iana"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+var errInvalidAddr = errors.New("invalid address")
+
+// aLongTimeAgo is a non-zero time, far in the past, used for immediate
+// cancellation of reads.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// A Pinger sends ICMP echo requests to a host over an endpoint and
+// matches the echo replies it receives to them.
+//
+// A Pinger works over both privileged raw endpoints and non-privileged
+// datagram-oriented endpoints, as returned by ListenPacket. On a raw
+// endpoint, the requests carry the identifier ID, and replies with
+// another identifier are ignored. On a datagram-oriented endpoint, the
+// kernel replaces the identifier with the endpoint's local port and
+// only delivers the replies carrying it.
+//
+// The endpoint must not be read by others while Ping runs.
+type Pinger struct {
+	// Conn is the endpoint used to send requests and receive
+	// replies.
+	Conn *PacketConn
+
+	// ID is the identifier of the requests sent over a raw
+	// endpoint. If zero, the process ID is used.
+	ID int
+
+	// Count is the number of requests to send. If zero or
+	// negative, requests are sent until the context is done.
+	Count int
+
+	// Interval is the time between requests. If zero, it defaults
+	// to one second.
+	Interval time.Duration
+
+	// Timeout is how long to wait for the replies to the requests
+	// after the last one is sent. If zero, it defaults to one
+	// second.
+	Timeout time.Duration
+
+	// Data is the data carried by the requests.
+	Data []byte
+
+	// OnReply, if non-nil, is called with each reply as it is
+	// received, from the goroutine running Ping.
+	OnReply func(PingReply)
+}
+
+// A PingReply describes an echo reply received by a Pinger.
+type PingReply struct {
+	Peer net.Addr      // source of the reply
+	Seq  int           // sequence number of the request
+	RTT  time.Duration // round-trip time
+}
+
+// PingStats are the statistics of a run of Pinger.Ping.
+type PingStats struct {
+	Sent     int         // number of requests sent
+	Received int         // number of requests answered
+	Replies  []PingReply // replies, in the order of their arrival
+}
+
+// Loss returns the fraction of the requests sent that were not
+// answered, between 0 and 1.
+func (s *PingStats) Loss() float64 {
+	if s.Sent == 0 {
+		return 0
+	}
+	return float64(s.Sent-s.Received) / float64(s.Sent)
+}
+
+// A pingReply is an echo reply read from the endpoint.
+type pingReply struct {
+	peer net.Addr
+	id   int
+	seq  int
+	at   time.Time
+}
+
+// Ping sends echo requests to dst, with sequence numbers counting from
+// zero, and collects the replies. The dst must be a *net.IPAddr or a
+// *net.UDPAddr; it is converted to the kind of address the endpoint
+// expects.
+//
+// Ping returns when Count requests have been sent and either all of
+// them have been answered or Timeout has elapsed since the last one,
+// or when ctx is done, in which case the statistics collected so far
+// are returned along with ctx.Err().
+func (p *Pinger) Ping(ctx context.Context, dst net.Addr) (*PingStats, error) {
+	if !p.Conn.ok() {
+		return nil, errInvalidConn
+	}
+	var proto int
+	var req Type
+	switch {
+	case p.Conn.p4 != nil:
+		proto, req = iana.ProtocolICMP, ipv4.ICMPTypeEcho
+	case p.Conn.p6 != nil:
+		proto, req = iana.ProtocolIPv6ICMP, ipv6.ICMPTypeEchoRequest
+	default:
+		return nil, errInvalidProtocol
+	}
+	id, dst, err := p.endpoint(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	replies := make(chan pingReply)
+	readErr := make(chan error, 1)
+	done := make(chan struct{})
+	go p.readReplies(proto, replies, readErr, done)
+	defer func() {
+		close(done)
+		p.Conn.SetReadDeadline(aLongTimeAgo)
+		<-readErr
+		p.Conn.SetReadDeadline(time.Time{})
+	}()
+
+	interval := p.Interval
+	if interval == 0 {
+		interval = time.Second
+	}
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var last *time.Timer // started when the last request is sent
+	var expired <-chan time.Time
+	defer func() {
+		if last != nil {
+			last.Stop()
+		}
+	}()
+
+	stats := new(PingStats)
+	sentAt := make(map[int]time.Time) // by sequence number, of unanswered requests
+	send := func() error {
+		seq := stats.Sent
+		m := Message{Type: req, Body: &Echo{ID: id, Seq: seq & 0xffff, Data: p.Data}}
+		b, err := m.Marshal(nil)
+		if err != nil {
+			return err
+		}
+		sentAt[seq&0xffff] = time.Now()
+		if _, err := p.Conn.WriteTo(b, dst); err != nil {
+			delete(sentAt, seq&0xffff)
+			return err
+		}
+		stats.Sent++
+		if stats.Sent == p.Count {
+			ticker.Stop()
+			last = time.NewTimer(timeout)
+			expired = last.C
+		}
+		return nil
+	}
+	if err := send(); err != nil {
+		return stats, err
+	}
+	for {
+		if stats.Sent == p.Count && len(sentAt) == 0 {
+			return stats, nil
+		}
+		select {
+		case <-ctx.Done():
+			return stats, ctx.Err()
+		case <-ticker.C:
+			if err := send(); err != nil {
+				return stats, err
+			}
+		case <-expired:
+			return stats, nil
+		case err := <-readErr:
+			readErr <- nil // for the deferred cleanup
+			return stats, err
+		case r := <-replies:
+			t, ok := sentAt[r.seq]
+			if r.id != id || !ok {
+				continue // not ours, or a duplicate
+			}
+			delete(sentAt, r.seq)
+			stats.Received++
+			reply := PingReply{Peer: r.peer, Seq: r.seq, RTT: r.at.Sub(t)}
+			stats.Replies = append(stats.Replies, reply)
+			if p.OnReply != nil {
+				p.OnReply(reply)
+			}
+		}
+	}
+}
+
+// endpoint returns the identifier of the requests and the destination
+// address in the form expected by the endpoint.
+func (p *Pinger) endpoint(dst net.Addr) (int, net.Addr, error) {
+	var ip net.IP
+	var zone string
+	switch a := dst.(type) {
+	case *net.IPAddr:
+		ip, zone = a.IP, a.Zone
+	case *net.UDPAddr:
+		ip, zone = a.IP, a.Zone
+	default:
+		return 0, nil, errInvalidAddr
+	}
+	if la, ok := p.Conn.LocalAddr().(*net.UDPAddr); ok {
+		return la.Port, &net.UDPAddr{IP: ip, Zone: zone}, nil
+	}
+	id := p.ID
+	if id == 0 {
+		id = os.Getpid()
+	}
+	return id & 0xffff, &net.IPAddr{IP: ip, Zone: zone}, nil
+}
+
+// readReplies reads the echo replies from the endpoint and sends them
+// to replies, until done is closed or a read fails. It then sends the
+// error that stopped it to errc, which is nil if done was closed.
+func (p *Pinger) readReplies(proto int, replies chan<- pingReply, errc chan<- error, done <-chan struct{}) {
+	b := make([]byte, 1500)
+	for {
+		n, peer, err := p.Conn.ReadFrom(b)
+		select {
+		case <-done:
+			errc <- nil
+			return
+		default:
+		}
+		if err != nil {
+			errc <- err
+			return
+		}
+		at := time.Now()
+		m, err := ParseMessage(proto, b[:n])
+		if err != nil {
+			continue
+		}
+		if m.Type != ipv4.ICMPTypeEchoReply && m.Type != ipv6.ICMPTypeEchoReply {
+			continue
+		}
+		echo, ok := m.Body.(*Echo)
+		if !ok {
+			continue
+		}
+		select {
+		case replies <- pingReply{peer: peer, id: echo.ID, seq: echo.Seq, at: at}:
+		case <-done:
+			errc <- nil
+			return
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package icmp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/internal/iana"
+	"golang.org/x/net/ipv4"
+)
+
+// echoConn is a net.PacketConn, and a net.Conn as ipv4.NewPacketConn
+// requires, answering the ICMPv4 echo requests
+// written to it, except those for which drop returns true.
+type echoConn struct {
+	drop func(seq int) bool
+
+	mu       sync.Mutex
+	replies  [][]byte
+	cond     *sync.Cond
+	deadline time.Time
+	closed   bool
+}
+
+func newEchoConn(drop func(int) bool) *echoConn {
+	c := &echoConn{drop: drop}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *echoConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.replies) == 0 {
+		if c.closed {
+			return 0, nil, errors.New("closed")
+		}
+		if !c.deadline.IsZero() && !time.Now().Before(c.deadline) {
+			return 0, nil, errors.New("i/o timeout")
+		}
+		c.cond.Wait()
+	}
+	n := copy(b, c.replies[0])
+	c.replies = c.replies[1:]
+	return n, &net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}, nil
+}
+
+func (c *echoConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	m, err := ParseMessage(iana.ProtocolICMP, b)
+	if err != nil {
+		return 0, err
+	}
+	echo := m.Body.(*Echo)
+	if c.drop != nil && c.drop(echo.Seq) {
+		return len(b), nil
+	}
+	m.Type = ipv4.ICMPTypeEchoReply
+	rb, err := m.Marshal(nil)
+	if err != nil {
+		return 0, err
+	}
+	c.mu.Lock()
+	c.replies = append(c.replies, rb)
+	c.cond.Broadcast()
+	c.mu.Unlock()
+	return len(b), nil
+}
+
+func (c *echoConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.deadline = t
+	c.cond.Broadcast()
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *echoConn) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.cond.Broadcast()
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *echoConn) Read(b []byte) (int, error) {
+	n, _, err := c.ReadFrom(b)
+	return n, err
+}
+
+func (c *echoConn) Write(b []byte) (int, error) { return c.WriteTo(b, nil) }
+
+func (c *echoConn) LocalAddr() net.Addr                { return &net.IPAddr{IP: net.IPv4zero} }
+func (c *echoConn) RemoteAddr() net.Addr               { return nil }
+func (c *echoConn) SetDeadline(t time.Time) error      { return c.SetReadDeadline(t) }
+func (c *echoConn) SetWriteDeadline(t time.Time) error { return nil }
+
+func newEchoPacketConn(drop func(int) bool) *PacketConn {
+	c := newEchoConn(drop)
+	return &PacketConn{c: c, p4: ipv4.NewPacketConn(c)}
+}
+
+func TestPinger(t *testing.T) {
+	dst := &net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}
+
+	t.Run("All", func(t *testing.T) {
+		var seqs []int
+		p := &Pinger{
+			Conn:     newEchoPacketConn(nil),
+			Count:    3,
+			Interval: time.Millisecond,
+			OnReply:  func(r PingReply) { seqs = append(seqs, r.Seq) },
+		}
+		stats, err := p.Ping(context.Background(), dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Sent != 3 || stats.Received != 3 || stats.Loss() != 0 {
+			t.Fatalf("got %+v; want 3 sent and received", stats)
+		}
+		for i, seq := range seqs {
+			if seq != i || stats.Replies[i].Seq != i {
+				t.Errorf("reply #%d has sequence number %d", i, seq)
+			}
+		}
+	})
+
+	t.Run("Loss", func(t *testing.T) {
+		p := &Pinger{
+			Conn:     newEchoPacketConn(func(seq int) bool { return seq%2 == 1 }),
+			Count:    4,
+			Interval: time.Millisecond,
+			Timeout:  50 * time.Millisecond,
+		}
+		stats, err := p.Ping(context.Background(), dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Sent != 4 || stats.Received != 2 || stats.Loss() != 0.5 {
+			t.Fatalf("got %+v; want 4 sent and 2 received", stats)
+		}
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		p := &Pinger{
+			Conn:     newEchoPacketConn(func(int) bool { return true }),
+			Interval: time.Millisecond,
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		stats, err := p.Ping(ctx, dst)
+		if err != context.DeadlineExceeded {
+			t.Fatalf("got %v; want %v", err, context.DeadlineExceeded)
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("Ping returned after %v", d)
+		}
+		if stats.Sent == 0 || stats.Received != 0 {
+			t.Errorf("got %+v; want some sent and none received", stats)
+		}
+	})
+}
@@ -91,10 +91,33 @@ func ValidateLabels(enable bool) Option {
 		}
 		o.trie = trie
 		o.validateLabels = enable
+		o.checkHyphens = enable
+		o.checkJoiners = enable
 		o.fromPuny = validateFromPunycode
 	}
 }
 
+// CheckHyphens sets whether to check for correct use of hyphens ('-') in
+// labels: a label may not start or end with a hyphen, nor have hyphens in
+// both its third and fourth position. It is set by ValidateLabels.
+//
+// This option corresponds to the CheckHyphens flag in UTS #46.
+func CheckHyphens(enable bool) Option {
+	return func(o *options) { o.checkHyphens = enable }
+}
+
+// CheckJoiners sets whether to check the ContextJ rules as defined in
+// Appendix A of RFC 5892, concerning the use of joiner runes. It is set by
+// ValidateLabels.
+//
+// This option corresponds to the CheckJoiners flag in UTS #46.
+func CheckJoiners(enable bool) Option {
+	return func(o *options) {
+		o.trie = trie
+		o.checkJoiners = enable
+	}
+}
+
 // StrictDomainName limits the set of permissible ASCII characters to those
 // allowed in domain names as defined in RFC 1034 (A-Z, a-z, 0-9 and the
 // hyphen). This is set by default for MapForLookup and ValidateForRegistration.
@@ -152,6 +175,8 @@ type options struct {
 	transitional      bool
 	useSTD3Rules      bool
 	validateLabels    bool
+	checkHyphens      bool
+	checkJoiners      bool
 	verifyDNSLength   bool
 	removeLeadingDots bool
 
@@ -249,6 +274,14 @@ var (
 
 	// Registration is the recommended profile for checking whether a given
 	// IDN is valid for registration, according to Section 4 of RFC 5891.
+	//
+	// Unlike Lookup, it does not map its input: any rune that Lookup would
+	// case fold, map to another rune or remove, such as 'A' or U+00AD SOFT
+	// HYPHEN, is rejected with an error identifying the rune, as is input
+	// that is not in Normalization Form C. Deviation characters such as
+	// 'ß' are kept as is rather than mapped as in the transitional
+	// processing used by Lookup, and the length of the domain name and its
+	// labels is verified.
 	Registration *Profile = registration
 
 	punycode = &Profile{}
@@ -256,6 +289,8 @@ var (
 		transitional:   true,
 		useSTD3Rules:   true,
 		validateLabels: true,
+		checkHyphens:   true,
+		checkJoiners:   true,
 		trie:           trie,
 		fromPuny:       validateFromPunycode,
 		mapping:        validateAndMap,
@@ -264,6 +299,8 @@ var (
 	display = &Profile{options{
 		useSTD3Rules:   true,
 		validateLabels: true,
+		checkHyphens:   true,
+		checkJoiners:   true,
 		trie:           trie,
 		fromPuny:       validateFromPunycode,
 		mapping:        validateAndMap,
@@ -272,16 +309,14 @@ var (
 	registration = &Profile{options{
 		useSTD3Rules:    true,
 		validateLabels:  true,
+		checkHyphens:    true,
+		checkJoiners:    true,
 		verifyDNSLength: true,
 		trie:            trie,
 		fromPuny:        validateFromPunycode,
 		mapping:         validateRegistration,
 		bidirule:        bidirule.ValidString,
 	}}
-
-	// TODO: profiles
-	// Register: recommended for approving domain names: don't do any mappings
-	// but rather reject on invalid input. Bundle or block deviation characters.
 )
 
 type labelError struct{ label, code_ string }
@@ -391,6 +426,23 @@ func (p *Profile) process(s string, toASCII bool) (string, error) {
 	return s, err
 }
 
+// mapString runs the mapping step of p, if any, on s.
+func (p *Profile) mapString(s string) (mapped string, isBidi bool, err error) {
+	if p.mapping == nil {
+		return s, false, nil
+	}
+	return p.mapping(p, s)
+}
+
+// validateBidi checks the Bidi Rule for a label of a domain name that
+// contains right-to-left characters if isBidi is set.
+func (p *Profile) validateBidi(label string, isBidi bool) error {
+	if isBidi && p.bidirule != nil && !p.bidirule(label) {
+		return &labelError{label, "B"}
+	}
+	return nil
+}
+
 func normalize(p *Profile, s string) (mapped string, isBidi bool, err error) {
 	// TODO: consider first doing a quick check to see if any of these checks
 	// need to be done. This will make it slower in the general case, but
@@ -680,22 +732,27 @@ func (p *Profile) validateLabel(s string) (err error) {
 		}
 		return nil
 	}
-	if !p.validateLabels {
-		return nil
-	}
-	trie := p.trie // p.validateLabels is only set if trie is set.
-	if len(s) > 4 && s[2] == '-' && s[3] == '-' {
-		return &labelError{s, "V2"}
+	if p.checkHyphens {
+		if len(s) > 4 && s[2] == '-' && s[3] == '-' {
+			return &labelError{s, "V2"}
+		}
+		if s[0] == '-' || s[len(s)-1] == '-' {
+			return &labelError{s, "V3"}
+		}
 	}
-	if s[0] == '-' || s[len(s)-1] == '-' {
-		return &labelError{s, "V3"}
+	if !p.validateLabels && !p.checkJoiners {
+		return nil
 	}
+	trie := p.trie // p.trie is set along with both options.
 	// TODO: merge the use of this in the trie.
 	v, sz := trie.lookupString(s)
 	x := info(v)
-	if x.isModifier() {
+	if p.validateLabels && x.isModifier() {
 		return &labelError{s, "V5"}
 	}
+	if !p.checkJoiners {
+		return nil
+	}
 	// Quickly return in the absence of zero-width (non) joiners.
 	if strings.Index(s, zwj) == -1 && strings.Index(s, zwnj) == -1 {
 		return nil
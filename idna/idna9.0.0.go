@@ -90,10 +90,33 @@ func ValidateLabels(enable bool) Option {
 		}
 		o.trie = trie
 		o.validateLabels = enable
+		o.checkHyphens = enable
+		o.checkJoiners = enable
 		o.fromPuny = validateFromPunycode
 	}
 }
 
+// CheckHyphens sets whether to check for correct use of hyphens ('-') in
+// labels: a label may not start or end with a hyphen, nor have hyphens in
+// both its third and fourth position. It is set by ValidateLabels.
+//
+// This option corresponds to the CheckHyphens flag in UTS #46.
+func CheckHyphens(enable bool) Option {
+	return func(o *options) { o.checkHyphens = enable }
+}
+
+// CheckJoiners sets whether to check the ContextJ rules as defined in
+// Appendix A of RFC 5892, concerning the use of joiner runes. It is set by
+// ValidateLabels.
+//
+// This option corresponds to the CheckJoiners flag in UTS #46.
+func CheckJoiners(enable bool) Option {
+	return func(o *options) {
+		o.trie = trie
+		o.checkJoiners = enable
+	}
+}
+
 // StrictDomainName limits the set of permissable ASCII characters to those
 // allowed in domain names as defined in RFC 1034 (A-Z, a-z, 0-9 and the
 // hyphen). This is set by default for MapForLookup and ValidateForRegistration.
@@ -152,6 +175,8 @@ type options struct {
 	transitional      bool
 	useSTD3Rules      bool
 	validateLabels    bool
+	checkHyphens      bool
+	checkJoiners      bool
 	verifyDNSLength   bool
 	removeLeadingDots bool
 
@@ -249,6 +274,14 @@ var (
 
 	// Registration is the recommended profile for checking whether a given
 	// IDN is valid for registration, according to Section 4 of RFC 5891.
+	//
+	// Unlike Lookup, it does not map its input: any rune that Lookup would
+	// case fold, map to another rune or remove, such as 'A' or U+00AD SOFT
+	// HYPHEN, is rejected with an error identifying the rune, as is input
+	// that is not in Normalization Form C. Deviation characters such as
+	// 'ß' are kept as is rather than mapped as in the transitional
+	// processing used by Lookup, and the length of the domain name and its
+	// labels is verified.
 	Registration *Profile = registration
 
 	punycode = &Profile{}
@@ -256,6 +289,8 @@ var (
 		transitional:      true,
 		useSTD3Rules:      true,
 		validateLabels:    true,
+		checkHyphens:      true,
+		checkJoiners:      true,
 		removeLeadingDots: true,
 		trie:              trie,
 		fromPuny:          validateFromPunycode,
@@ -265,6 +300,8 @@ var (
 	display = &Profile{options{
 		useSTD3Rules:      true,
 		validateLabels:    true,
+		checkHyphens:      true,
+		checkJoiners:      true,
 		removeLeadingDots: true,
 		trie:              trie,
 		fromPuny:          validateFromPunycode,
@@ -274,16 +311,14 @@ var (
 	registration = &Profile{options{
 		useSTD3Rules:    true,
 		validateLabels:  true,
+		checkHyphens:    true,
+		checkJoiners:    true,
 		verifyDNSLength: true,
 		trie:            trie,
 		fromPuny:        validateFromPunycode,
 		mapping:         validateRegistration,
 		bidirule:        bidirule.ValidString,
 	}}
-
-	// TODO: profiles
-	// Register: recommended for approving domain names: don't do any mappings
-	// but rather reject on invalid input. Bundle or block deviation characters.
 )
 
 type labelError struct{ label, code_ string }
@@ -382,6 +417,21 @@ func (p *Profile) process(s string, toASCII bool) (string, error) {
 	return s, err
 }
 
+// mapString runs the mapping step of p, if any, on s.
+func (p *Profile) mapString(s string) (mapped string, isBidi bool, err error) {
+	if p.mapping == nil {
+		return s, false, nil
+	}
+	mapped, err = p.mapping(p, s)
+	return mapped, false, err
+}
+
+// validateBidi is a no-op, as validateLabel checks the Bidi Rule for each
+// label.
+func (p *Profile) validateBidi(label string, isBidi bool) error {
+	return nil
+}
+
 func normalize(p *Profile, s string) (string, error) {
 	return norm.NFC.String(s), nil
 }
@@ -628,22 +678,27 @@ func (p *Profile) validateLabel(s string) error {
 	if p.bidirule != nil && !p.bidirule(s) {
 		return &labelError{s, "B"}
 	}
-	if !p.validateLabels {
-		return nil
-	}
-	trie := p.trie // p.validateLabels is only set if trie is set.
-	if len(s) > 4 && s[2] == '-' && s[3] == '-' {
-		return &labelError{s, "V2"}
+	if p.checkHyphens {
+		if len(s) > 4 && s[2] == '-' && s[3] == '-' {
+			return &labelError{s, "V2"}
+		}
+		if s[0] == '-' || s[len(s)-1] == '-' {
+			return &labelError{s, "V3"}
+		}
 	}
-	if s[0] == '-' || s[len(s)-1] == '-' {
-		return &labelError{s, "V3"}
+	if !p.validateLabels && !p.checkJoiners {
+		return nil
 	}
+	trie := p.trie // p.trie is set along with both options.
 	// TODO: merge the use of this in the trie.
 	v, sz := trie.lookupString(s)
 	x := info(v)
-	if x.isModifier() {
+	if p.validateLabels && x.isModifier() {
 		return &labelError{s, "V5"}
 	}
+	if !p.checkJoiners {
+		return nil
+	}
 	// Quickly return in the absence of zero-width (non) joiners.
 	if strings.Index(s, zwj) == -1 && strings.Index(s, zwnj) == -1 {
 		return nil
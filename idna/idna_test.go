@@ -5,6 +5,7 @@
 package idna
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -106,3 +107,78 @@ func TestIDNASeparators(t *testing.T) {
 
 // TODO(nigeltao): test errors, once we've specified when ToASCII and ToUnicode
 // return errors.
+
+func TestProfileProcess(t *testing.T) {
+	testCases := []struct {
+		name    string
+		profile *Profile
+		in      string
+		mapped  string
+		errs    []LabelError
+	}{
+		{"Lookup/valid", Lookup, "Bücher.EXAMPLE", "bücher.example", nil},
+		{"Lookup/hyphens", Lookup, "-abc-.de", "-abc-.de", []LabelError{{Label: "-abc-", Code: "V3"}}},
+		{"Lookup/all labels", Lookup, "ab--cd.a_b.com", "ab--cd.a_b.com", []LabelError{
+			{Label: "ab--cd", Code: "V2"},
+			{Label: "a_b", Code: "P1", Rune: '_'},
+		}},
+		{"Lookup/all steps", Lookup, "-a\u200d-.de", "-a\u200d-.de", []LabelError{
+			{Label: "-a\u200d-", Code: "V3"},
+			{Label: "-a\u200d-", Code: "C"},
+		}},
+		{"Lookup/combining mark", Lookup, "\u0300a.com", "\u0300a.com", []LabelError{{Label: "\u0300a", Code: "V5"}}},
+		{"Lookup/punycode", Lookup, "xn--bcher-kva.xn--a", "bücher.\u0080", []LabelError{{Label: "\u0080", Code: "V6"}}},
+		{"Lookup/bidi", Lookup, "aא.com", "aא.com", []LabelError{{Label: "aא", Code: "B"}}},
+		{"Registration/mapping", Registration, "Faß.de", "Faß.de", []LabelError{{Label: "Faß", Code: "P1", Rune: 'F'}}},
+		{"Registration/empty", Registration, "a..b", "a..b", []LabelError{{Label: "", Code: "A4"}}},
+		{"CheckHyphens", New(CheckHyphens(true)), "-a.b-.c", "-a.b-.c", []LabelError{
+			{Label: "-a", Code: "V3"},
+			{Label: "b-", Code: "V3"},
+		}},
+		{"CheckJoiners", New(CheckJoiners(true)), "-a\u200d.b", "-a\u200d.b", []LabelError{{Label: "-a\u200d", Code: "C"}}},
+		{"no CheckHyphens", New(MapForLookup(), CheckHyphens(false)), "-A-.com", "-a-.com", nil},
+	}
+	for _, tc := range testCases {
+		mapped, errs := tc.profile.Process(tc.in)
+		if mapped != tc.mapped {
+			t.Errorf("%s: Process(%q) mapped = %q, want %q", tc.name, tc.in, mapped, tc.mapped)
+		}
+		if !reflect.DeepEqual(errs, tc.errs) {
+			t.Errorf("%s: Process(%q) errs = %v, want %v", tc.name, tc.in, errs, tc.errs)
+		}
+		_, err := tc.profile.ToUnicode(tc.in)
+		if (err != nil) != (len(tc.errs) > 0) {
+			t.Errorf("%s: ToUnicode(%q) = %v, but Process reported %v", tc.name, tc.in, err, errs)
+		}
+	}
+}
+
+func TestRegistrationProfile(t *testing.T) {
+	testCases := []struct {
+		in        string
+		lookup    string // result of Lookup.ToASCII
+		lookupErr bool
+		regErr    string // error of Registration.ToASCII, if any
+	}{
+		{"bücher.example", "xn--bcher-kva.example", false, ""},
+		{"Bücher.example", "xn--bcher-kva.example", false, "idna: disallowed rune U+0042"},
+		{"xn--bcher-kva.example", "xn--bcher-kva.example", false, ""},
+		{"bu\u0308cher.example", "xn--bcher-kva.example", false, "idna: invalid label \"bu\u0308cher.example\""},
+		{"soft\u00adhyphen.example", "softhyphen.example", false, "idna: disallowed rune U+00AD"},
+		{"ｆｕｌｌ.example", "full.example", false, "idna: disallowed rune U+FF46"},
+		{"faß.example", "fass.example", false, ""},
+		{"a_b.example", "a_b.example", true, "idna: disallowed rune U+005F"},
+	}
+	for _, tc := range testCases {
+		got, err := Lookup.ToASCII(tc.in)
+		if got != tc.lookup || (err != nil) != tc.lookupErr {
+			t.Errorf("Lookup.ToASCII(%q) = %q, %v; want %q, error %t", tc.in, got, err, tc.lookup, tc.lookupErr)
+		}
+		_, err = Registration.ToASCII(tc.in)
+		if tc.regErr == "" && err != nil {
+			t.Errorf("Registration.ToASCII(%q) = %v; want no error", tc.in, err)
+		} else if tc.regErr != "" && (err == nil || err.Error() != tc.regErr) {
+			t.Errorf("Registration.ToASCII(%q) = %v; want %s", tc.in, err, tc.regErr)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package idna
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/secure/bidirule"
+	"golang.org/x/text/unicode/bidi"
+)
+
+// A LabelError describes a label of a domain name that failed one of the
+// processing steps of a Profile.
+type LabelError struct {
+	// Label is the offending label, after mapping and, for A-labels,
+	// Punycode decoding.
+	Label string
+
+	// Code is the error code used by the UTS #46 conformance tests:
+	//
+	//	P1  the label contains a disallowed rune, reported in Rune
+	//	V1  the label is not in Unicode Normalization Form C
+	//	V2  the label has hyphens in both the third and fourth position
+	//	V3  the label starts or ends with a hyphen
+	//	V5  the label starts with a combining mark
+	//	V6  a decoded A-label contains a rune that is not valid
+	//	A3  an A-label is not valid Punycode
+	//	A4  the label is empty or too long
+	//	B   the label does not satisfy the Bidi Rule of RFC 5893
+	//	C   the label does not satisfy the ContextJ rules for joiners
+	Code string
+
+	// Rune is the disallowed rune for P1 errors and zero otherwise.
+	Rune rune
+}
+
+func (e LabelError) Error() string {
+	if e.Code == "P1" {
+		return fmt.Sprintf("idna: disallowed rune %U in label %q", e.Rune, e.Label)
+	}
+	return fmt.Sprintf("idna: invalid label %q (%s)", e.Label, e.Code)
+}
+
+// newLabelError converts an error returned by one of the processing steps
+// for label into a LabelError.
+func newLabelError(label string, err error) LabelError {
+	switch e := err.(type) {
+	case *labelError:
+		return LabelError{Label: label, Code: e.code()}
+	case runeError:
+		return LabelError{Label: label, Code: e.code(), Rune: rune(e)}
+	}
+	return LabelError{Label: label, Code: "P1"}
+}
+
+// Process runs the steps of p on s label by label, as ToUnicode does, and
+// returns the mapped domain name along with an error for every step each
+// label fails, rather than stopping at the first error. The steps that run
+// are those selected by the options of p: mapping and normalization
+// (MapForLookup, ValidateForRegistration), the Bidi Rule (BidiRule), the
+// ContextJ rules for joiners (CheckJoiners) and hyphen placement
+// (CheckHyphens).
+//
+// Process does not verify the length of the domain name as a whole, which
+// only applies to ToASCII.
+func (p *Profile) Process(s string) (mapped string, errs []LabelError) {
+	pp := *p
+	pp.transitional = false
+	p = &pp
+
+	s, isBidi, _ := p.mapString(s)
+	if p.removeLeadingDots {
+		for ; len(s) > 0 && s[0] == '.'; s = s[1:] {
+		}
+	}
+	add := func(label string, err error) {
+		if err != nil {
+			errs = append(errs, newLabelError(label, err))
+		}
+	}
+	if p.verifyDNSLength && s == "" {
+		add(s, &labelError{s, "A4"})
+	}
+	labels := labelIter{orig: s}
+	for ; !labels.done(); labels.next() {
+		label := labels.label()
+		if label == "" {
+			// The label iterator skips the last label if it is empty.
+			if p.verifyDNSLength {
+				add(label, &labelError{label, "A4"})
+			}
+			continue
+		}
+		// The mapping step reports only the first error for the whole
+		// name, so run it again for the label, which is idempotent.
+		_, _, err := p.mapString(label)
+		add(label, err)
+		if !strings.HasPrefix(label, acePrefix) {
+			p.validateLabelSteps(label, add)
+			continue
+		}
+		u, err := decode(label[len(acePrefix):])
+		if err != nil {
+			add(label, err)
+			continue
+		}
+		isBidi = isBidi || bidirule.DirectionString(u) != bidi.LeftToRight
+		labels.set(u)
+		if p.validateLabels {
+			add(u, p.fromPuny(p, u))
+		}
+		p.validateLabelSteps(u, add)
+	}
+	for labels.reset(); !labels.done(); labels.next() {
+		label := labels.label()
+		add(label, p.validateBidi(label, isBidi))
+	}
+	return labels.result(), errs
+}
+
+// validateLabelSteps runs each check of validateLabel enabled in p
+// separately, so that all the checks label fails are reported.
+func (p *Profile) validateLabelSteps(label string, add func(label string, err error)) {
+	for _, o := range []options{
+		{bidirule: p.bidirule},
+		{checkHyphens: p.checkHyphens},
+		{validateLabels: p.validateLabels, trie: p.trie},
+		{checkJoiners: p.checkJoiners, trie: p.trie},
+	} {
+		step := Profile{o}
+		add(label, step.validateLabel(label))
+	}
+}
@@ -30,6 +30,29 @@ const (
 
 func punyError(s string) error { return &labelError{s, "A3"} }
 
+// PunycodeEncode encodes a single label, which must be valid UTF-8, using
+// the Punycode algorithm of RFC 3492. The result does not carry the ACE
+// prefix "xn--", and no mapping or validation of the label is done.
+func PunycodeEncode(label string) (string, error) {
+	if !utf8.ValidString(label) {
+		return "", punyError(label)
+	}
+	return encode("", label)
+}
+
+// PunycodeDecode decodes a single label, without its ACE prefix "xn--",
+// that was encoded using the Punycode algorithm of RFC 3492. It returns
+// an error if the label contains non-ASCII characters or is not a valid
+// encoding, including when decoding it overflows.
+func PunycodeDecode(label string) (string, error) {
+	for i := 0; i < len(label); i++ {
+		if label[i] >= utf8.RuneSelf {
+			return "", punyError(label)
+		}
+	}
+	return decode(label)
+}
+
 // decode decodes a string as specified in section 6.2.
 func decode(encoded string) (string, error) {
 	if encoded == "" {
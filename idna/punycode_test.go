@@ -196,3 +196,36 @@ func TestPunycodeErrors(t *testing.T) {
 		}
 	}
 }
+
+func TestPunycodeExported(t *testing.T) {
+	for _, tc := range punycodeTestCases {
+		if got, err := PunycodeDecode(tc.encoded); err != nil {
+			t.Errorf("PunycodeDecode(%q): %v", tc.encoded, err)
+		} else if got != tc.s {
+			t.Errorf("PunycodeDecode(%q): got %q, want %q", tc.encoded, got, tc.s)
+		}
+
+		if got, err := PunycodeEncode(tc.s); err != nil {
+			t.Errorf("PunycodeEncode(%q): %v", tc.s, err)
+		} else if got != tc.encoded {
+			t.Errorf("PunycodeEncode(%q): got %q, want %q", tc.s, got, tc.encoded)
+		}
+	}
+
+	for _, s := range []string{
+		"b\u00fccher-kva", // Non-ASCII basic code points.
+		"9999999999a",     // Overflow.
+	} {
+		if _, err := PunycodeDecode(s); err == nil {
+			t.Errorf("PunycodeDecode(%q): no error", s)
+		}
+	}
+	for _, s := range []string{
+		"b\xffcher",                           // Invalid UTF-8.
+		strings.Repeat("x", 65536) + "\uff00", // Overflow.
+	} {
+		if _, err := PunycodeEncode(s); err == nil {
+			t.Errorf("PunycodeEncode(%.20q): no error", s)
+		}
+	}
+}
@@ -18,11 +18,50 @@ var (
 	aLongTimeAgo = time.Unix(1, 0)
 )
 
-func (d *Dialer) connect(ctx context.Context, c net.Conn, address string) (_ net.Addr, ctxErr error) {
+func (d *Dialer) connect(ctx context.Context, c net.Conn, network, address string) (net.Conn, net.Addr, error) {
 	host, port, err := splitHostPort(address)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	if host, err = d.resolve(ctx, network, host); err != nil {
+		return nil, nil, err
+	}
+	return d.request(ctx, c, d.cmd, host, port)
+}
+
+// resolve returns host unchanged unless d.Resolver is set and host is
+// a name, in which case it returns the first address of host that
+// suits network.
+func (d *Dialer) resolve(ctx context.Context, network, host string) (string, error) {
+	if d.Resolver == nil || host == "" || net.ParseIP(host) != nil {
+		return host, nil
+	}
+	ips, err := d.Resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return "", err
+	}
+	for _, ip := range ips {
+		switch network[len(network)-1] {
+		case '4':
+			if ip.IP.To4() == nil {
+				continue
+			}
+		case '6':
+			if ip.IP.To4() != nil {
+				continue
+			}
+		}
+		return ip.IP.String(), nil
+	}
+	return "", &net.AddrError{Err: "no suitable address found", Addr: host}
+}
+
+// request performs the method negotiation and authentication, sends
+// the command cmd with the destination host and port, and returns the
+// connection to use from then on, which is c unless the authentication
+// method protects messages, and the bound address from the proxy
+// server's reply.
+func (d *Dialer) request(ctx context.Context, c net.Conn, cmd Command, host string, port int) (_ net.Conn, _ net.Addr, ctxErr error) {
 	if deadline, ok := ctx.Deadline(); ok && !deadline.IsZero() {
 		c.SetDeadline(deadline)
 		defer c.SetDeadline(noDeadline)
@@ -49,18 +88,17 @@ func (d *Dialer) connect(ctx context.Context, c net.Conn, address string) (_ net
 
 	b := make([]byte, 0, 6+len(host)) // the size here is just an estimate
 	b = append(b, Version5)
-	if len(d.AuthMethods) == 0 || d.Authenticate == nil {
-		b = append(b, 1, byte(AuthMethodNotRequired))
-	} else {
-		ams := d.AuthMethods
+	ams := []AuthMethod{AuthMethodNotRequired}
+	if len(d.AuthMethods) > 0 && (d.Authenticate != nil || d.Authenticator != nil) {
+		ams = d.AuthMethods
 		if len(ams) > 255 {
-			return nil, errors.New("too many authentication methods")
-		}
-		b = append(b, byte(len(ams)))
-		for _, am := range ams {
-			b = append(b, byte(am))
+			return nil, nil, errors.New("too many authentication methods")
 		}
 	}
+	b = append(b, byte(len(ams)))
+	for _, am := range ams {
+		b = append(b, byte(am))
+	}
 	if _, ctxErr = c.Write(b); ctxErr != nil {
 		return
 	}
@@ -69,54 +107,55 @@ func (d *Dialer) connect(ctx context.Context, c net.Conn, address string) (_ net
 		return
 	}
 	if b[0] != Version5 {
-		return nil, errors.New("unexpected protocol version " + strconv.Itoa(int(b[0])))
+		return nil, nil, errors.New("unexpected protocol version " + strconv.Itoa(int(b[0])))
 	}
 	am := AuthMethod(b[1])
 	if am == AuthMethodNoAcceptableMethods {
-		return nil, errors.New("no acceptable authentication methods")
+		return nil, nil, errors.New("no acceptable authentication methods")
+	}
+	// A server selecting a method that was not offered could
+	// otherwise downgrade the connection, for instance to
+	// AuthMethodNotRequired.
+	offered := false
+	for _, a := range ams {
+		if a == am {
+			offered = true
+		}
 	}
-	if d.Authenticate != nil {
+	if !offered {
+		return nil, nil, errors.New("unexpected authentication method " + strconv.Itoa(int(am)))
+	}
+	conn := c
+	if d.Authenticator != nil {
+		if conn, ctxErr = d.Authenticator.Authenticate(ctx, c, am); ctxErr != nil {
+			return
+		}
+	} else if d.Authenticate != nil {
 		if ctxErr = d.Authenticate(ctx, c, am); ctxErr != nil {
 			return
 		}
 	}
 
 	b = b[:0]
-	b = append(b, Version5, byte(d.cmd), 0)
-	if ip := net.ParseIP(host); ip != nil {
-		if ip4 := ip.To4(); ip4 != nil {
-			b = append(b, AddrTypeIPv4)
-			b = append(b, ip4...)
-		} else if ip6 := ip.To16(); ip6 != nil {
-			b = append(b, AddrTypeIPv6)
-			b = append(b, ip6...)
-		} else {
-			return nil, errors.New("unknown address type")
-		}
-	} else {
-		if len(host) > 255 {
-			return nil, errors.New("FQDN too long")
-		}
-		b = append(b, AddrTypeFQDN)
-		b = append(b, byte(len(host)))
-		b = append(b, host...)
+	b = append(b, Version5, byte(cmd), 0)
+	if b, ctxErr = appendAddr(b, host, port); ctxErr != nil {
+		return
 	}
-	b = append(b, byte(port>>8), byte(port))
-	if _, ctxErr = c.Write(b); ctxErr != nil {
+	if _, ctxErr = conn.Write(b); ctxErr != nil {
 		return
 	}
 
-	if _, ctxErr = io.ReadFull(c, b[:4]); ctxErr != nil {
+	if _, ctxErr = io.ReadFull(conn, b[:4]); ctxErr != nil {
 		return
 	}
 	if b[0] != Version5 {
-		return nil, errors.New("unexpected protocol version " + strconv.Itoa(int(b[0])))
+		return nil, nil, errors.New("unexpected protocol version " + strconv.Itoa(int(b[0])))
 	}
 	if cmdErr := Reply(b[1]); cmdErr != StatusSucceeded {
-		return nil, errors.New("unknown error " + cmdErr.String())
+		return nil, nil, errors.New("unknown error " + cmdErr.String())
 	}
 	if b[2] != 0 {
-		return nil, errors.New("non-zero reserved field")
+		return nil, nil, errors.New("non-zero reserved field")
 	}
 	l := 2
 	var a Addr
@@ -128,19 +167,19 @@ func (d *Dialer) connect(ctx context.Context, c net.Conn, address string) (_ net
 		l += net.IPv6len
 		a.IP = make(net.IP, net.IPv6len)
 	case AddrTypeFQDN:
-		if _, err := io.ReadFull(c, b[:1]); err != nil {
-			return nil, err
+		if _, err := io.ReadFull(conn, b[:1]); err != nil {
+			return nil, nil, err
 		}
 		l += int(b[0])
 	default:
-		return nil, errors.New("unknown address type " + strconv.Itoa(int(b[3])))
+		return nil, nil, errors.New("unknown address type " + strconv.Itoa(int(b[3])))
 	}
 	if cap(b) < l {
 		b = make([]byte, l)
 	} else {
 		b = b[:l]
 	}
-	if _, ctxErr = io.ReadFull(c, b); ctxErr != nil {
+	if _, ctxErr = io.ReadFull(conn, b); ctxErr != nil {
 		return
 	}
 	if a.IP != nil {
@@ -149,7 +188,31 @@ func (d *Dialer) connect(ctx context.Context, c net.Conn, address string) (_ net
 		a.Name = string(b[:len(b)-2])
 	}
 	a.Port = int(b[len(b)-2])<<8 | int(b[len(b)-1])
-	return &a, nil
+	return conn, &a, nil
+}
+
+// appendAddr appends the wire format of host and port, preceded by the
+// address type, to b.
+func appendAddr(b []byte, host string, port int) ([]byte, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			b = append(b, AddrTypeIPv4)
+			b = append(b, ip4...)
+		} else if ip6 := ip.To16(); ip6 != nil {
+			b = append(b, AddrTypeIPv6)
+			b = append(b, ip6...)
+		} else {
+			return nil, errors.New("unknown address type")
+		}
+	} else {
+		if len(host) > 255 {
+			return nil, errors.New("FQDN too long")
+		}
+		b = append(b, AddrTypeFQDN)
+		b = append(b, byte(len(host)))
+		b = append(b, host...)
+	}
+	return append(b, byte(port>>8), byte(port)), nil
 }
 
 func splitHostPort(address string) (string, int, error) {
@@ -6,6 +6,7 @@ package socks_test
 
 import (
 	"context"
+	"errors"
 	"io"
 	"math/rand"
 	"net"
@@ -127,6 +128,92 @@ func TestDial(t *testing.T) {
 			}
 		}
 	})
+	t.Run("UDPAssociate", func(t *testing.T) {
+		ss, err := sockstest.NewServer(sockstest.NoAuthRequired, udpRelayCmdFunc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ss.Close()
+		d := socks.NewDialer(ss.Addr().Network(), ss.Addr().String())
+		c, err := d.DialPacketContext(context.Background(), "udp", ss.TargetAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		c.SetDeadline(time.Now().Add(5 * time.Second))
+		for _, dst := range []net.Addr{
+			ss.TargetAddr(),
+			&net.UDPAddr{IP: net.IPv6loopback, Port: 53},
+			&socks.Addr{Name: "fqdn.doesnotexist", Port: 53},
+		} {
+			if _, err := c.WriteTo([]byte("hello"), dst); err != nil {
+				t.Fatal(err)
+			}
+			b := make([]byte, 16)
+			n, src, err := c.ReadFrom(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b[:n]) != "hello" || src.String() != dst.String() {
+				t.Errorf("got %q from %v; want %q from %v", b[:n], src, "hello", dst)
+			}
+		}
+		if _, err := c.(net.Conn).Write([]byte("world")); err != nil {
+			t.Fatal(err)
+		}
+		b := make([]byte, 16)
+		n, err := c.(net.Conn).Read(b)
+		if err != nil || string(b[:n]) != "world" {
+			t.Errorf("Read = %q, %v; want %q", b[:n], err, "world")
+		}
+	})
+}
+
+// udpRelayCmdFunc handles a UDP ASSOCIATE command with a relay that
+// echoes each datagram back to the client, dropping fragments.
+func udpRelayCmdFunc(rw io.ReadWriter, b []byte) error {
+	req, err := sockstest.ParseCmdRequest(b)
+	if err != nil {
+		return err
+	}
+	if req.Cmd != socks.CmdUDPAssociate {
+		return errors.New("unexpected command")
+	}
+	relay, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	defer relay.Close()
+	// Report an unspecified address so that the client substitutes
+	// the proxy server's address.
+	a := &socks.Addr{IP: net.IPv4zero, Port: relay.LocalAddr().(*net.UDPAddr).Port}
+	b, err = sockstest.MarshalCmdReply(socks.Version5, socks.StatusSucceeded, a)
+	if err != nil {
+		return err
+	}
+	if _, err := rw.Write(b); err != nil {
+		return err
+	}
+	go func() {
+		b := make([]byte, 512)
+		for {
+			n, src, err := relay.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			if n < 4 || b[2] != 0 {
+				continue
+			}
+			relay.WriteTo(b[:n], src)
+		}
+	}()
+	// The association terminates when the control connection closes.
+	var bb [1]byte
+	for {
+		if _, err := rw.Read(bb[:]); err != nil {
+			return err
+		}
+	}
 }
 
 func blackholeCmdFunc(rw io.ReadWriter, b []byte) error {
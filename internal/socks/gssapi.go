@@ -0,0 +1,220 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package socks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+)
+
+// An Authenticator performs the sub-negotiation of an authentication
+// method. Unlike the Dialer's Authenticate function, it may return a
+// connection that protects the messages exchanged after the
+// sub-negotiation, which is then used in place of c.
+type Authenticator interface {
+	Authenticate(ctx context.Context, c net.Conn, am AuthMethod) (net.Conn, error)
+}
+
+// A GSSAPIMechanism performs the GSS-API calls used by the GSSAPI
+// authentication method. Wrap and Unwrap may be called concurrently.
+type GSSAPIMechanism interface {
+	// InitSecContext continues establishing the security context
+	// with the token received from the proxy server, which is nil
+	// on the first call, like gss_init_sec_context. It returns the
+	// token to send to the server, if any, and whether the context
+	// is established.
+	InitSecContext(token []byte) (output []byte, established bool, err error)
+
+	// Wrap protects msg, encrypting it if confidential is true,
+	// like gss_wrap.
+	Wrap(msg []byte, confidential bool) ([]byte, error)
+
+	// Unwrap verifies, and decrypts if needed, a token wrapped by
+	// the server, like gss_unwrap.
+	Unwrap(token []byte) ([]byte, error)
+}
+
+// A GSSAPIProtectionLevel is the per-message protection negotiated by
+// the GSSAPI authentication method.
+type GSSAPIProtectionLevel byte
+
+const (
+	GSSAPIIntegrity       GSSAPIProtectionLevel = 0x01 // per-message integrity
+	GSSAPIConfidentiality GSSAPIProtectionLevel = 0x02 // per-message integrity and confidentiality
+)
+
+// Wire protocol constants of the GSSAPI authentication method.
+const (
+	gssapiVersion = 0x01
+
+	gssapiMsgAuth          = 0x01 // security context establishment
+	gssapiMsgProtection    = 0x02 // protection level negotiation
+	gssapiMsgEncapsulation = 0x03 // protected message
+	gssapiMsgAbort         = 0xff
+
+	// gssapiMaxChunk is the size of the chunks in which written
+	// data is wrapped, leaving room for the overhead of wrapping
+	// in the 16-bit length of a message.
+	gssapiMaxChunk = 32 << 10
+)
+
+// GSSAPI is an Authenticator for the GSSAPI authentication method.
+// See RFC 1961.
+type GSSAPI struct {
+	Mechanism GSSAPIMechanism
+
+	// ProtectionLevel is the protection requested from the proxy
+	// server, which chooses the level used. If zero,
+	// GSSAPIIntegrity is requested.
+	ProtectionLevel GSSAPIProtectionLevel
+}
+
+// Authenticate establishes a security context with the proxy server,
+// negotiates the protection level, and returns a connection that
+// protects the messages it reads and writes accordingly.
+func (g *GSSAPI) Authenticate(ctx context.Context, c net.Conn, am AuthMethod) (net.Conn, error) {
+	if am != AuthMethodGSSAPI {
+		return nil, errors.New("unsupported authentication method " + strconv.Itoa(int(am)))
+	}
+	// IO deadlines and cancelation are handled by the Dialer, which
+	// sets deadlines on the connection while authenticating.
+	var token []byte
+	for {
+		out, established, err := g.Mechanism.InitSecContext(token)
+		if err != nil {
+			return nil, err
+		}
+		if len(out) > 0 {
+			if err := writeGSSAPIMessage(c, gssapiMsgAuth, out); err != nil {
+				return nil, err
+			}
+		}
+		if established {
+			break
+		}
+		if token, err = readGSSAPIMessage(c, gssapiMsgAuth); err != nil {
+			return nil, err
+		}
+	}
+
+	level := g.ProtectionLevel
+	if level == 0 {
+		level = GSSAPIIntegrity
+	}
+	token, err := g.Mechanism.Wrap([]byte{byte(level)}, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeGSSAPIMessage(c, gssapiMsgProtection, token); err != nil {
+		return nil, err
+	}
+	if token, err = readGSSAPIMessage(c, gssapiMsgProtection); err != nil {
+		return nil, err
+	}
+	b, err := g.Mechanism.Unwrap(token)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != 1 {
+		return nil, errors.New("invalid GSSAPI protection level message")
+	}
+	switch level = GSSAPIProtectionLevel(b[0]); level {
+	case GSSAPIIntegrity, GSSAPIConfidentiality:
+	default:
+		return nil, errors.New("unsupported GSSAPI protection level " + strconv.Itoa(int(level)))
+	}
+	return &gssapiConn{Conn: c, mech: g.Mechanism, confidential: level == GSSAPIConfidentiality}, nil
+}
+
+func writeGSSAPIMessage(w io.Writer, mtyp byte, token []byte) error {
+	if len(token) > 0xffff {
+		return errors.New("GSSAPI token too long")
+	}
+	b := make([]byte, 0, 4+len(token))
+	b = append(b, gssapiVersion, mtyp, byte(len(token)>>8), byte(len(token)))
+	b = append(b, token...)
+	_, err := w.Write(b)
+	return err
+}
+
+// readGSSAPIMessage reads a message of type mtyp and returns its token.
+// It returns io.EOF if r is at EOF.
+func readGSSAPIMessage(r io.Reader, mtyp byte) ([]byte, error) {
+	var b [4]byte
+	if _, err := io.ReadFull(r, b[:2]); err != nil {
+		return nil, err
+	}
+	if b[1] == gssapiMsgAbort {
+		return nil, errors.New("GSSAPI authentication aborted by the proxy server")
+	}
+	if b[0] != gssapiVersion {
+		return nil, errors.New("unexpected GSSAPI version " + strconv.Itoa(int(b[0])))
+	}
+	if b[1] != mtyp {
+		return nil, errors.New("unexpected GSSAPI message type " + strconv.Itoa(int(b[1])))
+	}
+	if _, err := io.ReadFull(r, b[2:4]); err != nil {
+		return nil, noEOF(err)
+	}
+	token := make([]byte, int(b[2])<<8|int(b[3]))
+	if _, err := io.ReadFull(r, token); err != nil {
+		return nil, noEOF(err)
+	}
+	return token, nil
+}
+
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// A gssapiConn encapsulates the data it reads and writes in GSSAPI
+// messages, protected by a security context.
+type gssapiConn struct {
+	net.Conn
+	mech         GSSAPIMechanism
+	confidential bool
+	buf          []byte // unwrapped data not yet read
+}
+
+func (c *gssapiConn) Read(b []byte) (int, error) {
+	for len(c.buf) == 0 {
+		token, err := readGSSAPIMessage(c.Conn, gssapiMsgEncapsulation)
+		if err != nil {
+			return 0, err
+		}
+		if c.buf, err = c.mech.Unwrap(token); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(b, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
+
+func (c *gssapiConn) Write(b []byte) (int, error) {
+	n := 0
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > gssapiMaxChunk {
+			chunk = chunk[:gssapiMaxChunk]
+		}
+		token, err := c.mech.Wrap(chunk, c.confidential)
+		if err != nil {
+			return n, err
+		}
+		if err := writeGSSAPIMessage(c.Conn, gssapiMsgEncapsulation, token); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+		b = b[len(chunk):]
+	}
+	return n, nil
+}
@@ -0,0 +1,222 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package socks_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/internal/socks"
+)
+
+// toyMechanism is a GSSAPIMechanism that establishes a context in one
+// round trip and wraps messages by prefixing them with a byte telling
+// whether they are confidential.
+type toyMechanism struct {
+	round int
+}
+
+func (m *toyMechanism) InitSecContext(token []byte) ([]byte, bool, error) {
+	m.round++
+	switch m.round {
+	case 1:
+		return []byte("hello"), false, nil
+	case 2:
+		if string(token) != "world" {
+			return nil, false, errors.New("bad token " + string(token))
+		}
+		return nil, true, nil
+	}
+	return nil, false, errors.New("context already established")
+}
+
+func (m *toyMechanism) Wrap(msg []byte, confidential bool) ([]byte, error) {
+	if confidential {
+		return append([]byte{'c'}, msg...), nil
+	}
+	return append([]byte{'i'}, msg...), nil
+}
+
+func (m *toyMechanism) Unwrap(token []byte) ([]byte, error) {
+	if len(token) == 0 {
+		return nil, errors.New("empty token")
+	}
+	return token[1:], nil
+}
+
+func writeGSSAPI(w io.Writer, mtyp byte, token []byte) error {
+	_, err := w.Write(append([]byte{1, mtyp, byte(len(token) >> 8), byte(len(token))}, token...))
+	return err
+}
+
+func readGSSAPI(r io.Reader, mtyp byte) ([]byte, error) {
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	if b[0] != 1 || b[1] != mtyp {
+		return nil, errors.New("unexpected GSSAPI message header")
+	}
+	b = make([]byte, int(b[2])<<8|int(b[3]))
+	_, err := io.ReadFull(r, b)
+	return b, err
+}
+
+// serveGSSAPI performs the server side of the GSSAPI method on c,
+// agreeing to the requested protection level, and then echoes the
+// data it receives. It reports the wrapping prefix of the data.
+func serveGSSAPI(c net.Conn, prefix chan<- byte) error {
+	defer c.Close()
+	b := make([]byte, 3)
+	if _, err := io.ReadFull(c, b); err != nil {
+		return err
+	}
+	if !bytes.Equal(b, []byte{socks.Version5, 1, byte(socks.AuthMethodGSSAPI)}) {
+		return errors.New("unexpected greeting")
+	}
+	if _, err := c.Write([]byte{socks.Version5, byte(socks.AuthMethodGSSAPI)}); err != nil {
+		return err
+	}
+	token, err := readGSSAPI(c, 1)
+	if err != nil {
+		return err
+	}
+	if string(token) != "hello" {
+		return errors.New("unexpected token " + string(token))
+	}
+	if err := writeGSSAPI(c, 1, []byte("world")); err != nil {
+		return err
+	}
+	level, err := readGSSAPI(c, 2)
+	if err != nil {
+		return err
+	}
+	if err := writeGSSAPI(c, 2, level); err != nil {
+		return err
+	}
+	req, err := readGSSAPI(c, 3)
+	if err != nil {
+		return err
+	}
+	if len(req) < 5 || req[2] != byte(socks.CmdConnect) {
+		return errors.New("unexpected request")
+	}
+	// The request is still wrapped; wrap the reply likewise.
+	reply := []byte{req[0], socks.Version5, byte(socks.StatusSucceeded), 0, socks.AddrTypeIPv4, 127, 0, 0, 1, 0x12, 0x34}
+	if err := writeGSSAPI(c, 3, reply); err != nil {
+		return err
+	}
+	for {
+		data, err := readGSSAPI(c, 3)
+		if err != nil {
+			return err
+		}
+		prefix <- data[0]
+		if err := writeGSSAPI(c, 3, data); err != nil {
+			return err
+		}
+	}
+}
+
+func TestGSSAPI(t *testing.T) {
+	for _, level := range []socks.GSSAPIProtectionLevel{socks.GSSAPIIntegrity, socks.GSSAPIConfidentiality} {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+		prefix := make(chan byte, 16)
+		errc := make(chan error, 1)
+		go func() {
+			c, err := ln.Accept()
+			if err != nil {
+				errc <- err
+				return
+			}
+			errc <- serveGSSAPI(c, prefix)
+		}()
+
+		d := socks.NewDialer("tcp", ln.Addr().String())
+		d.AuthMethods = []socks.AuthMethod{socks.AuthMethodGSSAPI}
+		d.Authenticator = &socks.GSSAPI{Mechanism: new(toyMechanism), ProtectionLevel: level}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		c, err := d.DialContext(ctx, "tcp", "192.0.2.1:80")
+		if err != nil {
+			t.Fatalf("level %d: %v (server: %v)", level, err, <-errc)
+		}
+		if a := c.(*socks.Conn).BoundAddr().String(); a != "127.0.0.1:4660" {
+			t.Errorf("level %d: bound address = %s, want 127.0.0.1:4660", level, a)
+		}
+		c.SetDeadline(time.Now().Add(10 * time.Second))
+		msg := bytes.Repeat([]byte("0123456789"), 10000)
+		go c.Write(msg)
+		got := make([]byte, len(msg))
+		if _, err := io.ReadFull(c, got); err != nil {
+			t.Fatalf("level %d: %v", level, err)
+		}
+		if !bytes.Equal(got, msg) {
+			t.Errorf("level %d: echoed data differs", level)
+		}
+		want := byte('i')
+		if level == socks.GSSAPIConfidentiality {
+			want = 'c'
+		}
+		if p := <-prefix; p != want {
+			t.Errorf("level %d: data wrapped with %q, want %q", level, p, want)
+		}
+		c.Close()
+		if err := <-errc; err != io.EOF {
+			t.Errorf("level %d: server: %v", level, err)
+		}
+	}
+}
+
+func TestGSSAPIDowngrade(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		b := make([]byte, 3)
+		if _, err := io.ReadFull(c, b); err != nil {
+			return
+		}
+		// Select no authentication although only GSSAPI was offered,
+		// then grant the request.
+		c.Write([]byte{socks.Version5, byte(socks.AuthMethodNotRequired)})
+		io.ReadFull(c, make([]byte, 10))
+		c.Write([]byte{socks.Version5, byte(socks.StatusSucceeded), 0, socks.AddrTypeIPv4, 127, 0, 0, 1, 0x12, 0x34})
+	}()
+
+	d := socks.NewDialer("tcp", ln.Addr().String())
+	d.AuthMethods = []socks.AuthMethod{socks.AuthMethodGSSAPI}
+	d.Authenticator = &socks.GSSAPI{Mechanism: new(toyMechanism)}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if c, err := d.DialContext(ctx, "tcp", "192.0.2.1:80"); err == nil {
+		c.Close()
+		t.Fatal("DialContext succeeded after the server selected no authentication; want error")
+	}
+
+	g := &socks.GSSAPI{Mechanism: new(toyMechanism)}
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	if _, err := g.Authenticate(ctx, c1, socks.AuthMethodNotRequired); err == nil {
+		t.Error("Authenticate with AuthMethodNotRequired succeeded; want error")
+	}
+}
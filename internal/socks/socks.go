@@ -26,6 +26,8 @@ func (cmd Command) String() string {
 		return "socks connect"
 	case cmdBind:
 		return "socks bind"
+	case CmdUDPAssociate:
+		return "socks udp associate"
 	default:
 		return "socks " + strconv.Itoa(int(cmd))
 	}
@@ -73,7 +75,10 @@ const (
 	CmdConnect Command = 0x01 // establishes an active-open forward proxy connection
 	cmdBind    Command = 0x02 // establishes a passive-open forward proxy connection
 
+	CmdUDPAssociate Command = 0x03 // establishes an association for relaying UDP datagrams
+
 	AuthMethodNotRequired         AuthMethod = 0x00 // no authentication required
+	AuthMethodGSSAPI              AuthMethod = 0x01 // use GSS-API
 	AuthMethodUsernamePassword    AuthMethod = 0x02 // use username/password
 	AuthMethodNoAcceptableMethods AuthMethod = 0xff // no acceptable authentication methods
 
@@ -136,6 +141,19 @@ type Dialer struct {
 	// function. It must be non-nil when AuthMethods is not empty.
 	// It must return an error when the authentication is failed.
 	Authenticate func(context.Context, io.ReadWriter, AuthMethod) error
+
+	// Resolver, if non-nil, resolves the host names of target
+	// addresses on the client side, so that the proxy server is
+	// sent IP addresses. Otherwise the proxy server resolves them.
+	Resolver *net.Resolver
+
+	// Authenticator specifies the optional authenticator, which is
+	// used instead of Authenticate if non-nil. It must be non-nil
+	// when AuthMethods includes a method that protects the
+	// messages exchanged after authentication, such as
+	// AuthMethodGSSAPI. The connections of such methods are not
+	// supported by DialWithConn or DialPacketContext.
+	Authenticator Authenticator
 }
 
 // DialContext connects to the provided address on the provided
@@ -169,13 +187,13 @@ func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.
 		proxy, dst, _ := d.pathAddrs(address)
 		return nil, &net.OpError{Op: d.cmd.String(), Net: network, Source: proxy, Addr: dst, Err: err}
 	}
-	a, err := d.connect(ctx, c, address)
+	conn, a, err := d.connect(ctx, c, network, address)
 	if err != nil {
 		c.Close()
 		proxy, dst, _ := d.pathAddrs(address)
 		return nil, &net.OpError{Op: d.cmd.String(), Net: network, Source: proxy, Addr: dst, Err: err}
 	}
-	return &Conn{Conn: c, boundAddr: a}, nil
+	return &Conn{Conn: conn, boundAddr: a}, nil
 }
 
 // DialWithConn initiates a connection from SOCKS server to the target
@@ -193,7 +211,10 @@ func (d *Dialer) DialWithConn(ctx context.Context, c net.Conn, network, address
 		proxy, dst, _ := d.pathAddrs(address)
 		return nil, &net.OpError{Op: d.cmd.String(), Net: network, Source: proxy, Addr: dst, Err: errors.New("nil context")}
 	}
-	a, err := d.connect(ctx, c, address)
+	conn, a, err := d.connect(ctx, c, network, address)
+	if err == nil && conn != c {
+		err = errors.New("authentication method requires DialContext")
+	}
 	if err != nil {
 		proxy, dst, _ := d.pathAddrs(address)
 		return nil, &net.OpError{Op: d.cmd.String(), Net: network, Source: proxy, Addr: dst, Err: err}
@@ -223,11 +244,13 @@ func (d *Dialer) Dial(network, address string) (net.Conn, error) {
 		proxy, dst, _ := d.pathAddrs(address)
 		return nil, &net.OpError{Op: d.cmd.String(), Net: network, Source: proxy, Addr: dst, Err: err}
 	}
-	if _, err := d.DialWithConn(context.Background(), c, network, address); err != nil {
+	conn, _, err := d.connect(context.Background(), c, network, address)
+	if err != nil {
 		c.Close()
-		return nil, err
+		proxy, dst, _ := d.pathAddrs(address)
+		return nil, &net.OpError{Op: d.cmd.String(), Net: network, Source: proxy, Addr: dst, Err: err}
 	}
-	return c, nil
+	return conn, nil
 }
 
 func (d *Dialer) validateTarget(network, address string) error {
@@ -297,8 +320,9 @@ func (up *UsernamePassword) Authenticate(ctx context.Context, rw io.ReadWriter,
 		b = append(b, up.Username...)
 		b = append(b, byte(len(up.Password)))
 		b = append(b, up.Password...)
-		// TODO(mikio): handle IO deadlines and cancelation if
-		// necessary
+		// IO deadlines and cancelation are handled by the
+		// Dialer, which sets deadlines on the connection
+		// while authenticating.
 		if _, err := rw.Write(b); err != nil {
 			return err
 		}
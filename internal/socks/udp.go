@@ -0,0 +1,255 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package socks
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxUDPHeaderLen is the maximum length of the header preceding each
+// relayed datagram: RSV, FRAG, ATYP, a 255-byte FQDN and DST.PORT.
+const maxUDPHeaderLen = 2 + 1 + 1 + 1 + 255 + 2
+
+// A PacketConn represents a UDP association established with the UDP
+// ASSOCIATE command. It relays datagrams through the proxy server
+// and implements the net.PacketConn and net.Conn interfaces.
+//
+// The association lasts as long as the TCP connection to the proxy
+// server on which it was requested; closing the PacketConn closes
+// both.
+type PacketConn struct {
+	ctrl      net.Conn     // connection on which the association was requested
+	conn      *net.UDPConn // connected to the relay server
+	raddr     net.Addr     // destination of Write
+	boundAddr net.Addr
+
+	rmu  sync.Mutex // guards rbuf
+	rbuf []byte     // reused by ReadFrom for the datagram and its header
+}
+
+// BoundAddr returns the address of the relay server assigned by the
+// proxy server for the association.
+func (c *PacketConn) BoundAddr() net.Addr {
+	if c == nil {
+		return nil
+	}
+	return c.boundAddr
+}
+
+// ReadFrom reads a datagram relayed by the proxy server and returns
+// the number of bytes copied into p and the datagram's source address.
+// The address is a *net.UDPAddr, or an *Addr when the relay server
+// reports a domain name. Fragmented and malformed datagrams are
+// dropped.
+func (c *PacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	if cap(c.rbuf) < maxUDPHeaderLen+len(p) {
+		c.rbuf = make([]byte, maxUDPHeaderLen+len(p))
+	}
+	b := c.rbuf[:maxUDPHeaderLen+len(p)]
+	for {
+		n, err := c.conn.Read(b)
+		if err != nil {
+			return 0, nil, err
+		}
+		a, off, err := parseUDPHeader(b[:n])
+		if err != nil {
+			continue
+		}
+		return copy(p, b[off:n]), a, nil
+	}
+}
+
+// WriteTo sends p to addr through the relay server.
+func (c *PacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	if addr == nil {
+		return 0, &net.OpError{Op: "write", Net: "udp", Source: c.LocalAddr(), Err: errors.New("missing address")}
+	}
+	host, port, err := splitHostPort(addr.String())
+	if err != nil {
+		return 0, &net.OpError{Op: "write", Net: "udp", Source: c.LocalAddr(), Addr: addr, Err: err}
+	}
+	b := make([]byte, 0, maxUDPHeaderLen+len(p))
+	b = append(b, 0, 0, 0) // RSV and FRAG
+	if b, err = appendAddr(b, host, port); err != nil {
+		return 0, &net.OpError{Op: "write", Net: "udp", Source: c.LocalAddr(), Addr: addr, Err: err}
+	}
+	b = append(b, p...)
+	if _, err := c.conn.Write(b); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Read reads a datagram relayed by the proxy server, from any source.
+func (c *PacketConn) Read(b []byte) (int, error) {
+	n, _, err := c.ReadFrom(b)
+	return n, err
+}
+
+// Write sends b to the address passed to DialPacketContext.
+func (c *PacketConn) Write(b []byte) (int, error) {
+	return c.WriteTo(b, c.raddr)
+}
+
+// Close closes the association.
+func (c *PacketConn) Close() error {
+	err := c.conn.Close()
+	if cerr := c.ctrl.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// LocalAddr returns the local address of the UDP socket.
+func (c *PacketConn) LocalAddr() net.Addr { return c.conn.LocalAddr() }
+
+// RemoteAddr returns the address passed to DialPacketContext.
+func (c *PacketConn) RemoteAddr() net.Addr { return c.raddr }
+
+func (c *PacketConn) SetDeadline(t time.Time) error      { return c.conn.SetDeadline(t) }
+func (c *PacketConn) SetReadDeadline(t time.Time) error  { return c.conn.SetReadDeadline(t) }
+func (c *PacketConn) SetWriteDeadline(t time.Time) error { return c.conn.SetWriteDeadline(t) }
+
+// DialPacketContext establishes a UDP association with the proxy
+// server using the UDP ASSOCIATE command and returns a PacketConn
+// relaying datagrams through it. The network must be "udp", "udp4" or
+// "udp6". The address is the destination used by the PacketConn's
+// Write method; WriteTo may send to any address.
+//
+// The returned error value may be a net.OpError, as described for
+// DialContext.
+func (d *Dialer) DialPacketContext(ctx context.Context, network, address string) (net.PacketConn, error) {
+	cmd := CmdUDPAssociate
+	proxy, dst, _ := d.pathAddrs(address)
+	switch network {
+	case "udp", "udp4", "udp6":
+	default:
+		return nil, &net.OpError{Op: cmd.String(), Net: network, Source: proxy, Addr: dst, Err: errors.New("network not implemented")}
+	}
+	if ctx == nil {
+		return nil, &net.OpError{Op: cmd.String(), Net: network, Source: proxy, Addr: dst, Err: errors.New("nil context")}
+	}
+	c, err := d.associate(ctx, network, address)
+	if err != nil {
+		return nil, &net.OpError{Op: cmd.String(), Net: network, Source: proxy, Addr: dst, Err: err}
+	}
+	return c, nil
+}
+
+// DialPacket is like DialPacketContext but uses context.Background.
+func (d *Dialer) DialPacket(network, address string) (net.PacketConn, error) {
+	return d.DialPacketContext(context.Background(), network, address)
+}
+
+func (d *Dialer) associate(ctx context.Context, network, address string) (*PacketConn, error) {
+	host, port, err := splitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if host, err = d.resolve(ctx, network, host); err != nil {
+		return nil, err
+	}
+	var raddr net.Addr = &Addr{Name: host, Port: port}
+	if ip := net.ParseIP(host); ip != nil {
+		raddr = &net.UDPAddr{IP: ip, Port: port}
+	}
+	var c net.Conn
+	if d.ProxyDial != nil {
+		c, err = d.ProxyDial(ctx, d.proxyNetwork, d.proxyAddress)
+	} else {
+		var dd net.Dialer
+		c, err = dd.DialContext(ctx, d.proxyNetwork, d.proxyAddress)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The client does not know the address it will send datagrams
+	// from, so it sends zeros as permitted by RFC 1928.
+	unspecified := "0.0.0.0"
+	if network == "udp6" {
+		unspecified = "::"
+	}
+	conn, a, err := d.request(ctx, c, CmdUDPAssociate, unspecified, 0)
+	if err == nil && conn != c {
+		err = errors.New("per-message protection of datagrams not implemented")
+	}
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	relay, err := relayAddr(a.(*Addr), c.RemoteAddr())
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	uc, err := net.DialUDP(network, nil, relay)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return &PacketConn{ctrl: c, conn: uc, raddr: raddr, boundAddr: a}, nil
+}
+
+// relayAddr returns the UDP address of the relay server reported as a,
+// substituting the proxy server's address for an unspecified IP.
+func relayAddr(a *Addr, proxy net.Addr) (*net.UDPAddr, error) {
+	if a.IP == nil {
+		return net.ResolveUDPAddr("udp", a.String())
+	}
+	ip := a.IP
+	if ip.IsUnspecified() {
+		if ta, ok := proxy.(*net.TCPAddr); ok {
+			ip = ta.IP
+		}
+	}
+	return &net.UDPAddr{IP: ip, Port: a.Port}, nil
+}
+
+// parseUDPHeader parses the header of a relayed datagram in b and
+// returns its address and the offset of the data.
+func parseUDPHeader(b []byte) (net.Addr, int, error) {
+	if len(b) < 4 {
+		return nil, 0, errors.New("short datagram")
+	}
+	if b[0] != 0 || b[1] != 0 {
+		return nil, 0, errors.New("non-zero reserved field")
+	}
+	if b[2] != 0 {
+		return nil, 0, errors.New("fragmented datagram")
+	}
+	off := 4
+	var l int
+	switch b[3] {
+	case AddrTypeIPv4:
+		l = net.IPv4len
+	case AddrTypeIPv6:
+		l = net.IPv6len
+	case AddrTypeFQDN:
+		if len(b) < 5 {
+			return nil, 0, errors.New("short datagram")
+		}
+		l = int(b[4])
+		off++
+	default:
+		return nil, 0, errors.New("unknown address type " + strconv.Itoa(int(b[3])))
+	}
+	if len(b) < off+l+2 {
+		return nil, 0, errors.New("short datagram")
+	}
+	port := int(b[off+l])<<8 | int(b[off+l+1])
+	if b[3] == AddrTypeFQDN {
+		return &Addr{Name: string(b[off : off+l]), Port: port}, off + l + 2, nil
+	}
+	ip := make(net.IP, l)
+	copy(ip, b[off:])
+	return &net.UDPAddr{IP: ip, Port: port}, off + l + 2, nil
+}
@@ -29,6 +29,57 @@ func (c *genericOpt) SetTOS(tos int) error {
 	return so.SetInt(c.Conn, tos)
 }
 
+// DSCP returns the differentiated services codepoint, the upper six
+// bits of the type-of-service field, for outgoing packets.
+func (c *genericOpt) DSCP() (int, error) {
+	tos, err := c.TOS()
+	if err != nil {
+		return 0, err
+	}
+	return tos >> 2, nil
+}
+
+// SetDSCP sets the differentiated services codepoint for future
+// outgoing packets, leaving the ECN field unchanged. The dscp must be
+// in the range 0 to 63.
+func (c *genericOpt) SetDSCP(dscp int) error {
+	if dscp < 0 || dscp > 63 {
+		return errInvalidDSCP
+	}
+	tos, err := c.TOS()
+	if err != nil {
+		return err
+	}
+	return c.SetTOS(dscp<<2 | tos&0x03)
+}
+
+// ECN returns the explicit congestion notification field, the lower
+// two bits of the type-of-service field, for outgoing packets.
+func (c *genericOpt) ECN() (int, error) {
+	tos, err := c.TOS()
+	if err != nil {
+		return 0, err
+	}
+	return tos & 0x03, nil
+}
+
+// SetECN sets the explicit congestion notification field for future
+// outgoing packets, leaving the DSCP unchanged. The ecn must be in the
+// range 0 to 3.
+//
+// Some platforms, such as Linux, manage the field on TCP connections
+// themselves and ignore the value set.
+func (c *genericOpt) SetECN(ecn int) error {
+	if ecn < 0 || ecn > 3 {
+		return errInvalidECN
+	}
+	tos, err := c.TOS()
+	if err != nil {
+		return err
+	}
+	return c.SetTOS(tos&^0x03 | ecn)
+}
+
 // TTL returns the time-to-live field value for outgoing packets.
 func (c *genericOpt) TTL() (int, error) {
 	if !c.ok() {
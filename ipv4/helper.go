@@ -19,6 +19,8 @@ var (
 	errHeaderTooShort    = errors.New("header too short")
 	errExtHeaderTooShort = errors.New("extension header too short")
 	errInvalidConnType   = errors.New("invalid conn type")
+	errInvalidDSCP       = errors.New("invalid DSCP value, must be in range 0-63")
+	errInvalidECN        = errors.New("invalid ECN value, must be in range 0-3")
 	errNotImplemented    = errors.New("not implemented on " + runtime.GOOS + "/" + runtime.GOARCH)
 
 	// See https://www.freebsd.org/doc/en/books/porters-handbook/versions.html.
@@ -0,0 +1,193 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ipv4
+
+import (
+	"errors"
+	"net"
+)
+
+var (
+	errInvalidOption  = errors.New("invalid option")
+	errOptionsTooLong = errors.New("options too long")
+)
+
+// maxOptionsLen is the maximum length of the options of a header,
+// whose length is counted in 32-bit words in a 4-bit field.
+const maxOptionsLen = 0x0f<<2 - HeaderLen
+
+// An OptionKind is the type of an IPv4 option.
+type OptionKind int
+
+// Option kinds, see RFC 791 and
+// https://www.iana.org/assignments/ip-parameters.
+const (
+	OptionEOL  OptionKind = 0x00 // end of option list
+	OptionNOP  OptionKind = 0x01 // no operation
+	OptionRR   OptionKind = 0x07 // record route
+	OptionTS   OptionKind = 0x44 // internet timestamp
+	OptionLSRR OptionKind = 0x83 // loose source and record route
+	OptionSSRR OptionKind = 0x89 // strict source and record route
+)
+
+var optionKinds = map[OptionKind]string{
+	OptionEOL:  "end of option list",
+	OptionNOP:  "no operation",
+	OptionRR:   "record route",
+	OptionTS:   "internet timestamp",
+	OptionLSRR: "loose source and record route",
+	OptionSSRR: "strict source and record route",
+}
+
+func (k OptionKind) String() string {
+	if s, ok := optionKinds[k]; ok {
+		return s
+	}
+	return "<nil>"
+}
+
+// An Option represents an IPv4 option.
+type Option struct {
+	Kind OptionKind // option type
+	Data []byte     // option data, following the type and length octets
+}
+
+// ParseOptions parses b, the options of an IPv4 header such as the
+// Options field of a Header, as a list of options. It stops at the end
+// of option list option, which is included in the result, and ignores
+// the padding that follows it.
+//
+// The options of the recognized kinds are checked to be well formed.
+func ParseOptions(b []byte) ([]Option, error) {
+	var opts []Option
+	for len(b) > 0 {
+		kind := OptionKind(b[0])
+		switch kind {
+		case OptionEOL:
+			return append(opts, Option{Kind: kind}), nil
+		case OptionNOP:
+			opts = append(opts, Option{Kind: kind})
+			b = b[1:]
+			continue
+		}
+		if len(b) < 2 || int(b[1]) < 2 || len(b) < int(b[1]) {
+			return nil, errInvalidOption
+		}
+		o := Option{Kind: kind, Data: make([]byte, b[1]-2)}
+		copy(o.Data, b[2:b[1]])
+		if err := o.check(); err != nil {
+			return nil, err
+		}
+		opts = append(opts, o)
+		b = b[b[1]:]
+	}
+	return opts, nil
+}
+
+// check reports whether the data of o is well formed for its kind.
+func (o *Option) check() error {
+	switch o.Kind {
+	case OptionEOL, OptionNOP:
+		if len(o.Data) > 0 {
+			return errInvalidOption
+		}
+	case OptionRR, OptionLSRR, OptionSSRR:
+		// A pointer, counted in octets from the type octet, followed
+		// by addresses.
+		if len(o.Data) < 1 || (len(o.Data)-1)%net.IPv4len != 0 || o.Data[0] < 4 {
+			return errInvalidOption
+		}
+	case OptionTS:
+		// A pointer, counted in octets from the type octet, and the
+		// overflow and flag fields, followed by timestamps.
+		if len(o.Data) < 2 || o.Data[0] < 5 {
+			return errInvalidOption
+		}
+	}
+	return nil
+}
+
+// MarshalOptions returns the binary encoding of opts, padded with zeros
+// to a multiple of 4 octets as required by the header length, for use
+// as the Options field of a Header.
+func MarshalOptions(opts []Option) ([]byte, error) {
+	var b []byte
+	for _, o := range opts {
+		if err := o.check(); err != nil {
+			return nil, err
+		}
+		switch o.Kind {
+		case OptionEOL, OptionNOP:
+			b = append(b, byte(o.Kind))
+			continue
+		}
+		if len(o.Data) > maxOptionsLen-2 {
+			return nil, errOptionsTooLong
+		}
+		b = append(b, byte(o.Kind), byte(2+len(o.Data)))
+		b = append(b, o.Data...)
+	}
+	for len(b)%4 != 0 {
+		b = append(b, byte(OptionEOL))
+	}
+	if len(b) > maxOptionsLen {
+		return nil, errOptionsTooLong
+	}
+	return b, nil
+}
+
+// NewRouteOption returns an option of kind OptionRR, OptionLSRR or
+// OptionSSRR listing addrs, with its pointer referring to addrs[next],
+// or past the end of the route if next is len(addrs).
+// Nil addresses are encoded as 0.0.0.0; for instance,
+// NewRouteOption(OptionRR, make([]net.IP, 9), 0) returns a record
+// route option with room for 9 addresses.
+func NewRouteOption(kind OptionKind, addrs []net.IP, next int) (Option, error) {
+	switch kind {
+	case OptionRR, OptionLSRR, OptionSSRR:
+	default:
+		return Option{}, errInvalidOption
+	}
+	if next < 0 || next > len(addrs) || 1+len(addrs)*net.IPv4len > maxOptionsLen-2 {
+		return Option{}, errInvalidOption
+	}
+	o := Option{Kind: kind, Data: make([]byte, 1, 1+len(addrs)*net.IPv4len)}
+	o.Data[0] = byte(4 + next*net.IPv4len)
+	for _, addr := range addrs {
+		ip := addr.To4()
+		if ip == nil {
+			if addr != nil {
+				return Option{}, errInvalidOption
+			}
+			ip = net.IPv4zero.To4()
+		}
+		o.Data = append(o.Data, ip...)
+	}
+	return o, nil
+}
+
+// Route returns the addresses listed by o, an option of kind OptionRR,
+// OptionLSRR or OptionSSRR, and the index of the address its pointer
+// refers to, which is len(addrs) when the route is full. For a record
+// route option, addrs[:next] are the recorded addresses.
+func (o *Option) Route() (addrs []net.IP, next int, err error) {
+	switch o.Kind {
+	case OptionRR, OptionLSRR, OptionSSRR:
+	default:
+		return nil, 0, errInvalidOption
+	}
+	if err := o.check(); err != nil {
+		return nil, 0, err
+	}
+	ptr := int(o.Data[0]) - 4
+	n := (len(o.Data) - 1) / net.IPv4len
+	if ptr%net.IPv4len != 0 || ptr/net.IPv4len > n {
+		return nil, 0, errInvalidOption
+	}
+	for b := o.Data[1:]; len(b) > 0; b = b[net.IPv4len:] {
+		addrs = append(addrs, net.IPv4(b[0], b[1], b[2], b[3]))
+	}
+	return addrs, ptr / net.IPv4len, nil
+}
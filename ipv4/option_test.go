@@ -0,0 +1,166 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ipv4
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+)
+
+var optionTests = []struct {
+	wire []byte
+	opts []Option
+}{
+	{
+		wire: []byte{0x01, 0x01, 0x01, 0x00},
+		opts: []Option{{Kind: OptionNOP}, {Kind: OptionNOP}, {Kind: OptionNOP}, {Kind: OptionEOL}},
+	},
+	{
+		// Record route with two of three addresses recorded.
+		wire: []byte{
+			0x07, 0x0f, 0x0c,
+			192, 168, 0, 1,
+			10, 0, 0, 1,
+			0, 0, 0, 0,
+			0x00,
+		},
+		opts: []Option{
+			{Kind: OptionRR, Data: []byte{0x0c, 192, 168, 0, 1, 10, 0, 0, 1, 0, 0, 0, 0}},
+			{Kind: OptionEOL},
+		},
+	},
+	{
+		// Loose source route, and a timestamp option with one
+		// timestamp recorded.
+		wire: []byte{
+			0x83, 0x07, 0x04, 172, 16, 0, 1,
+			0x01,
+			0x44, 0x0c, 0x09, 0x00,
+			0x00, 0x00, 0x01, 0x02,
+			0x00, 0x00, 0x00, 0x00,
+		},
+		opts: []Option{
+			{Kind: OptionLSRR, Data: []byte{0x04, 172, 16, 0, 1}},
+			{Kind: OptionNOP},
+			{Kind: OptionTS, Data: []byte{0x09, 0x00, 0x00, 0x00, 0x01, 0x02, 0x00, 0x00, 0x00, 0x00}},
+		},
+	},
+	{
+		// Unknown options are kept as is.
+		wire: []byte{0x94, 0x04, 0x00, 0x00},
+		opts: []Option{{Kind: 0x94, Data: []byte{0x00, 0x00}}},
+	},
+}
+
+func TestParseOptions(t *testing.T) {
+	for i, tt := range optionTests {
+		opts, err := ParseOptions(tt.wire)
+		if err != nil {
+			t.Errorf("#%d: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(opts, tt.opts) {
+			t.Errorf("#%d: got %v; want %v", i, opts, tt.opts)
+		}
+	}
+
+	for i, b := range [][]byte{
+		{0x07},                         // missing length
+		{0x07, 0x01},                   // length too short
+		{0x07, 0x08, 0x04, 0, 0, 0, 0}, // length beyond the options
+		{0x07, 0x06, 0x04, 0, 0, 0},    // truncated address
+		{0x07, 0x07, 0x03, 0, 0, 0, 0}, // pointer too small
+		{0x44, 0x03, 0x05},             // missing flags
+	} {
+		if _, err := ParseOptions(b); err == nil {
+			t.Errorf("#%d: ParseOptions(%x) succeeded", i, b)
+		}
+	}
+}
+
+func TestMarshalOptions(t *testing.T) {
+	for i, tt := range optionTests {
+		b, err := MarshalOptions(tt.opts)
+		if err != nil {
+			t.Errorf("#%d: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(b, tt.wire) {
+			t.Errorf("#%d: got %#v; want %#v", i, b, tt.wire)
+		}
+	}
+
+	if _, err := MarshalOptions([]Option{{Kind: OptionNOP, Data: []byte{0}}}); err == nil {
+		t.Error("MarshalOptions with NOP data succeeded")
+	}
+	if _, err := MarshalOptions([]Option{{Kind: 0x94, Data: make([]byte, 39)}}); err == nil {
+		t.Error("MarshalOptions with oversized option succeeded")
+	}
+}
+
+func TestRouteOption(t *testing.T) {
+	o, err := NewRouteOption(OptionRR, make([]net.IP, 9), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := MarshalOptions([]Option{o})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Header{
+		Version:  Version,
+		Len:      HeaderLen + len(opts),
+		TotalLen: HeaderLen + len(opts),
+		TTL:      1,
+		Protocol: 1,
+		Dst:      net.IPv4(192, 0, 2, 1),
+		Options:  opts,
+	}
+	b, err := h.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != 60 {
+		t.Fatalf("header with 9-address record route is %d bytes long; want 60", len(b))
+	}
+
+	// Simulate two routers recording their addresses.
+	copy(b[HeaderLen+3:], []byte{10, 0, 0, 1, 10, 0, 0, 2})
+	b[HeaderLen+2] += 2 * net.IPv4len
+
+	var ph Header
+	if err := ph.Parse(b); err != nil {
+		t.Fatal(err)
+	}
+	popts, err := ParseOptions(ph.Options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(popts) != 2 || popts[0].Kind != OptionRR || popts[1].Kind != OptionEOL {
+		t.Fatalf("got options %v; want record route and end of list", popts)
+	}
+	addrs, next, err := popts[0].Route()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 9 || next != 2 {
+		t.Fatalf("got %d addresses, next %d; want 9, 2", len(addrs), next)
+	}
+	if !addrs[0].Equal(net.IPv4(10, 0, 0, 1)) || !addrs[1].Equal(net.IPv4(10, 0, 0, 2)) {
+		t.Errorf("recorded route %v; want [10.0.0.1 10.0.0.2]", addrs[:next])
+	}
+
+	if _, err := NewRouteOption(OptionRR, make([]net.IP, 10), 0); err == nil {
+		t.Error("NewRouteOption with 10 addresses succeeded")
+	}
+	if _, err := NewRouteOption(OptionTS, nil, 0); err == nil {
+		t.Error("NewRouteOption(OptionTS) succeeded")
+	}
+	if _, err := NewRouteOption(OptionLSRR, []net.IP{net.IPv6loopback}, 0); err == nil {
+		t.Error("NewRouteOption with IPv6 address succeeded")
+	}
+}
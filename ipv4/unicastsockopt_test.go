@@ -80,7 +80,9 @@ func TestPacketConnUnicastSocketOptions(t *testing.T) {
 		}
 		defer c.Close()
 
-		testUnicastSocketOptions(t, ipv4.NewPacketConn(c))
+		p := ipv4.NewPacketConn(c)
+		testUnicastSocketOptions(t, p)
+		testDSCPECN(t, p)
 	}
 }
 
@@ -145,3 +147,49 @@ func testUnicastSocketOptions(t *testing.T, c testIPv4UnicastConn) {
 		t.Fatalf("got %v; want %v", v, ttl)
 	}
 }
+
+type testIPv4DSCPECNConn interface {
+	DSCP() (int, error)
+	SetDSCP(int) error
+	ECN() (int, error)
+	SetECN(int) error
+	TOS() (int, error)
+}
+
+func testDSCPECN(t *testing.T, c testIPv4DSCPECNConn) {
+	t.Helper()
+
+	const dscp = iana.DiffServAF11 >> 2
+	if err := c.SetDSCP(dscp); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetECN(iana.ECNTransport1); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := c.DSCP(); err != nil {
+		t.Fatal(err)
+	} else if v != dscp {
+		t.Fatalf("got DSCP %v; want %v", v, dscp)
+	}
+	if v, err := c.ECN(); err != nil {
+		t.Fatal(err)
+	} else if v != iana.ECNTransport1 {
+		t.Fatalf("got ECN %v; want %v", v, iana.ECNTransport1)
+	}
+	if v, err := c.TOS(); err != nil {
+		t.Fatal(err)
+	} else if want := dscp<<2 | iana.ECNTransport1; v != want {
+		t.Fatalf("got TOS %#x; want %#x", v, want)
+	}
+
+	for _, v := range []int{-1, 64} {
+		if err := c.SetDSCP(v); err == nil {
+			t.Errorf("SetDSCP(%d) succeeded; want error", v)
+		}
+	}
+	for _, v := range []int{-1, 4} {
+		if err := c.SetECN(v); err == nil {
+			t.Errorf("SetECN(%d) succeeded; want error", v)
+		}
+	}
+}
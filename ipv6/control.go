@@ -62,6 +62,20 @@ type ControlMessage struct {
 	MTU          int    // path MTU, receiving only
 }
 
+// ECN returns the explicit congestion notification field, the lower two
+// bits of TrafficClass, of a received packet. TrafficClass is only
+// populated on received packets when FlagTrafficClass is set with
+// SetControlMessage.
+//
+// It returns an error on platforms that cannot receive the traffic
+// class, instead of reporting Not-ECT.
+func (cm *ControlMessage) ECN() (int, error) {
+	if ctlOpts[ctlTrafficClass].name == 0 {
+		return 0, errNotImplemented
+	}
+	return cm.TrafficClass & 0x03, nil
+}
+
 func (cm *ControlMessage) String() string {
 	if cm == nil {
 		return "<nil>"
@@ -76,6 +76,65 @@ func TestPacketConnReadWriteUnicastUDP(t *testing.T) {
 	}
 }
 
+func TestPacketConnReadECN(t *testing.T) {
+	switch runtime.GOOS {
+	case "aix", "darwin", "dragonfly", "freebsd", "linux", "netbsd", "openbsd", "solaris":
+	default:
+		var cm ipv6.ControlMessage
+		if _, err := cm.ECN(); err == nil {
+			t.Errorf("ControlMessage.ECN() succeeded on %s; want error", runtime.GOOS)
+		}
+		t.Skipf("not supported on %s", runtime.GOOS)
+	}
+	if !nettest.SupportsIPv6() {
+		t.Skip("ipv6 is not supported")
+	}
+
+	c, err := nettest.NewLocalPacketListener("udp6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	p := ipv6.NewPacketConn(c)
+	defer p.Close()
+
+	if err := p.SetControlMessage(ipv6.FlagTrafficClass, true); err != nil {
+		if protocolNotSupported(err) {
+			t.Skipf("not supported on %s", runtime.GOOS)
+		}
+		t.Fatal(err)
+	}
+	for _, ecn := range []int{iana.ECNTransport1, iana.ECNTransport0, iana.CongestionExperienced} {
+		wcm := ipv6.ControlMessage{TrafficClass: iana.DiffServAF11 | ecn}
+		if err := p.SetWriteDeadline(time.Now().Add(100 * time.Millisecond)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := p.WriteTo([]byte("HELLO-R-U-THERE"), &wcm, c.LocalAddr()); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.SetReadDeadline(time.Now().Add(100 * time.Millisecond)); err != nil {
+			t.Fatal(err)
+		}
+		_, rcm, _, err := p.ReadFrom(make([]byte, 128))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rcm == nil {
+			t.Fatal("got no control message")
+		}
+		got, err := rcm.ECN()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != ecn {
+			t.Errorf("got ECN %#x; want %#x", got, ecn)
+		}
+		if rcm.TrafficClass != wcm.TrafficClass {
+			t.Errorf("got traffic class %#x; want %#x", rcm.TrafficClass, wcm.TrafficClass)
+		}
+	}
+}
+
 func TestPacketConnReadWriteUnicastICMP(t *testing.T) {
 	switch runtime.GOOS {
 	case "fuchsia", "hurd", "js", "nacl", "plan9", "windows", "zos":
@@ -72,3 +72,41 @@ func TestTestConn(t *testing.T) {
 		})
 	}
 }
+
+func TestTestPacketConn(t *testing.T) {
+	tests := []struct{ name, network string }{
+		{"UDP", "udp"},
+		{"Unixgram", "unixgram"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !TestableNetwork(tt.network) {
+				t.Skipf("%s not supported on %s/%s", tt.network, runtime.GOOS, runtime.GOARCH)
+			}
+
+			mp := func() (c1, c2 net.PacketConn, stop func(), err error) {
+				c1, err = NewLocalPacketListener(tt.network)
+				if err != nil {
+					return nil, nil, nil, err
+				}
+				c2, err = NewLocalPacketListener(tt.network)
+				if err != nil {
+					c1.Close()
+					return nil, nil, nil, err
+				}
+				stop = func() {
+					for _, c := range []net.PacketConn{c1, c2} {
+						c.Close()
+						if tt.network == "unixgram" {
+							os.Remove(c.LocalAddr().String())
+						}
+					}
+				}
+				return c1, c2, stop, nil
+			}
+
+			TestPacketConn(t, mp)
+		})
+	}
+}
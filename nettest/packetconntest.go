@@ -0,0 +1,337 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nettest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"net"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// MakePacketConn creates two packet-oriented endpoints and returns them
+// as c1 and c2, such that a packet written by either with WriteTo to the
+// LocalAddr of the other is read by the other.
+// The stop function closes all resources, including c1 and c2, and
+// should not be nil.
+type MakePacketConn func() (c1, c2 net.PacketConn, stop func(), err error)
+
+// TestPacketConn tests that a net.PacketConn implementation properly
+// satisfies the interface. As with TestConn, the tests should not
+// produce any false positives, but may experience false negatives.
+//
+// The tests expect that no packet exchanged between c1 and c2 is lost,
+// duplicated or reordered, as is the case for UDP on a loopback
+// interface, and that packets of up to 1024 bytes can be sent.
+func TestPacketConn(t *testing.T, mp MakePacketConn) {
+	t.Run("BasicIO", func(t *testing.T) { packetTimeoutWrapper(t, mp, testPacketBasicIO) })
+	t.Run("RacyRead", func(t *testing.T) { packetTimeoutWrapper(t, mp, testPacketRacyRead) })
+	t.Run("ReadTimeout", func(t *testing.T) { packetTimeoutWrapper(t, mp, testPacketReadTimeout) })
+	t.Run("PastTimeout", func(t *testing.T) { packetTimeoutWrapper(t, mp, testPacketPastTimeout) })
+	t.Run("PresentTimeout", func(t *testing.T) { packetTimeoutWrapper(t, mp, testPacketPresentTimeout) })
+	t.Run("FutureTimeout", func(t *testing.T) { packetTimeoutWrapper(t, mp, testPacketFutureTimeout) })
+	t.Run("CloseTimeout", func(t *testing.T) { packetTimeoutWrapper(t, mp, testPacketCloseTimeout) })
+	t.Run("ConcurrentMethods", func(t *testing.T) { packetTimeoutWrapper(t, mp, testPacketConcurrentMethods) })
+}
+
+type packetConnTester func(t *testing.T, c1, c2 net.PacketConn)
+
+func packetTimeoutWrapper(t *testing.T, mp MakePacketConn, f packetConnTester) {
+	t.Helper()
+	c1, c2, stop, err := mp()
+	if err != nil {
+		t.Fatalf("unable to make packet conns: %v", err)
+	}
+	var once sync.Once
+	defer once.Do(func() { stop() })
+	timer := time.AfterFunc(time.Minute, func() {
+		once.Do(func() {
+			t.Error("test timed out; terminating packet conns")
+			stop()
+		})
+	})
+	defer timer.Stop()
+	f(t, c1, c2)
+}
+
+// testPacketBasicIO tests that the packets sent by c1 are received
+// whole by c2, from c1's address, and vice-versa.
+func testPacketBasicIO(t *testing.T, c1, c2 net.PacketConn) {
+	rng := rand.New(rand.NewSource(0))
+	buf := make([]byte, 2048)
+	for i := 0; i < 100; i++ {
+		want := make([]byte, 2+rng.Intn(1023))
+		rng.Read(want)
+		binary.LittleEndian.PutUint16(want, uint16(i))
+		src, dst := c1, c2
+		if i%2 == 1 {
+			src, dst = c2, c1
+		}
+		n, err := src.WriteTo(want, dst.LocalAddr())
+		if err != nil {
+			t.Fatalf("unexpected WriteTo error: %v", err)
+		}
+		if n != len(want) {
+			t.Errorf("unexpected WriteTo count: got %d, want %d", n, len(want))
+		}
+		n, addr, err := dst.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("unexpected ReadFrom error: %v", err)
+		}
+		if !bytes.Equal(buf[:n], want) {
+			t.Errorf("packet %d: received %d bytes differing from the %d sent", i, n, len(want))
+		}
+		checkPacketSource(t, addr, src.LocalAddr())
+	}
+}
+
+// testPacketRacyRead tests that it is safe to mutate the input ReadFrom
+// buffer immediately after cancelation has occurred.
+func testPacketRacyRead(t *testing.T, c1, c2 net.PacketConn) {
+	done := make(chan bool)
+	defer close(done)
+	go func() {
+		b := make([]byte, 1024)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if _, err := c2.WriteTo(b, c1.LocalAddr()); err != nil {
+				return
+			}
+			time.Sleep(100 * time.Microsecond)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	c1.SetReadDeadline(time.Now().Add(time.Millisecond))
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			b1 := make([]byte, 1024)
+			b2 := make([]byte, 1024)
+			for j := 0; j < 100; j++ {
+				_, _, err := c1.ReadFrom(b1)
+				copy(b1, b2) // Mutate b1 to trigger potential race
+				if err != nil {
+					checkForTimeoutError(t, err)
+					c1.SetReadDeadline(time.Now().Add(time.Millisecond))
+				}
+			}
+		}()
+	}
+}
+
+// testPacketReadTimeout tests that ReadFrom timeouts do not affect
+// WriteTo.
+func testPacketReadTimeout(t *testing.T, c1, c2 net.PacketConn) {
+	c1.SetReadDeadline(aLongTimeAgo)
+	_, _, err := c1.ReadFrom(make([]byte, 1024))
+	checkForTimeoutError(t, err)
+	if _, err := c1.WriteTo(make([]byte, 1024), c2.LocalAddr()); err != nil {
+		t.Errorf("unexpected WriteTo error: %v", err)
+	}
+}
+
+// testPacketPastTimeout tests that a deadline set in the past
+// immediately times out ReadFrom and WriteTo requests.
+func testPacketPastTimeout(t *testing.T, c1, c2 net.PacketConn) {
+	go packetEcho(c2)
+
+	testPacketRoundtrip(t, c1, c2.LocalAddr())
+
+	c1.SetDeadline(aLongTimeAgo)
+	n, err := c1.WriteTo(make([]byte, 1024), c2.LocalAddr())
+	if n != 0 {
+		t.Errorf("unexpected WriteTo count: got %d, want 0", n)
+	}
+	checkForTimeoutError(t, err)
+	n, _, err = c1.ReadFrom(make([]byte, 1024))
+	if n != 0 {
+		t.Errorf("unexpected ReadFrom count: got %d, want 0", n)
+	}
+	checkForTimeoutError(t, err)
+
+	testPacketRoundtrip(t, c1, c2.LocalAddr())
+}
+
+// testPacketPresentTimeout tests that a past deadline set while there
+// is a pending ReadFrom operation immediately times it out.
+func testPacketPresentTimeout(t *testing.T, c1, c2 net.PacketConn) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	wg.Add(2)
+
+	deadlineSet := make(chan bool, 1)
+	go func() {
+		defer wg.Done()
+		time.Sleep(100 * time.Millisecond)
+		deadlineSet <- true
+		c1.SetReadDeadline(aLongTimeAgo)
+	}()
+	go func() {
+		defer wg.Done()
+		n, _, err := c1.ReadFrom(make([]byte, 1024))
+		if n != 0 {
+			t.Errorf("unexpected ReadFrom count: got %d, want 0", n)
+		}
+		checkForTimeoutError(t, err)
+		if len(deadlineSet) == 0 {
+			t.Error("ReadFrom timed out before deadline is set")
+		}
+	}()
+}
+
+// testPacketFutureTimeout tests that a future deadline will eventually
+// time out ReadFrom operations, and that the endpoint remains usable.
+func testPacketFutureTimeout(t *testing.T, c1, c2 net.PacketConn) {
+	c1.SetDeadline(time.Now().Add(100 * time.Millisecond))
+	_, _, err := c1.ReadFrom(make([]byte, 1024))
+	checkForTimeoutError(t, err)
+
+	go packetEcho(c2)
+	testPacketRoundtrip(t, c1, c2.LocalAddr())
+}
+
+// testPacketCloseTimeout tests that calling Close immediately times out
+// pending ReadFrom operations, and that operations fail afterwards.
+func testPacketCloseTimeout(t *testing.T, c1, c2 net.PacketConn) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	wg.Add(2)
+
+	// Test for cancelation upon closure.
+	c1.SetDeadline(neverTimeout)
+	go func() {
+		defer wg.Done()
+		time.Sleep(100 * time.Millisecond)
+		c1.Close()
+	}()
+	go func() {
+		defer wg.Done()
+		var err error
+		buf := make([]byte, 1024)
+		for err == nil {
+			_, _, err = c1.ReadFrom(buf)
+		}
+		if _, err := c1.WriteTo(buf, c2.LocalAddr()); err == nil {
+			t.Error("WriteTo after Close succeeded")
+		}
+		if _, _, err := c1.ReadFrom(buf); err == nil {
+			t.Error("ReadFrom after Close succeeded")
+		}
+	}()
+}
+
+// testPacketConcurrentMethods tests that the methods of net.PacketConn
+// can safely be called concurrently.
+func testPacketConcurrentMethods(t *testing.T, c1, c2 net.PacketConn) {
+	if runtime.GOOS == "plan9" {
+		t.Skip("skipping on plan9; see https://golang.org/issue/20489")
+	}
+	go packetEcho(c2)
+
+	// The results of the calls may be nonsensical, but this should
+	// not trigger a race detector warning.
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(6)
+		go func() {
+			defer wg.Done()
+			c1.ReadFrom(make([]byte, 1024))
+		}()
+		go func() {
+			defer wg.Done()
+			c1.WriteTo(make([]byte, 1024), c2.LocalAddr())
+		}()
+		go func() {
+			defer wg.Done()
+			c1.SetDeadline(time.Now().Add(10 * time.Millisecond))
+		}()
+		go func() {
+			defer wg.Done()
+			c1.SetReadDeadline(aLongTimeAgo)
+		}()
+		go func() {
+			defer wg.Done()
+			c1.SetWriteDeadline(aLongTimeAgo)
+		}()
+		go func() {
+			defer wg.Done()
+			c1.LocalAddr()
+		}()
+	}
+	wg.Wait() // At worst, the deadline is set 10ms into the future
+
+	testPacketRoundtrip(t, c1, c2.LocalAddr())
+}
+
+// checkPacketSource checks that the source address of a packet, as
+// returned by ReadFrom, is the address of the endpoint that sent it.
+func checkPacketSource(t *testing.T, got, want net.Addr) {
+	t.Helper()
+	if got == nil {
+		t.Errorf("ReadFrom returned a nil address, want %v", want)
+		return
+	}
+	if got.Network() != want.Network() || got.String() != want.String() {
+		t.Errorf("ReadFrom returned address %s/%s, want %s/%s", got.Network(), got, want.Network(), want)
+	}
+}
+
+// packetEcho sends every packet read from c back to its source, until
+// c is closed.
+func packetEcho(c net.PacketConn) {
+	buf := make([]byte, 2048)
+	for {
+		n, addr, err := c.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if _, err := c.WriteTo(buf[:n], addr); err != nil {
+			return
+		}
+	}
+}
+
+// testPacketRoundtrip sends a packet from c to dst and reads it back,
+// skipping any packet received earlier, such as echoes of packets sent
+// by a previous test. It assumes that dst echoes every packet back to
+// its source.
+func testPacketRoundtrip(t *testing.T, c net.PacketConn, dst net.Addr) {
+	t.Helper()
+	if err := c.SetDeadline(neverTimeout); err != nil {
+		t.Errorf("roundtrip SetDeadline error: %v", err)
+	}
+
+	want := make([]byte, 16)
+	rand.Read(want)
+	if _, err := c.WriteTo(want, dst); err != nil {
+		t.Errorf("roundtrip WriteTo error: %v", err)
+		return
+	}
+	buf := make([]byte, 2048)
+	for {
+		n, addr, err := c.ReadFrom(buf)
+		if err != nil {
+			t.Errorf("roundtrip ReadFrom error: %v", err)
+			return
+		}
+		if bytes.Equal(buf[:n], want) {
+			checkPacketSource(t, addr, dst)
+			return
+		}
+	}
+}
@@ -9,29 +9,45 @@ package netutil // import "golang.org/x/net/netutil"
 import (
 	"net"
 	"sync"
+	"sync/atomic"
 )
 
 // LimitListener returns a Listener that accepts at most n simultaneous
 // connections from the provided Listener.
-func LimitListener(l net.Listener, n int) net.Listener {
-	return &limitListener{
+func LimitListener(l net.Listener, n int) *LimitedListener {
+	return &LimitedListener{
 		Listener: l,
 		sem:      make(chan struct{}, n),
 		done:     make(chan struct{}),
 	}
 }
 
-type limitListener struct {
+// A LimitedListener is a Listener that limits the number of simultaneous
+// connections it accepts. It is returned by LimitListener.
+type LimitedListener struct {
 	net.Listener
 	sem       chan struct{}
 	closeOnce sync.Once     // ensures the done chan is only closed once
 	done      chan struct{} // no values sent; closed when Close is called
+
+	inUse, peak int32 // accessed atomically
+}
+
+// InUse returns the number of connections accepted by l that have not
+// yet been closed.
+func (l *LimitedListener) InUse() int {
+	return int(atomic.LoadInt32(&l.inUse))
+}
+
+// Peak returns the largest value InUse has had.
+func (l *LimitedListener) Peak() int {
+	return int(atomic.LoadInt32(&l.peak))
 }
 
 // acquire acquires the limiting semaphore. Returns true if successfully
 // accquired, false if the listener is closed and the semaphore is not
 // acquired.
-func (l *limitListener) acquire() bool {
+func (l *LimitedListener) acquire() bool {
 	select {
 	case <-l.done:
 		return false
@@ -39,9 +55,21 @@ func (l *limitListener) acquire() bool {
 		return true
 	}
 }
-func (l *limitListener) release() { <-l.sem }
+func (l *LimitedListener) release() { <-l.sem }
 
-func (l *limitListener) Accept() (net.Conn, error) {
+// Accept waits for a free slot and for the next connection to the listener.
+func (l *LimitedListener) Accept() (net.Conn, error) {
+	c, err := l.accept()
+	if err != nil {
+		return nil, err
+	}
+	l.track(c)
+	return c, nil
+}
+
+// accept accepts a connection that holds a slot until it is closed, but
+// does not yet count it in InUse.
+func (l *LimitedListener) accept() (*limitListenerConn, error) {
 	acquired := l.acquire()
 	// If the semaphore isn't acquired because the listener was closed, expect
 	// that this call to accept won't block, but immediately return an error.
@@ -55,7 +83,25 @@ func (l *limitListener) Accept() (net.Conn, error) {
 	return &limitListenerConn{Conn: c, release: l.release}, nil
 }
 
-func (l *limitListener) Close() error {
+// track counts c in InUse until it is closed.
+func (l *LimitedListener) track(c *limitListenerConn) {
+	n := atomic.AddInt32(&l.inUse, 1)
+	for {
+		peak := atomic.LoadInt32(&l.peak)
+		if n <= peak || atomic.CompareAndSwapInt32(&l.peak, peak, n) {
+			break
+		}
+	}
+	release := c.release
+	c.release = func() {
+		atomic.AddInt32(&l.inUse, -1)
+		release()
+	}
+}
+
+// Close closes the underlying Listener and unblocks any Accept calls
+// waiting for a slot.
+func (l *LimitedListener) Close() error {
 	err := l.Listener.Close()
 	l.closeOnce.Do(func() { close(l.done) })
 	return err
@@ -72,3 +118,93 @@ func (l *limitListenerConn) Close() error {
 	l.releaseOnce.Do(l.release)
 	return err
 }
+
+// LimitListenerPerIP returns a Listener that accepts at most globalMax
+// simultaneous connections from the provided Listener, and at most perIP
+// simultaneous connections from any one remote IP address. A connection
+// from an address that is already at its limit is closed as soon as it
+// is accepted. If perIP <= 0, connections are not limited per IP address
+// and the result is equivalent to LimitListener(l, globalMax).
+func LimitListenerPerIP(l net.Listener, globalMax, perIP int) net.Listener {
+	if perIP <= 0 {
+		return LimitListener(l, globalMax)
+	}
+	return &perIPLimitListener{
+		LimitedListener: &LimitedListener{
+			Listener: l,
+			sem:      make(chan struct{}, globalMax),
+			done:     make(chan struct{}),
+		},
+		perIP: perIP,
+		open:  make(map[string]int),
+	}
+}
+
+type perIPLimitListener struct {
+	*LimitedListener
+	perIP int
+
+	mu   sync.Mutex
+	open map[string]int // number of open connections by remote IP
+}
+
+func (l *perIPLimitListener) Accept() (net.Conn, error) {
+	for {
+		c, err := l.accept()
+		if err != nil {
+			return nil, err
+		}
+		ip := remoteIP(c.RemoteAddr())
+		if !l.acquireIP(ip) {
+			c.Close()
+			continue
+		}
+		release := c.release
+		c.release = func() {
+			l.releaseIP(ip)
+			release()
+		}
+		l.track(c)
+		return c, nil
+	}
+}
+
+// acquireIP reports whether a connection from ip may be opened, and if
+// so, counts it.
+func (l *perIPLimitListener) acquireIP(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.open[ip] >= l.perIP {
+		return false
+	}
+	l.open[ip]++
+	return true
+}
+
+func (l *perIPLimitListener) releaseIP(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.open[ip]--; l.open[ip] <= 0 {
+		delete(l.open, ip)
+	}
+}
+
+// remoteIP returns the IP address of addr without the port, in a
+// canonical form, so that e.g. an IPv4-mapped IPv6 address and the
+// IPv4 address it maps count as the same client.
+func remoteIP(addr net.Addr) string {
+	switch a := addr.(type) {
+	case nil:
+		return ""
+	case *net.TCPAddr:
+		return a.IP.String()
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		host = addr.String()
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	return host
+}
@@ -156,3 +156,149 @@ func TestLimitListenerClose(t *testing.T) {
 		t.Fatalf("Accept() still blocking")
 	}
 }
+
+func TestLimitListenerPerIP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	ln = LimitListenerPerIP(ln, 10, 1)
+
+	dial := func() net.Conn {
+		c, err := net.DialTimeout("tcp", ln.Addr().String(), timeout)
+		if err != nil {
+			t.Fatalf("DialTimeout: %v", err)
+		}
+		return c
+	}
+
+	accepted := make(chan net.Conn)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				close(accepted)
+				return
+			}
+			accepted <- c
+		}
+	}()
+
+	c1 := dial()
+	defer c1.Close()
+	s1 := <-accepted
+
+	// A second connection from the same IP is closed by the listener.
+	c2 := dial()
+	defer c2.Close()
+	c2.SetReadDeadline(time.Now().Add(timeout))
+	if _, err := c2.Read(make([]byte, 1)); err == nil {
+		t.Fatal("second connection from the same IP was not closed")
+	} else if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		t.Fatal("second connection from the same IP was not closed: read timed out")
+	}
+
+	// Closing the first connection frees the IP's slot.
+	s1.Close()
+	c3 := dial()
+	defer c3.Close()
+	select {
+	case s3 := <-accepted:
+		s3.Close()
+	case <-time.After(timeout):
+		t.Fatal("connection not accepted after the IP's slot was released")
+	}
+}
+
+func TestLimitListenerPerIPUnlimited(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	ln = LimitListenerPerIP(ln, 10, 0)
+
+	accepted := make(chan net.Conn)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				close(accepted)
+				return
+			}
+			accepted <- c
+		}
+	}()
+
+	// With no per-IP limit, several connections from the same IP are
+	// accepted.
+	for i := 0; i < 3; i++ {
+		c, err := net.DialTimeout("tcp", ln.Addr().String(), timeout)
+		if err != nil {
+			t.Fatalf("DialTimeout: %v", err)
+		}
+		defer c.Close()
+		select {
+		case s := <-accepted:
+			defer s.Close()
+		case <-time.After(timeout):
+			t.Fatalf("connection %d not accepted", i)
+		}
+	}
+}
+
+func TestRemoteIP(t *testing.T) {
+	for _, tt := range []struct {
+		addr net.Addr
+		want string
+	}{
+		{&net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 80}, "192.0.2.1"},
+		{&net.TCPAddr{IP: net.ParseIP("::ffff:192.0.2.1"), Port: 80}, "192.0.2.1"},
+		{&net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 443}, "2001:db8::1"},
+		{&net.UDPAddr{IP: net.ParseIP("2001:0db8:0:0::1"), Port: 443}, "2001:db8::1"},
+		{&net.UnixAddr{Name: "/tmp/sock", Net: "unix"}, "/tmp/sock"},
+		{nil, ""},
+	} {
+		if got := remoteIP(tt.addr); got != tt.want {
+			t.Errorf("remoteIP(%v) = %q; want %q", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestLimitListenerInUse(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	ll := LimitListener(ln, 5)
+
+	var conns []net.Conn
+	for i := 0; i < 3; i++ {
+		c, err := net.DialTimeout("tcp", ll.Addr().String(), timeout)
+		if err != nil {
+			t.Fatalf("DialTimeout: %v", err)
+		}
+		defer c.Close()
+		s, err := ll.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, s)
+	}
+	if n := ll.InUse(); n != 3 {
+		t.Errorf("InUse() = %d; want 3", n)
+	}
+
+	conns[0].Close()
+	conns[0].Close() // a second Close does not release another slot
+	conns[1].Close()
+	if n := ll.InUse(); n != 1 {
+		t.Errorf("after closing 2 connections, InUse() = %d; want 1", n)
+	}
+	if n := ll.Peak(); n != 3 {
+		t.Errorf("Peak() = %d; want 3", n)
+	}
+	conns[2].Close()
+}
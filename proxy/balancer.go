@@ -0,0 +1,170 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// A Strategy determines which dialer a Balancer tries first for each
+// connection.
+type Strategy int
+
+const (
+	// RoundRobin starts each connection with the dialer following
+	// the one the previous connection started with.
+	RoundRobin Strategy = iota
+
+	// Random starts each connection with a randomly chosen dialer.
+	Random
+)
+
+// A Balancer spreads connections over several Dialers, typically for
+// different proxies. If a dial fails, the next Dialer is tried.
+type Balancer struct {
+	// MaxAttempts is the maximum number of Dialers tried for a
+	// connection. If zero, all of them are tried.
+	MaxAttempts int
+
+	// FailureThreshold is the number of consecutive failed dials
+	// after which a Dialer is considered unhealthy. Unhealthy
+	// Dialers are skipped until their Cooldown has passed, unless
+	// all the Dialers are unhealthy, in which case they are tried
+	// anyway. If zero, Dialers are never considered unhealthy.
+	FailureThreshold int
+
+	// Cooldown is how long a Dialer stays unhealthy after reaching
+	// FailureThreshold. If zero, a cooldown of 30 seconds is used.
+	Cooldown time.Duration
+
+	dialers  []Dialer
+	strategy Strategy
+
+	mu     sync.Mutex
+	next   int            // first dialer of the next connection, for RoundRobin
+	health []dialerHealth // indexed like dialers
+}
+
+// defaultCooldown is the cooldown used if Balancer.Cooldown is zero.
+const defaultCooldown = 30 * time.Second
+
+type dialerHealth struct {
+	failures  int       // consecutive failed dials
+	unhealthy time.Time // time until which the dialer is unhealthy
+}
+
+var (
+	_ Dialer        = (*Balancer)(nil)
+	_ ContextDialer = (*Balancer)(nil)
+)
+
+// NewBalancer returns a Balancer that spreads connections over dialers
+// using strategy.
+func NewBalancer(dialers []Dialer, strategy Strategy) *Balancer {
+	return &Balancer{
+		dialers:  dialers,
+		strategy: strategy,
+		health:   make([]dialerHealth, len(dialers)),
+	}
+}
+
+// Dial connects to the address addr on the given network through one
+// of the Balancer's dialers.
+func (b *Balancer) Dial(network, addr string) (net.Conn, error) {
+	return b.DialContext(context.Background(), network, addr)
+}
+
+// DialContext connects to the address addr on the given network through
+// one of the Balancer's dialers. Dials that fail because ctx is done do
+// not count against the health of a dialer.
+func (b *Balancer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if len(b.dialers) == 0 {
+		return nil, errors.New("proxy: balancer has no dialers")
+	}
+	var err error
+	for _, i := range b.order(time.Now()) {
+		var c net.Conn
+		if x, ok := b.dialers[i].(ContextDialer); ok {
+			c, err = x.DialContext(ctx, network, addr)
+		} else {
+			c, err = dialContext(ctx, b.dialers[i], network, addr)
+		}
+		if err != nil && ctx.Err() != nil {
+			return nil, err
+		}
+		b.report(i, err, time.Now())
+		if err == nil {
+			return c, nil
+		}
+	}
+	return nil, err
+}
+
+// MarkUnhealthy marks the dialer at index i of the dialers passed to
+// NewBalancer as unhealthy for the given duration, as if it had failed
+// FailureThreshold times in a row.
+func (b *Balancer) MarkUnhealthy(i int, d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.health[i] = dialerHealth{unhealthy: time.Now().Add(d)}
+}
+
+// order returns the indexes of the dialers to try for a connection at
+// time now, in order. Unhealthy dialers are left out unless all of
+// them are unhealthy.
+func (b *Balancer) order(now time.Time) []int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := len(b.dialers)
+	var start int
+	switch b.strategy {
+	case Random:
+		start = rand.Intn(n)
+	default:
+		start = b.next
+		b.next = (b.next + 1) % n
+	}
+	order := make([]int, 0, n)
+	var unhealthy []int
+	for k := 0; k < n; k++ {
+		i := (start + k) % n
+		if now.Before(b.health[i].unhealthy) {
+			unhealthy = append(unhealthy, i)
+		} else {
+			order = append(order, i)
+		}
+	}
+	if len(order) == 0 {
+		order = unhealthy
+	}
+	if b.MaxAttempts > 0 && b.MaxAttempts < len(order) {
+		order = order[:b.MaxAttempts]
+	}
+	return order
+}
+
+// report records the result of a dial through dialer i at time now.
+func (b *Balancer) report(i int, err error, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h := &b.health[i]
+	if err == nil {
+		*h = dialerHealth{}
+		return
+	}
+	h.failures++
+	if b.FailureThreshold > 0 && h.failures >= b.FailureThreshold {
+		cooldown := b.Cooldown
+		if cooldown == 0 {
+			cooldown = defaultCooldown
+		}
+		*h = dialerHealth{unhealthy: now.Add(cooldown)}
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// A countingDialer counts its dials, which fail if err is set.
+type countingDialer struct {
+	name  string
+	dials *[]string
+	err   error
+}
+
+func (d *countingDialer) Dial(network, addr string) (net.Conn, error) {
+	*d.dials = append(*d.dials, d.name)
+	if d.err != nil {
+		return nil, d.err
+	}
+	c, _ := net.Pipe()
+	return c, nil
+}
+
+func TestBalancerRoundRobin(t *testing.T) {
+	var dials []string
+	a := &countingDialer{name: "a", dials: &dials}
+	b := &countingDialer{name: "b", dials: &dials, err: errors.New("b is down")}
+	c := &countingDialer{name: "c", dials: &dials}
+	lb := NewBalancer([]Dialer{a, b, c}, RoundRobin)
+
+	for i := 0; i < 3; i++ {
+		conn, err := lb.Dial("tcp", "example.com:80")
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+	want := []string{"a", "b", "c", "c"}
+	if !reflect.DeepEqual(dials, want) {
+		t.Errorf("dials = %q; want %q", dials, want)
+	}
+}
+
+func TestBalancerMaxAttempts(t *testing.T) {
+	var dials []string
+	errDown := errors.New("down")
+	a := &countingDialer{name: "a", dials: &dials, err: errDown}
+	b := &countingDialer{name: "b", dials: &dials, err: errDown}
+	c := &countingDialer{name: "c", dials: &dials}
+	lb := NewBalancer([]Dialer{a, b, c}, RoundRobin)
+	lb.MaxAttempts = 2
+
+	if _, err := lb.Dial("tcp", "example.com:80"); err != errDown {
+		t.Errorf("Dial error = %v; want %v", err, errDown)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(dials, want) {
+		t.Errorf("dials = %q; want %q", dials, want)
+	}
+}
+
+func TestBalancerUnhealthy(t *testing.T) {
+	var dials []string
+	a := &countingDialer{name: "a", dials: &dials, err: errors.New("a is down")}
+	b := &countingDialer{name: "b", dials: &dials}
+	lb := NewBalancer([]Dialer{a, b}, RoundRobin)
+	lb.FailureThreshold = 2
+	lb.Cooldown = time.Hour
+
+	for i := 0; i < 5; i++ {
+		conn, err := lb.Dial("tcp", "example.com:80")
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+	// After failing twice, a is skipped until the cooldown passes.
+	want := []string{"a", "b", "b", "a", "b", "b", "b"}
+	if !reflect.DeepEqual(dials, want) {
+		t.Errorf("dials = %q; want %q", dials, want)
+	}
+
+	// Once it has passed, a takes its turn again.
+	lb.health[0].unhealthy = time.Now()
+	dials = nil
+	a.err = nil
+	for i := 0; i < 2; i++ {
+		conn, err := lb.Dial("tcp", "example.com:80")
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+	if want := []string{"b", "a"}; !reflect.DeepEqual(dials, want) {
+		t.Errorf("after cooldown, dials = %q; want %q", dials, want)
+	}
+
+	dials = nil
+	lb.MarkUnhealthy(0, time.Hour)
+	for i := 0; i < 2; i++ {
+		conn, err := lb.Dial("tcp", "example.com:80")
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+	if want := []string{"b", "b"}; !reflect.DeepEqual(dials, want) {
+		t.Errorf("after MarkUnhealthy, dials = %q; want %q", dials, want)
+	}
+}
+
+func TestBalancerAllUnhealthy(t *testing.T) {
+	var dials []string
+	errDown := errors.New("down")
+	a := &countingDialer{name: "a", dials: &dials, err: errDown}
+	b := &countingDialer{name: "b", dials: &dials, err: errDown}
+	lb := NewBalancer([]Dialer{a, b}, RoundRobin)
+	lb.FailureThreshold = 1
+
+	// A zero Cooldown still marks failing dialers unhealthy.
+	if _, err := lb.Dial("tcp", "example.com:80"); err != errDown {
+		t.Errorf("Dial error = %v; want %v", err, errDown)
+	}
+	for i := range lb.health {
+		if lb.health[i].unhealthy.Before(time.Now().Add(defaultCooldown / 2)) {
+			t.Errorf("dialer %d unhealthy until %v; want about %v from now", i, lb.health[i].unhealthy, defaultCooldown)
+		}
+	}
+
+	// When all the dialers are unhealthy, they are tried anyway.
+	dials = nil
+	b.err = nil
+	conn, err := lb.Dial("tcp", "example.com:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if want := []string{"b"}; !reflect.DeepEqual(dials, want) {
+		t.Errorf("dials = %q; want %q", dials, want)
+	}
+}
+
+// A ctxDialer fails to dial once its context is done.
+type ctxDialer struct{}
+
+func (ctxDialer) Dial(network, addr string) (net.Conn, error) {
+	return ctxDialer{}.DialContext(context.Background(), network, addr)
+}
+
+func (ctxDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c, _ := net.Pipe()
+	return c, nil
+}
+
+func TestBalancerContextCanceled(t *testing.T) {
+	lb := NewBalancer([]Dialer{ctxDialer{}}, Random)
+	lb.FailureThreshold = 1
+	lb.Cooldown = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := lb.DialContext(ctx, "tcp", "example.com:80"); err != context.Canceled {
+		t.Errorf("DialContext error = %v; want %v", err, context.Canceled)
+	}
+	if !lb.health[0].unhealthy.IsZero() {
+		t.Error("canceled dial marked the dialer unhealthy")
+	}
+}
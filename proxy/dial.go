@@ -7,6 +7,7 @@ package proxy
 import (
 	"context"
 	"net"
+	"time"
 )
 
 // A ContextDialer dials using a context.
@@ -52,3 +53,39 @@ func dialContext(ctx context.Context, d Dialer, network, address string) (net.Co
 	}
 	return conn, err
 }
+
+var aLongTimeAgo = time.Unix(1, 0)
+
+// watchContext makes I/O on c, a connection to a proxy, fail once ctx is
+// done or its deadline passes, until stop is called. stop clears the
+// deadline of c and returns ctx.Err() if ctx is done, or err otherwise.
+func watchContext(ctx context.Context, c net.Conn) (stop func(err error) error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetDeadline(deadline)
+	}
+	if ctx.Done() == nil {
+		return func(err error) error {
+			c.SetDeadline(time.Time{})
+			return err
+		}
+	}
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			c.SetDeadline(aLongTimeAgo)
+		case <-done:
+		}
+	}()
+	return func(err error) error {
+		close(done)
+		<-exited
+		c.SetDeadline(time.Time{})
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+}
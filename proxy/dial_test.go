@@ -7,6 +7,8 @@ package proxy
 import (
 	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"testing"
 	"time"
@@ -97,6 +99,55 @@ func TestDial(t *testing.T) {
 		}
 		c.Close()
 	})
+	t.Run("StalledProxyWithCancel", func(t *testing.T) {
+		defer ResetProxyEnv()
+		l, err := nettest.NewLocalListener("tcp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+		// The proxy accepts connections but never responds, and
+		// reports when the client abandons the handshake.
+		closed := make(chan struct{}, 1)
+		go func() {
+			for {
+				c, err := l.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					defer c.Close()
+					io.Copy(ioutil.Discard, c)
+					closed <- struct{}{}
+				}()
+			}
+		}()
+		for _, scheme := range []string{"socks5", "http"} {
+			if err = os.Setenv("ALL_PROXY", fmt.Sprintf("%s://%s", scheme, l.Addr().String())); err != nil {
+				t.Fatal(err)
+			}
+			if err = os.Setenv("NO_PROXY", "bypass.example.com"); err != nil {
+				t.Fatal(err)
+			}
+			ResetCachedEnvironment()
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(100*time.Millisecond, cancel)
+			start := time.Now()
+			c, err := Dial(ctx, "tcp", "example.com:80")
+			if err == nil {
+				c.Close()
+				t.Fatalf("%s: dial through stalled proxy succeeded", scheme)
+			}
+			if d := time.Since(start); d > 5*time.Second {
+				t.Errorf("%s: dial took %v to return after cancelation", scheme, d)
+			}
+			select {
+			case <-closed:
+			case <-time.After(5 * time.Second):
+				t.Errorf("%s: connection to proxy not closed after cancelation", scheme)
+			}
+		}
+	})
 	t.Run("SOCKS5WithTimeoutExceeded", func(t *testing.T) {
 		defer ResetProxyEnv()
 		s, err := sockstest.NewServer(sockstest.NoAuthRequired, sockstest.NoProxyRequired)
@@ -0,0 +1,206 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// defaultAttemptDelay is the delay between connection attempts
+// recommended by RFC 8305 Section 8.
+const defaultAttemptDelay = 250 * time.Millisecond
+
+// HappyEyeballsOptions configures a dialer returned by HappyEyeballs.
+type HappyEyeballsOptions struct {
+	// AttemptDelay is the time to wait for a connection attempt
+	// before starting the next one in parallel (the "Connection
+	// Attempt Delay" of RFC 8305 Section 5). If zero, 250ms is used.
+	AttemptDelay time.Duration
+
+	// LookupIPAddr, if non-nil, resolves host names instead of
+	// net.DefaultResolver.LookupIPAddr.
+	LookupIPAddr func(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+type happyEyeballs struct {
+	forward ContextDialer
+	opts    HappyEyeballsOptions
+}
+
+var (
+	_ Dialer        = (*happyEyeballs)(nil)
+	_ ContextDialer = (*happyEyeballs)(nil)
+)
+
+// HappyEyeballs returns a Dialer that connects to TCP addresses through
+// forward following the Happy Eyeballs algorithm of RFC 8305: it
+// resolves the host, interleaves its IPv6 and IPv4 addresses, and
+// starts a connection attempt to each in turn, either when the previous
+// attempt fails or after a delay, until one succeeds. The first
+// connection established is returned and the other attempts are
+// abandoned. If opts is nil, the default options are used.
+//
+// Addresses whose host is an IP address, and networks other than
+// "tcp", "tcp4" and "tcp6", are dialed through forward as they are.
+//
+// The returned Dialer also implements ContextDialer.
+func HappyEyeballs(forward ContextDialer, opts *HappyEyeballsOptions) Dialer {
+	h := &happyEyeballs{forward: forward}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h
+}
+
+// Dial connects to the address addr on the given network.
+func (h *happyEyeballs) Dial(network, addr string) (net.Conn, error) {
+	return h.DialContext(context.Background(), network, addr)
+}
+
+// DialContext connects to the address addr on the given network.
+func (h *happyEyeballs) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return h.forward.DialContext(ctx, network, addr)
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if isIPLiteral(host) {
+		return h.forward.DialContext(ctx, network, addr)
+	}
+	lookup := h.opts.LookupIPAddr
+	if lookup == nil {
+		lookup = net.DefaultResolver.LookupIPAddr
+	}
+	ips, err := lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	addrs := interleaveAddrs(ips, network, port)
+	if len(addrs) == 0 {
+		return nil, &net.AddrError{Err: "no suitable address found", Addr: host}
+	}
+	delay := h.opts.AttemptDelay
+	if delay == 0 {
+		delay = defaultAttemptDelay
+	}
+	return h.race(ctx, network, addrs, delay)
+}
+
+type dialResult struct {
+	c   net.Conn
+	err error
+}
+
+// race dials addrs in order, starting each attempt when the previous one
+// fails or after delay, and returns the first connection established.
+// If all attempts fail, it returns the error of the first one.
+func (h *happyEyeballs) race(ctx context.Context, network string, addrs []string, delay time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan dialResult, len(addrs))
+	var next, pending int
+	start := func() {
+		addr := addrs[next]
+		next++
+		pending++
+		go func() {
+			c, err := h.forward.DialContext(ctx, network, addr)
+			results <- dialResult{c, err}
+		}()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	resetTimer := func() {
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(delay)
+	}
+
+	var firstErr error
+	start()
+	for pending > 0 {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				// Close the connections of the attempts that
+				// succeed before noticing the cancelation.
+				go func(n int) {
+					for ; n > 0; n-- {
+						if r := <-results; r.c != nil {
+							r.c.Close()
+						}
+					}
+				}(pending)
+				return r.c, nil
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if next < len(addrs) && ctx.Err() == nil {
+				start()
+				resetTimer()
+			}
+		case <-timer.C:
+			if next < len(addrs) {
+				start()
+				timer.Reset(delay)
+			}
+		}
+	}
+	return nil, firstErr
+}
+
+// interleaveAddrs returns the addresses of ips suitable for network,
+// joined with port, alternating between address families. The family
+// of the first address of ips comes first.
+func interleaveAddrs(ips []net.IPAddr, network, port string) []string {
+	var primary, fallback []net.IPAddr
+	for _, ip := range ips {
+		is4 := ip.IP.To4() != nil
+		if network == "tcp4" && !is4 || network == "tcp6" && is4 {
+			continue
+		}
+		if len(primary) == 0 || (primary[0].IP.To4() != nil) == is4 {
+			primary = append(primary, ip)
+		} else {
+			fallback = append(fallback, ip)
+		}
+	}
+	addrs := make([]string, 0, len(primary)+len(fallback))
+	for i := 0; i < len(primary) || i < len(fallback); i++ {
+		if i < len(primary) {
+			addrs = append(addrs, net.JoinHostPort(primary[i].String(), port))
+		}
+		if i < len(fallback) {
+			addrs = append(addrs, net.JoinHostPort(fallback[i].String(), port))
+		}
+	}
+	return addrs
+}
+
+// isIPLiteral reports whether host is an IP address, possibly with an
+// IPv6 zone.
+func isIPLiteral(host string) bool {
+	for i := len(host) - 1; i >= 0; i-- {
+		if host[i] == '%' {
+			host = host[:i]
+			break
+		}
+	}
+	return net.ParseIP(host) != nil
+}
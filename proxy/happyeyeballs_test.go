@@ -0,0 +1,154 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A scriptedDialer records its dials. Dials to the addresses in fail
+// fail at once, those to the addresses in hang block until their context
+// is done, and the others succeed.
+type scriptedDialer struct {
+	fail, hang map[string]bool
+
+	mu    sync.Mutex
+	dials []string
+}
+
+func (d *scriptedDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	d.mu.Lock()
+	d.dials = append(d.dials, addr)
+	d.mu.Unlock()
+	switch {
+	case d.fail[addr]:
+		return nil, errors.New("connection refused")
+	case d.hang[addr]:
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	c, _ := net.Pipe()
+	return c, nil
+}
+
+func (d *scriptedDialer) dialed() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.dials...)
+}
+
+func lookupStatic(ips ...string) func(context.Context, string) ([]net.IPAddr, error) {
+	return func(context.Context, string) ([]net.IPAddr, error) {
+		var addrs []net.IPAddr
+		for _, ip := range ips {
+			addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+		}
+		return addrs, nil
+	}
+}
+
+func TestHappyEyeballsFallback(t *testing.T) {
+	fd := &scriptedDialer{hang: map[string]bool{"[2001:db8::1]:80": true}}
+	d := HappyEyeballs(fd, &HappyEyeballsOptions{
+		AttemptDelay: 10 * time.Millisecond,
+		LookupIPAddr: lookupStatic("2001:db8::1", "2001:db8::2", "192.0.2.1"),
+	})
+	c, err := d.Dial("tcp", "example.com:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	if got, want := fd.dialed(), []string{"[2001:db8::1]:80", "192.0.2.1:80"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dialed %q; want %q", got, want)
+	}
+}
+
+func TestHappyEyeballsFailFast(t *testing.T) {
+	fd := &scriptedDialer{fail: map[string]bool{"192.0.2.1:80": true}}
+	d := HappyEyeballs(fd, &HappyEyeballsOptions{
+		AttemptDelay: time.Hour,
+		LookupIPAddr: lookupStatic("192.0.2.1", "192.0.2.2", "2001:db8::1"),
+	})
+	c, err := d.Dial("tcp", "example.com:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	if got, want := fd.dialed(), []string{"192.0.2.1:80", "[2001:db8::1]:80"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dialed %q; want %q", got, want)
+	}
+}
+
+func TestHappyEyeballsAllFail(t *testing.T) {
+	fd := &scriptedDialer{fail: map[string]bool{"192.0.2.1:80": true, "[2001:db8::1]:80": true}}
+	d := HappyEyeballs(fd, &HappyEyeballsOptions{
+		LookupIPAddr: lookupStatic("192.0.2.1", "2001:db8::1"),
+	})
+	if _, err := d.Dial("tcp", "example.com:80"); err == nil {
+		t.Fatal("Dial succeeded; want error")
+	}
+	if got := fd.dialed(); len(got) != 2 {
+		t.Errorf("dialed %q; want both addresses", got)
+	}
+}
+
+func TestHappyEyeballsNetwork(t *testing.T) {
+	fd := &scriptedDialer{}
+	d := HappyEyeballs(fd, &HappyEyeballsOptions{
+		LookupIPAddr: lookupStatic("2001:db8::1", "192.0.2.1"),
+	})
+	c, err := d.Dial("tcp4", "example.com:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	if got, want := fd.dialed(), []string{"192.0.2.1:80"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dialed %q; want %q", got, want)
+	}
+
+	d = HappyEyeballs(fd, &HappyEyeballsOptions{LookupIPAddr: lookupStatic("192.0.2.1")})
+	if _, err := d.Dial("tcp6", "example.com:80"); err == nil {
+		t.Error("tcp6 Dial of IPv4-only host succeeded; want error")
+	}
+}
+
+func TestHappyEyeballsIPLiteral(t *testing.T) {
+	fd := &scriptedDialer{}
+	d := HappyEyeballs(fd, &HappyEyeballsOptions{
+		LookupIPAddr: func(context.Context, string) ([]net.IPAddr, error) {
+			t.Error("IP address was looked up")
+			return nil, errors.New("unexpected lookup")
+		},
+	})
+	for _, addr := range []string{"192.0.2.1:80", "[2001:db8::1]:80", "[fe80::1%eth0]:80"} {
+		c, err := d.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+	}
+	if got := fd.dialed(); len(got) != 3 {
+		t.Errorf("dialed %q; want each address once", got)
+	}
+}
+
+func TestHappyEyeballsContextCanceled(t *testing.T) {
+	fd := &scriptedDialer{hang: map[string]bool{"192.0.2.1:80": true, "[2001:db8::1]:80": true}}
+	d := HappyEyeballs(fd, &HappyEyeballsOptions{
+		AttemptDelay: time.Millisecond,
+		LookupIPAddr: lookupStatic("192.0.2.1", "2001:db8::1"),
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := d.(ContextDialer).DialContext(ctx, "tcp", "example.com:80"); err != context.DeadlineExceeded {
+		t.Errorf("DialContext error = %v; want %v", err, context.DeadlineExceeded)
+	}
+}
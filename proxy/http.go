@@ -0,0 +1,141 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// An httpConnectDialer makes connections through an HTTP proxy using
+// the CONNECT method.
+type httpConnectDialer struct {
+	proxyAddr string
+	auth      *Auth
+	forward   Dialer
+
+	// tlsConfig is non-nil if the connection to the proxy uses TLS.
+	tlsConfig *tls.Config
+}
+
+var (
+	_ Dialer        = (*httpConnectDialer)(nil)
+	_ ContextDialer = (*httpConnectDialer)(nil)
+)
+
+// newHTTPConnect returns a Dialer for an http or https proxy URL.
+func newHTTPConnect(u *url.URL, auth *Auth, forward Dialer) (Dialer, error) {
+	d := &httpConnectDialer{auth: auth, forward: forward}
+	port := u.Port()
+	switch u.Scheme {
+	case "http":
+		if port == "" {
+			port = "80"
+		}
+	case "https":
+		if port == "" {
+			port = "443"
+		}
+		d.tlsConfig = &tls.Config{ServerName: u.Hostname()}
+	}
+	d.proxyAddr = net.JoinHostPort(u.Hostname(), port)
+	return d, nil
+}
+
+// Dial connects to the address addr on the given network via the
+// proxy.
+func (d *httpConnectDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+// DialContext connects to the address addr on the given network via
+// the proxy. The context governs both the connection to the proxy and
+// the CONNECT handshake.
+func (d *httpConnectDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, errors.New("proxy: no support for HTTP CONNECT proxy connections of type " + network)
+	}
+	var c net.Conn
+	var err error
+	switch f := d.forward.(type) {
+	case nil:
+		var dd net.Dialer
+		c, err = dd.DialContext(ctx, "tcp", d.proxyAddr)
+	case ContextDialer:
+		c, err = f.DialContext(ctx, "tcp", d.proxyAddr)
+	default:
+		c, err = dialContext(ctx, f, "tcp", d.proxyAddr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c, err = d.connect(ctx, c, addr)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// connect performs the TLS handshake, if any, and the CONNECT request
+// for addr on c, the connection to the proxy.
+func (d *httpConnectDialer) connect(ctx context.Context, c net.Conn, addr string) (_ net.Conn, err error) {
+	stop := watchContext(ctx, c)
+	defer func() { err = stop(err) }()
+
+	if d.tlsConfig != nil {
+		tc := tls.Client(c, d.tlsConfig)
+		if err := tc.Handshake(); err != nil {
+			return c, err
+		}
+		c = tc
+	}
+	req := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if d.auth != nil {
+		cred := d.auth.User + ":" + d.auth.Password
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(cred)))
+	}
+	if err := req.Write(c); err != nil {
+		return c, err
+	}
+	br := bufio.NewReader(c)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return c, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return c, errors.New("proxy: CONNECT " + addr + " via " + d.proxyAddr + ": " + resp.Proto + " " + resp.Status)
+	}
+	// The body of a successful response is the tunnel itself, and the
+	// proxy may already have relayed some of it.
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: c, r: br}, nil
+	}
+	return c, nil
+}
+
+// A bufferedConn is a net.Conn whose first reads are served from data
+// buffered while reading the proxy's response.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
@@ -0,0 +1,123 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// connectHandler is an HTTP CONNECT proxy requiring the given
+// Proxy-Authorization header value, if not empty.
+func connectHandler(t *testing.T, wantAuth string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "CONNECT" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if wantAuth != "" && r.Header.Get("Proxy-Authorization") != wantAuth {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		c, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer c.Close()
+		w.WriteHeader(http.StatusOK)
+		rc, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer rc.Close()
+		go io.Copy(c, buf)
+		io.Copy(rc, c)
+	})
+}
+
+func TestHTTPConnect(t *testing.T) {
+	echo := newEchoServer(t)
+	defer echo.Close()
+	wantAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:password"))
+	ts := httptest.NewServer(connectHandler(t, wantAuth))
+	defer ts.Close()
+
+	u, err := url.Parse("http://user:password@" + ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := FromURL(u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDialEcho(t, d, echo.Addr().String())
+
+	u.User = url.UserPassword("user", "wrong")
+	d, err = FromURL(u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.Dial("tcp", echo.Addr().String())
+	if err == nil || !strings.Contains(err.Error(), "HTTP/1.1 407 Proxy Authentication Required") {
+		t.Errorf("Dial with bad credentials: got %v; want error with status line", err)
+	}
+}
+
+func TestHTTPSConnect(t *testing.T) {
+	echo := newEchoServer(t)
+	defer echo.Close()
+	ts := httptest.NewTLSServer(connectHandler(t, ""))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := FromURL(u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ts.Certificate())
+	d.(*httpConnectDialer).tlsConfig = &tls.Config{RootCAs: roots, ServerName: "example.com"}
+	testDialEcho(t, d, echo.Addr().String())
+}
+
+func TestFromURLChainHTTPToSOCKS5(t *testing.T) {
+	echo := newEchoServer(t)
+	defer echo.Close()
+	ts := httptest.NewServer(connectHandler(t, ""))
+	defer ts.Close()
+	targets := make(chan string, 1)
+	ss := newSOCKS5Forwarder(t, targets)
+	defer ss.Close()
+
+	var urls []*url.URL
+	for _, s := range []string{ts.URL, "socks5://" + ss.Addr().String()} {
+		u, err := url.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		urls = append(urls, u)
+	}
+	d, err := FromURLChain(urls, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDialEcho(t, d, echo.Addr().String())
+	if got, want := <-targets, echo.Addr().String(); got != want {
+		t.Errorf("SOCKS5 proxy connected to %s; want %s", got, want)
+	}
+}
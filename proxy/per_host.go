@@ -21,6 +21,11 @@ type PerHost struct {
 	bypassHosts    []string
 }
 
+var (
+	_ Dialer        = (*PerHost)(nil)
+	_ ContextDialer = (*PerHost)(nil)
+)
+
 // NewPerHost returns a PerHost Dialer that directs connections to either
 // defaultDialer or bypass, depending on whether the connection matches one of
 // the configured rules.
@@ -71,6 +76,8 @@ func (p *PerHost) dialerForRequest(host string) Dialer {
 		return p.def
 	}
 
+	// Host names are compared case-insensitively.
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
 	for _, zone := range p.bypassZones {
 		if strings.HasSuffix(host, zone) {
 			return p.bypass
@@ -91,9 +98,9 @@ func (p *PerHost) dialerForRequest(host string) Dialer {
 
 // AddFromString parses a string that contains comma-separated values
 // specifying hosts that should use the bypass proxy. Each value is either an
-// IP address, a CIDR range, a zone (*.example.com) or a host name
-// (localhost). A best effort is made to parse the string and errors are
-// ignored.
+// IP address, a CIDR range (10.0.0.0/8), a zone (*.example.com or
+// .example.com) or a host name (localhost). A best effort is made to parse
+// the string and errors are ignored.
 func (p *PerHost) AddFromString(s string) {
 	hosts := strings.Split(s, ",")
 	for _, host := range hosts {
@@ -112,8 +119,8 @@ func (p *PerHost) AddFromString(s string) {
 			p.AddIP(ip)
 			continue
 		}
-		if strings.HasPrefix(host, "*.") {
-			p.AddZone(host[1:])
+		if strings.HasPrefix(host, "*.") || strings.HasPrefix(host, ".") {
+			p.AddZone(strings.TrimPrefix(host, "*"))
 			continue
 		}
 		p.AddHost(host)
@@ -143,6 +150,7 @@ func (p *PerHost) AddZone(zone string) {
 	if !strings.HasPrefix(zone, ".") {
 		zone = "." + zone
 	}
+	zone = strings.ToLower(zone)
 	p.bypassZones = append(p.bypassZones, zone)
 }
 
@@ -151,5 +159,5 @@ func (p *PerHost) AddHost(host string) {
 	if strings.HasSuffix(host, ".") {
 		host = host[:len(host)-1]
 	}
-	p.bypassHosts = append(p.bypassHosts, host)
+	p.bypassHosts = append(p.bypassHosts, strings.ToLower(host))
 }
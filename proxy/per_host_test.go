@@ -74,3 +74,32 @@ func TestPerHost(t *testing.T) {
 		}
 	})
 }
+
+func TestPerHostAddFromString(t *testing.T) {
+	var def, bypass recordingProxy
+	perHost := NewPerHost(&def, &bypass)
+	perHost.AddFromString("*.internal.example.com, .corp.example.com, Intranet, 192.168.0.0/16, fd00::/8")
+	for _, tt := range []struct {
+		addr   string
+		bypass bool
+	}{
+		{"a.internal.example.com:80", true},
+		{"A.B.Internal.Example.COM:80", true},
+		{"internal.example.com:80", true},
+		{"xinternal.example.com:80", false},
+		{"db.corp.example.com:80", true},
+		{"corp.example.com.:80", true},
+		{"intranet:80", true},
+		{"intranet.example.com:80", false},
+		{"192.168.10.20:80", true},
+		{"192.169.0.1:80", false},
+		{"[fd00::1]:80", true},
+		{"[fe80::1]:80", false},
+	} {
+		def.addrs, bypass.addrs = nil, nil
+		perHost.Dial("tcp", tt.addr)
+		if got := len(bypass.addrs) == 1; got != tt.bypass {
+			t.Errorf("%s: bypass = %v; want %v", tt.addr, got, tt.bypass)
+		}
+	}
+}
@@ -21,6 +21,27 @@ type Dialer interface {
 	Dial(network, addr string) (c net.Conn, err error)
 }
 
+// A BoundAddrConn is a connection made through a proxy that reports the
+// address the proxy bound for its connection to the destination, such as
+// the BND.ADDR and BND.PORT of a SOCKS5 reply. Connections returned by
+// the DialContext method of the SOCKS5 dialer implement it; its
+// deprecated Dial method returns the raw transport connection.
+type BoundAddrConn interface {
+	net.Conn
+
+	// BoundAddr returns the address reported by the proxy.
+	BoundAddr() net.Addr
+}
+
+// A PacketDialer is a means to establish packet-oriented connections,
+// such as UDP associations, through a proxy.
+type PacketDialer interface {
+	// DialPacket returns a connection relaying datagrams via the proxy.
+	// Its Write method, if any, sends to addr; WriteTo may send to any
+	// address.
+	DialPacket(network, addr string) (c net.PacketConn, err error)
+}
+
 // Auth contains authentication parameters that specific Dialers may require.
 type Auth struct {
 	User, Password string
@@ -78,6 +99,12 @@ func RegisterDialerType(scheme string, f func(*url.URL, Dialer) (Dialer, error))
 
 // FromURL returns a Dialer given a URL specification and an underlying
 // Dialer for it to make network requests.
+//
+// The socks5 and socks5h schemes select a SOCKS5 proxy. The http and
+// https schemes select a proxy that is sent a CONNECT request for each
+// connection, over TLS for https, with Basic authentication if the URL
+// has user information. The socks4 and socks4a schemes select a SOCKS4
+// proxy, with the user name of the URL, if any, as the user ID.
 func FromURL(u *url.URL, forward Dialer) (Dialer, error) {
 	var auth *Auth
 	if u.User != nil {
@@ -106,9 +133,37 @@ func FromURL(u *url.URL, forward Dialer) (Dialer, error) {
 		}
 	}
 
+	// HTTP CONNECT and SOCKS4 proxies are checked after registered
+	// schemes, which predate them, so that existing registrations keep
+	// working.
+	switch u.Scheme {
+	case "http", "https":
+		return newHTTPConnect(u, auth, forward)
+	case "socks4", "socks4a":
+		return newSOCKS4(u, forward)
+	}
+
 	return nil, errors.New("proxy: unknown scheme: " + u.Scheme)
 }
 
+// FromURLChain returns a Dialer that connects through each of the proxies
+// given by urls in turn. The first proxy is reached using forward, and
+// each subsequent proxy is reached through the one before it, so the
+// last proxy connects to the final destination.
+func FromURLChain(urls []*url.URL, forward Dialer) (Dialer, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("proxy: empty proxy chain")
+	}
+	d := forward
+	for _, u := range urls {
+		var err error
+		if d, err = FromURL(u, d); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
 var (
 	allProxyEnv = &envOnce{
 		names: []string{"ALL_PROXY", "all_proxy"},
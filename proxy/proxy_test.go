@@ -9,6 +9,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/url"
 	"os"
@@ -110,7 +111,173 @@ func TestSOCKS5(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	if _, ok := c.(*net.TCPConn); !ok {
+		t.Errorf("Dial returned %T; want *net.TCPConn", c)
+	}
+	c.Close()
+	c, err = proxy.(ContextDialer).DialContext(context.Background(), "tcp", ss.TargetAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc, ok := c.(BoundAddrConn)
+	if !ok {
+		t.Fatalf("%T does not implement BoundAddrConn", c)
+	}
+	// sockstest.NoProxyRequired binds the target port plus one.
+	want := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: ss.TargetAddr().(*net.TCPAddr).Port + 1}
+	if got := bc.BoundAddr(); got == nil || got.String() != want.String() {
+		t.Errorf("BoundAddr() = %v; want %v", got, want)
+	}
 	c.Close()
+	if _, ok := proxy.(PacketDialer); !ok {
+		t.Errorf("%T does not implement PacketDialer", proxy)
+	}
+}
+
+// newSOCKS5Forwarder returns a SOCKS5 server that connects to the
+// requested target and relays data, recording each target address.
+func newSOCKS5Forwarder(t *testing.T, targets chan<- string) *sockstest.Server {
+	ss, err := sockstest.NewServer(sockstest.NoAuthRequired, func(rw io.ReadWriter, b []byte) error {
+		req, err := sockstest.ParseCmdRequest(b)
+		if err != nil {
+			return err
+		}
+		targets <- req.Addr.String()
+		c, err := net.Dial("tcp", req.Addr.String())
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		b, err = sockstest.MarshalCmdReply(socks.Version5, socks.StatusSucceeded, &socks.Addr{IP: net.IPv4(127, 0, 0, 1), Port: 1})
+		if err != nil {
+			return err
+		}
+		if _, err := rw.Write(b); err != nil {
+			return err
+		}
+		go io.Copy(c, rw)
+		_, err = io.Copy(rw, c)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ss
+}
+
+// newEchoServer returns a listener whose connections echo their input.
+func newEchoServer(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				io.Copy(c, c)
+			}()
+		}
+	}()
+	return ln
+}
+
+// testDialEcho dials the echo server at addr using d and checks that
+// data makes the round trip.
+func testDialEcho(t *testing.T, d Dialer, addr string) {
+	c, err := d.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 5)
+	if _, err := io.ReadFull(c, b); err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "hello" {
+		t.Errorf("got %q; want %q", b, "hello")
+	}
+}
+
+func TestFromURLChain(t *testing.T) {
+	echo := newEchoServer(t)
+	defer echo.Close()
+	targets1 := make(chan string, 1)
+	ss1 := newSOCKS5Forwarder(t, targets1)
+	defer ss1.Close()
+	targets2 := make(chan string, 1)
+	ss2 := newSOCKS5Forwarder(t, targets2)
+	defer ss2.Close()
+
+	var urls []*url.URL
+	for _, s := range []string{"socks5://" + ss1.Addr().String(), "socks5h://" + ss2.Addr().String()} {
+		u, err := url.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		urls = append(urls, u)
+	}
+	d, err := FromURLChain(urls, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDialEcho(t, d, echo.Addr().String())
+	if got, want := <-targets1, ss2.Addr().String(); got != want {
+		t.Errorf("first proxy connected to %s; want %s", got, want)
+	}
+	if got, want := <-targets2, echo.Addr().String(); got != want {
+		t.Errorf("second proxy connected to %s; want %s", got, want)
+	}
+
+	if _, err := FromURLChain(nil, nil); err == nil {
+		t.Error("FromURLChain with no URLs succeeded")
+	}
+}
+
+func TestSOCKS5WithResolver(t *testing.T) {
+	echo := newEchoServer(t)
+	defer echo.Close()
+	targets := make(chan string, 1)
+	ss := newSOCKS5Forwarder(t, targets)
+	defer ss.Close()
+	_, port, err := net.SplitHostPort(echo.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := net.JoinHostPort("localhost", port)
+
+	for _, tt := range []struct {
+		resolve bool
+		want    string
+	}{
+		{false, addr},
+		{true, echo.Addr().String()},
+	} {
+		var d Dialer
+		if tt.resolve {
+			d, err = SOCKS5WithResolver("tcp", ss.Addr().String(), nil, nil, nil)
+		} else {
+			d, err = SOCKS5("tcp", ss.Addr().String(), nil, nil)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := d.Dial("tcp4", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+		if got := <-targets; got != tt.want {
+			t.Errorf("resolve=%v: proxy connected to %s; want %s", tt.resolve, got, tt.want)
+		}
+	}
 }
 
 type funcFailDialer func(context.Context) error
@@ -0,0 +1,208 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+)
+
+const (
+	socks4Version        = 0x04
+	socks4CommandConnect = 0x01
+	socks4MaxUserIDLen   = 255
+)
+
+// SOCKS4 reply codes.
+const (
+	socks4Granted        = 0x5a
+	socks4Rejected       = 0x5b
+	socks4NoIdentd       = 0x5c
+	socks4IdentdMismatch = 0x5d
+)
+
+// A socks4Dialer makes connections through a SOCKS4 or SOCKS4a proxy
+// using the CONNECT command.
+type socks4Dialer struct {
+	proxyNetwork string
+	proxyAddr    string
+	userID       string
+	forward      Dialer
+
+	// remoteResolve is set for SOCKS4a, which lets the proxy
+	// resolve host names.
+	remoteResolve bool
+}
+
+var (
+	_ Dialer        = (*socks4Dialer)(nil)
+	_ ContextDialer = (*socks4Dialer)(nil)
+)
+
+// SOCKS4 returns a Dialer that makes SOCKSv4 connections to the given
+// address. Host names are resolved locally to IPv4 addresses, since
+// the protocol carries only those. If auth is non-nil, auth.User is
+// sent in the USERID field of the requests, which is otherwise empty;
+// auth.Password is unused, as SOCKSv4 has no passwords. The USERID
+// field is limited to 255 bytes.
+func SOCKS4(network, address string, auth *Auth, forward Dialer) (Dialer, error) {
+	return newSOCKS4Dialer(network, address, auth, forward, false)
+}
+
+// SOCKS4A is like SOCKS4 but makes SOCKSv4a connections, which leave
+// the resolution of host names to the proxy server.
+func SOCKS4A(network, address string, auth *Auth, forward Dialer) (Dialer, error) {
+	return newSOCKS4Dialer(network, address, auth, forward, true)
+}
+
+func newSOCKS4Dialer(network, address string, auth *Auth, forward Dialer, remoteResolve bool) (Dialer, error) {
+	d := &socks4Dialer{proxyNetwork: network, proxyAddr: address, forward: forward, remoteResolve: remoteResolve}
+	if auth != nil {
+		d.userID = auth.User
+	}
+	if len(d.userID) > socks4MaxUserIDLen {
+		return nil, errors.New("proxy: SOCKS4 user ID too long")
+	}
+	return d, nil
+}
+
+// newSOCKS4 returns a Dialer for a socks4 or socks4a proxy URL. The
+// user name of the URL, if any, is sent as the USERID.
+func newSOCKS4(u *url.URL, forward Dialer) (Dialer, error) {
+	port := u.Port()
+	if port == "" {
+		port = "1080"
+	}
+	var auth *Auth
+	if u.User != nil {
+		auth = &Auth{User: u.User.Username()}
+	}
+	return newSOCKS4Dialer("tcp", net.JoinHostPort(u.Hostname(), port), auth, forward, u.Scheme == "socks4a")
+}
+
+// Dial connects to the address addr on the given network via the
+// proxy.
+func (d *socks4Dialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+// DialContext connects to the address addr on the given network via
+// the proxy. The context governs the connection to the proxy, the
+// resolution of the host name, if any, and the CONNECT request.
+func (d *socks4Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4":
+	default:
+		return nil, errors.New("proxy: no support for SOCKS4 proxy connections of type " + network)
+	}
+	req, err := d.request(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	var c net.Conn
+	switch f := d.forward.(type) {
+	case nil:
+		var dd net.Dialer
+		c, err = dd.DialContext(ctx, d.proxyNetwork, d.proxyAddr)
+	case ContextDialer:
+		c, err = f.DialContext(ctx, d.proxyNetwork, d.proxyAddr)
+	default:
+		c, err = dialContext(ctx, f, d.proxyNetwork, d.proxyAddr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := d.connect(ctx, c, req); err != nil {
+		c.Close()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, errors.New("proxy: SOCKS4 CONNECT " + addr + " via " + d.proxyAddr + ": " + err.Error())
+	}
+	return c, nil
+}
+
+// request returns the CONNECT request for addr.
+func (d *socks4Dialer) request(ctx context.Context, addr string) ([]byte, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, errors.New("proxy: invalid port " + portStr)
+	}
+	var ip4 net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 = ip.To4(); ip4 == nil {
+			return nil, errors.New("proxy: SOCKS4 does not support IPv6 address " + host)
+		}
+	} else if !d.remoteResolve {
+		ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			if ip4 = ip.IP.To4(); ip4 != nil {
+				break
+			}
+		}
+		if ip4 == nil {
+			return nil, &net.AddrError{Err: "no IPv4 address found", Addr: host}
+		}
+	}
+
+	req := []byte{socks4Version, socks4CommandConnect, byte(port >> 8), byte(port)}
+	if ip4 != nil {
+		req = append(req, ip4...)
+	} else {
+		// SOCKS4a marks a request carrying a host name with the
+		// invalid address 0.0.0.x, x non-zero.
+		req = append(req, 0, 0, 0, 1)
+	}
+	req = append(req, d.userID...)
+	req = append(req, 0)
+	if ip4 == nil {
+		req = append(req, host...)
+		req = append(req, 0)
+	}
+	return req, nil
+}
+
+// connect sends req on c, the connection to the proxy, and reads the
+// reply.
+func (d *socks4Dialer) connect(ctx context.Context, c net.Conn, req []byte) (err error) {
+	stop := watchContext(ctx, c)
+	defer func() { err = stop(err) }()
+
+	if _, err := c.Write(req); err != nil {
+		return err
+	}
+	// The reply is a null byte, the reply code, and a port and
+	// address that CONNECT replies leave unused.
+	var reply [8]byte
+	if _, err := io.ReadFull(c, reply[:]); err != nil {
+		return err
+	}
+	if reply[0] != 0 {
+		return errors.New("unexpected reply version " + strconv.Itoa(int(reply[0])))
+	}
+	switch reply[1] {
+	case socks4Granted:
+		return nil
+	case socks4Rejected:
+		return errors.New("request rejected or failed")
+	case socks4NoIdentd:
+		return errors.New("request rejected because the proxy cannot connect to identd on the client")
+	case socks4IdentdMismatch:
+		return errors.New("request rejected because identd reported a different user ID")
+	default:
+		return errors.New("unknown reply code " + strconv.Itoa(int(reply[1])))
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// A socks4Request is a CONNECT request received by a SOCKS4 server.
+type socks4Request struct {
+	ip     net.IP
+	port   int
+	userID string
+	host   string // for SOCKS4a requests
+}
+
+// newSOCKS4Server returns a SOCKS4a server that sends the requests it
+// receives to reqs and answers them with code, relaying the connection
+// to the requested address if code grants the request.
+func newSOCKS4Server(t *testing.T, code byte, reqs chan<- socks4Request) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				br := bufio.NewReader(c)
+				var hdr [8]byte
+				if _, err := io.ReadFull(br, hdr[:]); err != nil || hdr[0] != 4 || hdr[1] != 1 {
+					return
+				}
+				req := socks4Request{ip: net.IP(hdr[4:8]), port: int(hdr[2])<<8 | int(hdr[3])}
+				if req.userID, err = br.ReadString(0); err != nil {
+					return
+				}
+				req.userID = strings.TrimSuffix(req.userID, "\x00")
+				if hdr[4] == 0 && hdr[5] == 0 && hdr[6] == 0 && hdr[7] != 0 {
+					if req.host, err = br.ReadString(0); err != nil {
+						return
+					}
+					req.host = strings.TrimSuffix(req.host, "\x00")
+				}
+				reqs <- req
+				if code != socks4Granted {
+					c.Write([]byte{0, code, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				host := req.host
+				if host == "" {
+					host = req.ip.String()
+				}
+				dst, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(req.port)))
+				if err != nil {
+					c.Write([]byte{0, socks4Rejected, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				defer dst.Close()
+				c.Write([]byte{0, socks4Granted, 0, 0, 0, 0, 0, 0})
+				go io.Copy(dst, br)
+				io.Copy(c, dst)
+			}()
+		}
+	}()
+	return ln
+}
+
+func TestSOCKS4(t *testing.T) {
+	echo := newEchoServer(t)
+	defer echo.Close()
+	reqs := make(chan socks4Request, 1)
+	ss := newSOCKS4Server(t, socks4Granted, reqs)
+	defer ss.Close()
+
+	d, err := SOCKS4("tcp", ss.Addr().String(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDialEcho(t, d, echo.Addr().String())
+	req := <-reqs
+	_, port, _ := net.SplitHostPort(echo.Addr().String())
+	if !req.ip.Equal(net.IPv4(127, 0, 0, 1)) || strconv.Itoa(req.port) != port || req.userID != "" || req.host != "" {
+		t.Errorf("got request %+v; want CONNECT 127.0.0.1:%s without user ID", req, port)
+	}
+
+	if _, err := d.Dial("tcp", "[::1]:80"); err == nil {
+		t.Error("Dial to IPv6 address succeeded; want error")
+	}
+	if _, err := d.Dial("udp", echo.Addr().String()); err == nil {
+		t.Error("Dial of udp network succeeded; want error")
+	}
+}
+
+func TestSOCKS4a(t *testing.T) {
+	echo := newEchoServer(t)
+	defer echo.Close()
+	reqs := make(chan socks4Request, 1)
+	ss := newSOCKS4Server(t, socks4Granted, reqs)
+	defer ss.Close()
+
+	u, err := url.Parse("socks4a://gopher@" + ss.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := FromURL(u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, _ := net.SplitHostPort(echo.Addr().String())
+	testDialEcho(t, d, net.JoinHostPort("localhost", port))
+	if req := <-reqs; req.host != "localhost" || req.userID != "gopher" {
+		t.Errorf("got request %+v; want host localhost and user ID gopher", req)
+	}
+}
+
+func TestSOCKS4UserID(t *testing.T) {
+	echo := newEchoServer(t)
+	defer echo.Close()
+	reqs := make(chan socks4Request, 1)
+	ss := newSOCKS4Server(t, socks4Granted, reqs)
+	defer ss.Close()
+
+	for _, newDialer := range []func(string, string, *Auth, Dialer) (Dialer, error){SOCKS4, SOCKS4A} {
+		d, err := newDialer("tcp", ss.Addr().String(), &Auth{User: "gopher", Password: "unused"}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testDialEcho(t, d, echo.Addr().String())
+		if req := <-reqs; req.userID != "gopher" {
+			t.Errorf("got request %+v; want user ID gopher", req)
+		}
+	}
+
+	if _, err := SOCKS4("tcp", ss.Addr().String(), &Auth{User: strings.Repeat("x", 256)}, nil); err == nil {
+		t.Error("SOCKS4 with a 256-byte user ID succeeded; want error")
+	}
+}
+
+func TestSOCKS4Reply(t *testing.T) {
+	for _, tt := range []struct {
+		code byte
+		want string
+	}{
+		{socks4Rejected, "rejected or failed"},
+		{socks4NoIdentd, "identd"},
+		{socks4IdentdMismatch, "different user ID"},
+		{0x42, "unknown reply code 66"},
+	} {
+		reqs := make(chan socks4Request, 1)
+		ss := newSOCKS4Server(t, tt.code, reqs)
+		d, err := SOCKS4("tcp", ss.Addr().String(), nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = d.Dial("tcp", "192.0.2.1:80")
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("reply code %#x: got %v; want error containing %q", tt.code, err, tt.want)
+		}
+		ss.Close()
+	}
+}
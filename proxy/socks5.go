@@ -14,19 +14,11 @@ import (
 // SOCKS5 returns a Dialer that makes SOCKSv5 connections to the given
 // address with an optional username and password.
 // See RFC 1928 and RFC 1929.
+//
+// The returned Dialer also implements PacketDialer using the UDP
+// ASSOCIATE command, for the "udp", "udp4" and "udp6" networks.
 func SOCKS5(network, address string, auth *Auth, forward Dialer) (Dialer, error) {
-	d := socks.NewDialer(network, address)
-	if forward != nil {
-		if f, ok := forward.(ContextDialer); ok {
-			d.ProxyDial = func(ctx context.Context, network string, address string) (net.Conn, error) {
-				return f.DialContext(ctx, network, address)
-			}
-		} else {
-			d.ProxyDial = func(ctx context.Context, network string, address string) (net.Conn, error) {
-				return dialContext(ctx, forward, network, address)
-			}
-		}
-	}
+	d := newSOCKS5Dialer(network, address, forward)
 	if auth != nil {
 		up := socks.UsernamePassword{
 			Username: auth.User,
@@ -40,3 +32,77 @@ func SOCKS5(network, address string, auth *Auth, forward Dialer) (Dialer, error)
 	}
 	return d, nil
 }
+
+// SOCKS5WithResolver is like SOCKS5 but resolves the host names of
+// target addresses locally using resolver, or net.DefaultResolver if
+// resolver is nil, and sends the proxy server IP addresses only. This
+// suits proxy servers that cannot resolve names themselves. Addresses
+// matching the network family are preferred for "tcp4", "tcp6",
+// "udp4" and "udp6".
+func SOCKS5WithResolver(network, address string, auth *Auth, resolver *net.Resolver, forward Dialer) (Dialer, error) {
+	d, err := SOCKS5(network, address, auth, forward)
+	if err != nil {
+		return nil, err
+	}
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	d.(*socks.Dialer).Resolver = resolver
+	return d, nil
+}
+
+// A GSSAPIMechanism performs the GSS-API calls used by SOCKS5GSSAPI,
+// typically through a Kerberos implementation. Wrap and Unwrap may be
+// called concurrently.
+type GSSAPIMechanism interface {
+	// InitSecContext continues establishing the security context
+	// with the token received from the proxy server, which is nil
+	// on the first call. It returns the token to send to the
+	// server, if any, and whether the context is established.
+	InitSecContext(token []byte) (output []byte, established bool, err error)
+
+	// Wrap protects msg, encrypting it if confidential is true.
+	Wrap(msg []byte, confidential bool) ([]byte, error)
+
+	// Unwrap verifies, and decrypts if needed, a token wrapped by
+	// the proxy server.
+	Unwrap(token []byte) ([]byte, error)
+}
+
+// SOCKS5GSSAPI returns a Dialer that makes SOCKSv5 connections to the
+// given address, authenticating with the GSSAPI method using mech.
+// See RFC 1961.
+//
+// The messages exchanged with the proxy server after authentication,
+// including the data relayed by the returned connections, are
+// integrity protected, and also encrypted if confidential is true and
+// the proxy server agrees to it. The returned Dialer does not support
+// UDP associations.
+func SOCKS5GSSAPI(network, address string, mech GSSAPIMechanism, confidential bool, forward Dialer) (Dialer, error) {
+	d := newSOCKS5Dialer(network, address, forward)
+	g := &socks.GSSAPI{Mechanism: mech, ProtectionLevel: socks.GSSAPIIntegrity}
+	if confidential {
+		g.ProtectionLevel = socks.GSSAPIConfidentiality
+	}
+	d.AuthMethods = []socks.AuthMethod{socks.AuthMethodGSSAPI}
+	d.Authenticator = g
+	return d, nil
+}
+
+// newSOCKS5Dialer returns a SOCKS5 dialer that connects to the proxy
+// server through forward, if non-nil.
+func newSOCKS5Dialer(network, address string, forward Dialer) *socks.Dialer {
+	d := socks.NewDialer(network, address)
+	if forward != nil {
+		if f, ok := forward.(ContextDialer); ok {
+			d.ProxyDial = func(ctx context.Context, network string, address string) (net.Conn, error) {
+				return f.DialContext(ctx, network, address)
+			}
+		} else {
+			d.ProxyDial = func(ctx context.Context, network string, address string) (net.Conn, error) {
+				return dialContext(ctx, forward, network, address)
+			}
+		}
+	}
+	return d
+}
@@ -83,6 +83,21 @@ func (list) String() string {
 // domains like "foo.appspot.com" can be found at
 // https://wiki.mozilla.org/Public_Suffix_List/Use_Cases
 func PublicSuffix(domain string) (publicSuffix string, icann bool) {
+	publicSuffix, icann, _ = PublicSuffixWithInfo(domain)
+	return publicSuffix, icann
+}
+
+// PublicSuffixWithInfo is like PublicSuffix but also reports whether the
+// public suffix is managed, that is, whether it was matched by a rule of
+// the publicsuffix.org list rather than by the default "*" rule applied to
+// unlisted top level domains.
+//
+// icann is true if the matching rule is in the ICANN section of the list
+// and false if it is in the PRIVATE section or if the public suffix is not
+// managed. For example, "foo.co.uk" has an ICANN managed public suffix,
+// "foo.blogspot.co.uk" has a privately managed public suffix and "cromulent"
+// has an unmanaged public suffix.
+func PublicSuffixWithInfo(domain string) (publicSuffix string, icann, managed bool) {
 	lo, hi := uint32(0), uint32(numTLD)
 	s, suffix, icannNode, wildcard := domain, len(domain), false, false
 loop:
@@ -128,9 +143,9 @@ loop:
 	}
 	if suffix == len(domain) {
 		// If no rules match, the prevailing rule is "*".
-		return domain[1+strings.LastIndex(domain, "."):], icann
+		return domain[1+strings.LastIndex(domain, "."):], icann, false
 	}
-	return domain[suffix:], icann
+	return domain[suffix:], icann, true
 }
 
 const notFound uint32 = 1<<32 - 1
@@ -165,11 +180,15 @@ func nodeLabel(i uint32) string {
 // EffectiveTLDPlusOne returns the effective top level domain plus one more
 // label. For example, the eTLD+1 for "foo.bar.golang.org" is "golang.org".
 func EffectiveTLDPlusOne(domain string) (string, error) {
+	return effectiveTLDPlusOne(domain, PublicSuffix)
+}
+
+func effectiveTLDPlusOne(domain string, publicSuffix func(string) (string, bool)) (string, error) {
 	if strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") || strings.Contains(domain, "..") {
 		return "", fmt.Errorf("publicsuffix: empty label in domain %q", domain)
 	}
 
-	suffix, _ := PublicSuffix(domain)
+	suffix, _ := publicSuffix(domain)
 	if len(domain) <= len(suffix) {
 		return "", fmt.Errorf("publicsuffix: cannot derive eTLD+1 for domain %q", domain)
 	}
@@ -78,6 +78,35 @@ func TestICANN(t *testing.T) {
 	}
 }
 
+func TestPublicSuffixWithInfo(t *testing.T) {
+	testCases := []struct {
+		domain      string
+		wantPS      string
+		wantICANN   bool
+		wantManaged bool
+	}{
+		{"foo.org", "org", true, true},
+		{"foo.co.uk", "co.uk", true, true},
+		{"foo.dyndns.org", "dyndns.org", false, true},
+		{"foo.blogspot.co.uk", "blogspot.co.uk", false, true},
+		{"foo.intranet", "intranet", false, false},
+		{"cromulent", "cromulent", false, false},
+		{"", "", false, false},
+	}
+	for _, tc := range testCases {
+		gotPS, gotICANN, gotManaged := PublicSuffixWithInfo(tc.domain)
+		if gotPS != tc.wantPS || gotICANN != tc.wantICANN || gotManaged != tc.wantManaged {
+			t.Errorf("%q: got (%q, %t, %t), want (%q, %t, %t)", tc.domain, gotPS, gotICANN, gotManaged, tc.wantPS, tc.wantICANN, tc.wantManaged)
+		}
+	}
+	for _, tc := range publicSuffixTestCases {
+		gotPS, gotICANN, _ := PublicSuffixWithInfo(tc.domain)
+		if gotPS != tc.wantPS || gotICANN != tc.wantICANN {
+			t.Errorf("%q: got (%q, %t), want (%q, %t)", tc.domain, gotPS, gotICANN, tc.wantPS, tc.wantICANN)
+		}
+	}
+}
+
 var publicSuffixTestCases = []struct {
 	domain    string
 	wantPS    string
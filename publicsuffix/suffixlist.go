@@ -0,0 +1,148 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package publicsuffix
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// A SuffixList is a public suffix list loaded at run time, for example
+// from an updated copy of the publicsuffix.org list. It is safe for
+// concurrent use.
+//
+// The package-level functions always use the copy of the list compiled into
+// the library.
+type SuffixList struct {
+	// Each map is keyed by a rule without its "*." or "!" prefix, and
+	// records whether the rule is in the ICANN section of the list.
+	normal    map[string]bool
+	wildcard  map[string]bool
+	exception map[string]bool
+}
+
+// NewList parses a public suffix list in the format of
+// https://publicsuffix.org/list/public_suffix_list.dat.
+//
+// Rules with Unicode labels are converted to their Punycode form, so
+// domains passed to the methods of the returned SuffixList must be in
+// ASCII form, as for PublicSuffix.
+func NewList(r io.Reader) (*SuffixList, error) {
+	l := &SuffixList{
+		normal:    make(map[string]bool),
+		wildcard:  make(map[string]bool),
+		exception: make(map[string]bool),
+	}
+	icann := false
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		s := strings.TrimSpace(sc.Text())
+		if strings.Contains(s, "BEGIN ICANN DOMAINS") {
+			icann = true
+			continue
+		}
+		if strings.Contains(s, "END ICANN DOMAINS") {
+			icann = false
+			continue
+		}
+		if s == "" || strings.HasPrefix(s, "//") {
+			continue
+		}
+		// A rule is the first whitespace-delimited token of a line.
+		s = strings.Fields(s)[0]
+		m, exception := l.normal, false
+		switch {
+		case strings.HasPrefix(s, "*."):
+			s, m = s[2:], l.wildcard
+		case strings.HasPrefix(s, "!"):
+			s, m, exception = s[1:], l.exception, true
+		}
+		rule, err := idna.ToASCII(s)
+		if err != nil {
+			return nil, fmt.Errorf("publicsuffix: invalid rule %q on line %d: %v", sc.Text(), line, err)
+		}
+		rule = strings.ToLower(rule)
+		if !validRule(rule) {
+			return nil, fmt.Errorf("publicsuffix: invalid rule %q on line %d", sc.Text(), line)
+		}
+		if exception && !strings.Contains(rule, ".") {
+			return nil, fmt.Errorf("publicsuffix: exception rule for top level domain %q on line %d", sc.Text(), line)
+		}
+		m[rule] = icann
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// PublicSuffix returns the public suffix of the domain according to l. See
+// the PublicSuffix function for details.
+func (l *SuffixList) PublicSuffix(domain string) (publicSuffix string, icann bool) {
+	publicSuffix, icann, _ = l.PublicSuffixWithInfo(domain)
+	return publicSuffix, icann
+}
+
+// PublicSuffixWithInfo returns the public suffix of the domain according to
+// l. See the PublicSuffixWithInfo function for details.
+func (l *SuffixList) PublicSuffixWithInfo(domain string) (publicSuffix string, icann, managed bool) {
+	// Walk the suffixes of domain from the top level domain down, keeping
+	// the longest matching rule. An exception rule prevails over all
+	// others, and its public suffix is the parent of the matched name.
+	suffix := len(domain)
+	// parent is the start of the parent of domain[i:], or len(domain) if
+	// domain[i:] is the top level domain.
+	parent := len(domain)
+	for i := 1 + strings.LastIndex(domain, "."); ; {
+		s := domain[i:]
+		if ic, ok := l.exception[s]; ok {
+			return domain[parent:], ic, true
+		}
+		if ic, ok := l.normal[s]; ok {
+			suffix, icann = i, ic
+		}
+		if parent < len(domain) {
+			if ic, ok := l.wildcard[domain[parent:]]; ok {
+				suffix, icann = i, ic
+			}
+		}
+		if i == 0 {
+			break
+		}
+		parent, i = i, 1+strings.LastIndex(domain[:i-1], ".")
+	}
+	if suffix == len(domain) {
+		// If no rules match, the prevailing rule is "*".
+		return domain[1+strings.LastIndex(domain, "."):], false, false
+	}
+	return domain[suffix:], icann, true
+}
+
+// EffectiveTLDPlusOne returns the effective top level domain plus one more
+// label according to l. See the EffectiveTLDPlusOne function for details.
+func (l *SuffixList) EffectiveTLDPlusOne(domain string) (string, error) {
+	return effectiveTLDPlusOne(domain, l.PublicSuffix)
+}
+
+// validRule reports whether rule, stripped of its "*." or "!" prefix, is a
+// non-empty sequence of non-empty labels of ASCII letters, digits, hyphens
+// and underscores.
+func validRule(rule string) bool {
+	if rule == "" || rule[0] == '.' || rule[len(rule)-1] == '.' || strings.Contains(rule, "..") {
+		return false
+	}
+	for i := 0; i < len(rule); i++ {
+		switch c := rule[i]; {
+		case 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,117 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package publicsuffix
+
+import (
+	"strings"
+	"testing"
+)
+
+// compiledList returns the rules compiled into the library in the
+// public_suffix_list.dat format.
+func compiledList() string {
+	var b strings.Builder
+	b.WriteString("// ===BEGIN ICANN DOMAINS===\n")
+	for i, r := range rules {
+		if i == numICANNRules {
+			b.WriteString("// ===END ICANN DOMAINS===\n\n// ===BEGIN PRIVATE DOMAINS===\n")
+		}
+		b.WriteString(r + "\n")
+	}
+	b.WriteString("// ===END PRIVATE DOMAINS===\n")
+	return b.String()
+}
+
+func TestNewListCompiled(t *testing.T) {
+	l, err := NewList(strings.NewReader(compiledList()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range publicSuffixTestCases {
+		gotPS, gotICANN := l.PublicSuffix(tc.domain)
+		if gotPS != tc.wantPS || gotICANN != tc.wantICANN {
+			t.Errorf("%q: got (%q, %t), want (%q, %t)", tc.domain, gotPS, gotICANN, tc.wantPS, tc.wantICANN)
+		}
+		_, _, wantManaged := PublicSuffixWithInfo(tc.domain)
+		if _, _, gotManaged := l.PublicSuffixWithInfo(tc.domain); gotManaged != wantManaged {
+			t.Errorf("%q: got managed %t, want %t", tc.domain, gotManaged, wantManaged)
+		}
+	}
+	for _, tc := range eTLDPlusOneTestCases {
+		got, _ := l.EffectiveTLDPlusOne(tc.domain)
+		if got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.domain, got, tc.want)
+		}
+	}
+}
+
+func TestNewList(t *testing.T) {
+	const data = `// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0.
+
+// ===BEGIN ICANN DOMAINS===
+
+// example : https://example.com/
+example
+co.example	some trailing text
+*.wild.example
+!www.wild.example
+
+// рф : https://cctld.ru/
+рф
+ком.рф
+
+// ===END ICANN DOMAINS===
+// ===BEGIN PRIVATE DOMAINS===
+
+users.co.example
+// ===END PRIVATE DOMAINS===
+`
+	l, err := NewList(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		domain      string
+		wantPS      string
+		wantICANN   bool
+		wantManaged bool
+		wantETLD1   string
+	}{
+		{"example", "example", true, true, ""},
+		{"foo.example", "example", true, true, "foo.example"},
+		{"foo.co.example", "co.example", true, true, "foo.co.example"},
+		{"a.foo.users.co.example", "users.co.example", false, true, "foo.users.co.example"},
+		{"a.b.wild.example", "b.wild.example", true, true, "a.b.wild.example"},
+		{"a.www.wild.example", "wild.example", true, true, "www.wild.example"},
+		{"wild.example", "example", true, true, "wild.example"},
+		{"foo.xn--p1ai", "xn--p1ai", true, true, "foo.xn--p1ai"},
+		{"foo.xn--j1aef.xn--p1ai", "xn--j1aef.xn--p1ai", true, true, "foo.xn--j1aef.xn--p1ai"},
+		{"foo.com", "com", false, false, "foo.com"},
+	}
+	for _, tc := range testCases {
+		gotPS, gotICANN, gotManaged := l.PublicSuffixWithInfo(tc.domain)
+		if gotPS != tc.wantPS || gotICANN != tc.wantICANN || gotManaged != tc.wantManaged {
+			t.Errorf("%q: got (%q, %t, %t), want (%q, %t, %t)", tc.domain, gotPS, gotICANN, gotManaged, tc.wantPS, tc.wantICANN, tc.wantManaged)
+		}
+		if got, _ := l.EffectiveTLDPlusOne(tc.domain); got != tc.wantETLD1 {
+			t.Errorf("%q: got eTLD+1 %q, want %q", tc.domain, got, tc.wantETLD1)
+		}
+	}
+}
+
+func TestNewListErrors(t *testing.T) {
+	for _, data := range []string{
+		"!com\n",
+		"foo..com\n",
+		".com\n",
+		"*.*.com\n",
+		"foo/bar.com\n",
+	} {
+		if _, err := NewList(strings.NewReader(data)); err == nil {
+			t.Errorf("NewList(%q) succeeded, want error", data)
+		}
+	}
+}
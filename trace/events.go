@@ -43,18 +43,37 @@ var buckets = []bucket{
 //
 // Most users will use the Events handler.
 func RenderEvents(w http.ResponseWriter, req *http.Request, sensitive bool) {
+	data := newEventsPage(req)
+	if data.EventLogs != nil {
+		defer data.EventLogs.Free()
+	}
+
+	famMu.RLock()
+	defer famMu.RUnlock()
+	if err := eventsTmpl().Execute(w, data); err != nil {
+		log.Printf("net/trace: Failed executing template: %v", err)
+	}
+}
+
+// eventsPage holds the data shown on /debug/events.
+type eventsPage struct {
+	Families []string // family names
+	Buckets  []bucket
+	Counts   [][]int // eventLog count per family/bucket
+
+	// Set when a bucket has been selected.
+	Family    string
+	Bucket    int
+	EventLogs eventLogs
+	Expanded  bool
+}
+
+// newEventsPage collects the data shown on /debug/events for req.
+// The request may be nil. The event logs in the result are sorted and
+// ref'd; the caller should call their Free method when it is done with them.
+func newEventsPage(req *http.Request) *eventsPage {
 	now := time.Now()
-	data := &struct {
-		Families []string // family names
-		Buckets  []bucket
-		Counts   [][]int // eventLog count per family/bucket
-
-		// Set when a bucket has been selected.
-		Family    string
-		Bucket    int
-		EventLogs eventLogs
-		Expanded  bool
-	}{
+	data := &eventsPage{
 		Buckets: buckets,
 	}
 
@@ -86,19 +105,13 @@ func RenderEvents(w http.ResponseWriter, req *http.Request, sensitive bool) {
 			data.EventLogs = getEventFamily(data.Family).Copy(now, buckets[data.Bucket].MaxErrAge)
 		}
 		if data.EventLogs != nil {
-			defer data.EventLogs.Free()
 			sort.Sort(data.EventLogs)
 		}
 		if exp, err := strconv.ParseBool(req.FormValue("exp")); err == nil {
 			data.Expanded = exp
 		}
 	}
-
-	famMu.RLock()
-	defer famMu.RUnlock()
-	if err := eventsTmpl().Execute(w, data); err != nil {
-		log.Printf("net/trace: Failed executing template: %v", err)
-	}
+	return data
 }
 
 func parseEventsArgs(req *http.Request) (fam string, b int, ok bool) {
@@ -145,6 +158,7 @@ func NewEventLog(family, title string) EventLog {
 
 func (el *eventLog) Finish() {
 	getEventFamily(el.Family).remove(el)
+	storeEventLog(getStorage(), el)
 	el.unref() // matches ref in New
 }
 
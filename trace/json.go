@@ -0,0 +1,238 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// The JSON encodings of the /debug/requests and /debug/events pages.
+// Durations are in seconds.
+
+type jsonRequests struct {
+	Families []jsonFamily `json:"families"`
+	Sampling bool         `json:"sampling,omitempty"`
+
+	// Set when a bucket has been selected.
+	Family    string         `json:"family,omitempty"`
+	Bucket    int            `json:"bucket"`
+	Active    bool           `json:"active,omitempty"`
+	Traces    []jsonTrace    `json:"traces,omitempty"`
+	Total     int            `json:"total,omitempty"`
+	Histogram *jsonHistogram `json:"histogram,omitempty"`
+}
+
+type jsonFamily struct {
+	Name    string       `json:"name"`
+	Active  int          `json:"active"`
+	Buckets []jsonBucket `json:"buckets"`
+	Skipped int64        `json:"skipped,omitempty"` // completed traces discarded by the sampler
+}
+
+type jsonBucket struct {
+	Cond  string `json:"cond"`
+	Empty bool   `json:"empty"`
+}
+
+type jsonTrace struct {
+	Title   string      `json:"title"`
+	Start   time.Time   `json:"start"`
+	Elapsed float64     `json:"elapsed"`
+	Error   bool        `json:"error,omitempty"`
+	TraceID uint64      `json:"traceID,omitempty"`
+	SpanID  uint64      `json:"spanID,omitempty"`
+	Events  []jsonEvent `json:"events"`
+
+	ContextTraceID string `json:"contextTraceID,omitempty"`
+	ContextSpanID  string `json:"contextSpanID,omitempty"`
+}
+
+type jsonEvent struct {
+	When     time.Time `json:"when"`
+	Elapsed  float64   `json:"elapsed"`
+	What     string    `json:"what,omitempty"`
+	Redacted bool      `json:"redacted,omitempty"`
+	Error    bool      `json:"error,omitempty"`
+}
+
+type jsonHistogram struct {
+	Window            string                `json:"window"`
+	Count             int64                 `json:"count"`
+	Median            int64                 `json:"median"`
+	Mean              float64               `json:"mean"`
+	StandardDeviation float64               `json:"standardDeviation"`
+	Buckets           []jsonHistogramBucket `json:"buckets"`
+}
+
+// A jsonHistogramBucket counts the latencies in [Lower, Upper) microseconds.
+type jsonHistogramBucket struct {
+	Lower int64 `json:"lower"`
+	Upper int64 `json:"upper"`
+	N     int64 `json:"n"`
+}
+
+type jsonEvents struct {
+	Families []jsonEventFamily `json:"families"`
+
+	// Set when a bucket has been selected.
+	Family    string         `json:"family,omitempty"`
+	Bucket    string         `json:"bucket,omitempty"`
+	EventLogs []jsonEventLog `json:"eventLogs,omitempty"`
+}
+
+type jsonEventFamily struct {
+	Name   string         `json:"name"`
+	Counts map[string]int `json:"counts"` // bucket name -> event log count
+}
+
+type jsonEventLog struct {
+	Title   string      `json:"title"`
+	Start   time.Time   `json:"start"`
+	Elapsed float64     `json:"elapsed"`
+	Stack   string      `json:"stack"`
+	Events  []jsonEvent `json:"events"`
+}
+
+// RenderJSON writes the data of the page typically served at
+// /debug/requests as JSON. It does not do any auth checking.
+// The request may be nil.
+//
+// Most users will use the Traces handler with the query parameter fmt=json.
+func RenderJSON(w io.Writer, req *http.Request, sensitive bool) {
+	data := newRequestsPage(req, sensitive)
+	defer data.Traces.Free()
+
+	out := &jsonRequests{
+		Families: make([]jsonFamily, len(data.Families)),
+		Sampling: data.Sampling,
+		Active:   data.Active,
+		Total:    data.Total,
+	}
+	for i, fam := range data.Families {
+		f := jsonFamily{
+			Name:    fam,
+			Active:  data.ActiveTraceCount[fam],
+			Skipped: data.SkippedTraces[fam],
+			Buckets: make([]jsonBucket, bucketsPerFamily),
+		}
+		for b, c := range bucketConds {
+			f.Buckets[b] = jsonBucket{Cond: c.String(), Empty: data.EmptyBuckets[fam][b]}
+		}
+		out.Families[i] = f
+	}
+	if data.Family != "" {
+		out.Family, out.Bucket = data.Family, data.Bucket
+	}
+	for _, tr := range data.Traces {
+		out.Traces = append(out.Traces, tr.json(data.ShowSensitive))
+	}
+	if h := data.histogram; h != nil {
+		d := h.newData()
+		jh := &jsonHistogram{
+			Window:            data.HistogramWindow,
+			Count:             d.Count,
+			Median:            d.Median,
+			Mean:              d.Mean,
+			StandardDeviation: d.StandardDeviation,
+			Buckets:           []jsonHistogramBucket{},
+		}
+		for _, b := range d.Buckets {
+			if b != nil {
+				jh.Buckets = append(jh.Buckets, jsonHistogramBucket{Lower: b.Lower, Upper: b.Upper, N: b.N})
+			}
+		}
+		out.Histogram = jh
+	}
+	writeJSON(w, out)
+}
+
+// RenderEventsJSON writes the data of the page typically served at
+// /debug/events as JSON. It does not do any auth checking.
+// The request may be nil.
+//
+// Most users will use the Events handler with the query parameter fmt=json.
+func RenderEventsJSON(w io.Writer, req *http.Request, sensitive bool) {
+	data := newEventsPage(req)
+	defer data.EventLogs.Free()
+
+	out := &jsonEvents{
+		Families: make([]jsonEventFamily, len(data.Families)),
+	}
+	for i, fam := range data.Families {
+		f := jsonEventFamily{Name: fam, Counts: make(map[string]int, len(data.Buckets))}
+		for j, b := range data.Buckets {
+			f.Counts[b.String] = data.Counts[i][j]
+		}
+		out.Families[i] = f
+	}
+	if data.Family != "" {
+		out.Family, out.Bucket = data.Family, data.Buckets[data.Bucket].String
+	}
+	for _, el := range data.EventLogs {
+		out.EventLogs = append(out.EventLogs, el.json())
+	}
+	writeJSON(w, out)
+}
+
+func writeJSON(w io.Writer, v interface{}) {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(v); err != nil {
+		log.Printf("net/trace: Failed encoding JSON: %v", err)
+	}
+}
+
+func (tr *trace) json(showSensitive bool) jsonTrace {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+	elapsed := tr.Elapsed
+	if elapsed == 0 {
+		// Active trace.
+		elapsed = time.Since(tr.Start)
+	}
+	jt := jsonTrace{
+		Title:   tr.Title,
+		Start:   tr.Start,
+		Elapsed: elapsed.Seconds(),
+		Error:   tr.IsError,
+		TraceID: tr.traceID,
+		SpanID:  tr.spanID,
+		Events:  make([]jsonEvent, len(tr.events)),
+
+		ContextTraceID: tr.ctxTraceID,
+		ContextSpanID:  tr.ctxSpanID,
+	}
+	for i, e := range tr.events {
+		je := jsonEvent{When: e.When, Elapsed: e.Elapsed.Seconds()}
+		if e.Sensitive && !showSensitive {
+			je.Redacted = true
+		} else {
+			je.What = fmt.Sprint(e.What)
+		}
+		jt.Events[i] = je
+	}
+	return jt
+}
+
+func (el *eventLog) json() jsonEventLog {
+	jl := jsonEventLog{
+		Title:   el.Title,
+		Start:   el.Start,
+		Elapsed: time.Since(el.Start).Seconds(),
+		Stack:   el.Stack(),
+	}
+	el.mu.RLock()
+	defer el.mu.RUnlock()
+	jl.Events = make([]jsonEvent, len(el.events))
+	for i, e := range el.events {
+		jl.Events[i] = jsonEvent{When: e.When, Elapsed: e.Elapsed.Seconds(), What: e.What, Error: e.IsErr}
+	}
+	return jl
+}
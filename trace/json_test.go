@@ -0,0 +1,100 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTracesJSON(t *testing.T) {
+	tr := New("json.Family", "request 1")
+	tr.LazyPrintf("hello %d", 42)
+	tr.LazyLog(secretString{}, true)
+	tr.SetError()
+	tr.Finish()
+
+	for _, sensitive := range []bool{true, false} {
+		req := httptest.NewRequest("GET", "/debug/requests?fam=json.Family&b=8&fmt=json", nil)
+		if !sensitive {
+			req.URL.RawQuery += "&show_sensitive=0"
+		}
+		req.RemoteAddr = "127.0.0.1:1234"
+		rec := httptest.NewRecorder()
+		Traces(rec, req)
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", ct)
+		}
+		var got jsonRequests
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("invalid JSON: %v\n%s", err, rec.Body)
+		}
+		found := false
+		for _, f := range got.Families {
+			if f.Name == "json.Family" {
+				found = true
+				if len(f.Buckets) != bucketsPerFamily || f.Buckets[0].Empty || !f.Buckets[7].Empty {
+					t.Errorf("family buckets = %+v", f.Buckets)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("family json.Family missing from %+v", got.Families)
+		}
+		if got.Family != "json.Family" || got.Bucket != 8 || len(got.Traces) != 1 {
+			t.Fatalf("got family %q, bucket %d, %d traces; want json.Family, 8, 1", got.Family, got.Bucket, len(got.Traces))
+		}
+		jt := got.Traces[0]
+		if jt.Title != "request 1" || !jt.Error || len(jt.Events) != 2 {
+			t.Fatalf("trace = %+v", jt)
+		}
+		if jt.Events[0].What != "hello 42" {
+			t.Errorf("event 0 = %+v, want hello 42", jt.Events[0])
+		}
+		if e := jt.Events[1]; sensitive && (e.What != "secret" || e.Redacted) || !sensitive && (e.What != "" || !e.Redacted) {
+			t.Errorf("sensitive=%t: event 1 = %+v", sensitive, e)
+		}
+	}
+}
+
+func TestEventsJSON(t *testing.T) {
+	el := NewEventLog("json.Events", "conn 1")
+	defer el.Finish()
+	el.Printf("connected")
+	el.Errorf("broken")
+
+	req := httptest.NewRequest("GET", "/debug/events?fam=json.Events&b=0&fmt=json", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	rec := httptest.NewRecorder()
+	Events(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var got jsonEvents
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, rec.Body)
+	}
+	found := false
+	for _, f := range got.Families {
+		if f.Name == "json.Events" {
+			found = true
+			if f.Counts["total"] != 1 || f.Counts["errors"] != 1 {
+				t.Errorf("counts = %v", f.Counts)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("family json.Events missing from %+v", got.Families)
+	}
+	if got.Family != "json.Events" || got.Bucket != "total" || len(got.EventLogs) != 1 {
+		t.Fatalf("got family %q, bucket %q, %d event logs", got.Family, got.Bucket, len(got.EventLogs))
+	}
+	jl := got.EventLogs[0]
+	if jl.Title != "conn 1" || len(jl.Events) != 2 || jl.Events[0].What != "connected" || jl.Events[0].Error || !jl.Events[1].Error {
+		t.Errorf("event log = %+v", jl)
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace
+
+import "sync"
+
+var (
+	samplerMu sync.RWMutex
+	sampler   func(family string) bool // nil means every trace is kept
+)
+
+// SetSampler installs f to decide which traces are kept once they finish.
+// New calls f with the family of each trace it creates; a trace for which
+// f returns false still shows up among the active traces, but is discarded
+// when it finishes unless SetError was called on it. The latency
+// histograms count all traces, sampled or not.
+//
+// Sampling lets a program keep the rarer traces of low-traffic families
+// from being evicted by those of a busy one. The function f may be called
+// concurrently. Calling SetSampler with nil keeps every trace again.
+func SetSampler(f func(family string) bool) {
+	samplerMu.Lock()
+	sampler = f
+	samplerMu.Unlock()
+}
+
+func getSampler() func(family string) bool {
+	samplerMu.RLock()
+	defer samplerMu.RUnlock()
+	return sampler
+}
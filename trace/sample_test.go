@@ -0,0 +1,58 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSampler(t *testing.T) {
+	SetSampler(func(fam string) bool { return fam != "sample.Busy" })
+	defer SetSampler(nil)
+
+	for i := 0; i < 3; i++ {
+		tr := New("sample.Busy", "request")
+		tr.Finish()
+	}
+	tr := New("sample.Busy", "failed request")
+	tr.SetError()
+	tr.Finish()
+	tr = New("sample.Rare", "rare request")
+	tr.Finish()
+
+	if got := getFamily("sample.Busy", false).Skipped; got != 3 {
+		t.Errorf("sample.Busy skipped %d traces, want 3", got)
+	}
+	for _, tt := range []struct {
+		fam    string
+		bucket int
+		want   int
+	}{
+		{"sample.Busy", 0, 1},
+		{"sample.Busy", 8, 1}, // errors
+		{"sample.Rare", 0, 1},
+	} {
+		trl := queryTraces(getStorage(), bucketQuery(tt.fam, tt.bucket, false))
+		if len(trl) != tt.want {
+			t.Errorf("bucket %d of %s has %d traces, want %d", tt.bucket, tt.fam, len(trl), tt.want)
+		}
+		trl.Free()
+	}
+
+	req, err := http.NewRequest("GET", "/debug/requests", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	Render(&buf, req, true)
+	for _, want := range []string{"Sampling is active", "[3 not sampled]"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Render does not contain %q", want)
+		}
+	}
+}
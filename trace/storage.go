@@ -0,0 +1,410 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A Storage keeps finished traces and event logs.
+//
+// By default, they are kept by the Storage returned by NewMemoryStorage.
+// A program may install a different Storage with SetStorage, for instance
+// to stream finished traces to disk so that they survive a restart.
+//
+// The methods of a Storage may be called concurrently.
+type Storage interface {
+	// Record stores a finished trace or event log. It is called
+	// synchronously by Finish, so it should not block for long.
+	// The Storage may retain r.
+	Record(r *Record)
+
+	// Query returns the stored records matching q, most recent first.
+	// The caller must not modify the returned records.
+	Query(q Query) []*Record
+}
+
+var defaultStorage = newMemoryStorage()
+
+var (
+	storageMu sync.RWMutex
+	storage   Storage = defaultStorage
+)
+
+// SetStorage installs s as the storage for traces and event logs that
+// finish from now on. The /debug/requests page lists completed traces
+// from s. Calling SetStorage with nil restores the default in-memory
+// storage.
+func SetStorage(s Storage) {
+	if s == nil {
+		s = defaultStorage
+	}
+	storageMu.Lock()
+	storage = s
+	storageMu.Unlock()
+}
+
+func getStorage() Storage {
+	storageMu.RLock()
+	defer storageMu.RUnlock()
+	return storage
+}
+
+// A RecordKind identifies what a Record was produced by.
+type RecordKind int
+
+const (
+	KindTrace    RecordKind = iota // a Trace
+	KindEventLog                   // an EventLog
+)
+
+// A Record is a snapshot of a finished Trace or EventLog.
+type Record struct {
+	Kind    RecordKind
+	Family  string
+	Title   string
+	Start   time.Time
+	Elapsed time.Duration
+
+	// IsError reports whether the trace called SetError, or whether
+	// the event log called Errorf.
+	IsError bool
+
+	// TraceID and SpanID hold the values passed to SetTraceInfo.
+	TraceID, SpanID uint64
+
+	// ContextTraceID and ContextSpanID hold the values passed to
+	// SetTraceContext.
+	ContextTraceID, ContextSpanID string
+
+	Events []RecordEvent
+}
+
+// A RecordEvent is an entry in the log of a Record.
+type RecordEvent struct {
+	When      time.Time
+	Elapsed   time.Duration // since the previous event, or the start
+	What      string
+	Sensitive bool // whether What contains sensitive information
+	IsError   bool // whether the entry was logged with EventLog.Errorf
+}
+
+// A Query selects records from a Storage.
+type Query struct {
+	Kind RecordKind
+
+	// Family restricts the results to a single family.
+	// The empty string matches all families.
+	Family string
+
+	MinElapsed time.Duration // minimum elapsed time
+	ErrorsOnly bool          // only records with IsError set
+	TracedOnly bool          // only records with a non-zero SpanID or a ContextSpanID
+
+	// Max is the maximum number of records to return.
+	// Zero means no limit.
+	Max int
+}
+
+// Match reports whether r satisfies the conditions of q, ignoring Max.
+func (q *Query) Match(r *Record) bool {
+	switch {
+	case r.Kind != q.Kind:
+		return false
+	case q.Family != "" && r.Family != q.Family:
+		return false
+	case r.Elapsed < q.MinElapsed:
+		return false
+	case q.ErrorsOnly && !r.IsError:
+		return false
+	case q.TracedOnly && r.SpanID == 0 && r.ContextSpanID == "":
+		return false
+	}
+	return true
+}
+
+// bucketQuery returns the query listing the traces of bucket b of family fam.
+func bucketQuery(fam string, b int, tracedOnly bool) Query {
+	q := Query{Kind: KindTrace, Family: fam, TracedOnly: tracedOnly, Max: tracesPerBucket}
+	switch c := bucketConds[b].(type) {
+	case minCond:
+		q.MinElapsed = time.Duration(c)
+	case errorCond:
+		q.ErrorsOnly = true
+	}
+	return q
+}
+
+// emptyBuckets reports, for each bucket of family fam, whether s holds
+// no trace of the bucket, querying s for at most one trace per bucket.
+func emptyBuckets(s Storage, fam string) []bool {
+	empty := make([]bool, bucketsPerFamily)
+	for b := range bucketConds {
+		q := bucketQuery(fam, b, false)
+		q.Max = 1
+		trl := queryTraces(s, q)
+		empty[b] = len(trl) == 0
+		trl.Free()
+	}
+	return empty
+}
+
+// record returns a snapshot of the finished trace tr.
+func (tr *trace) record() *Record {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+	r := &Record{
+		Kind:    KindTrace,
+		Family:  tr.Family,
+		Title:   tr.Title,
+		Start:   tr.Start,
+		Elapsed: tr.Elapsed,
+		IsError: tr.IsError,
+		TraceID: tr.traceID,
+		SpanID:  tr.spanID,
+		Events:  make([]RecordEvent, len(tr.events)),
+
+		ContextTraceID: tr.ctxTraceID,
+		ContextSpanID:  tr.ctxSpanID,
+	}
+	for i, e := range tr.events {
+		r.Events[i] = RecordEvent{
+			When:      e.When,
+			Elapsed:   e.Elapsed,
+			What:      fmt.Sprint(e.What),
+			Sensitive: e.Sensitive,
+		}
+	}
+	return r
+}
+
+// record returns a snapshot of the finished event log el.
+func (el *eventLog) record() *Record {
+	el.mu.RLock()
+	defer el.mu.RUnlock()
+	r := &Record{
+		Kind:    KindEventLog,
+		Family:  el.Family,
+		Title:   el.Title,
+		Start:   el.Start,
+		Elapsed: time.Since(el.Start),
+		IsError: !el.LastErrorTime.IsZero(),
+		Events:  make([]RecordEvent, len(el.events)),
+	}
+	for i, e := range el.events {
+		r.Events[i] = RecordEvent{
+			When:    e.When,
+			Elapsed: e.Elapsed,
+			What:    e.What,
+			IsError: e.IsErr,
+		}
+	}
+	return r
+}
+
+// recordTrace returns a trace for rendering r.
+// The trace is ref'd; the caller should unref it when it is done with it.
+func recordTrace(r *Record) *trace {
+	tr := newTrace()
+	tr.ref()
+	tr.Family, tr.Title, tr.Start = r.Family, r.Title, r.Start
+	tr.Elapsed, tr.IsError = r.Elapsed, r.IsError
+	tr.traceID, tr.spanID = r.TraceID, r.SpanID
+	tr.ctxTraceID, tr.ctxSpanID = r.ContextTraceID, r.ContextSpanID
+	tr.events = make([]event, len(r.Events))
+	prev := r.Start
+	for i, e := range r.Events {
+		tr.events[i] = event{
+			When:      e.When,
+			Elapsed:   e.Elapsed,
+			NewDay:    i > 0 && prev.Day() != e.When.Day(),
+			Sensitive: e.Sensitive,
+			What:      e.What,
+		}
+		prev = e.When
+	}
+	return tr
+}
+
+// storeTrace stores the finished trace tr in s.
+func storeTrace(s Storage, tr *trace) {
+	if ms, ok := s.(*memoryStorage); ok {
+		ms.add(tr)
+		return
+	}
+	s.Record(tr.record())
+}
+
+// storeEventLog stores the finished event log el in s.
+func storeEventLog(s Storage, el *eventLog) {
+	if _, ok := s.(*memoryStorage); ok {
+		return // discarded anyway
+	}
+	s.Record(el.record())
+}
+
+// queryTraces returns the traces in s matching q, ready for rendering.
+// The traces are ref'd; the caller should call the Free method of the
+// list when it is done with them.
+func queryTraces(s Storage, q Query) traceList {
+	if ms, ok := s.(*memoryStorage); ok {
+		return ms.traces(q)
+	}
+	rs := s.Query(q)
+	trl := make(traceList, 0, len(rs))
+	for _, r := range rs {
+		trl = append(trl, recordTrace(r))
+	}
+	return trl
+}
+
+// NewMemoryStorage returns a Storage that keeps the most recent finished
+// traces of each family in memory, in a small number of fixed-size
+// buckets by latency and for errors, and discards event logs. It is the
+// default Storage.
+func NewMemoryStorage() Storage {
+	return newMemoryStorage()
+}
+
+// memoryStorage keeps the finished traces themselves, rather than
+// records of them, so that their lazily logged events are only
+// formatted when shown.
+type memoryStorage struct {
+	mu       sync.RWMutex
+	families map[string]*[bucketsPerFamily]*traceBucket
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{families: make(map[string]*[bucketsPerFamily]*traceBucket)}
+}
+
+// buckets returns the buckets of family fam, allocating them if needed
+// and create is true.
+func (s *memoryStorage) buckets(fam string, create bool) *[bucketsPerFamily]*traceBucket {
+	s.mu.RLock()
+	bs := s.families[fam]
+	s.mu.RUnlock()
+	if bs != nil || !create {
+		return bs
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if bs = s.families[fam]; bs == nil {
+		bs = new([bucketsPerFamily]*traceBucket)
+		for i, c := range bucketConds {
+			bs[i] = &traceBucket{Cond: c}
+		}
+		s.families[fam] = bs
+	}
+	return bs
+}
+
+// add adds the finished trace tr to the buckets whose condition it meets.
+func (s *memoryStorage) add(tr *trace) {
+	bs := s.buckets(tr.Family, true)
+	var match [bucketsPerFamily]bool
+	tr.mu.RLock() // protects tr fields in Cond.match calls
+	for i, b := range bs {
+		match[i] = b.Cond.match(tr)
+	}
+	tr.mu.RUnlock()
+	for i, b := range bs {
+		if match[i] {
+			b.Add(tr)
+		}
+	}
+}
+
+func (s *memoryStorage) Record(r *Record) {
+	if r.Kind != KindTrace {
+		return
+	}
+	tr := recordTrace(r)
+	s.add(tr)
+	tr.unref()
+}
+
+func (s *memoryStorage) Query(q Query) []*Record {
+	trl := s.traces(q)
+	defer trl.Free()
+	rs := make([]*Record, len(trl))
+	for i, tr := range trl {
+		rs[i] = tr.record()
+	}
+	return rs
+}
+
+// traces returns the stored traces matching q, most recent first.
+// The traces are ref'd; the caller should call the Free method of the
+// list when it is done with them.
+func (s *memoryStorage) traces(q Query) traceList {
+	if q.Kind != KindTrace {
+		return nil
+	}
+	var fams []*[bucketsPerFamily]*traceBucket
+	if q.Family != "" {
+		if bs := s.buckets(q.Family, false); bs != nil {
+			fams = append(fams, bs)
+		}
+	} else {
+		s.mu.RLock()
+		for _, bs := range s.families {
+			fams = append(fams, bs)
+		}
+		s.mu.RUnlock()
+	}
+
+	// A trace may be in several buckets of its family.
+	seen := make(map[*trace]bool)
+	var all traceList
+	for _, bs := range fams {
+		for _, b := range bs {
+			b.mu.RLock()
+			for i := 0; i < b.length; i++ {
+				if tr := b.buf[(b.start+i)%tracesPerBucket]; !seen[tr] {
+					seen[tr] = true
+					tr.ref()
+					all = append(all, tr)
+				}
+			}
+			b.mu.RUnlock()
+		}
+	}
+	trl := all[:0]
+	for _, tr := range all {
+		if q.matchTrace(tr) {
+			trl = append(trl, tr)
+		} else {
+			tr.unref()
+		}
+	}
+	sort.Sort(trl)
+	if q.Max > 0 && len(trl) > q.Max {
+		trl[q.Max:].Free()
+		trl = trl[:q.Max]
+	}
+	return trl
+}
+
+// matchTrace is like Match, for the finished trace tr.
+func (q *Query) matchTrace(tr *trace) bool {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+	switch {
+	case q.Family != "" && tr.Family != q.Family:
+		return false
+	case tr.Elapsed < q.MinElapsed:
+		return false
+	case q.ErrorsOnly && !tr.IsError:
+		return false
+	case q.TracedOnly && tr.spanID == 0 && tr.ctxSpanID == "":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,190 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type sliceStorage struct {
+	mu      sync.Mutex
+	records []*Record
+}
+
+func (s *sliceStorage) Record(r *Record) {
+	s.mu.Lock()
+	s.records = append(s.records, r)
+	s.mu.Unlock()
+}
+
+func (s *sliceStorage) Query(q Query) []*Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rs []*Record
+	for i := len(s.records) - 1; i >= 0; i-- {
+		if q.Match(s.records[i]) {
+			rs = append(rs, s.records[i])
+		}
+		if q.Max > 0 && len(rs) == q.Max {
+			break
+		}
+	}
+	return rs
+}
+
+func TestStorage(t *testing.T) {
+	s := new(sliceStorage)
+	SetStorage(s)
+	defer SetStorage(nil)
+
+	tr := New("storage.Family", "request 1")
+	tr.LazyPrintf("hello %d", 42)
+	tr.LazyLog(secretString{}, true)
+	tr.SetError()
+	tr.Finish()
+
+	el := NewEventLog("storage.Events", "conn 1")
+	el.Printf("connected")
+	el.Errorf("broken")
+	el.Finish()
+
+	if len(s.records) != 2 {
+		t.Fatalf("got %d records, want 2", len(s.records))
+	}
+	r := s.records[0]
+	if r.Kind != KindTrace || r.Family != "storage.Family" || r.Title != "request 1" || !r.IsError {
+		t.Errorf("trace record = %+v", r)
+	}
+	if len(r.Events) != 2 || r.Events[0].What != "hello 42" || r.Events[1].What != "secret" || !r.Events[1].Sensitive {
+		t.Errorf("trace record events = %+v", r.Events)
+	}
+	r = s.records[1]
+	if r.Kind != KindEventLog || r.Family != "storage.Events" || !r.IsError {
+		t.Errorf("event log record = %+v", r)
+	}
+	if len(r.Events) != 2 || r.Events[0].IsError || !r.Events[1].IsError {
+		t.Errorf("event log record events = %+v", r.Events)
+	}
+
+	if trl := defaultStorage.traces(Query{Kind: KindTrace, Family: "storage.Family"}); len(trl) != 0 {
+		trl.Free()
+		t.Error("trace was added to the default storage")
+	}
+
+	for _, tt := range []struct {
+		url       string
+		sensitive bool
+		want      []string
+		notWant   []string
+	}{
+		{"/debug/requests?fam=storage.Family&b=0&exp=1", true, []string{"request 1", "hello 42", "secret"}, nil},
+		{"/debug/requests?fam=storage.Family&b=0&exp=1", false, []string{"request 1", "hello 42", "[redacted]"}, []string{"secret"}},
+		{"/debug/requests?fam=storage.Family&b=8", true, []string{"request 1"}, nil},
+		{"/debug/requests?fam=storage.Family&b=7", true, nil, []string{"request 1"}},
+	} {
+		req, err := http.NewRequest("GET", tt.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		Render(&buf, req, tt.sensitive)
+		for _, w := range tt.want {
+			if !strings.Contains(buf.String(), w) {
+				t.Errorf("Render(%q, %t) does not contain %q", tt.url, tt.sensitive, w)
+			}
+		}
+		for _, w := range tt.notWant {
+			if strings.Contains(buf.String(), w) {
+				t.Errorf("Render(%q, %t) contains %q", tt.url, tt.sensitive, w)
+			}
+		}
+	}
+}
+
+func TestMemoryStorage(t *testing.T) {
+	s := NewMemoryStorage()
+	start := time.Now()
+	for i, r := range []*Record{
+		{Kind: KindTrace, Family: "a", Title: "fast", Start: start, Elapsed: time.Millisecond},
+		{Kind: KindTrace, Family: "a", Title: "slow", Start: start.Add(1), Elapsed: 2 * time.Second},
+		{Kind: KindTrace, Family: "a", Title: "failed", Start: start.Add(2), IsError: true, SpanID: 1},
+		{Kind: KindTrace, Family: "b", Title: "other", Start: start.Add(3)},
+		{Kind: KindEventLog, Family: "a", Title: "conn", Start: start.Add(4)},
+	} {
+		r.Events = []RecordEvent{{When: r.Start, What: fmt.Sprint("event ", i)}}
+		s.Record(r)
+	}
+
+	for _, tt := range []struct {
+		q    Query
+		want []string
+	}{
+		{Query{Kind: KindTrace, Family: "a"}, []string{"failed", "slow", "fast"}},
+		{Query{Kind: KindTrace, Family: "a", Max: 2}, []string{"failed", "slow"}},
+		{Query{Kind: KindTrace}, []string{"other", "failed", "slow", "fast"}},
+		{Query{Kind: KindTrace, Family: "a", MinElapsed: time.Second}, []string{"slow"}},
+		{Query{Kind: KindTrace, Family: "a", ErrorsOnly: true}, []string{"failed"}},
+		{Query{Kind: KindTrace, Family: "a", TracedOnly: true}, []string{"failed"}},
+		{Query{Kind: KindEventLog}, nil},
+	} {
+		var got []string
+		for _, r := range s.Query(tt.q) {
+			got = append(got, r.Title)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Query(%+v) = %q, want %q", tt.q, got, tt.want)
+		}
+	}
+	if rs := s.Query(Query{Kind: KindTrace, Family: "a", MinElapsed: time.Second}); len(rs) != 1 || len(rs[0].Events) != 1 || rs[0].Events[0].What != "event 1" {
+		t.Errorf("slow trace record = %+v", rs)
+	}
+
+	// The first bucket keeps only the most recent traces.
+	for i := 0; i < tracesPerBucket; i++ {
+		s.Record(&Record{Kind: KindTrace, Family: "a", Title: "new", Start: start.Add(time.Duration(10 + i))})
+	}
+	if got := emptyBuckets(s, "a"); !reflect.DeepEqual(got, []bool{false, false, false, false, false, false, true, true, false}) {
+		t.Errorf("emptyBuckets = %v", got)
+	}
+	if rs := s.Query(Query{Kind: KindTrace, Family: "a"}); len(rs) != tracesPerBucket+2 {
+		t.Errorf("got %d traces, want %d", len(rs), tracesPerBucket+2)
+	}
+}
+
+// A queryRecorder is a Storage that records the queries made to it.
+type queryRecorder struct {
+	Storage
+	queries []Query
+}
+
+func (s *queryRecorder) Query(q Query) []*Record {
+	s.queries = append(s.queries, q)
+	return s.Storage.Query(q)
+}
+
+func TestEmptyBucketsQueries(t *testing.T) {
+	s := &queryRecorder{Storage: newMemoryStorage()}
+	s.Record(&Record{Kind: KindTrace, Family: "a", Title: "fast", Start: time.Now()})
+	emptyBuckets(s, "a")
+	if len(s.queries) != bucketsPerFamily {
+		t.Errorf("got %d queries, want %d", len(s.queries), bucketsPerFamily)
+	}
+	for _, q := range s.queries {
+		if q.Max != 1 {
+			t.Errorf("query %+v: want Max of 1", q)
+		}
+	}
+}
+
+type secretString struct{}
+
+func (secretString) String() string { return "secret" }
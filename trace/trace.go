@@ -59,6 +59,9 @@ connections.
 The /debug/events HTTP endpoint organizes the event logs by family and
 by time since the last error.  The expanded view displays recent log
 entries and the log's call stack.
+
+Both endpoints serve the same data as JSON when given the query
+parameter fmt=json.
 */
 package trace // import "golang.org/x/net/trace"
 
@@ -147,6 +150,9 @@ func FromContext(ctx context.Context) (tr Trace, ok bool) {
 // The package initialization registers it in http.DefaultServeMux
 // at /debug/requests.
 //
+// It responds with JSON, as written by RenderJSON, if the query
+// parameter fmt is "json".
+//
 // It performs authorization by running AuthRequest.
 func Traces(w http.ResponseWriter, req *http.Request) {
 	any, sensitive := AuthRequest(req)
@@ -154,6 +160,11 @@ func Traces(w http.ResponseWriter, req *http.Request) {
 		http.Error(w, "not allowed", http.StatusUnauthorized)
 		return
 	}
+	if req.FormValue("fmt") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		RenderJSON(w, req, sensitive)
+		return
+	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	Render(w, req, sensitive)
 }
@@ -162,6 +173,9 @@ func Traces(w http.ResponseWriter, req *http.Request) {
 // The package initialization registers it in http.DefaultServeMux
 // at /debug/events.
 //
+// It responds with JSON, as written by RenderEventsJSON, if the query
+// parameter fmt is "json".
+//
 // It performs authorization by running AuthRequest.
 func Events(w http.ResponseWriter, req *http.Request) {
 	any, sensitive := AuthRequest(req)
@@ -169,6 +183,11 @@ func Events(w http.ResponseWriter, req *http.Request) {
 		http.Error(w, "not allowed", http.StatusUnauthorized)
 		return
 	}
+	if req.FormValue("fmt") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		RenderEventsJSON(w, req, sensitive)
+		return
+	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	RenderEvents(w, req, sensitive)
 }
@@ -178,30 +197,49 @@ func Events(w http.ResponseWriter, req *http.Request) {
 //
 // Most users will use the Traces handler.
 func Render(w io.Writer, req *http.Request, sensitive bool) {
-	data := &struct {
-		Families         []string
-		ActiveTraceCount map[string]int
-		CompletedTraces  map[string]*family
-
-		// Set when a bucket has been selected.
-		Traces        traceList
-		Family        string
-		Bucket        int
-		Expanded      bool
-		Traced        bool
-		Active        bool
-		ShowSensitive bool // whether to show sensitive events
-
-		Histogram       template.HTML
-		HistogramWindow string // e.g. "last minute", "last hour", "all time"
-
-		// If non-zero, the set of traces is a partial set,
-		// and this is the total number.
-		Total int
-	}{
-		CompletedTraces: completedTraces,
+	data := newRequestsPage(req, sensitive)
+	if data.Traces != nil {
+		defer data.Traces.Free()
 	}
 
+	if err := pageTmpl().ExecuteTemplate(w, "Page", data); err != nil {
+		log.Printf("net/trace: Failed executing template: %v", err)
+	}
+}
+
+// requestsPage holds the data shown on /debug/requests.
+type requestsPage struct {
+	Families         []string
+	ActiveTraceCount map[string]int
+	BucketConds      []cond
+	EmptyBuckets     map[string][]bool // family -> whether each bucket is empty
+	SkippedTraces    map[string]int64  // family -> completed traces discarded by the sampler
+	Sampling         bool              // whether a sampler is installed
+
+	// Set when a bucket has been selected.
+	Traces        traceList
+	Family        string
+	Bucket        int
+	Expanded      bool
+	Traced        bool
+	Active        bool
+	ShowSensitive bool // whether to show sensitive events
+
+	Histogram       template.HTML
+	HistogramWindow string // e.g. "last minute", "last hour", "all time"
+
+	// If non-zero, the set of traces is a partial set,
+	// and this is the total number.
+	Total int
+
+	histogram *histogram // the histogram selected, if any
+}
+
+// newRequestsPage collects the data shown on /debug/requests for req.
+// The request may be nil. The traces in the result are sorted and ref'd;
+// the caller should call their Free method when it is done with them.
+func newRequestsPage(req *http.Request, sensitive bool) *requestsPage {
+	data := new(requestsPage)
 	data.ShowSensitive = sensitive
 	if req != nil {
 		// Allow show_sensitive=0 to force hiding of sensitive data for testing.
@@ -235,6 +273,21 @@ func Render(w io.Writer, req *http.Request, sensitive bool) {
 	}
 	activeMu.RUnlock()
 
+	data.Sampling = getSampler() != nil
+	data.SkippedTraces = make(map[string]int64, len(data.Families))
+	for _, fam := range data.Families {
+		if f := getFamily(fam, false); f != nil {
+			data.SkippedTraces[fam] = atomic.LoadInt64(&f.Skipped)
+		}
+	}
+
+	s := getStorage()
+	data.BucketConds = bucketConds[:]
+	data.EmptyBuckets = make(map[string][]bool, len(data.Families))
+	for _, fam := range data.Families {
+		data.EmptyBuckets[fam] = emptyBuckets(s, fam)
+	}
+
 	var ok bool
 	data.Family, data.Bucket, ok = parseArgs(req)
 	switch {
@@ -248,9 +301,7 @@ func Render(w io.Writer, req *http.Request, sensitive bool) {
 			data.Total = n
 		}
 	case data.Bucket < bucketsPerFamily:
-		if b := lookupBucket(data.Family, data.Bucket); b != nil {
-			data.Traces = b.Copy(data.Traced)
-		}
+		data.Traces = queryTraces(s, bucketQuery(data.Family, data.Bucket, data.Traced))
 	default:
 		if f := getFamily(data.Family, false); f != nil {
 			var obs timeseries.Observable
@@ -268,21 +319,16 @@ func Render(w io.Writer, req *http.Request, sensitive bool) {
 			}
 			f.LatencyMu.RUnlock()
 			if obs != nil {
-				data.Histogram = obs.(*histogram).html()
+				data.histogram = obs.(*histogram)
+				data.Histogram = data.histogram.html()
 			}
 		}
 	}
 
 	if data.Traces != nil {
-		defer data.Traces.Free()
 		sort.Sort(data.Traces)
 	}
-
-	completedMu.RLock()
-	defer completedMu.RUnlock()
-	if err := pageTmpl().ExecuteTemplate(w, "Page", data); err != nil {
-		log.Printf("net/trace: Failed executing template: %v", err)
-	}
+	return data
 }
 
 func parseArgs(req *http.Request) (fam string, b int, ok bool) {
@@ -301,14 +347,6 @@ func parseArgs(req *http.Request) (fam string, b int, ok bool) {
 	return fam, b, true
 }
 
-func lookupBucket(fam string, b int) *traceBucket {
-	f := getFamily(fam, false)
-	if f == nil || b < 0 || b >= len(f.Buckets) {
-		return nil
-	}
-	return f.Buckets[b]
-}
-
 type contextKeyT string
 
 var contextKey = contextKeyT("golang.org/x/net/trace.Trace")
@@ -348,6 +386,22 @@ type Trace interface {
 	Finish()
 }
 
+// traceContextSetter is implemented by the traces returned by New.
+type traceContextSetter interface {
+	setTraceContext(traceID, spanID string)
+}
+
+// SetTraceContext links tr to a span of a distributed trace, such as one
+// recorded by OpenTelemetry, identified by its trace and span IDs in hex,
+// as in a W3C traceparent header (see ExtractTraceparent). The IDs are
+// shown in /debug/requests, and the trace is listed among the traced
+// requests. It does nothing if tr was not returned by New.
+func SetTraceContext(tr Trace, traceID, spanID string) {
+	if s, ok := tr.(traceContextSetter); ok {
+		s.setTraceContext(traceID, spanID)
+	}
+}
+
 type lazySprintf struct {
 	format string
 	a      []interface{}
@@ -365,6 +419,10 @@ func New(family, title string) Trace {
 	tr.Start = time.Now()
 	tr.maxEvents = maxEventsPerTrace
 	tr.events = tr.eventsBuf[:0]
+	tr.sampled = true
+	if f := getSampler(); f != nil {
+		tr.sampled = f(family)
+	}
 
 	activeMu.RLock()
 	s := activeTraces[tr.Family]
@@ -412,13 +470,14 @@ func (tr *trace) Finish() {
 	m.Remove(tr)
 
 	f := getFamily(tr.Family, true)
-	tr.mu.RLock() // protects tr fields in Cond.match calls
-	for _, b := range f.Buckets {
-		if b.Cond.match(tr) {
-			b.Add(tr)
-		}
-	}
+	tr.mu.RLock()
+	keep := tr.sampled || tr.IsError
 	tr.mu.RUnlock()
+	if keep {
+		storeTrace(getStorage(), tr)
+	} else {
+		atomic.AddInt64(&f.Skipped, 1)
+	}
 
 	// Add a sample of elapsed time as microseconds to the family's timeseries
 	h := new(histogram)
@@ -560,29 +619,34 @@ func allocFamily(fam string) *family {
 	return f
 }
 
-// family represents a set of trace buckets and associated latency information.
+// family represents the latency information of a family of traces.
+// The finished traces themselves are kept by the Storage.
 type family struct {
-	// traces may occur in multiple buckets.
-	Buckets [bucketsPerFamily]*traceBucket
+	// Skipped is the number of completed traces discarded by the sampler.
+	// It is accessed atomically, and kept first so that it is 64-bit
+	// aligned on 32-bit platforms.
+	Skipped int64
 
 	// latency time series
 	LatencyMu sync.RWMutex
 	Latency   *timeseries.MinuteHourSeries
 }
 
+// bucketConds holds the condition for each bucket of a family.
+var bucketConds = [bucketsPerFamily]cond{
+	minCond(0),
+	minCond(50 * time.Millisecond),
+	minCond(100 * time.Millisecond),
+	minCond(200 * time.Millisecond),
+	minCond(500 * time.Millisecond),
+	minCond(1 * time.Second),
+	minCond(10 * time.Second),
+	minCond(100 * time.Second),
+	errorCond{},
+}
+
 func newFamily() *family {
 	return &family{
-		Buckets: [bucketsPerFamily]*traceBucket{
-			{Cond: minCond(0)},
-			{Cond: minCond(50 * time.Millisecond)},
-			{Cond: minCond(100 * time.Millisecond)},
-			{Cond: minCond(200 * time.Millisecond)},
-			{Cond: minCond(500 * time.Millisecond)},
-			{Cond: minCond(1 * time.Second)},
-			{Cond: minCond(10 * time.Second)},
-			{Cond: minCond(100 * time.Second)},
-			{Cond: errorCond{}},
-		},
 		Latency: timeseries.NewMinuteHourSeries(func() timeseries.Observable { return new(histogram) }),
 	}
 }
@@ -622,36 +686,6 @@ func (b *traceBucket) Add(tr *trace) {
 	tr.ref()
 }
 
-// Copy returns a copy of the traces in the bucket.
-// If tracedOnly is true, only the traces with trace information will be returned.
-// The logs will be ref'd before returning; the caller should call
-// the Free method when it is done with them.
-// TODO(dsymonds): keep track of traced requests in separate buckets.
-func (b *traceBucket) Copy(tracedOnly bool) traceList {
-	b.mu.RLock()
-	defer b.mu.RUnlock()
-
-	trl := make(traceList, 0, b.length)
-	for i, x := 0, b.start; i < b.length; i++ {
-		tr := b.buf[x]
-		if !tracedOnly || tr.spanID != 0 {
-			tr.ref()
-			trl = append(trl, tr)
-		}
-		x++
-		if x == b.length {
-			x = 0
-		}
-	}
-	return trl
-}
-
-func (b *traceBucket) Empty() bool {
-	b.mu.RLock()
-	defer b.mu.RUnlock()
-	return b.length == 0
-}
-
 // cond represents a condition on a trace.
 type cond interface {
 	match(t *trace) bool
@@ -721,14 +755,17 @@ type trace struct {
 	// Start time of the this trace.
 	Start time.Time
 
-	mu        sync.RWMutex
-	events    []event // Append-only sequence of events (modulo discards).
-	maxEvents int
-	recycler  func(interface{})
-	IsError   bool          // Whether this trace resulted in an error.
-	Elapsed   time.Duration // Elapsed time for this trace, zero while active.
-	traceID   uint64        // Trace information if non-zero.
-	spanID    uint64
+	mu         sync.RWMutex
+	events     []event // Append-only sequence of events (modulo discards).
+	maxEvents  int
+	recycler   func(interface{})
+	IsError    bool          // Whether this trace resulted in an error.
+	Elapsed    time.Duration // Elapsed time for this trace, zero while active.
+	traceID    uint64        // Trace information if non-zero.
+	spanID     uint64
+	ctxTraceID string // distributed trace context, if set; see SetTraceContext
+	ctxSpanID  string
+	sampled    bool // whether to keep this trace once finished; see SetSampler
 
 	refs int32     // how many buckets this is in
 	disc discarded // scratch space to avoid allocation
@@ -748,7 +785,10 @@ func (tr *trace) reset() {
 	tr.Elapsed = 0
 	tr.traceID = 0
 	tr.spanID = 0
+	tr.ctxTraceID = ""
+	tr.ctxSpanID = ""
 	tr.IsError = false
+	tr.sampled = false
 	tr.maxEvents = 0
 	tr.events = nil
 	tr.recycler = nil
@@ -852,6 +892,12 @@ func (tr *trace) SetTraceInfo(traceID, spanID uint64) {
 	tr.mu.Unlock()
 }
 
+func (tr *trace) setTraceContext(traceID, spanID string) {
+	tr.mu.Lock()
+	tr.ctxTraceID, tr.ctxSpanID = traceID, spanID
+	tr.mu.Unlock()
+}
+
 func (tr *trace) SetMaxEvents(m int) {
 	tr.mu.Lock()
 	// Always keep at least three events: first, discarded count, last.
@@ -884,6 +930,17 @@ func (tr *trace) unref() {
 	}
 }
 
+// TraceContext returns the distributed trace context of tr for
+// display, or the empty string if it has none.
+func (tr *trace) TraceContext() string {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+	if tr.ctxTraceID == "" && tr.ctxSpanID == "" {
+		return ""
+	}
+	return "trace_id=" + tr.ctxTraceID + " span_id=" + tr.ctxSpanID
+}
+
 func (tr *trace) When() string {
 	return tr.Start.Format("2006/01/02 15:04:05.000000")
 }
@@ -1004,6 +1061,9 @@ const pageHTML = `
 			text-align: right;
 			white-space: nowrap;
 		}
+		table#reqs td.tracectx {
+			font-family: monospace;
+		}
 		table#reqs td.elapsed {
 			padding: 0 0.5em;
 			text-align: right;
@@ -1034,17 +1094,16 @@ const pageHTML = `
 			{{if $n}}</a>{{end}}
 		</td>
 
-		{{$f := index $.CompletedTraces $fam}}
-		{{range $i, $b := $f.Buckets}}
-		{{$empty := $b.Empty}}
+		{{range $i, $c := $.BucketConds}}
+		{{$empty := index $.EmptyBuckets $fam $i}}
 		<td {{if $empty}}class="empty"{{end}}>
 		{{if not $empty}}<a href="?fam={{$fam}}&b={{$i}}{{if $.Expanded}}&exp=1{{end}}">{{end}}
-		[{{.Cond}}]
+		[{{$c}}]
 		{{if not $empty}}</a>{{end}}
 		</td>
 		{{end}}
 
-		{{$nb := len $f.Buckets}}
+		{{$nb := len $.BucketConds}}
 		<td class="latency-first">
 		<a href="?fam={{$fam}}&b={{$nb}}">[minute]</a>
 		</td>
@@ -1055,9 +1114,17 @@ const pageHTML = `
 		<a href="?fam={{$fam}}&b={{add $nb 2}}">[total]</a>
 		</td>
 
+		{{$skipped := index $.SkippedTraces $fam}}
+		{{if $skipped}}
+		<td class="latency-first">[{{$skipped}} not sampled]</td>
+		{{end}}
+
 	</tr>
 	{{end}}
 </table>
+{{if $.Sampling}}
+<p><em>Sampling is active: completed traces without errors are only kept for a subset of requests.</em></p>
+{{end}}
 {{end}} {{/* end of StatusTable */}}
 
 {{define "Epilog"}}
@@ -1104,7 +1171,7 @@ const pageHTML = `
 		<td class="when">{{$tr.When}}</td>
 		<td class="elapsed">{{$tr.ElapsedTime}}</td>
 		<td>{{$tr.Title}}</td>
-		{{/* TODO: include traceID/spanID */}}
+		{{with $tr.TraceContext}}<td class="tracectx">{{.}}</td>{{end}}
 	</tr>
 	{{if $.Expanded}}
 	{{range $tr.Events}}
@@ -21,6 +21,7 @@ func TestReset(t *testing.T) {
 	tr.LazyPrintf("%d", 1)
 	tr.SetRecycler(func(_ interface{}) {})
 	tr.SetTraceInfo(3, 4)
+	SetTraceContext(tr, "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7")
 	tr.SetMaxEvents(100)
 	tr.SetError()
 	tr.Finish()
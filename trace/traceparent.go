@@ -0,0 +1,75 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace
+
+import "net/http"
+
+// traceparentHeader is the canonical key of the W3C Trace Context
+// header, https://www.w3.org/TR/trace-context/.
+const traceparentHeader = "Traceparent"
+
+// ExtractTraceparent returns the trace and span IDs carried by the W3C
+// traceparent header of h, for use with SetTraceContext. It
+// reports false if h has no traceparent header, more than one, or an
+// invalid one.
+func ExtractTraceparent(h http.Header) (traceID, spanID string, ok bool) {
+	vs := h[traceparentHeader]
+	if len(vs) != 1 {
+		return "", "", false
+	}
+	v := vs[0]
+	// version "-" trace-id "-" parent-id "-" trace-flags
+	if len(v) < 55 || v[2] != '-' || v[35] != '-' || v[52] != '-' {
+		return "", "", false
+	}
+	version := v[:2]
+	if !isLowerHex(version) || version == "ff" || !isLowerHex(v[53:55]) {
+		return "", "", false
+	}
+	// Later versions may append fields, which are ignored.
+	if len(v) > 55 && (version == "00" || v[55] != '-') {
+		return "", "", false
+	}
+	traceID, spanID = v[3:35], v[36:52]
+	if !validTraceContextID(traceID) || !validTraceContextID(spanID) {
+		return "", "", false
+	}
+	return traceID, spanID, true
+}
+
+// InjectTraceparent sets the W3C traceparent header of h to carry
+// traceID and spanID, which must be 32 and 16 lowercase hex digits
+// respectively and not all zeros, flagged as sampled if sampled is
+// true. It does nothing if either ID is invalid.
+func InjectTraceparent(h http.Header, traceID, spanID string, sampled bool) {
+	if len(traceID) != 32 || len(spanID) != 16 || !validTraceContextID(traceID) || !validTraceContextID(spanID) {
+		return
+	}
+	flags := "00"
+	if sampled {
+		flags = "01"
+	}
+	h[traceparentHeader] = []string{"00-" + traceID + "-" + spanID + "-" + flags}
+}
+
+// validTraceContextID reports whether id is a valid trace or span ID,
+// of lowercase hex digits and not all zeros.
+func validTraceContextID(id string) bool {
+	for i := 0; i < len(id); i++ {
+		if id[i] != '0' {
+			return isLowerHex(id)
+		}
+	}
+	return false
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExtractTraceparent(t *testing.T) {
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+	for _, tt := range []struct {
+		v  string
+		ok bool
+	}{
+		{"00-" + traceID + "-" + spanID + "-01", true},
+		{"00-" + traceID + "-" + spanID + "-00", true},
+		{"01-" + traceID + "-" + spanID + "-01-future", true},
+		{"00-" + traceID + "-" + spanID + "-01-future", false},
+		{"01-" + traceID + "-" + spanID + "-01x", false},
+		{"ff-" + traceID + "-" + spanID + "-01", false},
+		{"00-" + strings.ToUpper(traceID) + "-" + spanID + "-01", false},
+		{"00-00000000000000000000000000000000-" + spanID + "-01", false},
+		{"00-" + traceID + "-0000000000000000-01", false},
+		{"00-" + traceID + "-" + spanID, false},
+		{"00_" + traceID + "-" + spanID + "-01", false},
+	} {
+		h := http.Header{"Traceparent": {tt.v}}
+		gotTrace, gotSpan, ok := ExtractTraceparent(h)
+		if ok != tt.ok || ok && (gotTrace != traceID || gotSpan != spanID) {
+			t.Errorf("ExtractTraceparent(%q) = %q, %q, %v; want ok %v", tt.v, gotTrace, gotSpan, ok, tt.ok)
+		}
+	}
+
+	h := make(http.Header)
+	InjectTraceparent(h, traceID, spanID, true)
+	if got, want := h.Get("traceparent"), "00-"+traceID+"-"+spanID+"-01"; got != want {
+		t.Errorf("InjectTraceparent set %q; want %q", got, want)
+	}
+	if gotTrace, gotSpan, ok := ExtractTraceparent(h); !ok || gotTrace != traceID || gotSpan != spanID {
+		t.Errorf("round trip = %q, %q, %v", gotTrace, gotSpan, ok)
+	}
+	h = make(http.Header)
+	InjectTraceparent(h, traceID, "123", false)
+	if len(h) != 0 {
+		t.Errorf("InjectTraceparent with an invalid span ID set %v", h)
+	}
+}
+
+func TestTraceContextDisplay(t *testing.T) {
+	tr := New("tracectx.Family", "request 1")
+	SetTraceContext(tr, "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7")
+	tr.Finish()
+
+	req := httptest.NewRequest("GET", "/debug/requests?fam=tracectx.Family&b=0&rtraced=1", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	rec := httptest.NewRecorder()
+	Traces(rec, req)
+	if want := "trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=00f067aa0ba902b7"; !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("traced requests page does not contain %q:\n%s", want, rec.Body)
+	}
+}
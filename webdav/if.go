@@ -151,7 +151,8 @@ loop:
 
 	if i != 0 {
 		tokenStr, remaining = s[:i], s[i:]
-		if tokenStr == "Not" {
+		// ABNF strings such as "Not" are case-insensitive, as per RFC 5234.
+		if strings.EqualFold(tokenStr, "Not") {
 			return notTokenType, "", remaining
 		}
 		return strTokenType, tokenStr, remaining
@@ -162,7 +163,7 @@ loop:
 	case '<':
 		j, tokenType = strings.IndexByte(s, '>'), angleTokenType
 	case '[':
-		j, tokenType = strings.IndexByte(s, ']'), squareTokenType
+		j, tokenType = entityTagEnd(s), squareTokenType
 	default:
 		return rune(s[0]), "", s[1:]
 	}
@@ -171,3 +172,21 @@ loop:
 	}
 	return tokenType, s[1:j], s[j+1:]
 }
+
+// entityTagEnd returns the index of the ']' that closes the "[entity-tag]"
+// at the start of s, or -1. A ']' within the entity-tag's quoted-string,
+// as defined in RFC 7230 section 3.2.6, does not close it.
+func entityTagEnd(s string) int {
+	quoted := false
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case !quoted && c == ']':
+			return i
+		}
+	}
+	return -1
+}
@@ -65,6 +65,14 @@ func TestParseIfHeader(t *testing.T) {
 		"bad: double Not",
 		`(Not Not a)`,
 		ifHeader{},
+	}, {
+		"bad: unfinished quoted ETag",
+		`(["a]b)`,
+		ifHeader{},
+	}, {
+		"bad: list before tag",
+		`(a) <foo> (b)`,
+		ifHeader{},
 	}, {
 		"good: one list with a Token",
 		`(a)`,
@@ -132,6 +140,61 @@ func TestParseIfHeader(t *testing.T) {
 				}},
 			}},
 		},
+	}, {
+		"good: lower case Not",
+		`(not a) (NOT [b])`,
+		ifHeader{
+			lists: []ifList{{
+				conditions: []Condition{{
+					Not:   true,
+					Token: `a`,
+				}},
+			}, {
+				conditions: []Condition{{
+					Not:  true,
+					ETag: `b`,
+				}},
+			}},
+		},
+	}, {
+		"good: ETag with brackets and escapes",
+		`(["a]b"] [W/"c\"]"])`,
+		ifHeader{
+			lists: []ifList{{
+				conditions: []Condition{{
+					ETag: `"a]b"`,
+				}, {
+					ETag: `W/"c\"]"`,
+				}},
+			}},
+		},
+	}, {
+		"good: two tagged resources",
+		`</a> (<urn:x> ["1"]) (Not <DAV:no-lock>) </b> (Not ["2"] <urn:y>)`,
+		ifHeader{
+			lists: []ifList{{
+				resourceTag: `/a`,
+				conditions: []Condition{{
+					Token: `urn:x`,
+				}, {
+					ETag: `"1"`,
+				}},
+			}, {
+				resourceTag: `/a`,
+				conditions: []Condition{{
+					Not:   true,
+					Token: `DAV:no-lock`,
+				}},
+			}, {
+				resourceTag: `/b`,
+				conditions: []Condition{{
+					Not:  true,
+					ETag: `"2"`,
+				}, {
+					Token: `urn:y`,
+				}},
+			}},
+		},
 	}, {
 		"section 7.5.1",
 		`<http://www.example.com/users/f/fielding/index.html> 
@@ -93,6 +93,18 @@ type LockSystem interface {
 	Unlock(now time.Time, token string) error
 }
 
+// LockChecker is an optional interface for a LockSystem. The Handler uses
+// it to evaluate "Not <token>" conditions of the If header, which fail if
+// token identifies a current lock on the resource. LockSystems that don't
+// implement it are probed with Confirm instead, which cannot see locks that
+// another request has confirmed and not yet released.
+type LockChecker interface {
+	// HasLock reports whether token identifies a lock on the named
+	// resource, either a lock rooted at name or an infinite depth lock
+	// on one of its ancestors.
+	HasLock(now time.Time, name, token string) (bool, error)
+}
+
 // LockDetails are a lock's metadata.
 type LockDetails struct {
 	// Root is the root resource name being locked. For a zero-depth lock, the
@@ -190,25 +202,31 @@ func (m *memLS) Confirm(now time.Time, name0, name1 string, conditions ...Condit
 //
 // n may be a parent of the named resource, if n is an infinite depth lock.
 func (m *memLS) lookup(name string, conditions ...Condition) (n *memLSNode) {
-	// TODO: support Condition.Not and Condition.ETag.
+	// The Handler evaluates Not and ETag conditions before calling Confirm.
 	for _, c := range conditions {
-		n = m.byToken[c.Token]
-		if n == nil || n.held {
+		if c.Not || c.ETag != "" {
 			continue
 		}
-		if name == n.details.Root {
-			return n
-		}
-		if n.details.ZeroDepth {
+		n = m.byToken[c.Token]
+		if n == nil || n.held {
 			continue
 		}
-		if n.details.Root == "/" || strings.HasPrefix(name, n.details.Root+"/") {
+		if n.locks(name) {
 			return n
 		}
 	}
 	return nil
 }
 
+func (m *memLS) HasLock(now time.Time, name, token string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.collectExpiredNodes(now)
+
+	n := m.byToken[token]
+	return n != nil && n.locks(slashClean(name)), nil
+}
+
 func (m *memLS) hold(n *memLSNode) {
 	if n.held {
 		panic("webdav: memLS inconsistent held state")
@@ -383,6 +401,18 @@ type memLSNode struct {
 	held bool
 }
 
+// locks reports whether n's lock applies to the named resource: n is
+// rooted at name, or n has infinite depth and is rooted at an ancestor.
+func (n *memLSNode) locks(name string) bool {
+	if name == n.details.Root {
+		return true
+	}
+	if n.details.ZeroDepth {
+		return false
+	}
+	return n.details.Root == "/" || strings.HasPrefix(name, n.details.Root+"/")
+}
+
 type byExpiry []*memLSNode
 
 func (b *byExpiry) Len() int {
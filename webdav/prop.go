@@ -273,13 +273,26 @@ func allprop(ctx context.Context, fs FileSystem, ls LockSystem, name string, inc
 }
 
 // Patch patches the properties of resource name. The return values are
-// constrained in the same manner as DeadPropsHolder.Patch.
-func patch(ctx context.Context, fs FileSystem, ls LockSystem, name string, patches []Proppatch) ([]Propstat, error) {
+// constrained in the same manner as DeadPropsHolder.Patch. The properties
+// named in protected, such as those reported by a Handler's QuotaFunc,
+// are protected like the other live properties.
+func patch(ctx context.Context, fs FileSystem, ls LockSystem, name string, patches []Proppatch, protected []xml.Name) ([]Propstat, error) {
+	isProtected := func(pn xml.Name) bool {
+		if _, ok := liveProps[pn]; ok {
+			return true
+		}
+		for _, n := range protected {
+			if pn == n {
+				return true
+			}
+		}
+		return false
+	}
 	conflict := false
 loop:
 	for _, patch := range patches {
 		for _, p := range patch.Props {
-			if _, ok := liveProps[p.XMLName]; ok {
+			if isProtected(p.XMLName) {
 				conflict = true
 				break loop
 			}
@@ -295,7 +308,7 @@ loop:
 		}
 		for _, patch := range patches {
 			for _, p := range patch.Props {
-				if _, ok := liveProps[p.XMLName]; ok {
+				if isProtected(p.XMLName) {
 					pstatForbidden.Props = append(pstatForbidden.Props, Property{XMLName: p.XMLName})
 				} else {
 					pstatFailedDep.Props = append(pstatFailedDep.Props, Property{XMLName: p.XMLName})
@@ -467,3 +480,161 @@ func findSupportedLock(ctx context.Context, fs FileSystem, ls LockSystem, name s
 		`<D:locktype><D:write/></D:locktype>` +
 		`</D:lockentry>`, nil
 }
+
+// ACLInfo holds the access control information of a resource, as reported
+// by the properties defined in RFC 3744 and RFC 5397.
+// See http://www.webdav.org/specs/rfc3744.html#principal.properties
+type ACLInfo struct {
+	// Owner is the URL of the principal that owns the resource. If empty,
+	// DAV:owner is reported without a value.
+	Owner string
+	// CurrentUserPrincipal is the URL of the principal of the user making
+	// the request. If empty, the user is reported as unauthenticated.
+	CurrentUserPrincipal string
+	// Privileges holds the privileges of the user making the request on the
+	// resource, such as {Space: "DAV:", Local: "read"}.
+	Privileges []xml.Name
+}
+
+// aclPropNames holds the names of the properties reported from an ACLInfo.
+// They are not returned for allprop requests, as per section 5 of RFC 3744.
+var aclPropNames = []xml.Name{
+	{Space: "DAV:", Local: "owner"},
+	{Space: "DAV:", Local: "current-user-principal"},
+	{Space: "DAV:", Local: "current-user-privilege-set"},
+}
+
+// find returns the value of the property pn, if it is one of aclPropNames.
+func (a *ACLInfo) find(pn xml.Name) (innerXML string, ok bool) {
+	href := func(u string) string {
+		return `<D:href xmlns:D="DAV:">` + escapeXML(u) + `</D:href>`
+	}
+	switch pn {
+	case aclPropNames[0]:
+		if a.Owner == "" {
+			return "", true
+		}
+		return href(a.Owner), true
+	case aclPropNames[1]:
+		if a.CurrentUserPrincipal == "" {
+			return `<D:unauthenticated xmlns:D="DAV:"/>`, true
+		}
+		return href(a.CurrentUserPrincipal), true
+	case aclPropNames[2]:
+		var buf bytes.Buffer
+		for _, p := range a.Privileges {
+			buf.WriteString(`<D:privilege xmlns:D="DAV:">`)
+			if p.Space == "DAV:" {
+				fmt.Fprintf(&buf, `<D:%s/>`, p.Local)
+			} else {
+				fmt.Fprintf(&buf, `<P:%s xmlns:P="%s"/>`, p.Local, escapeXML(p.Space))
+			}
+			buf.WriteString(`</D:privilege>`)
+		}
+		return buf.String(), true
+	}
+	return "", false
+}
+
+// propstats returns pstats, as computed for a PROPFIND request, with the
+// properties of a added. If propname is true, pstats lists property names
+// and the names of the properties of a are added with appendPropNames.
+// Otherwise, the properties of a are reported with their values, overriding
+// any dead properties of the same names.
+func (a *ACLInfo) propstats(pstats []Propstat, propname bool) []Propstat {
+	if propname {
+		return appendPropNames(pstats, aclPropNames)
+	}
+	pstatOK := Propstat{Status: http.StatusOK}
+	pstatNotFound := Propstat{Status: http.StatusNotFound}
+	for _, pstat := range pstats {
+		for _, p := range pstat.Props {
+			if innerXML, ok := a.find(p.XMLName); ok {
+				pstatOK.Props = append(pstatOK.Props, Property{
+					XMLName:  p.XMLName,
+					InnerXML: []byte(innerXML),
+				})
+			} else if pstat.Status == http.StatusNotFound {
+				pstatNotFound.Props = append(pstatNotFound.Props, p)
+			} else {
+				pstatOK.Props = append(pstatOK.Props, p)
+			}
+		}
+	}
+	return makePropstats(pstatOK, pstatNotFound)
+}
+
+// appendPropNames appends names to the 200 OK propstat of pstats, as
+// computed for a propname PROPFIND request, skipping those it already lists
+// as dead properties.
+func appendPropNames(pstats []Propstat, names []xml.Name) []Propstat {
+	for i := range pstats {
+		if pstats[i].Status != http.StatusOK {
+			continue
+		}
+		seen := make(map[xml.Name]bool)
+		for _, p := range pstats[i].Props {
+			seen[p.XMLName] = true
+		}
+		for _, pn := range names {
+			if !seen[pn] {
+				pstats[i].Props = append(pstats[i].Props, Property{XMLName: pn})
+			}
+		}
+	}
+	return pstats
+}
+
+// quotaPropNames holds the names of the properties reported from a
+// Handler's QuotaFunc. They are not returned for allprop requests, as per
+// section 3 of RFC 4331.
+var quotaPropNames = []xml.Name{
+	{Space: "DAV:", Local: "quota-available-bytes"},
+	{Space: "DAV:", Local: "quota-used-bytes"},
+}
+
+// quotaPropstats is like ACLInfo.propstats for the quota properties of
+// resource name, whose values are returned by quota. It only calls quota
+// if any of the properties are requested.
+func quotaPropstats(ctx context.Context, quota func(context.Context, string) (int64, int64, error), name string, pstats []Propstat, propname bool) ([]Propstat, error) {
+	if propname {
+		return appendPropNames(pstats, quotaPropNames), nil
+	}
+	wanted := false
+	for _, pstat := range pstats {
+		for _, p := range pstat.Props {
+			if p.XMLName == quotaPropNames[0] || p.XMLName == quotaPropNames[1] {
+				wanted = true
+			}
+		}
+	}
+	if !wanted {
+		return pstats, nil
+	}
+	used, available, err := quota(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	pstatOK := Propstat{Status: http.StatusOK}
+	pstatNotFound := Propstat{Status: http.StatusNotFound}
+	for _, pstat := range pstats {
+		for _, p := range pstat.Props {
+			switch {
+			case p.XMLName == quotaPropNames[0] && available >= 0:
+				p = Property{XMLName: p.XMLName, InnerXML: []byte(strconv.FormatInt(available, 10))}
+			case p.XMLName == quotaPropNames[0]:
+				// An unknown amount of available space is reported
+				// as not found, even over a dead property.
+				pstatNotFound.Props = append(pstatNotFound.Props, Property{XMLName: p.XMLName})
+				continue
+			case p.XMLName == quotaPropNames[1]:
+				p = Property{XMLName: p.XMLName, InnerXML: []byte(strconv.FormatInt(used, 10))}
+			case pstat.Status == http.StatusNotFound:
+				pstatNotFound.Props = append(pstatNotFound.Props, p)
+				continue
+			}
+			pstatOK.Props = append(pstatOK.Props, p)
+		}
+	}
+	return makePropstats(pstatOK, pstatNotFound), nil
+}
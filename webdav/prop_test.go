@@ -538,7 +538,7 @@ func TestMemPS(t *testing.T) {
 			case "propfind":
 				propstats, err = props(ctx, fs, ls, op.name, op.pnames)
 			case "proppatch":
-				propstats, err = patch(ctx, fs, ls, op.name, op.patches)
+				propstats, err = patch(ctx, fs, ls, op.name, op.patches, nil)
 			default:
 				t.Fatalf("%s: %s not implemented", desc, op.op)
 			}
@@ -6,6 +6,8 @@
 package webdav // import "golang.org/x/net/webdav"
 
 import (
+	"context"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
@@ -13,6 +15,7 @@ import (
 	"net/url"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -27,6 +30,40 @@ type Handler struct {
 	// Logger is an optional error logger. If non-nil, it will be called
 	// for all HTTP requests.
 	Logger func(*http.Request, error)
+	// ACL optionally supplies the access control information of resource
+	// name for request r. If non-nil, PROPFIND reports the DAV:owner,
+	// DAV:current-user-principal and DAV:current-user-privilege-set
+	// properties of RFC 3744 from its result. Returning a nil *ACLInfo
+	// omits them for that resource.
+	ACL func(r *http.Request, name string) (*ACLInfo, error)
+	// Authorize is an optional function consulted before each operation.
+	// It is called with the request's method and the path of the resource
+	// it operates on, after the Prefix is stripped. For COPY and MOVE, it
+	// is called a second time with the destination path. A non-nil error
+	// denies the operation with 403 Forbidden, or with the status of a
+	// *StatusError.
+	Authorize func(ctx context.Context, method, path string) error
+	// QuotaFunc optionally reports the storage used by resource name and
+	// the storage still available to it, in bytes. If non-nil, PROPFIND
+	// reports them as the DAV:quota-used-bytes and
+	// DAV:quota-available-bytes properties of RFC 4331. A negative
+	// available means that no quota applies, and DAV:quota-available-bytes
+	// is then not found.
+	QuotaFunc func(ctx context.Context, name string) (used, available int64, err error)
+}
+
+// A StatusError is an error returned by Handler.Authorize to deny an
+// operation with a specific HTTP status code.
+type StatusError struct {
+	Status int
+	Err    error
+}
+
+func (e *StatusError) Error() string {
+	if e.Err == nil {
+		return "webdav: " + StatusText(e.Status)
+	}
+	return e.Err.Error()
 }
 
 func (h *Handler) stripPrefix(p string) (string, int, error) {
@@ -45,6 +82,8 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		status, err = http.StatusInternalServerError, errNoFileSystem
 	} else if h.LockSystem == nil {
 		status, err = http.StatusInternalServerError, errNoLockSystem
+	} else if astatus, aerr := h.authorizeRequest(r); aerr != nil {
+		status, err = astatus, aerr
 	} else {
 		switch r.Method {
 		case "OPTIONS":
@@ -55,6 +94,8 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			status, err = h.handleDelete(w, r)
 		case "PUT":
 			status, err = h.handlePut(w, r)
+		case "PATCH":
+			status, err = h.handlePatch(w, r)
 		case "MKCOL":
 			status, err = h.handleMkcol(w, r)
 		case "COPY", "MOVE":
@@ -81,6 +122,31 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// authorizeRequest consults h.Authorize for the resource named by r's URL.
+func (h *Handler) authorizeRequest(r *http.Request) (status int, err error) {
+	reqPath, _, err := h.stripPrefix(r.URL.Path)
+	if err != nil {
+		// The method's handler reports the mismatch.
+		return 0, nil
+	}
+	return h.authorize(r.Context(), r.Method, reqPath)
+}
+
+// authorize consults h.Authorize, if any, for the operation method on the
+// resource at name.
+func (h *Handler) authorize(ctx context.Context, method, name string) (status int, err error) {
+	if h.Authorize == nil {
+		return 0, nil
+	}
+	if err := h.Authorize(ctx, method, name); err != nil {
+		if se, ok := err.(*StatusError); ok {
+			return se.Status, err
+		}
+		return http.StatusForbidden, err
+	}
+	return 0, nil
+}
+
 func (h *Handler) lock(now time.Time, root string) (token string, status int, err error) {
 	token, err = h.LockSystem.Create(now, LockDetails{
 		Root:      root,
@@ -100,35 +166,7 @@ func (h *Handler) confirmLocks(r *http.Request, src, dst string) (release func()
 	hdr := r.Header.Get("If")
 	if hdr == "" {
 		// An empty If header means that the client hasn't previously created locks.
-		// Even if this client doesn't care about locks, we still need to check that
-		// the resources aren't locked by another client, so we create temporary
-		// locks that would conflict with another client's locks. These temporary
-		// locks are unlocked at the end of the HTTP request.
-		now, srcToken, dstToken := time.Now(), "", ""
-		if src != "" {
-			srcToken, status, err = h.lock(now, src)
-			if err != nil {
-				return nil, status, err
-			}
-		}
-		if dst != "" {
-			dstToken, status, err = h.lock(now, dst)
-			if err != nil {
-				if srcToken != "" {
-					h.LockSystem.Unlock(now, srcToken)
-				}
-				return nil, status, err
-			}
-		}
-
-		return func() {
-			if dstToken != "" {
-				h.LockSystem.Unlock(now, dstToken)
-			}
-			if srcToken != "" {
-				h.LockSystem.Unlock(now, srcToken)
-			}
-		}, 0, nil
+		return h.tempLocks(src, dst)
 	}
 
 	ih, ok := parseIfHeader(hdr)
@@ -137,23 +175,42 @@ func (h *Handler) confirmLocks(r *http.Request, src, dst string) (release func()
 	}
 	// ih is a disjunction (OR) of ifLists, so any ifList will do.
 	for _, l := range ih.lists {
-		lsrc := l.resourceTag
+		// An untagged list applies to the Request-URI, which for COPY is
+		// not the src whose locks are confirmed.
+		lsrc, name := l.resourceTag, ""
 		if lsrc == "" {
 			lsrc = src
+			name, status, err = h.stripPrefix(r.URL.Path)
+			if err != nil {
+				return nil, status, err
+			}
 		} else {
 			u, err := url.Parse(lsrc)
 			if err != nil {
 				continue
 			}
-			if u.Host != r.Host {
+			// The tag may be an absolute URI or an absolute path.
+			if u.Host != "" && u.Host != r.Host {
 				continue
 			}
 			lsrc, status, err = h.stripPrefix(u.Path)
 			if err != nil {
 				return nil, status, err
 			}
+			name = lsrc
+		}
+		tokens, ok, err := h.evalConditions(r.Context(), name, l.conditions)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		if !ok {
+			continue
 		}
-		release, err = h.LockSystem.Confirm(time.Now(), lsrc, dst, l.conditions...)
+		if len(tokens) == 0 {
+			// The list is true but claims no locks, as in "(Not <DAV:no-lock>)".
+			return h.tempLocks(src, dst)
+		}
+		release, err = h.LockSystem.Confirm(time.Now(), lsrc, dst, tokens...)
 		if err == ErrConfirmationFailed {
 			continue
 		}
@@ -169,6 +226,98 @@ func (h *Handler) confirmLocks(r *http.Request, src, dst string) (release func()
 	return nil, http.StatusPreconditionFailed, ErrLocked
 }
 
+// tempLocks checks that the resources src and dst, if non-empty, aren't
+// locked by another client, for a request that claims no locks. It creates
+// temporary locks that would conflict with another client's locks. These
+// temporary locks are unlocked by release at the end of the HTTP request.
+func (h *Handler) tempLocks(src, dst string) (release func(), status int, err error) {
+	now, srcToken, dstToken := time.Now(), "", ""
+	if src != "" {
+		srcToken, status, err = h.lock(now, src)
+		if err != nil {
+			return nil, status, err
+		}
+	}
+	if dst != "" {
+		dstToken, status, err = h.lock(now, dst)
+		if err != nil {
+			if srcToken != "" {
+				h.LockSystem.Unlock(now, srcToken)
+			}
+			return nil, status, err
+		}
+	}
+
+	return func() {
+		if dstToken != "" {
+			h.LockSystem.Unlock(now, dstToken)
+		}
+		if srcToken != "" {
+			h.LockSystem.Unlock(now, srcToken)
+		}
+	}, 0, nil
+}
+
+// evalConditions evaluates the ETag and Not conditions of an If header list
+// for the resource name. It reports whether they all hold, and returns the
+// remaining conditions, which claim lock tokens and are left for the
+// LockSystem to confirm.
+//
+// A Not condition on a lock token holds unless the token identifies a
+// current lock on the resource. Clients commonly name a token they do not
+// hold only to make the list true, as in "(Not <DAV:no-lock>)".
+func (h *Handler) evalConditions(ctx context.Context, name string, conditions []Condition) (tokens []Condition, ok bool, err error) {
+	etag, haveETag := "", false
+	for _, c := range conditions {
+		if c.ETag == "" {
+			if !c.Not {
+				tokens = append(tokens, c)
+				continue
+			}
+			locked, err := h.hasLock(name, c.Token)
+			if err != nil {
+				return nil, false, err
+			}
+			if locked {
+				return nil, false, nil
+			}
+			continue
+		}
+		if !haveETag {
+			fi, err := h.FileSystem.Stat(ctx, name)
+			if err == nil {
+				etag, err = findETag(ctx, h.FileSystem, h.LockSystem, name, fi)
+			}
+			if err != nil && !os.IsNotExist(err) {
+				return nil, false, err
+			}
+			haveETag = true
+		}
+		if (c.ETag == etag) == c.Not {
+			return nil, false, nil
+		}
+	}
+	return tokens, true, nil
+}
+
+// hasLock reports whether token identifies a current lock on the resource
+// name, using the LockSystem's HasLock method if it implements LockChecker.
+func (h *Handler) hasLock(name, token string) (bool, error) {
+	now := time.Now()
+	if lc, ok := h.LockSystem.(LockChecker); ok {
+		return lc.HasLock(now, name, token)
+	}
+	release, err := h.LockSystem.Confirm(now, name, "", Condition{Token: token})
+	if err == ErrConfirmationFailed {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	release()
+	return true, nil
+}
+
 func (h *Handler) handleOptions(w http.ResponseWriter, r *http.Request) (status int, err error) {
 	reqPath, status, err := h.stripPrefix(r.URL.Path)
 	if err != nil {
@@ -180,7 +329,7 @@ func (h *Handler) handleOptions(w http.ResponseWriter, r *http.Request) (status
 		if fi.IsDir() {
 			allow = "OPTIONS, LOCK, DELETE, PROPPATCH, COPY, MOVE, UNLOCK, PROPFIND"
 		} else {
-			allow = "OPTIONS, LOCK, GET, HEAD, POST, DELETE, PROPPATCH, COPY, MOVE, UNLOCK, PROPFIND, PUT"
+			allow = "OPTIONS, LOCK, GET, HEAD, POST, DELETE, PROPPATCH, COPY, MOVE, UNLOCK, PROPFIND, PUT, PATCH"
 		}
 	}
 	w.Header().Set("Allow", allow)
@@ -289,6 +438,142 @@ func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request) (status int,
 	return http.StatusCreated, nil
 }
 
+// handlePatch writes the request body into part of an existing file, as
+// SabreDAV's partial update extension does. The range to write is given by
+// a Content-Range header or a SabreDAV X-Update-Range header.
+// See https://sabre.io/dav/http-patch/
+func (h *Handler) handlePatch(w http.ResponseWriter, r *http.Request) (status int, err error) {
+	reqPath, status, err := h.stripPrefix(r.URL.Path)
+	if err != nil {
+		return status, err
+	}
+	release, status, err := h.confirmLocks(r, reqPath, "")
+	if err != nil {
+		return status, err
+	}
+	defer release()
+	ctx := r.Context()
+
+	f, err := h.FileSystem.OpenFile(ctx, reqPath, os.O_RDWR, 0)
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+	if fi.IsDir() {
+		return http.StatusMethodNotAllowed, nil
+	}
+	offset, n, status, err := parseUpdateRange(r.Header, fi.Size())
+	if err != nil {
+		if status == http.StatusRequestedRangeNotSatisfiable {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", fi.Size()))
+		}
+		return status, err
+	}
+	if n >= 0 && r.ContentLength >= 0 && r.ContentLength != n {
+		return http.StatusBadRequest, errInvalidUpdateRange
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return http.StatusMethodNotAllowed, err
+	}
+	if n >= 0 {
+		_, err = io.CopyN(f, r.Body, n)
+		if err == io.EOF {
+			return http.StatusBadRequest, errInvalidUpdateRange
+		}
+	} else {
+		_, err = io.Copy(f, r.Body)
+	}
+	if err != nil {
+		return http.StatusMethodNotAllowed, err
+	}
+	if fi, err = f.Stat(); err != nil {
+		return http.StatusMethodNotAllowed, err
+	}
+	etag, err := findETag(ctx, h.FileSystem, h.LockSystem, reqPath, fi)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	w.Header().Set("ETag", etag)
+	return http.StatusNoContent, nil
+}
+
+// parseUpdateRange returns the offset at which a PATCH request with header
+// hdr writes to a file of the given size, and the number of bytes it writes,
+// or -1 if the range does not say.
+//
+// It accepts a Content-Range header of the form "bytes start-end/total",
+// where total may be "*", and SabreDAV's X-Update-Range header, whose value
+// is one of "append", "bytes=start-end", "bytes=start-" or "bytes=-suffix",
+// the latter writing from suffix bytes before the end of the file.
+func parseUpdateRange(hdr http.Header, size int64) (offset, n int64, status int, err error) {
+	n = -1
+	if cr := hdr.Get("Content-Range"); cr != "" {
+		spec := strings.TrimPrefix(cr, "bytes ")
+		i, j := strings.IndexByte(spec, '-'), strings.IndexByte(spec, '/')
+		if len(spec) == len(cr) || i < 0 || j < i {
+			return 0, 0, http.StatusBadRequest, errInvalidUpdateRange
+		}
+		start, err1 := strconv.ParseInt(spec[:i], 10, 64)
+		end, err2 := strconv.ParseInt(spec[i+1:j], 10, 64)
+		if err1 != nil || err2 != nil || start < 0 || end < start {
+			return 0, 0, http.StatusBadRequest, errInvalidUpdateRange
+		}
+		if total := spec[j+1:]; total != "*" {
+			if t, err := strconv.ParseInt(total, 10, 64); err != nil || end >= t {
+				return 0, 0, http.StatusBadRequest, errInvalidUpdateRange
+			}
+		}
+		offset, n = start, end-start+1
+	} else if ur := hdr.Get("X-Update-Range"); ur == "append" {
+		offset = size
+	} else if ur != "" {
+		spec := strings.TrimPrefix(ur, "bytes=")
+		i := strings.IndexByte(spec, '-')
+		if len(spec) == len(ur) || i < 0 {
+			return 0, 0, http.StatusBadRequest, errInvalidUpdateRange
+		}
+		var start, end int64
+		var err1, err2 error
+		switch {
+		case i == 0:
+			var suffix int64
+			suffix, err1 = strconv.ParseInt(spec[1:], 10, 64)
+			if err1 == nil && suffix > size {
+				return 0, 0, http.StatusRequestedRangeNotSatisfiable, errUnsatisfiableRange
+			}
+			start, end = size-suffix, -1
+		case i == len(spec)-1:
+			start, err1 = strconv.ParseInt(spec[:i], 10, 64)
+			end = -1
+		default:
+			start, err1 = strconv.ParseInt(spec[:i], 10, 64)
+			end, err2 = strconv.ParseInt(spec[i+1:], 10, 64)
+			if err2 == nil && end < start {
+				err2 = errInvalidUpdateRange
+			}
+		}
+		if err1 != nil || err2 != nil || start < 0 || start > size {
+			return 0, 0, http.StatusBadRequest, errInvalidUpdateRange
+		}
+		offset = start
+		if end >= 0 {
+			n = end - start + 1
+		}
+	} else {
+		return 0, 0, http.StatusBadRequest, errInvalidUpdateRange
+	}
+	// Writing past the end of the file would leave a hole.
+	if offset > size {
+		return 0, 0, http.StatusRequestedRangeNotSatisfiable, errUnsatisfiableRange
+	}
+	return offset, n, 0, nil
+}
+
 func (h *Handler) handleMkcol(w http.ResponseWriter, r *http.Request) (status int, err error) {
 	reqPath, status, err := h.stripPrefix(r.URL.Path)
 	if err != nil {
@@ -345,6 +630,9 @@ func (h *Handler) handleCopyMove(w http.ResponseWriter, r *http.Request) (status
 	}
 
 	ctx := r.Context()
+	if status, err := h.authorize(ctx, r.Method, dst); err != nil {
+		return status, err
+	}
 
 	if r.Method == "COPY" {
 		// Section 7.5.1 says that a COPY only needs to lock the destination,
@@ -556,6 +844,21 @@ func (h *Handler) handlePropfind(w http.ResponseWriter, r *http.Request) (status
 		if err != nil {
 			return err
 		}
+		if h.ACL != nil {
+			acl, err := h.ACL(r, reqPath)
+			if err != nil {
+				return err
+			}
+			if acl != nil {
+				pstats = acl.propstats(pstats, pf.Propname != nil)
+			}
+		}
+		if h.QuotaFunc != nil {
+			pstats, err = quotaPropstats(ctx, h.QuotaFunc, reqPath, pstats, pf.Propname != nil)
+			if err != nil {
+				return err
+			}
+		}
 		href := path.Join(h.Prefix, reqPath)
 		if href != "/" && info.IsDir() {
 			href += "/"
@@ -597,7 +900,14 @@ func (h *Handler) handleProppatch(w http.ResponseWriter, r *http.Request) (statu
 	if err != nil {
 		return status, err
 	}
-	pstats, err := patch(ctx, h.FileSystem, h.LockSystem, reqPath, patches)
+	var protected []xml.Name
+	if h.ACL != nil {
+		protected = append(protected, aclPropNames...)
+	}
+	if h.QuotaFunc != nil {
+		protected = append(protected, quotaPropNames...)
+	}
+	pstats, err := patch(ctx, h.FileSystem, h.LockSystem, reqPath, patches, protected)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
@@ -696,11 +1006,13 @@ var (
 	errInvalidProppatch        = errors.New("webdav: invalid proppatch")
 	errInvalidResponse         = errors.New("webdav: invalid response")
 	errInvalidTimeout          = errors.New("webdav: invalid timeout")
+	errInvalidUpdateRange      = errors.New("webdav: invalid update range")
 	errNoFileSystem            = errors.New("webdav: no file system")
 	errNoLockSystem            = errors.New("webdav: no lock system")
 	errNotADirectory           = errors.New("webdav: not a directory")
 	errPrefixMismatch          = errors.New("webdav: prefix mismatch")
 	errRecursionTooDeep        = errors.New("webdav: recursion too deep")
+	errUnsatisfiableRange      = errors.New("webdav: unsatisfiable update range")
 	errUnsupportedLockInfo     = errors.New("webdav: unsupported lock info")
 	errUnsupportedMethod       = errors.New("webdav: unsupported method")
 )
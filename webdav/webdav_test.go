@@ -6,6 +6,7 @@ package webdav
 
 import (
 	"context"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
@@ -347,3 +348,672 @@ func TestFilenameEscape(t *testing.T) {
 		}
 	}
 }
+
+func TestPatch(t *testing.T) {
+	ctx := context.Background()
+	fs := NewMemFS()
+	srv := httptest.NewServer(&Handler{
+		FileSystem: fs,
+		LockSystem: NewMemLS(),
+	})
+	defer srv.Close()
+
+	do := func(method, body string, headers ...string) (*http.Response, error) {
+		req, err := http.NewRequest(method, srv.URL+"/file", strings.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		for len(headers) >= 2 {
+			req.Header.Add(headers[0], headers[1])
+			headers = headers[2:]
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		res.Body.Close()
+		return res, nil
+	}
+	contents := func() string {
+		f, err := fs.OpenFile(ctx, "/file", os.O_RDONLY, 0)
+		if err != nil {
+			t.Fatalf("OpenFile: %v", err)
+		}
+		defer f.Close()
+		b, err := ioutil.ReadAll(f)
+		if err != nil {
+			t.Fatalf("ReadAll: %v", err)
+		}
+		return string(b)
+	}
+
+	if res, err := do("PATCH", "x", "Content-Range", "bytes 0-0/*"); err != nil {
+		t.Fatal(err)
+	} else if res.StatusCode != http.StatusNotFound {
+		t.Errorf("PATCH of missing file: got status %d, want %d", res.StatusCode, http.StatusNotFound)
+	}
+
+	testCases := []struct {
+		body       string
+		header     []string
+		wantStatus int
+		want       string
+	}{
+		{"01", []string{"Content-Range", "bytes 2-3/10"}, http.StatusNoContent, "ab01efghij"},
+		{"01", []string{"Content-Range", "bytes 8-9/*"}, http.StatusNoContent, "abcdefgh01"},
+		{"0123", []string{"Content-Range", "bytes 8-11/12"}, http.StatusNoContent, "abcdefgh0123"},
+		{"01", []string{"Content-Range", "bytes 10-11/*"}, http.StatusNoContent, "abcdefghij01"},
+		{"01", []string{"Content-Range", "bytes 11-12/*"}, http.StatusRequestedRangeNotSatisfiable, "abcdefghij"},
+		{"012", []string{"Content-Range", "bytes 2-3/*"}, http.StatusBadRequest, "abcdefghij"},
+		{"01", []string{"Content-Range", "bytes 2-3/3"}, http.StatusBadRequest, "abcdefghij"},
+		{"01", []string{"Content-Range", "2-3/*"}, http.StatusBadRequest, "abcdefghij"},
+		{"01", nil, http.StatusBadRequest, "abcdefghij"},
+		{"01", []string{"X-Update-Range", "append"}, http.StatusNoContent, "abcdefghij01"},
+		{"01", []string{"X-Update-Range", "bytes=1-2"}, http.StatusNoContent, "a01defghij"},
+		{"0123", []string{"X-Update-Range", "bytes=8-"}, http.StatusNoContent, "abcdefgh0123"},
+		{"0", []string{"X-Update-Range", "bytes=-3"}, http.StatusNoContent, "abcdefg0ij"},
+		{"0", []string{"X-Update-Range", "bytes=-11"}, http.StatusRequestedRangeNotSatisfiable, "abcdefghij"},
+		{"0", []string{"X-Update-Range", "bytes=11-"}, http.StatusBadRequest, "abcdefghij"},
+		{"0", []string{"X-Update-Range", "bytes=3-2"}, http.StatusBadRequest, "abcdefghij"},
+	}
+	for _, tc := range testCases {
+		if res, err := do("PUT", "abcdefghij"); err != nil {
+			t.Fatal(err)
+		} else if res.StatusCode != http.StatusCreated {
+			t.Fatalf("PUT: got status %d, want %d", res.StatusCode, http.StatusCreated)
+		}
+		res, err := do("PATCH", tc.body, tc.header...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != tc.wantStatus {
+			t.Errorf("PATCH %q with %q: got status %d, want %d", tc.body, tc.header, res.StatusCode, tc.wantStatus)
+		}
+		if got := contents(); got != tc.want {
+			t.Errorf("PATCH %q with %q: got contents %q, want %q", tc.body, tc.header, got, tc.want)
+		}
+		if tc.wantStatus == http.StatusRequestedRangeNotSatisfiable {
+			if got, want := res.Header.Get("Content-Range"), "bytes */10"; got != want {
+				t.Errorf("PATCH %q with %q: got Content-Range %q, want %q", tc.body, tc.header, got, want)
+			}
+		}
+	}
+
+	// A locked file may only be patched by the lock holder.
+	res, err := do("LOCK", `<?xml version="1.0" encoding="utf-8" ?>
+		<D:lockinfo xmlns:D='DAV:'>
+			<D:lockscope><D:exclusive/></D:lockscope>
+			<D:locktype><D:write/></D:locktype>
+		</D:lockinfo>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := res.Header.Get("Lock-Token")
+	if res, err := do("PATCH", "01", "X-Update-Range", "append"); err != nil {
+		t.Fatal(err)
+	} else if res.StatusCode != StatusLocked {
+		t.Errorf("PATCH of locked file: got status %d, want %d", res.StatusCode, StatusLocked)
+	}
+	if res, err := do("PATCH", "01", "X-Update-Range", "append", "If", "("+token+")"); err != nil {
+		t.Fatal(err)
+	} else if res.StatusCode != http.StatusNoContent {
+		t.Errorf("PATCH of locked file with lock token: got status %d, want %d", res.StatusCode, http.StatusNoContent)
+	}
+}
+
+func TestPropfindACL(t *testing.T) {
+	const propfindBody = `<?xml version="1.0" encoding="utf-8" ?>
+		<D:propfind xmlns:D="DAV:">
+			<D:prop>
+				<D:owner/>
+				<D:current-user-principal/>
+				<D:current-user-privilege-set/>
+			</D:prop>
+		</D:propfind>`
+	const allpropBody = `<?xml version="1.0" encoding="utf-8" ?>
+		<D:propfind xmlns:D="DAV:"><D:allprop/></D:propfind>`
+	const propnameBody = `<?xml version="1.0" encoding="utf-8" ?>
+		<D:propfind xmlns:D="DAV:"><D:propname/></D:propfind>`
+	const proppatchBody = `<?xml version="1.0" encoding="utf-8" ?>
+		<D:propertyupdate xmlns:D="DAV:">
+			<D:set><D:prop><D:current-user-privilege-set><D:all/></D:current-user-privilege-set></D:prop></D:set>
+		</D:propertyupdate>`
+
+	ctx := context.Background()
+	fs := NewMemFS()
+	for _, name := range []string{"/mine", "/public"} {
+		f, err := fs.OpenFile(ctx, name, os.O_CREATE, 0644)
+		if err != nil {
+			t.Fatalf("OpenFile(%q): %v", name, err)
+		}
+		f.Close()
+	}
+	h := &Handler{
+		FileSystem: fs,
+		LockSystem: NewMemLS(),
+	}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	doMethod := func(method, name, body string) string {
+		req, err := http.NewRequest(method, srv.URL+name, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Depth", "0")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		b, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != StatusMulti {
+			t.Fatalf("%s %s: got status %d, want %d", method, name, res.StatusCode, StatusMulti)
+		}
+		return string(b)
+	}
+	do := func(name, body string) string {
+		return doMethod("PROPFIND", name, body)
+	}
+
+	// Without an ACL function, the properties are not found and PROPPATCH
+	// treats them as dead properties.
+	if got := do("/mine", propfindBody); !strings.Contains(got, "404 Not Found") || strings.Contains(got, "200 OK") {
+		t.Errorf("PROPFIND without ACL:\n%s", got)
+	}
+	if got := doMethod("PROPPATCH", "/public", proppatchBody); !strings.Contains(got, "200 OK") {
+		t.Errorf("PROPPATCH without ACL:\n%s", got)
+	}
+
+	h.ACL = func(r *http.Request, name string) (*ACLInfo, error) {
+		if name != "/mine" {
+			return &ACLInfo{
+				Privileges: []xml.Name{{Space: "DAV:", Local: "read"}},
+			}, nil
+		}
+		return &ACLInfo{
+			Owner:                "/principals/gopher",
+			CurrentUserPrincipal: "/principals/gopher",
+			Privileges: []xml.Name{
+				{Space: "DAV:", Local: "read"},
+				{Space: "DAV:", Local: "write"},
+				{Space: "http://example.com/ns", Local: "share"},
+			},
+		}, nil
+	}
+	testCases := []struct {
+		name, body string
+		want       []string
+		notWant    []string
+	}{{
+		name: "/mine",
+		body: propfindBody,
+		want: []string{
+			`<D:owner><D:href xmlns:D="DAV:">/principals/gopher</D:href></D:owner>`,
+			`<D:current-user-principal><D:href xmlns:D="DAV:">/principals/gopher</D:href></D:current-user-principal>`,
+			`<D:privilege xmlns:D="DAV:"><D:read/></D:privilege>`,
+			`<D:privilege xmlns:D="DAV:"><D:write/></D:privilege>`,
+			`<D:privilege xmlns:D="DAV:"><P:share xmlns:P="http://example.com/ns"/></D:privilege>`,
+		},
+		notWant: []string{"404 Not Found"},
+	}, {
+		name: "/public",
+		body: propfindBody,
+		want: []string{
+			`<D:owner></D:owner>`,
+			`<D:current-user-principal><D:unauthenticated xmlns:D="DAV:"/></D:current-user-principal>`,
+			`<D:privilege xmlns:D="DAV:"><D:read/></D:privilege>`,
+		},
+		// The dead property set above is overridden by the ACL.
+		notWant: []string{"404 Not Found", "write", "all/>"},
+	}, {
+		name:    "/mine",
+		body:    allpropBody,
+		notWant: []string{"owner", "current-user-principal", "current-user-privilege-set"},
+	}, {
+		name: "/mine",
+		body: propnameBody,
+		want: []string{"<D:owner>", "<D:current-user-principal>", "<D:current-user-privilege-set>"},
+	}, {
+		name: "/public",
+		body: propnameBody,
+		want: []string{"<D:owner>", "<D:current-user-principal>", "<D:current-user-privilege-set>"},
+	}}
+	for _, tc := range testCases {
+		got := do(tc.name, tc.body)
+		for _, w := range tc.want {
+			if !strings.Contains(got, w) {
+				t.Errorf("PROPFIND %s %.40q: response does not contain %q:\n%s", tc.name, tc.body, w, got)
+			}
+		}
+		for _, w := range tc.notWant {
+			if strings.Contains(got, w) {
+				t.Errorf("PROPFIND %s %.40q: response contains %q:\n%s", tc.name, tc.body, w, got)
+			}
+		}
+	}
+
+	// The dead property set above is listed once.
+	if got := do("/public", propnameBody); strings.Count(got, "<D:current-user-privilege-set>") != 1 {
+		t.Errorf("PROPFIND propname with ACL:\n%s", got)
+	}
+
+	// With an ACL function, the properties are protected.
+	if got := doMethod("PROPPATCH", "/public", proppatchBody); !strings.Contains(got, "403 Forbidden") || !strings.Contains(got, "cannot-modify-protected-property") {
+		t.Errorf("PROPPATCH with ACL:\n%s", got)
+	}
+	if got := do("/public", propfindBody); strings.Contains(got, "all/>") || !strings.Contains(got, "<D:read/>") {
+		t.Errorf("PROPFIND after PROPPATCH with ACL:\n%s", got)
+	}
+}
+
+func TestQuota(t *testing.T) {
+	const propfindBody = `<?xml version="1.0" encoding="utf-8" ?>
+		<D:propfind xmlns:D="DAV:">
+			<D:prop>
+				<D:quota-available-bytes/>
+				<D:quota-used-bytes/>
+			</D:prop>
+		</D:propfind>`
+	const allpropBody = `<?xml version="1.0" encoding="utf-8" ?>
+		<D:propfind xmlns:D="DAV:"><D:allprop/></D:propfind>`
+	const propnameBody = `<?xml version="1.0" encoding="utf-8" ?>
+		<D:propfind xmlns:D="DAV:"><D:propname/></D:propfind>`
+	const proppatchBody = `<?xml version="1.0" encoding="utf-8" ?>
+		<D:propertyupdate xmlns:D="DAV:">
+			<D:set><D:prop><D:quota-used-bytes>1</D:quota-used-bytes></D:prop></D:set>
+		</D:propertyupdate>`
+
+	ctx := context.Background()
+	fs := NewMemFS()
+	for _, name := range []string{"/limited", "/unlimited", "/patched"} {
+		if err := fs.Mkdir(ctx, name, 0755); err != nil {
+			t.Fatalf("Mkdir(%q): %v", name, err)
+		}
+	}
+	h := &Handler{
+		FileSystem: fs,
+		LockSystem: NewMemLS(),
+	}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	doMethod := func(method, name, body string) string {
+		req, err := http.NewRequest(method, srv.URL+name, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Depth", "0")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		b, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != StatusMulti {
+			t.Fatalf("%s %s: got status %d, want %d", method, name, res.StatusCode, StatusMulti)
+		}
+		return string(b)
+	}
+	do := func(name, body string) string {
+		return doMethod("PROPFIND", name, body)
+	}
+
+	// Without a QuotaFunc, the properties are not found and PROPPATCH
+	// treats them as dead properties.
+	if got := do("/limited", propfindBody); !strings.Contains(got, "404 Not Found") || strings.Contains(got, "200 OK") {
+		t.Errorf("PROPFIND without QuotaFunc:\n%s", got)
+	}
+	if got := doMethod("PROPPATCH", "/patched", proppatchBody); !strings.Contains(got, "200 OK") {
+		t.Errorf("PROPPATCH without QuotaFunc:\n%s", got)
+	}
+
+	var calls int
+	h.QuotaFunc = func(ctx context.Context, name string) (used, available int64, err error) {
+		calls++
+		if name == "/limited" {
+			return 1024, 4096, nil
+		}
+		return 2048, -1, nil
+	}
+	testCases := []struct {
+		name, body string
+		want       []string
+		notWant    []string
+		wantCalls  int
+	}{{
+		name: "/limited",
+		body: propfindBody,
+		want: []string{
+			`<D:quota-available-bytes>4096</D:quota-available-bytes>`,
+			`<D:quota-used-bytes>1024</D:quota-used-bytes>`,
+		},
+		notWant:   []string{"404 Not Found"},
+		wantCalls: 1,
+	}, {
+		name: "/unlimited",
+		body: propfindBody,
+		want: []string{
+			`<D:quota-used-bytes>2048</D:quota-used-bytes>`,
+			"404 Not Found",
+		},
+		wantCalls: 1,
+	}, {
+		name:    "/limited",
+		body:    allpropBody,
+		notWant: []string{"quota"},
+	}, {
+		// The quota properties override the dead property set by
+		// PROPPATCH without a QuotaFunc.
+		name: "/patched",
+		body: propfindBody,
+		want: []string{
+			`<D:quota-used-bytes>2048</D:quota-used-bytes>`,
+		},
+		notWant:   []string{`<D:quota-used-bytes>1</D:quota-used-bytes>`},
+		wantCalls: 1,
+	}}
+	for _, tc := range testCases {
+		calls = 0
+		got := do(tc.name, tc.body)
+		for _, w := range tc.want {
+			if !strings.Contains(got, w) {
+				t.Errorf("PROPFIND %s %.40q: response does not contain %q:\n%s", tc.name, tc.body, w, got)
+			}
+		}
+		for _, w := range tc.notWant {
+			if strings.Contains(got, w) {
+				t.Errorf("PROPFIND %s %.40q: response contains %q:\n%s", tc.name, tc.body, w, got)
+			}
+		}
+		if calls != tc.wantCalls {
+			t.Errorf("PROPFIND %s %.40q: QuotaFunc called %d times, want %d", tc.name, tc.body, calls, tc.wantCalls)
+		}
+	}
+
+	// The dead property is listed once with the quota properties.
+	got := do("/patched", propnameBody)
+	for _, pn := range []string{"quota-available-bytes", "quota-used-bytes"} {
+		if n := strings.Count(got, "<D:"+pn); n != 1 {
+			t.Errorf("PROPFIND /patched propname: %s listed %d times, want 1:\n%s", pn, n, got)
+		}
+	}
+
+	// With a QuotaFunc, the properties are protected.
+	if got := doMethod("PROPPATCH", "/limited", proppatchBody); !strings.Contains(got, "403 Forbidden") || !strings.Contains(got, "cannot-modify-protected-property") {
+		t.Errorf("PROPPATCH with QuotaFunc:\n%s", got)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	type call struct{ method, path string }
+	var calls []call
+	h := &Handler{
+		Prefix:     "/dav",
+		FileSystem: NewMemFS(),
+		LockSystem: NewMemLS(),
+		Authorize: func(ctx context.Context, method, path string) error {
+			calls = append(calls, call{method, path})
+			switch {
+			case method == "DELETE":
+				return errors.New("no deletes")
+			case strings.HasPrefix(path, "/private"):
+				return &StatusError{Status: http.StatusNotFound}
+			}
+			return nil
+		},
+	}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	testCases := []struct {
+		method, path string
+		headers      []string
+		wantStatus   int
+		wantCalls    []call
+	}{
+		{"PUT", "/dav/a", nil, http.StatusCreated, []call{{"PUT", "/a"}}},
+		{"DELETE", "/dav/a", nil, http.StatusForbidden, []call{{"DELETE", "/a"}}},
+		{"GET", "/dav/a", nil, http.StatusOK, []call{{"GET", "/a"}}},
+		{"PUT", "/dav/private", nil, http.StatusNotFound, []call{{"PUT", "/private"}}},
+		{"COPY", "/dav/a", []string{"Destination", "/dav/b"}, http.StatusCreated, []call{{"COPY", "/a"}, {"COPY", "/b"}}},
+		{"MOVE", "/dav/a", []string{"Destination", "/dav/private"}, http.StatusNotFound, []call{{"MOVE", "/a"}, {"MOVE", "/private"}}},
+		{"GET", "/elsewhere", nil, http.StatusNotFound, nil},
+	}
+	for _, tc := range testCases {
+		calls = nil
+		req, err := http.NewRequest(tc.method, srv.URL+tc.path, strings.NewReader("blah"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i+1 < len(tc.headers); i += 2 {
+			req.Header.Set(tc.headers[i], tc.headers[i+1])
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != tc.wantStatus {
+			t.Errorf("%s %s: got status %d, want %d", tc.method, tc.path, res.StatusCode, tc.wantStatus)
+		}
+		if !reflect.DeepEqual(calls, tc.wantCalls) {
+			t.Errorf("%s %s: got Authorize calls %v, want %v", tc.method, tc.path, calls, tc.wantCalls)
+		}
+	}
+	if _, err := h.FileSystem.Stat(context.Background(), "/a"); err != nil {
+		t.Errorf("Stat(/a) after forbidden DELETE: %v", err)
+	}
+}
+
+func TestIfHeaderConditions(t *testing.T) {
+	srv := httptest.NewServer(&Handler{
+		FileSystem: NewMemFS(),
+		LockSystem: NewMemLS(),
+	})
+	defer srv.Close()
+
+	do := func(method, urlStr, body string, headers ...string) *http.Response {
+		req, err := http.NewRequest(method, urlStr, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for len(headers) >= 2 {
+			req.Header.Add(headers[0], headers[1])
+			headers = headers[2:]
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+
+	res := do("PUT", srv.URL+"/file", "blah")
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT: got status %d, want %d", res.StatusCode, http.StatusCreated)
+	}
+	etag := res.Header.Get("ETag")
+
+	// Each successful PUT changes the ETag, so the file is unlocked and
+	// the conditions below do not depend on it.
+	unlocked := []struct {
+		ifHeader   string
+		wantStatus int
+	}{
+		{`(["wrong"])`, http.StatusPreconditionFailed},
+		{`(Not ["wrong"])`, http.StatusCreated},
+		{`(Not <DAV:no-lock>)`, http.StatusCreated},
+		{`(<DAV:no-lock>)`, http.StatusPreconditionFailed},
+		{`(<DAV:no-lock>) (not <DAV:no-lock>)`, http.StatusCreated},
+		{`</other> (Not ["wrong"])`, http.StatusCreated},
+	}
+	for _, tc := range unlocked {
+		if res := do("PUT", srv.URL+"/file", "blah", "If", tc.ifHeader); res.StatusCode != tc.wantStatus {
+			t.Errorf("PUT with If: %s: got status %d, want %d", tc.ifHeader, res.StatusCode, tc.wantStatus)
+		}
+	}
+
+	res = do("PUT", srv.URL+"/file", "blah")
+	etag = res.Header.Get("ETag")
+	if res := do("PUT", srv.URL+"/file", "blah", "If", "(["+etag+"])"); res.StatusCode != http.StatusCreated {
+		t.Errorf("PUT with If: ([%s]): got status %d, want %d", etag, res.StatusCode, http.StatusCreated)
+	}
+
+	res = do("LOCK", srv.URL+"/file", `<?xml version="1.0" encoding="utf-8" ?>
+		<D:lockinfo xmlns:D='DAV:'>
+			<D:lockscope><D:exclusive/></D:lockscope>
+			<D:locktype><D:write/></D:locktype>
+		</D:lockinfo>`)
+	token := strings.Trim(res.Header.Get("Lock-Token"), "<>")
+	etag = do("HEAD", srv.URL+"/file", "").Header.Get("ETag")
+
+	locked := []struct {
+		ifHeader   string
+		wantStatus int
+	}{
+		{`(Not <DAV:no-lock>)`, StatusLocked},
+		{`(<` + token + `> ["wrong"])`, http.StatusPreconditionFailed},
+		{`(<` + token + `> Not [` + etag + `])`, http.StatusPreconditionFailed},
+		{`(["wrong"]) (<` + token + `>)`, http.StatusCreated},
+	}
+	for _, tc := range locked {
+		if res := do("PUT", srv.URL+"/file", "blah", "If", tc.ifHeader); res.StatusCode != tc.wantStatus {
+			t.Errorf("PUT locked file with If: %s: got status %d, want %d", tc.ifHeader, res.StatusCode, tc.wantStatus)
+		}
+	}
+
+	etag = do("HEAD", srv.URL+"/file", "").Header.Get("ETag")
+	for _, ifHeader := range []string{
+		`<` + srv.URL + `/file> (<` + token + `> [` + etag + `])`,
+		`</file> (<` + token + `>)`,
+	} {
+		if res := do("PUT", srv.URL+"/file", "blah", "If", ifHeader); res.StatusCode != http.StatusCreated {
+			t.Errorf("PUT locked file with If: %s: got status %d, want %d", ifHeader, res.StatusCode, http.StatusCreated)
+		}
+	}
+}
+
+func TestIfHeaderConditionsCopyMove(t *testing.T) {
+	srv := httptest.NewServer(&Handler{
+		FileSystem: NewMemFS(),
+		LockSystem: NewMemLS(),
+	})
+	defer srv.Close()
+
+	do := func(method, urlStr, body string, headers ...string) *http.Response {
+		req, err := http.NewRequest(method, urlStr, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for len(headers) >= 2 {
+			req.Header.Add(headers[0], headers[1])
+			headers = headers[2:]
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+
+	// Untagged lists apply to the Request-URI, which is the source of a COPY
+	// or MOVE, even though a COPY only locks its destination.
+	testCases := []struct {
+		method     string
+		ifHeader   func(etag string) string
+		wantStatus int
+	}{
+		{"COPY", func(etag string) string { return "([" + etag + "])" }, http.StatusCreated},
+		{"COPY", func(etag string) string { return `(["wrong"])` }, http.StatusPreconditionFailed},
+		{"COPY", func(etag string) string { return "(Not [" + etag + "])" }, http.StatusPreconditionFailed},
+		{"COPY", func(etag string) string { return `(Not ["wrong"])` }, http.StatusCreated},
+		{"MOVE", func(etag string) string { return "([" + etag + "])" }, http.StatusCreated},
+		{"MOVE", func(etag string) string { return `(["wrong"])` }, http.StatusPreconditionFailed},
+		{"MOVE", func(etag string) string { return "(Not [" + etag + "])" }, http.StatusPreconditionFailed},
+		{"MOVE", func(etag string) string { return `(Not ["wrong"])` }, http.StatusCreated},
+	}
+	for _, tc := range testCases {
+		do("DELETE", srv.URL+"/b", "")
+		res := do("PUT", srv.URL+"/a", "blah")
+		if res.StatusCode != http.StatusCreated && res.StatusCode != http.StatusNoContent {
+			t.Fatalf("PUT /a: got status %d", res.StatusCode)
+		}
+		ifHeader := tc.ifHeader(res.Header.Get("ETag"))
+		res = do(tc.method, srv.URL+"/a", "", "Destination", srv.URL+"/b", "If", ifHeader)
+		if res.StatusCode != tc.wantStatus {
+			t.Errorf("%s /a with If: %s: got status %d, want %d", tc.method, ifHeader, res.StatusCode, tc.wantStatus)
+		}
+	}
+}
+
+func TestIfHeaderNotLockToken(t *testing.T) {
+	// confirmOnlyLS hides the LockChecker implementation of the memLS, so
+	// that the Handler falls back to probing with Confirm.
+	type confirmOnlyLS struct{ LockSystem }
+	for _, ls := range []LockSystem{NewMemLS(), confirmOnlyLS{NewMemLS()}} {
+		srv := httptest.NewServer(&Handler{
+			FileSystem: NewMemFS(),
+			LockSystem: ls,
+		})
+
+		do := func(method, urlStr, body string, headers ...string) *http.Response {
+			req, err := http.NewRequest(method, urlStr, strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for len(headers) >= 2 {
+				req.Header.Add(headers[0], headers[1])
+				headers = headers[2:]
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			return res
+		}
+		lock := func(name string) string {
+			res := do("LOCK", srv.URL+name, `<?xml version="1.0" encoding="utf-8" ?>
+				<D:lockinfo xmlns:D='DAV:'>
+					<D:lockscope><D:exclusive/></D:lockscope>
+					<D:locktype><D:write/></D:locktype>
+				</D:lockinfo>`)
+			return strings.Trim(res.Header.Get("Lock-Token"), "<>")
+		}
+
+		do("PUT", srv.URL+"/file", "blah")
+		do("PUT", srv.URL+"/other", "blah")
+		a, b := lock("/file"), lock("/other")
+
+		testCases := []struct {
+			ifHeader   string
+			wantStatus int
+		}{
+			// a locks /file, so "Not <a>" is false for /file.
+			{`</file> (<` + a + `> Not <` + a + `>)`, http.StatusPreconditionFailed},
+			{`</file> (Not <` + a + `>)`, http.StatusPreconditionFailed},
+			{`(<` + a + `> Not <` + a + `>)`, http.StatusPreconditionFailed},
+			// b locks /other only, so "Not <b>" is true for /file.
+			{`</file> (<` + a + `> Not <` + b + `>)`, http.StatusCreated},
+			{`(<` + a + `> Not <` + b + `>)`, http.StatusCreated},
+			{`</file> (<` + a + `> Not <DAV:no-lock>)`, http.StatusCreated},
+			{`</other> (Not <` + b + `>) </file> (<` + a + `>)`, http.StatusCreated},
+			{`</other> (Not <` + b + `>) </file> (Not <` + a + `>)`, http.StatusPreconditionFailed},
+		}
+		for _, tc := range testCases {
+			if res := do("PUT", srv.URL+"/file", "blah", "If", tc.ifHeader); res.StatusCode != tc.wantStatus {
+				t.Errorf("%T: PUT with If: %s: got status %d, want %d", ls, tc.ifHeader, res.StatusCode, tc.wantStatus)
+			}
+		}
+		srv.Close()
+	}
+}
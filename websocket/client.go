@@ -6,6 +6,7 @@ package websocket
 
 import (
 	"bufio"
+	"context"
 	"io"
 	"net"
 	"net/http"
@@ -16,6 +17,13 @@ import (
 type DialError struct {
 	*Config
 	Err error
+
+	// Response is the server's response to the opening handshake,
+	// if the handshake failed after it was read. If Err is
+	// ErrBadStatus, its Body holds up to the first 64KB of the body
+	// the server sent, which remains readable after the connection
+	// is closed.
+	Response *http.Response
 }
 
 func (e *DialError) Error() string {
@@ -40,15 +48,25 @@ func NewConfig(server, origin string) (config *Config, err error) {
 
 // NewClient creates a new WebSocket client connection over rwc.
 func NewClient(config *Config, rwc io.ReadWriteCloser) (ws *Conn, err error) {
+	ws, _, err = newClient(config, rwc)
+	return
+}
+
+// newClient is like NewClient but also returns the server's response to
+// a failed handshake, if any.
+func newClient(config *Config, rwc io.ReadWriteCloser) (ws *Conn, resp *http.Response, err error) {
 	br := bufio.NewReader(rwc)
 	bw := bufio.NewWriter(rwc)
-	err = hybiClientHandshake(config, br, bw)
+	resp, deflate, err := hybiClientHandshake(config, br, bw)
 	if err != nil {
 		return
 	}
 	buf := bufio.NewReadWriter(br, bw)
-	ws = newHybiClientConn(config, buf, rwc)
-	return
+	ws = newHybiClientConn(config, buf, rwc, deflate)
+	// The handshake checked that the server selected one of the
+	// offered subprotocols, if any.
+	ws.subprotocol = resp.Header.Get("Sec-WebSocket-Protocol")
+	return ws, nil, nil
 }
 
 // Dial opens a new client connection to a WebSocket.
@@ -79,22 +97,30 @@ func parseAuthority(location *url.URL) string {
 
 // DialConfig opens a new client connection to a WebSocket with a config.
 func DialConfig(config *Config) (ws *Conn, err error) {
+	return DialContext(context.Background(), config)
+}
+
+// DialContext opens a new client connection to a WebSocket with a config.
+// The context governs the dial, the TLS handshake if any, and the
+// WebSocket opening handshake; once DialContext returns, the context no
+// longer affects the connection.
+func DialContext(ctx context.Context, config *Config) (ws *Conn, err error) {
 	var client net.Conn
+	var resp *http.Response
 	if config.Location == nil {
-		return nil, &DialError{config, ErrBadWebSocketLocation}
+		return nil, &DialError{Config: config, Err: ErrBadWebSocketLocation}
 	}
 	if config.Origin == nil {
-		return nil, &DialError{config, ErrBadWebSocketOrigin}
-	}
-	dialer := config.Dialer
-	if dialer == nil {
-		dialer = &net.Dialer{}
+		return nil, &DialError{Config: config, Err: ErrBadWebSocketOrigin}
 	}
-	client, err = dialWithDialer(dialer, config)
+	client, err = dialContext(ctx, config)
 	if err != nil {
 		goto Error
 	}
-	ws, err = NewClient(config, client)
+	err = withContext(ctx, client, func() (err error) {
+		ws, resp, err = newClient(config, client)
+		return err
+	})
 	if err != nil {
 		client.Close()
 		goto Error
@@ -102,5 +128,5 @@ func DialConfig(config *Config) (ws *Conn, err error) {
 	return
 
 Error:
-	return nil, &DialError{config, err}
+	return nil, &DialError{Config: config, Err: err, Response: resp}
 }
@@ -0,0 +1,258 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package websocket
+
+// This file implements the permessage-deflate extension.
+// https://tools.ietf.org/html/rfc7692
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	permessageDeflate = "permessage-deflate"
+
+	// maxWindowBits is the LZ77 window size used by compress/flate.
+	maxWindowBits = 15
+	minWindowBits = 8
+
+	// deflateTail is appended to a compressed message before inflating it:
+	// the empty stored block stripped by the sender (RFC 7692, section
+	// 7.2.2), followed by a final empty stored block so that the
+	// decompressor reports io.EOF at the end of the message.
+	deflateTail = "\x00\x00\xff\xff\x01\x00\x00\xff\xff"
+)
+
+// deflateParams holds the negotiated permessage-deflate parameters.
+// A window bits value of zero means the parameter was not negotiated.
+type deflateParams struct {
+	serverNoContextTakeover bool
+	clientNoContextTakeover bool
+	serverMaxWindowBits     int
+	clientMaxWindowBits     int
+}
+
+// String returns the parameters formatted as a Sec-WebSocket-Extensions
+// element.
+func (p *deflateParams) String() string {
+	s := permessageDeflate
+	if p.serverNoContextTakeover {
+		s += "; server_no_context_takeover"
+	}
+	if p.clientNoContextTakeover {
+		s += "; client_no_context_takeover"
+	}
+	if p.serverMaxWindowBits != 0 {
+		s += "; server_max_window_bits=" + strconv.Itoa(p.serverMaxWindowBits)
+	}
+	if p.clientMaxWindowBits != 0 {
+		s += "; client_max_window_bits=" + strconv.Itoa(p.clientMaxWindowBits)
+	}
+	return s
+}
+
+// An extension is an element of a Sec-WebSocket-Extensions header.
+type extension struct {
+	name   string
+	params []extensionParam
+}
+
+type extensionParam struct {
+	name, value string
+}
+
+// parseExtensions parses all Sec-WebSocket-Extensions header fields in h.
+func parseExtensions(h http.Header) []extension {
+	var exts []extension
+	for _, v := range h["Sec-Websocket-Extensions"] {
+		for _, e := range strings.Split(v, ",") {
+			parts := strings.Split(e, ";")
+			name := strings.TrimSpace(parts[0])
+			if name == "" {
+				continue
+			}
+			ext := extension{name: strings.ToLower(name)}
+			for _, p := range parts[1:] {
+				var param extensionParam
+				if i := strings.Index(p, "="); i >= 0 {
+					param.name = p[:i]
+					param.value = strings.Trim(strings.TrimSpace(p[i+1:]), `"`)
+				} else {
+					param.name = p
+				}
+				param.name = strings.ToLower(strings.TrimSpace(param.name))
+				ext.params = append(ext.params, param)
+			}
+			exts = append(exts, ext)
+		}
+	}
+	return exts
+}
+
+// parseWindowBits parses the value of a *_max_window_bits parameter.
+func parseWindowBits(s string) (int, bool) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < minWindowBits || n > maxWindowBits {
+		return 0, false
+	}
+	return n, true
+}
+
+// acceptDeflateOffer returns the server's response to a client's
+// permessage-deflate offer, or nil if the offer is not acceptable.
+func acceptDeflateOffer(ext extension) *deflateParams {
+	p := new(deflateParams)
+	seen := make(map[string]bool)
+	for _, param := range ext.params {
+		if seen[param.name] {
+			return nil
+		}
+		seen[param.name] = true
+		switch param.name {
+		case "server_no_context_takeover":
+			if param.value != "" {
+				return nil
+			}
+			p.serverNoContextTakeover = true
+		case "client_no_context_takeover":
+			if param.value != "" {
+				return nil
+			}
+			p.clientNoContextTakeover = true
+		case "server_max_window_bits":
+			n, ok := parseWindowBits(param.value)
+			if !ok {
+				return nil
+			}
+			p.serverMaxWindowBits = n
+		case "client_max_window_bits":
+			// The client supports the parameter, but the server
+			// inflates with the full window, so it is not limited.
+			if param.value != "" {
+				if _, ok := parseWindowBits(param.value); !ok {
+					return nil
+				}
+			}
+		default:
+			return nil
+		}
+	}
+	return p
+}
+
+// parseDeflateResponse parses the server's permessage-deflate response to
+// the client's offer.
+func parseDeflateResponse(ext extension) (*deflateParams, error) {
+	p := new(deflateParams)
+	seen := make(map[string]bool)
+	for _, param := range ext.params {
+		if seen[param.name] {
+			return nil, ErrUnsupportedExtensions
+		}
+		seen[param.name] = true
+		ok := true
+		switch param.name {
+		case "server_no_context_takeover":
+			ok = param.value == ""
+			p.serverNoContextTakeover = true
+		case "client_no_context_takeover":
+			ok = param.value == ""
+			p.clientNoContextTakeover = true
+		case "server_max_window_bits":
+			p.serverMaxWindowBits, ok = parseWindowBits(param.value)
+		case "client_max_window_bits":
+			p.clientMaxWindowBits, ok = parseWindowBits(param.value)
+		default:
+			ok = false
+		}
+		if !ok {
+			return nil, ErrUnsupportedExtensions
+		}
+	}
+	return p, nil
+}
+
+// A compressor deflates outgoing message payloads.
+type compressor struct {
+	buf               bytes.Buffer
+	w                 *flate.Writer
+	noContextTakeover bool
+}
+
+// newCompressor returns a compressor honoring the peer's limit on the
+// sliding window size. compress/flate always uses a 32KB window, so
+// smaller windows are honored by not emitting back-references at all.
+func newCompressor(windowBits int, noContextTakeover bool) *compressor {
+	level := flate.DefaultCompression
+	if windowBits != 0 && windowBits < maxWindowBits {
+		level = flate.HuffmanOnly
+	}
+	c := &compressor{noContextTakeover: noContextTakeover}
+	c.w, _ = flate.NewWriter(&c.buf, level)
+	return c
+}
+
+// compress returns the compressed form of p. The result is only valid
+// until the next call to compress.
+func (c *compressor) compress(p []byte) ([]byte, error) {
+	c.buf.Reset()
+	if _, err := c.w.Write(p); err != nil {
+		return nil, err
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+	if c.noContextTakeover {
+		c.w.Reset(&c.buf)
+	}
+	b := c.buf.Bytes()
+	// Remove the empty stored block written by Flush.
+	return b[:len(b)-4], nil
+}
+
+// A decompressor inflates incoming message payloads.
+type decompressor struct {
+	r io.ReadCloser
+
+	// window holds the tail of the previously inflated messages when the
+	// peer may use context takeover.
+	window            []byte
+	noContextTakeover bool
+}
+
+func newDecompressor(noContextTakeover bool) *decompressor {
+	return &decompressor{noContextTakeover: noContextTakeover}
+}
+
+// decompress returns the inflated form of the compressed message p. It
+// returns ErrFrameTooLarge if the result would exceed limit bytes.
+func (d *decompressor) decompress(p []byte, limit int) ([]byte, error) {
+	src := io.MultiReader(bytes.NewReader(p), strings.NewReader(deflateTail))
+	if d.r == nil {
+		d.r = flate.NewReaderDict(src, d.window)
+	} else if err := d.r.(flate.Resetter).Reset(src, d.window); err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(d.r, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > limit {
+		return nil, ErrFrameTooLarge
+	}
+	if !d.noContextTakeover {
+		d.window = append(d.window, b...)
+		if n := len(d.window) - 1<<maxWindowBits; n > 0 {
+			d.window = append(d.window[:0], d.window[n:]...)
+		}
+	}
+	return b, nil
+}
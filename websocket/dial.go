@@ -5,20 +5,110 @@
 package websocket
 
 import (
+	"context"
 	"crypto/tls"
 	"net"
+	"time"
 )
 
-func dialWithDialer(dialer *net.Dialer, config *Config) (conn net.Conn, err error) {
+// dialContext opens the network connection for config, over TLS for
+// the wss scheme. The context governs the dial and the TLS handshake.
+func dialContext(ctx context.Context, config *Config) (conn net.Conn, err error) {
 	switch config.Location.Scheme {
-	case "ws":
-		conn, err = dialer.Dial("tcp", parseAuthority(config.Location))
+	case "ws", "wss":
+	default:
+		return nil, ErrBadScheme
+	}
+	addr := parseAuthority(config.Location)
+	if d := config.Dialer; d != nil && config.Dial == nil && config.Location.Scheme == "wss" {
+		// As with tls.DialWithDialer, the Dialer's Timeout and
+		// Deadline bound the TLS handshake as well as the dial.
+		var cancel context.CancelFunc
+		if d.Timeout != 0 {
+			ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+			defer cancel()
+		}
+		if !d.Deadline.IsZero() {
+			ctx, cancel = context.WithDeadline(ctx, d.Deadline)
+			defer cancel()
+		}
+	}
+	if config.Dial != nil {
+		conn, err = dialFunc(ctx, config.Dial, addr)
+	} else {
+		dialer := config.Dialer
+		if dialer == nil {
+			dialer = &net.Dialer{}
+		}
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil || config.Location.Scheme != "wss" {
+		return conn, err
+	}
+
+	tlsConfig := config.TlsConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.ServerName = config.Location.Hostname()
+	}
+	tlsConn := tls.Client(conn, tlsConfig)
+	err = withContext(ctx, conn, tlsConn.Handshake)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
 
-	case "wss":
-		conn, err = tls.DialWithDialer(dialer, "tcp", parseAuthority(config.Location), config.TlsConfig)
+// dialFunc calls dial for addr, abandoning it if ctx is done first. A
+// connection established after that is closed.
+func dialFunc(ctx context.Context, dial func(network, addr string) (net.Conn, error), addr string) (net.Conn, error) {
+	if ctx.Done() == nil {
+		return dial("tcp", addr)
+	}
+	type result struct {
+		c   net.Conn
+		err error
+	}
+	resc := make(chan result)
+	abandoned := make(chan struct{})
+	go func() {
+		c, err := dial("tcp", addr)
+		select {
+		case resc <- result{c, err}:
+		case <-abandoned:
+			if c != nil {
+				c.Close()
+			}
+		}
+	}()
+	select {
+	case r := <-resc:
+		return r.c, r.err
+	case <-ctx.Done():
+		close(abandoned)
+		return nil, ctx.Err()
+	}
+}
 
-	default:
-		err = ErrBadScheme
+// withContext calls f, which does I/O on c, aborting the I/O if ctx is
+// done first, in which case it returns ctx.Err(). Unlike watchContext
+// alone, it also applies the deadline of ctx to c, so that the I/O times
+// out even if the deadline passes before ctx is done. It leaves c without
+// a deadline.
+func withContext(ctx context.Context, c net.Conn, f func() error) (err error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetDeadline(deadline)
+		defer c.SetDeadline(time.Time{})
+	}
+	stop := watchContext(ctx, c.SetDeadline)
+	err = f()
+	stop()
+	if ctxErr := ctx.Err(); ctxErr != nil && err != nil {
+		err = ctxErr
 	}
-	return
+	return err
 }
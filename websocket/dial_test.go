@@ -5,10 +5,15 @@
 package websocket
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"net"
+	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
@@ -41,3 +46,230 @@ func TestDialConfigTLSWithDialer(t *testing.T) {
 		t.Fatalf("expected timeout error, got %#v", neterr)
 	}
 }
+
+func TestDialContextWithDial(t *testing.T) {
+	once.Do(startServer)
+	config := newConfig(t, "/echo")
+	var dialed string
+	config.Dial = func(network, addr string) (net.Conn, error) {
+		dialed = network + " " + addr
+		return net.Dial(network, addr)
+	}
+	ws, err := DialContext(context.Background(), config)
+	if err != nil {
+		t.Fatalf("DialContext: %v", err)
+	}
+	defer ws.Close()
+	if want := "tcp " + serverAddr; dialed != want {
+		t.Errorf("Dial called with %q, want %q", dialed, want)
+	}
+	msg := []byte("hello, world\n")
+	if _, err := ws.Write(msg); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	var got = make([]byte, 512)
+	n, err := ws.Read(got)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if string(got[:n]) != string(msg) {
+		t.Errorf("Echo: got %q, want %q", got[:n], msg)
+	}
+}
+
+func TestDialContextTLSWithDial(t *testing.T) {
+	tlsServer := httptest.NewTLSServer(Handler(echoServer))
+	defer tlsServer.Close()
+	config, _ := NewConfig(fmt.Sprintf("wss://%s/echo", tlsServer.Listener.Addr()), "http://localhost")
+	config.TlsConfig = &tls.Config{
+		InsecureSkipVerify: true,
+	}
+	called := false
+	config.Dial = func(network, addr string) (net.Conn, error) {
+		called = true
+		return net.Dial(network, addr)
+	}
+	ws, err := DialContext(context.Background(), config)
+	if err != nil {
+		t.Fatalf("DialContext: %v", err)
+	}
+	defer ws.Close()
+	if !called {
+		t.Error("Dial was not called")
+	}
+	if _, err := ws.Write([]byte("hello")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	got := make([]byte, 512)
+	n, err := ws.Read(got)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if string(got[:n]) != "hello" {
+		t.Errorf("Echo: got %q, want %q", got[:n], "hello")
+	}
+}
+
+func TestDialContextCancelHandshake(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		// Accept the connection but never reply to the handshake.
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		io.Copy(ioutil.Discard, c)
+	}()
+
+	config, _ := NewConfig(fmt.Sprintf("ws://%s/echo", ln.Addr()), "http://localhost")
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, err = DialContext(ctx, config)
+	dialerr, ok := err.(*DialError)
+	if !ok {
+		t.Fatalf("DialError expected, got %#v", err)
+	}
+	if dialerr.Err != context.Canceled {
+		t.Fatalf("got error %v, want %v", dialerr.Err, context.Canceled)
+	}
+}
+
+func TestDialContextCancelDial(t *testing.T) {
+	config, _ := NewConfig("ws://example.com/echo", "http://localhost")
+	block := make(chan struct{})
+	defer close(block)
+	config.Dial = func(network, addr string) (net.Conn, error) {
+		<-block
+		return nil, errors.New("unreachable")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := DialContext(ctx, config)
+	dialerr, ok := err.(*DialError)
+	if !ok {
+		t.Fatalf("DialError expected, got %#v", err)
+	}
+	if dialerr.Err != context.DeadlineExceeded {
+		t.Fatalf("got error %v, want %v", dialerr.Err, context.DeadlineExceeded)
+	}
+}
+
+func TestDialConfigTLSHandshakeTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		// Accept the connection but never reply to the TLS handshake.
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		io.Copy(ioutil.Discard, c)
+	}()
+
+	config, _ := NewConfig(fmt.Sprintf("wss://%s/echo", ln.Addr()), "http://localhost")
+	config.Dialer = &net.Dialer{Timeout: 50 * time.Millisecond}
+	_, err = DialConfig(config)
+	dialerr, ok := err.(*DialError)
+	if !ok {
+		t.Fatalf("DialError expected, got %#v", err)
+	}
+	if dialerr.Err != context.DeadlineExceeded {
+		t.Fatalf("got error %v, want %v", dialerr.Err, context.DeadlineExceeded)
+	}
+}
+
+func TestWithContextCanceledAfterSuccess(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	err := withContext(ctx, c1, func() error {
+		cancel()
+		// Wait for the cancellation to abort the I/O on c1.
+		c1.Read(make([]byte, 1))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go c2.Write([]byte("x"))
+	if _, err := c1.Read(make([]byte, 1)); err != nil {
+		t.Fatalf("Read after withContext returned = %v", err)
+	}
+}
+
+func TestDialErrorResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="ws"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, "credentials required")
+	}))
+	defer server.Close()
+
+	_, err := Dial("ws://"+server.Listener.Addr().String()+"/", "", "http://localhost/")
+	dialerr, ok := err.(*DialError)
+	if !ok || dialerr.Err != ErrBadStatus {
+		t.Fatalf("Dial: got %v; want a DialError with %v", err, ErrBadStatus)
+	}
+	res := dialerr.Response
+	if res == nil {
+		t.Fatal("DialError.Response is nil")
+	}
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("StatusCode = %d; want %d", res.StatusCode, http.StatusUnauthorized)
+	}
+	if got, want := res.Header.Get("WWW-Authenticate"), `Basic realm="ws"`; got != want {
+		t.Errorf("WWW-Authenticate = %q; want %q", got, want)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("reading body: %v", err)
+	}
+	if string(body) != "credentials required" {
+		t.Errorf("body = %q; want %q", body, "credentials required")
+	}
+}
+
+func TestDialErrorResponseEndlessBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		chunk := make([]byte, 4096)
+		for {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := Dial("ws://"+server.Listener.Addr().String()+"/", "", "http://localhost/")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		dialerr, ok := err.(*DialError)
+		if !ok || dialerr.Err != ErrBadStatus {
+			t.Fatalf("Dial: got %v; want a DialError with %v", err, ErrBadStatus)
+		}
+		body, err := ioutil.ReadAll(dialerr.Response.Body)
+		if err != nil {
+			t.Fatalf("reading body: %v", err)
+		}
+		if len(body) != maxErrorBodySize {
+			t.Errorf("len(body) = %d; want %d", len(body), maxErrorBodySize)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Dial did not return")
+	}
+}
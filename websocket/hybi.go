@@ -38,6 +38,10 @@ const (
 	closeStatusExtensionMismatch = 1010
 
 	maxControlFramePayloadLength = 125
+
+	// maxErrorBodySize is how much of the body of a response
+	// rejecting the opening handshake is kept for DialError.
+	maxErrorBodySize = 64 << 10
 )
 
 var (
@@ -46,6 +50,8 @@ var (
 	ErrBadClosingStatus      = &ProtocolError{"bad closing status"}
 	ErrUnsupportedExtensions = &ProtocolError{"unsupported extensions"}
 	ErrNotImplemented        = &ProtocolError{"not implemented"}
+	ErrControlFrameTooLarge  = &ProtocolError{"control frame payload too large"}
+	ErrFragmentedControl     = &ProtocolError{"fragmented control frame"}
 
 	handshakeHeader = map[string]bool{
 		"Host":                   true,
@@ -181,10 +187,17 @@ func (buf hybiFrameReaderFactory) NewFrameReader() (frame frameReader, err error
 type hybiFrameWriter struct {
 	writer *bufio.Writer
 
-	header *hybiFrameHeader
+	header     *hybiFrameHeader
+	compressor *compressor
 }
 
 func (frame *hybiFrameWriter) Write(msg []byte) (n int, err error) {
+	n = len(msg)
+	if frame.compressor != nil {
+		if msg, err = frame.compressor.compress(msg); err != nil {
+			return 0, err
+		}
+	}
 	var header []byte
 	var b byte
 	if frame.header.Fin {
@@ -233,12 +246,12 @@ func (frame *hybiFrameWriter) Write(msg []byte) (n int, err error) {
 		}
 		frame.writer.Write(data)
 		err = frame.writer.Flush()
-		return length, err
+		return n, err
 	}
 	frame.writer.Write(header)
 	frame.writer.Write(msg)
 	err = frame.writer.Flush()
-	return length, err
+	return n, err
 }
 
 func (frame *hybiFrameWriter) Close() error { return nil }
@@ -246,6 +259,7 @@ func (frame *hybiFrameWriter) Close() error { return nil }
 type hybiFrameWriterFactory struct {
 	*bufio.Writer
 	needMaskingKey bool
+	compressor     *compressor
 }
 
 func (buf hybiFrameWriterFactory) NewFrameWriter(payloadType byte) (frame frameWriter, err error) {
@@ -256,12 +270,19 @@ func (buf hybiFrameWriterFactory) NewFrameWriter(payloadType byte) (frame frameW
 			return nil, err
 		}
 	}
-	return &hybiFrameWriter{writer: buf.Writer, header: frameHeader}, nil
+	w := &hybiFrameWriter{writer: buf.Writer, header: frameHeader}
+	// Control frames are never compressed.
+	if buf.compressor != nil && (payloadType == TextFrame || payloadType == BinaryFrame) {
+		frameHeader.Rsv[0] = true
+		w.compressor = buf.compressor
+	}
+	return w, nil
 }
 
 type hybiFrameHandler struct {
-	conn        *Conn
-	payloadType byte
+	conn         *Conn
+	payloadType  byte
+	decompressor *decompressor
 }
 
 func (handler *hybiFrameHandler) HandleFrame(frame frameReader) (frameReader, error) {
@@ -281,11 +302,24 @@ func (handler *hybiFrameHandler) HandleFrame(frame frameReader) (frameReader, er
 	if header := frame.HeaderReader(); header != nil {
 		io.Copy(ioutil.Discard, header)
 	}
+	compressed := frame.(*hybiFrameReader).header.Rsv[0]
+	if compressed {
+		// RSV1 is only valid on the first frame of a message when
+		// permessage-deflate has been negotiated.
+		op := frame.PayloadType()
+		if handler.decompressor == nil || (op != TextFrame && op != BinaryFrame) {
+			handler.WriteClose(closeStatusProtocolError)
+			return nil, io.EOF
+		}
+	}
 	switch frame.PayloadType() {
 	case ContinuationFrame:
 		frame.(*hybiFrameReader).header.OpCode = handler.payloadType
 	case TextFrame, BinaryFrame:
 		handler.payloadType = frame.PayloadType()
+		if compressed {
+			return handler.inflate(frame.(*hybiFrameReader))
+		}
 	case CloseFrame:
 		return nil, io.EOF
 	case PingFrame, PongFrame:
@@ -295,8 +329,17 @@ func (handler *hybiFrameHandler) HandleFrame(frame frameReader) (frameReader, er
 			return nil, err
 		}
 		io.Copy(ioutil.Discard, frame)
+		h := handler.conn.pongHandler
 		if frame.PayloadType() == PingFrame {
-			if _, err := handler.WritePong(b[:n]); err != nil {
+			if h = handler.conn.pingHandler; h == nil {
+				h = func(data []byte) error {
+					_, err := handler.WritePong(data)
+					return err
+				}
+			}
+		}
+		if h != nil {
+			if err := h(b[:n]); err != nil {
 				return nil, err
 			}
 		}
@@ -305,6 +348,68 @@ func (handler *hybiFrameHandler) HandleFrame(frame frameReader) (frameReader, er
 	return frame, nil
 }
 
+// inflate reads the compressed message starting with frame, including any
+// continuation frames, and returns a frame reader for the inflated payload.
+func (handler *hybiFrameHandler) inflate(frame *hybiFrameReader) (frameReader, error) {
+	limit := handler.conn.maxPayloadBytes()
+	var msg []byte
+	for {
+		if frame.header.Length > int64(limit-len(msg)) {
+			handler.WriteClose(closeStatusTooBigData)
+			return nil, ErrFrameTooLarge
+		}
+		b, err := ioutil.ReadAll(frame)
+		if err != nil {
+			return nil, err
+		}
+		msg = append(msg, b...)
+		if frame.header.Fin {
+			break
+		}
+		if frame, err = handler.nextContinuation(); err != nil {
+			return nil, err
+		}
+	}
+	data, err := handler.decompressor.decompress(msg, limit)
+	if err == ErrFrameTooLarge {
+		handler.WriteClose(closeStatusTooBigData)
+		return nil, err
+	}
+	if err != nil {
+		handler.WriteClose(closeStatusBadMessageData)
+		return nil, err
+	}
+	return &hybiFrameReader{
+		reader: bytes.NewReader(data),
+		header: hybiFrameHeader{Fin: true, OpCode: handler.payloadType, Length: int64(len(data))},
+		length: len(data),
+	}, nil
+}
+
+// nextContinuation reads the next continuation frame of the current
+// message, handling any control frames that precede it.
+func (handler *hybiFrameHandler) nextContinuation() (*hybiFrameReader, error) {
+	for {
+		frame, err := handler.conn.frameReaderFactory.NewFrameReader()
+		if err != nil {
+			return nil, err
+		}
+		op := frame.PayloadType()
+		frame, err = handler.HandleFrame(frame)
+		if err != nil {
+			return nil, err
+		}
+		if frame == nil {
+			continue
+		}
+		if op != ContinuationFrame {
+			handler.WriteClose(closeStatusProtocolError)
+			return nil, io.EOF
+		}
+		return frame.(*hybiFrameReader), nil
+	}
+}
+
 func (handler *hybiFrameHandler) WriteClose(status int) (err error) {
 	handler.conn.wio.Lock()
 	defer handler.conn.wio.Unlock()
@@ -332,19 +437,32 @@ func (handler *hybiFrameHandler) WritePong(msg []byte) (n int, err error) {
 }
 
 // newHybiConn creates a new WebSocket connection speaking hybi draft protocol.
-func newHybiConn(config *Config, buf *bufio.ReadWriter, rwc io.ReadWriteCloser, request *http.Request) *Conn {
+// If deflate is non-nil, messages are compressed with the negotiated
+// permessage-deflate parameters.
+func newHybiConn(config *Config, buf *bufio.ReadWriter, rwc io.ReadWriteCloser, request *http.Request, deflate *deflateParams) *Conn {
 	if buf == nil {
 		br := bufio.NewReader(rwc)
 		bw := bufio.NewWriter(rwc)
 		buf = bufio.NewReadWriter(br, bw)
 	}
+	var c *compressor
+	var d *decompressor
+	if p := deflate; p != nil {
+		if request != nil {
+			c = newCompressor(p.serverMaxWindowBits, p.serverNoContextTakeover)
+			d = newDecompressor(p.clientNoContextTakeover)
+		} else {
+			c = newCompressor(p.clientMaxWindowBits, p.clientNoContextTakeover)
+			d = newDecompressor(p.serverNoContextTakeover)
+		}
+	}
 	ws := &Conn{config: config, request: request, buf: buf, rwc: rwc,
 		frameReaderFactory: hybiFrameReaderFactory{buf.Reader},
 		frameWriterFactory: hybiFrameWriterFactory{
-			buf.Writer, request == nil},
+			buf.Writer, request == nil, c},
 		PayloadType:        TextFrame,
 		defaultCloseStatus: closeStatusNormal}
-	ws.frameHandler = &hybiFrameHandler{conn: ws}
+	ws.frameHandler = &hybiFrameHandler{conn: ws, decompressor: d}
 	return ws
 }
 
@@ -401,8 +519,10 @@ func getNonceAccept(nonce []byte) (expected []byte, err error) {
 	return
 }
 
-// Client handshake described in draft-ietf-hybi-thewebsocket-protocol-17
-func hybiClientHandshake(config *Config, br *bufio.Reader, bw *bufio.Writer) (err error) {
+// Client handshake described in draft-ietf-hybi-thewebsocket-protocol-17.
+// It returns the server's response, if one was read, even on error, and
+// the negotiated permessage-deflate parameters, if any.
+func hybiClientHandshake(config *Config, br *bufio.Reader, bw *bufio.Writer) (resp *http.Response, deflate *deflateParams, err error) {
 	bw.WriteString("GET " + config.Location.RequestURI() + " HTTP/1.1\r\n")
 
 	// According to RFC 6874, an HTTP client, proxy, or other
@@ -419,44 +539,57 @@ func hybiClientHandshake(config *Config, br *bufio.Reader, bw *bufio.Writer) (er
 	bw.WriteString("Origin: " + strings.ToLower(config.Origin.String()) + "\r\n")
 
 	if config.Version != ProtocolVersionHybi13 {
-		return ErrBadProtocolVersion
+		return resp, nil, ErrBadProtocolVersion
 	}
 
 	bw.WriteString("Sec-WebSocket-Version: " + fmt.Sprintf("%d", config.Version) + "\r\n")
 	if len(config.Protocol) > 0 {
 		bw.WriteString("Sec-WebSocket-Protocol: " + strings.Join(config.Protocol, ", ") + "\r\n")
 	}
-	// TODO(ukai): send Sec-WebSocket-Extensions.
+	if config.EnableCompression {
+		bw.WriteString("Sec-WebSocket-Extensions: " + permessageDeflate + "; client_max_window_bits\r\n")
+	}
 	err = config.Header.WriteSubset(bw, handshakeHeader)
 	if err != nil {
-		return err
+		return resp, nil, err
 	}
 
 	bw.WriteString("\r\n")
 	if err = bw.Flush(); err != nil {
-		return err
+		return resp, nil, err
 	}
 
-	resp, err := http.ReadResponse(br, &http.Request{Method: "GET"})
+	resp, err = http.ReadResponse(br, &http.Request{Method: "GET"})
 	if err != nil {
-		return err
+		return resp, nil, err
 	}
 	if resp.StatusCode != 101 {
-		return ErrBadStatus
+		// Buffer the body, so that it stays readable once the
+		// connection is closed. The original body is not closed, as
+		// that would drain the rest of it; the caller closes the
+		// connection instead.
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		return resp, nil, ErrBadStatus
 	}
 	if strings.ToLower(resp.Header.Get("Upgrade")) != "websocket" ||
 		strings.ToLower(resp.Header.Get("Connection")) != "upgrade" {
-		return ErrBadUpgrade
+		return resp, nil, ErrBadUpgrade
 	}
 	expectedAccept, err := getNonceAccept(nonce)
 	if err != nil {
-		return err
+		return resp, nil, err
 	}
 	if resp.Header.Get("Sec-WebSocket-Accept") != string(expectedAccept) {
-		return ErrChallengeResponse
+		return resp, nil, ErrChallengeResponse
 	}
-	if resp.Header.Get("Sec-WebSocket-Extensions") != "" {
-		return ErrUnsupportedExtensions
+	if exts := parseExtensions(resp.Header); len(exts) > 0 {
+		if !config.EnableCompression || len(exts) != 1 || exts[0].name != permessageDeflate {
+			return resp, nil, ErrUnsupportedExtensions
+		}
+		if deflate, err = parseDeflateResponse(exts[0]); err != nil {
+			return resp, nil, err
+		}
 	}
 	offeredProtocol := resp.Header.Get("Sec-WebSocket-Protocol")
 	if offeredProtocol != "" {
@@ -468,23 +601,25 @@ func hybiClientHandshake(config *Config, br *bufio.Reader, bw *bufio.Writer) (er
 			}
 		}
 		if !protocolMatched {
-			return ErrBadWebSocketProtocol
+			return resp, nil, ErrBadWebSocketProtocol
 		}
 		config.Protocol = []string{offeredProtocol}
 	}
 
-	return nil
+	return resp, deflate, nil
 }
 
 // newHybiClientConn creates a client WebSocket connection after handshake.
-func newHybiClientConn(config *Config, buf *bufio.ReadWriter, rwc io.ReadWriteCloser) *Conn {
-	return newHybiConn(config, buf, rwc, nil)
+func newHybiClientConn(config *Config, buf *bufio.ReadWriter, rwc io.ReadWriteCloser, deflate *deflateParams) *Conn {
+	return newHybiConn(config, buf, rwc, nil, deflate)
 }
 
 // A HybiServerHandshaker performs a server handshake using hybi draft protocol.
 type hybiServerHandshaker struct {
 	*Config
-	accept []byte
+	accept      []byte
+	deflate     *deflateParams // negotiated permessage-deflate parameters
+	subprotocol string         // negotiated subprotocol
 }
 
 func (c *hybiServerHandshaker) ReadHandshake(buf *bufio.Reader, req *http.Request) (code int, err error) {
@@ -520,11 +655,35 @@ func (c *hybiServerHandshaker) ReadHandshake(buf *bufio.Reader, req *http.Reques
 	if err != nil {
 		return http.StatusBadRequest, err
 	}
+	var offered []string
 	protocol := strings.TrimSpace(req.Header.Get("Sec-Websocket-Protocol"))
 	if protocol != "" {
 		protocols := strings.Split(protocol, ",")
 		for i := 0; i < len(protocols); i++ {
-			c.Protocol = append(c.Protocol, strings.TrimSpace(protocols[i]))
+			offered = append(offered, strings.TrimSpace(protocols[i]))
+		}
+	}
+	if len(c.Protocol) > 0 {
+		chosen := selectProtocol(c.Protocol, offered)
+		if chosen == "" && c.ProtocolRequired {
+			return http.StatusBadRequest, ErrBadWebSocketProtocol
+		}
+		c.Protocol = nil
+		if chosen != "" {
+			c.Protocol = []string{chosen}
+		}
+	} else {
+		c.Protocol = offered
+	}
+	c.deflate = nil
+	if c.EnableCompression {
+		for _, ext := range parseExtensions(req.Header) {
+			if ext.name != permessageDeflate {
+				continue
+			}
+			if c.deflate = acceptDeflateOffer(ext); c.deflate != nil {
+				break
+			}
 		}
 	}
 	c.accept, err = getNonceAccept([]byte(key))
@@ -534,6 +693,19 @@ func (c *hybiServerHandshaker) ReadHandshake(buf *bufio.Reader, req *http.Reques
 	return http.StatusSwitchingProtocols, nil
 }
 
+// selectProtocol returns the first of the server's subprotocols that the
+// client offered, or the empty string if there is none.
+func selectProtocol(server, offered []string) string {
+	for _, p := range server {
+		for _, o := range offered {
+			if p == o {
+				return p
+			}
+		}
+	}
+	return ""
+}
+
 // Origin parses the Origin header in req.
 // If the Origin header is not set, it returns nil and nil.
 func Origin(config *Config, req *http.Request) (*url.URL, error) {
@@ -559,10 +731,14 @@ func (c *hybiServerHandshaker) AcceptHandshake(buf *bufio.Writer) (err error) {
 	buf.WriteString("Upgrade: websocket\r\n")
 	buf.WriteString("Connection: Upgrade\r\n")
 	buf.WriteString("Sec-WebSocket-Accept: " + string(c.accept) + "\r\n")
+	c.subprotocol = ""
 	if len(c.Protocol) > 0 {
-		buf.WriteString("Sec-WebSocket-Protocol: " + c.Protocol[0] + "\r\n")
+		c.subprotocol = c.Protocol[0]
+		buf.WriteString("Sec-WebSocket-Protocol: " + c.subprotocol + "\r\n")
+	}
+	if c.deflate != nil {
+		buf.WriteString("Sec-WebSocket-Extensions: " + c.deflate.String() + "\r\n")
 	}
-	// TODO(ukai): send Sec-WebSocket-Extensions.
 	if c.Header != nil {
 		err := c.Header.WriteSubset(buf, handshakeHeader)
 		if err != nil {
@@ -574,10 +750,12 @@ func (c *hybiServerHandshaker) AcceptHandshake(buf *bufio.Writer) (err error) {
 }
 
 func (c *hybiServerHandshaker) NewServerConn(buf *bufio.ReadWriter, rwc io.ReadWriteCloser, request *http.Request) *Conn {
-	return newHybiServerConn(c.Config, buf, rwc, request)
+	ws := newHybiServerConn(c.Config, buf, rwc, request, c.deflate)
+	ws.subprotocol = c.subprotocol
+	return ws
 }
 
 // newHybiServerConn returns a new WebSocket connection speaking hybi draft protocol.
-func newHybiServerConn(config *Config, buf *bufio.ReadWriter, rwc io.ReadWriteCloser, request *http.Request) *Conn {
-	return newHybiConn(config, buf, rwc, request)
+func newHybiServerConn(config *Config, buf *bufio.ReadWriter, rwc io.ReadWriteCloser, request *http.Request, deflate *deflateParams) *Conn {
+	return newHybiConn(config, buf, rwc, request, deflate)
 }
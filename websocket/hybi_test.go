@@ -7,10 +7,12 @@ package websocket
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -68,7 +70,7 @@ Sec-WebSocket-Protocol: chat
 		config.handshakeData = map[string]string{
 			"key": "dGhlIHNhbXBsZSBub25jZQ==",
 		}
-		if err := hybiClientHandshake(&config, br, bw); err != nil {
+		if _, _, err := hybiClientHandshake(&config, br, bw); err != nil {
 			t.Fatal("handshake", err)
 		}
 		req, err := http.ReadRequest(bufio.NewReader(&b))
@@ -132,7 +134,7 @@ Sec-WebSocket-Protocol: chat
 	config.handshakeData = map[string]string{
 		"key": "dGhlIHNhbXBsZSBub25jZQ==",
 	}
-	err = hybiClientHandshake(config, br, bw)
+	_, _, err = hybiClientHandshake(config, br, bw)
 	if err != nil {
 		t.Errorf("handshake failed: %v", err)
 	}
@@ -218,6 +220,153 @@ Sec-WebSocket-Version: 13
 	}
 }
 
+func TestHybiServerHandshakeNegotiateProtocol(t *testing.T) {
+	for _, tt := range []struct {
+		server   []string
+		required bool
+		offered  string
+		code     int
+		want     string
+	}{
+		{[]string{"superchat", "chat"}, false, "chat, superchat", http.StatusSwitchingProtocols, "superchat"},
+		{[]string{"v2", "chat"}, true, "chat, superchat", http.StatusSwitchingProtocols, "chat"},
+		{[]string{"v2"}, false, "chat, superchat", http.StatusSwitchingProtocols, ""},
+		{[]string{"v2"}, false, "", http.StatusSwitchingProtocols, ""},
+		{[]string{"v2"}, true, "chat, superchat", http.StatusBadRequest, ""},
+		{[]string{"v2"}, true, "", http.StatusBadRequest, ""},
+	} {
+		config := &Config{Protocol: tt.server, ProtocolRequired: tt.required}
+		handshaker := &hybiServerHandshaker{Config: config}
+		header := ""
+		if tt.offered != "" {
+			header = "Sec-WebSocket-Protocol: " + tt.offered + "\r\n"
+		}
+		br := bufio.NewReader(strings.NewReader("GET /chat HTTP/1.1\r\n" +
+			"Host: server.example.com\r\n" +
+			"Upgrade: websocket\r\n" +
+			"Connection: Upgrade\r\n" +
+			"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+			header +
+			"Sec-WebSocket-Version: 13\r\n\r\n"))
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			t.Fatal("request", err)
+		}
+		code, err := handshaker.ReadHandshake(br, req)
+		if code != tt.code {
+			t.Errorf("server %q, offered %q: status %d, want %d", tt.server, tt.offered, code, tt.code)
+		}
+		if code != http.StatusSwitchingProtocols {
+			if err != ErrBadWebSocketProtocol {
+				t.Errorf("server %q, offered %q: error %v, want %v", tt.server, tt.offered, err, ErrBadWebSocketProtocol)
+			}
+			continue
+		}
+		var b bytes.Buffer
+		bw := bufio.NewWriter(&b)
+		if err := handshaker.AcceptHandshake(bw); err != nil {
+			t.Errorf("server %q, offered %q: handshake response failed: %v", tt.server, tt.offered, err)
+			continue
+		}
+		ws := handshaker.NewServerConn(bufio.NewReadWriter(br, bw), nil, req)
+		if got := ws.Subprotocol(); got != tt.want {
+			t.Errorf("server %q, offered %q: subprotocol %q, want %q", tt.server, tt.offered, got, tt.want)
+		}
+		header = "Sec-WebSocket-Protocol: " + tt.want + "\r\n"
+		if got := strings.Contains(b.String(), header); got != (tt.want != "") {
+			t.Errorf("server %q, offered %q: response %q", tt.server, tt.offered, b.String())
+		}
+	}
+}
+
+func TestHybiServerHandshakeCompression(t *testing.T) {
+	config := &Config{EnableCompression: true}
+	handshaker := &hybiServerHandshaker{Config: config}
+	br := bufio.NewReader(strings.NewReader(`GET /chat HTTP/1.1
+Host: server.example.com
+Upgrade: websocket
+Connection: Upgrade
+Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==
+Origin: http://example.com
+Sec-WebSocket-Extensions: x-webkit-deflate-frame, permessage-deflate; server_max_window_bits=20
+Sec-WebSocket-Extensions: permessage-deflate; server_no_context_takeover; server_max_window_bits=10; client_max_window_bits
+Sec-WebSocket-Version: 13
+
+`))
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		t.Fatal("request", err)
+	}
+	code, err := handshaker.ReadHandshake(br, req)
+	if err != nil {
+		t.Errorf("handshake failed: %v", err)
+	}
+	if code != http.StatusSwitchingProtocols {
+		t.Errorf("status expected %q but got %q", http.StatusSwitchingProtocols, code)
+	}
+	b := bytes.NewBuffer([]byte{})
+	bw := bufio.NewWriter(b)
+	err = handshaker.AcceptHandshake(bw)
+	if err != nil {
+		t.Errorf("handshake response failed: %v", err)
+	}
+	expectedResponse := strings.Join([]string{
+		"HTTP/1.1 101 Switching Protocols",
+		"Upgrade: websocket",
+		"Connection: Upgrade",
+		"Sec-WebSocket-Accept: s3pPLMBiTxaQ9kYGzzhZRbK+xOo=",
+		"Sec-WebSocket-Extensions: permessage-deflate; server_no_context_takeover; server_max_window_bits=10",
+		"", ""}, "\r\n")
+
+	if b.String() != expectedResponse {
+		t.Errorf("handshake expected %q but got %q", expectedResponse, b.String())
+	}
+}
+
+func TestHybiClientHandshakeCompression(t *testing.T) {
+	response := `HTTP/1.1 101 Switching Protocols
+Upgrade: websocket
+Connection: Upgrade
+Sec-WebSocket-Accept: s3pPLMBiTxaQ9kYGzzhZRbK+xOo=
+Sec-WebSocket-Extensions: permessage-deflate; client_no_context_takeover; client_max_window_bits=9
+
+`
+	for _, enable := range []bool{true, false} {
+		var b bytes.Buffer
+		bw := bufio.NewWriter(&b)
+		br := bufio.NewReader(strings.NewReader(response))
+		config, err := NewConfig("ws://server.example.com/chat", "http://example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.EnableCompression = enable
+		config.handshakeData = map[string]string{
+			"key": "dGhlIHNhbXBsZSBub25jZQ==",
+		}
+		_, deflate, err := hybiClientHandshake(config, br, bw)
+		if !enable {
+			if err != ErrUnsupportedExtensions {
+				t.Errorf("handshake without compression: got %v; want %v", err, ErrUnsupportedExtensions)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal("handshake", err)
+		}
+		req, err := http.ReadRequest(bufio.NewReader(&b))
+		if err != nil {
+			t.Fatal("read request", err)
+		}
+		if got, want := req.Header.Get("Sec-Websocket-Extensions"), "permessage-deflate; client_max_window_bits"; got != want {
+			t.Errorf("Sec-WebSocket-Extensions expected %q, but got %q", want, got)
+		}
+		want := deflateParams{clientNoContextTakeover: true, clientMaxWindowBits: 9}
+		if deflate == nil || *deflate != want {
+			t.Errorf("negotiated parameters %+v; want %+v", deflate, want)
+		}
+	}
+}
+
 func TestHybiServerHandshakeNoSubProtocol(t *testing.T) {
 	config := new(Config)
 	handshaker := &hybiServerHandshaker{Config: config}
@@ -291,7 +440,7 @@ Sec-WebSocket-Version: 9
 
 func testHybiFrame(t *testing.T, testHeader, testPayload, testMaskedPayload []byte, frameHeader *hybiFrameHeader) {
 	b := bytes.NewBuffer([]byte{})
-	frameWriterFactory := &hybiFrameWriterFactory{bufio.NewWriter(b), false}
+	frameWriterFactory := &hybiFrameWriterFactory{bufio.NewWriter(b), false, nil}
 	w, _ := frameWriterFactory.NewFrameWriter(TextFrame)
 	w.(*hybiFrameWriter).header = frameHeader
 	_, err := w.Write(testPayload)
@@ -411,7 +560,7 @@ func TestHybiClientRead(t *testing.T) {
 		0x81, 0x05, 'w', 'o', 'r', 'l', 'd'}
 	br := bufio.NewReader(bytes.NewBuffer(wireData))
 	bw := bufio.NewWriter(bytes.NewBuffer([]byte{}))
-	conn := newHybiConn(newConfig(t, "/"), bufio.NewReadWriter(br, bw), nil, nil)
+	conn := newHybiConn(newConfig(t, "/"), bufio.NewReadWriter(br, bw), nil, nil, nil)
 
 	msg := make([]byte, 512)
 	n, err := conn.Read(msg)
@@ -443,13 +592,253 @@ func TestHybiClientRead(t *testing.T) {
 	}
 }
 
+func TestHybiClientReadCompressed(t *testing.T) {
+	// Examples from RFC 7692, section 7.2.3.
+	wireData := []byte{
+		0xc1, 0x07, 0xf2, 0x48, 0xcd, 0xc9, 0xc9, 0x07, 0x00, // "Hello"
+		0x41, 0x03, 0xf2, 0x48, 0xcd, // fragmented "Hello"
+		0x89, 0x05, 'h', 'e', 'l', 'l', 'o', // ping
+		0x80, 0x04, 0xc9, 0xc9, 0x07, 0x00,
+		0xc1, 0x05, 0xf2, 0x00, 0x11, 0x00, 0x00, // "Hello" referring to the previous message
+		0x81, 0x05, 'w', 'o', 'r', 'l', 'd', // uncompressed
+	}
+	br := bufio.NewReader(bytes.NewBuffer(wireData))
+	bw := bufio.NewWriter(bytes.NewBuffer([]byte{}))
+	config := newConfig(t, "/")
+	conn := newHybiConn(config, bufio.NewReadWriter(br, bw), nil, nil, &deflateParams{})
+
+	msg := make([]byte, 512)
+	for i, want := range []string{"Hello", "Hello", "Hello", "world"} {
+		n, err := conn.Read(msg)
+		if err != nil {
+			t.Fatalf("read frame %d, error %q", i, err)
+		}
+		if string(msg[:n]) != want {
+			t.Errorf("read frame %d, got %q; want %q", i, msg[:n], want)
+		}
+	}
+	if _, err := conn.Read(msg); err == nil {
+		t.Errorf("read not EOF")
+	}
+}
+
+func TestHybiReadCompressedWithoutNegotiation(t *testing.T) {
+	wireData := []byte{0xc1, 0x07, 0xf2, 0x48, 0xcd, 0xc9, 0xc9, 0x07, 0x00}
+	br := bufio.NewReader(bytes.NewBuffer(wireData))
+	bw := bufio.NewWriter(bytes.NewBuffer([]byte{}))
+	conn := newHybiConn(newConfig(t, "/"), bufio.NewReadWriter(br, bw), nil, nil, nil)
+
+	msg := make([]byte, 512)
+	if n, err := conn.Read(msg); err != io.EOF {
+		t.Errorf("read compressed frame: got %q, %v; want EOF", msg[:n], err)
+	}
+}
+
+func TestHybiCompressedRoundTrip(t *testing.T) {
+	payload := bytes.Repeat([]byte(`{"metric":"cpu","value":42},`), 100)
+	for _, p := range []deflateParams{
+		{},
+		{serverNoContextTakeover: true, clientNoContextTakeover: true},
+		{serverMaxWindowBits: 9, clientMaxWindowBits: 8},
+	} {
+		var b bytes.Buffer
+		config := newConfig(t, "/")
+		br := bufio.NewReader(&b)
+		bw := bufio.NewWriter(&b)
+		client := newHybiConn(config, bufio.NewReadWriter(br, bw), nil, nil, &p)
+		server := newHybiConn(config, bufio.NewReadWriter(br, bw), nil, new(http.Request), &p)
+		for i := 0; i < 3; i++ {
+			if _, err := client.Write(payload); err != nil {
+				t.Fatalf("%+v: write: %v", p, err)
+			}
+			if b.Len() >= len(payload) {
+				t.Errorf("%+v: compressed message is %d bytes; payload is %d bytes", p, b.Len(), len(payload))
+			}
+			if b.Bytes()[0]&0x40 == 0 {
+				t.Errorf("%+v: RSV1 not set on compressed frame", p)
+			}
+			var got []byte
+			if err := Message.Receive(server, &got); err != nil {
+				t.Fatalf("%+v: receive: %v", p, err)
+			}
+			if !bytes.Equal(got, payload) {
+				t.Fatalf("%+v: received %q; want %q", p, got, payload)
+			}
+		}
+	}
+}
+
+func TestHybiReadMessage(t *testing.T) {
+	wireData := []byte{0x01, 0x03, 'h', 'e', 'l',
+		0x89, 0x05, 'h', 'e', 'l', 'l', 'o', // ping
+		0x80, 0x02, 'l', 'o',
+		0x82, 0x02, 0x00, 0x01}
+	br := bufio.NewReader(bytes.NewBuffer(wireData))
+	bw := bufio.NewWriter(bytes.NewBuffer([]byte{}))
+	conn := newHybiConn(newConfig(t, "/"), bufio.NewReadWriter(br, bw), nil, nil, nil)
+
+	for i, want := range []struct {
+		typ int
+		p   []byte
+	}{
+		{TextFrame, []byte("hello")},
+		{BinaryFrame, []byte{0x00, 0x01}},
+	} {
+		typ, p, err := conn.ReadMessage(context.Background())
+		if err != nil {
+			t.Fatalf("read message %d, error %q", i, err)
+		}
+		if typ != want.typ || !bytes.Equal(p, want.p) {
+			t.Errorf("read message %d, got %d %q; want %d %q", i, typ, p, want.typ, want.p)
+		}
+	}
+	if _, _, err := conn.ReadMessage(context.Background()); err == nil {
+		t.Errorf("read not EOF")
+	}
+}
+
+func TestHybiReadFrame(t *testing.T) {
+	wireData := []byte{0x01, 0x03, 'h', 'e', 'l',
+		0x89, 0x05, 'h', 'e', 'l', 'l', 'o', // ping
+		0x80, 0x02, 'l', 'o',
+		0x82, 0x04, 0x00, 0x01, 0x02, 0x03,
+		0x88, 0x02, 0x03, 0xe8}
+	br := bufio.NewReader(bytes.NewBuffer(wireData))
+	var out bytes.Buffer
+	bw := bufio.NewWriter(&out)
+	conn := newHybiConn(newConfig(t, "/"), bufio.NewReadWriter(br, bw), nil, nil, nil)
+	conn.MaxPayloadBytes = 3
+
+	for i, want := range []struct {
+		opcode byte
+		final  bool
+		p      []byte
+		err    error
+	}{
+		{TextFrame, false, []byte("hel"), nil},
+		{err: ErrFrameTooLarge}, // the ping, discarded by the next read
+		{ContinuationFrame, true, []byte("lo"), nil},
+		{err: ErrFrameTooLarge},
+		{CloseFrame, true, []byte{0x03, 0xe8}, nil},
+	} {
+		opcode, final, p, err := conn.ReadFrame()
+		if err != want.err {
+			t.Fatalf("read frame %d, error %v; want %v", i, err, want.err)
+		}
+		if err != nil {
+			continue
+		}
+		if opcode != want.opcode || final != want.final || !bytes.Equal(p, want.p) {
+			t.Errorf("read frame %d, got %d %v %q; want %d %v %q", i, opcode, final, p, want.opcode, want.final, want.p)
+		}
+	}
+	if _, _, _, err := conn.ReadFrame(); err != io.EOF {
+		t.Errorf("read after last frame, error %v; want EOF", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("ReadFrame wrote %x; want nothing", out.Bytes())
+	}
+}
+
+func TestHybiWriteFrame(t *testing.T) {
+	var out bytes.Buffer
+	bw := bufio.NewWriter(&out)
+	br := bufio.NewReader(bytes.NewBuffer(nil))
+	conn := newHybiConn(newConfig(t, "/"), bufio.NewReadWriter(br, bw), nil, new(http.Request), nil)
+
+	for _, f := range []struct {
+		opcode byte
+		final  bool
+		p      string
+	}{
+		{TextFrame, false, "hel"},
+		{PingFrame, true, "hi"},
+		{ContinuationFrame, true, "lo"},
+	} {
+		if err := conn.WriteFrame(f.opcode, f.final, []byte(f.p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []byte{0x01, 0x03, 'h', 'e', 'l',
+		0x89, 0x02, 'h', 'i',
+		0x80, 0x02, 'l', 'o'}
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("WriteFrame wrote %x; want %x", out.Bytes(), want)
+	}
+
+	if err := conn.WriteFrame(PingFrame, false, nil); err != ErrFragmentedControl {
+		t.Errorf("WriteFrame with fragmented ping = %v; want %v", err, ErrFragmentedControl)
+	}
+	if err := conn.WriteFrame(CloseFrame, true, make([]byte, maxControlFramePayloadLength+1)); err != ErrControlFrameTooLarge {
+		t.Errorf("WriteFrame with oversized close = %v; want %v", err, ErrControlFrameTooLarge)
+	}
+
+	// Frames written by a client are masked, and read back unmasked.
+	out.Reset()
+	client := newHybiConn(newConfig(t, "/"), bufio.NewReadWriter(br, bw), nil, nil, nil)
+	if err := client.WriteFrame(BinaryFrame, true, []byte{0x00, 0x01}); err != nil {
+		t.Fatal(err)
+	}
+	server := newHybiConn(newConfig(t, "/"), bufio.NewReadWriter(bufio.NewReader(&out), bw), nil, new(http.Request), nil)
+	opcode, final, p, err := server.ReadFrame()
+	if err != nil || opcode != BinaryFrame || !final || !bytes.Equal(p, []byte{0x00, 0x01}) {
+		t.Errorf("server read %d %v %x %v; want %d true 0001 <nil>", opcode, final, p, err, BinaryFrame)
+	}
+}
+
+func TestHybiPingPongHandlers(t *testing.T) {
+	wireData := []byte{
+		0x89, 0x05, 'h', 'e', 'l', 'l', 'o', // ping
+		0x8a, 0x05, 'w', 'o', 'r', 'l', 'd', // pong
+		0x81, 0x02, 'h', 'i',
+		0x89, 0x00, // ping
+		0x81, 0x02, 'h', 'i'}
+	var out bytes.Buffer
+	br := bufio.NewReader(bytes.NewBuffer(wireData))
+	bw := bufio.NewWriter(&out)
+	conn := newHybiConn(newConfig(t, "/"), bufio.NewReadWriter(br, bw), nil, nil, nil)
+
+	var pings, pongs []string
+	conn.SetPingHandler(func(data []byte) error {
+		pings = append(pings, string(data))
+		return nil
+	})
+	conn.SetPongHandler(func(data []byte) error {
+		pongs = append(pongs, string(data))
+		return nil
+	})
+	msg := make([]byte, 512)
+	if _, err := conn.Read(msg); err != nil {
+		t.Fatalf("read 1st frame, error %q", err)
+	}
+	if !reflect.DeepEqual(pings, []string{"hello"}) || !reflect.DeepEqual(pongs, []string{"world"}) {
+		t.Errorf("handlers got pings %q, pongs %q; want [hello], [world]", pings, pongs)
+	}
+	if out.Len() != 0 {
+		t.Errorf("custom ping handler: wrote %x; want nothing", out.Bytes())
+	}
+
+	// The default ping handler replies with a masked pong.
+	conn.SetPingHandler(nil)
+	if _, err := conn.Read(msg); err != nil {
+		t.Fatalf("read 2nd frame, error %q", err)
+	}
+	if got := out.Bytes(); len(got) != 6 || got[0] != 0x8a || got[1] != 0x80 {
+		t.Errorf("default ping handler wrote %x; want an empty masked pong", got)
+	}
+
+	if err := conn.WritePing(make([]byte, maxControlFramePayloadLength+1)); err != ErrControlFrameTooLarge {
+		t.Errorf("WritePing with oversized payload = %v; want %v", err, ErrControlFrameTooLarge)
+	}
+}
+
 func TestHybiShortRead(t *testing.T) {
 	wireData := []byte{0x81, 0x05, 'h', 'e', 'l', 'l', 'o',
 		0x89, 0x05, 'h', 'e', 'l', 'l', 'o', // ping
 		0x81, 0x05, 'w', 'o', 'r', 'l', 'd'}
 	br := bufio.NewReader(bytes.NewBuffer(wireData))
 	bw := bufio.NewWriter(bytes.NewBuffer([]byte{}))
-	conn := newHybiConn(newConfig(t, "/"), bufio.NewReadWriter(br, bw), nil, nil)
+	conn := newHybiConn(newConfig(t, "/"), bufio.NewReadWriter(br, bw), nil, nil, nil)
 
 	step := 0
 	pos := 0
@@ -493,7 +882,7 @@ func TestHybiServerRead(t *testing.T) {
 	}
 	br := bufio.NewReader(bytes.NewBuffer(wireData))
 	bw := bufio.NewWriter(bytes.NewBuffer([]byte{}))
-	conn := newHybiConn(newConfig(t, "/"), bufio.NewReadWriter(br, bw), nil, new(http.Request))
+	conn := newHybiConn(newConfig(t, "/"), bufio.NewReadWriter(br, bw), nil, new(http.Request), nil)
 
 	expected := [][]byte{[]byte("hello"), []byte("world")}
 
@@ -533,7 +922,7 @@ func TestHybiServerReadWithoutMasking(t *testing.T) {
 	wireData := []byte{0x81, 0x05, 'h', 'e', 'l', 'l', 'o'}
 	br := bufio.NewReader(bytes.NewBuffer(wireData))
 	bw := bufio.NewWriter(bytes.NewBuffer([]byte{}))
-	conn := newHybiConn(newConfig(t, "/"), bufio.NewReadWriter(br, bw), nil, new(http.Request))
+	conn := newHybiConn(newConfig(t, "/"), bufio.NewReadWriter(br, bw), nil, new(http.Request), nil)
 	// server MUST close the connection upon receiving a non-masked frame.
 	msg := make([]byte, 512)
 	_, err := conn.Read(msg)
@@ -548,7 +937,7 @@ func TestHybiClientReadWithMasking(t *testing.T) {
 	}
 	br := bufio.NewReader(bytes.NewBuffer(wireData))
 	bw := bufio.NewWriter(bytes.NewBuffer([]byte{}))
-	conn := newHybiConn(newConfig(t, "/"), bufio.NewReadWriter(br, bw), nil, nil)
+	conn := newHybiConn(newConfig(t, "/"), bufio.NewReadWriter(br, bw), nil, nil, nil)
 
 	// client MUST close the connection upon receiving a masked frame.
 	msg := make([]byte, 512)
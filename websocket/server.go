@@ -29,6 +29,13 @@ func newServerConn(rwc io.ReadWriteCloser, buf *bufio.ReadWriter, req *http.Requ
 		buf.Flush()
 		return
 	}
+	if config.CheckOrigin != nil && !config.CheckOrigin(config, req) {
+		code = http.StatusForbidden
+		fmt.Fprintf(buf, "HTTP/1.1 %03d %s\r\n", code, http.StatusText(code))
+		buf.WriteString("\r\n")
+		buf.Flush()
+		return nil, ErrBadWebSocketOrigin
+	}
 	if handshake != nil {
 		err = handshake(config, req)
 		if err != nil {
@@ -14,6 +14,7 @@ package websocket // import "golang.org/x/net/websocket"
 
 import (
 	"bufio"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
@@ -86,9 +87,19 @@ type Config struct {
 	// A Websocket client origin.
 	Origin *url.URL
 
-	// WebSocket subprotocols.
+	// WebSocket subprotocols. A client offers them to the server.
+	// A server that sets them negotiates a subprotocol: it selects
+	// the first of them, in its order of preference, that the client
+	// offers. A server that does not set them receives the client's
+	// offers here, and its Handshake func must choose one of them.
 	Protocol []string
 
+	// ProtocolRequired specifies whether a server whose Protocol is
+	// set fails the handshake with 400 Bad Request when the client
+	// offers none of its subprotocols. Otherwise, the connection is
+	// accepted without a subprotocol.
+	ProtocolRequired bool
+
 	// WebSocket protocol version.
 	Version int
 
@@ -101,6 +112,26 @@ type Config struct {
 	// Dialer used when opening websocket connections.
 	Dialer *net.Dialer
 
+	// Dial, if non-nil, is used instead of Dialer to open the network
+	// connection, which is then wrapped in TLS for wss locations. It is
+	// called with the "tcp" network.
+	Dial func(network, addr string) (net.Conn, error)
+
+	// EnableCompression specifies whether the client offers, or the
+	// server accepts, the permessage-deflate extension (RFC 7692).
+	// When negotiated, text and binary messages are compressed
+	// transparently; control frames are never compressed. The inflated
+	// size of a compressed message is limited by Conn.MaxPayloadBytes,
+	// and exceeding it closes the connection.
+	EnableCompression bool
+
+	// CheckOrigin, if non-nil, is called by the server during the
+	// opening handshake, before Server.Handshake, to validate the
+	// request's Origin header; websocket.Origin parses it. If CheckOrigin
+	// returns false, the handshake fails with 403 Forbidden. It is the
+	// place to guard against cross-site WebSocket hijacking.
+	CheckOrigin func(config *Config, req *http.Request) bool
+
 	handshakeData map[string]string
 }
 
@@ -162,8 +193,9 @@ type frameHandler interface {
 //
 // Multiple goroutines may invoke methods on a Conn simultaneously.
 type Conn struct {
-	config  *Config
-	request *http.Request
+	config      *Config
+	request     *http.Request
+	subprotocol string // negotiated in the opening handshake
 
 	buf *bufio.ReadWriter
 	rwc io.ReadWriteCloser
@@ -182,6 +214,9 @@ type Conn struct {
 	// MaxPayloadBytes limits the size of frame payload received over Conn
 	// by Codec's Receive method. If zero, DefaultMaxPayloadBytes is used.
 	MaxPayloadBytes int
+
+	pingHandler func(data []byte) error
+	pongHandler func(data []byte) error
 }
 
 // Read implements the io.Reader interface:
@@ -231,6 +266,259 @@ func (ws *Conn) Write(msg []byte) (n int, err error) {
 	return n, err
 }
 
+// ReadMessage reads the next complete text or binary message from ws,
+// reassembling continuation frames, and returns its payload type and data.
+// The size of the message is limited by ws.MaxPayloadBytes, as in Codec's
+// Receive method.
+//
+// If ctx is done before a message arrives, ReadMessage returns ctx.Err()
+// by moving the connection's read deadline into the past, and ws remains
+// usable. If ctx is done after part of a message has been read, the rest
+// of the message is lost and ws should be closed. Reads interrupted by
+// ctx reset the read deadline to zero.
+func (ws *Conn) ReadMessage(ctx context.Context) (messageType int, p []byte, err error) {
+	if err := ctx.Err(); err != nil {
+		return 0, nil, err
+	}
+	ws.rio.Lock()
+	defer ws.rio.Unlock()
+	stop := watchContext(ctx, ws.SetReadDeadline)
+	messageType, p, err = ws.readMessage()
+	if stop() && err != nil {
+		err = ctx.Err()
+	}
+	return messageType, p, err
+}
+
+func (ws *Conn) readMessage() (messageType int, p []byte, err error) {
+	if ws.frameReader != nil {
+		if _, err = io.Copy(ioutil.Discard, ws.frameReader); err != nil {
+			return 0, nil, err
+		}
+		ws.frameReader = nil
+	}
+	limit := ws.maxPayloadBytes()
+	for {
+		frame, err := ws.frameReaderFactory.NewFrameReader()
+		if err != nil {
+			return 0, nil, err
+		}
+		frame, err = ws.frameHandler.HandleFrame(frame)
+		if err != nil {
+			return 0, nil, err
+		}
+		if frame == nil {
+			continue
+		}
+		messageType = int(frame.PayloadType())
+		hf, ok := frame.(*hybiFrameReader)
+		if ok && hf.header.Length > int64(limit-len(p)) {
+			ws.frameReader = frame
+			return 0, nil, ErrFrameTooLarge
+		}
+		b, err := ioutil.ReadAll(frame)
+		if err != nil {
+			return 0, nil, err
+		}
+		p = append(p, b...)
+		if !ok || hf.header.Fin {
+			return messageType, p, nil
+		}
+	}
+}
+
+// WriteMessage writes data to ws as a single message of the given payload
+// type, which must be TextFrame or BinaryFrame.
+//
+// If ctx is done before the write completes, WriteMessage returns
+// ctx.Err() by moving the connection's write deadline into the past. A
+// write interrupted this way may leave a partial frame on the wire, so
+// ws should be closed. Writes interrupted by ctx reset the write deadline
+// to zero.
+func (ws *Conn) WriteMessage(ctx context.Context, messageType int, data []byte) error {
+	if messageType != TextFrame && messageType != BinaryFrame {
+		return ErrNotSupported
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ws.wio.Lock()
+	defer ws.wio.Unlock()
+	stop := watchContext(ctx, ws.SetWriteDeadline)
+	w, err := ws.frameWriterFactory.NewFrameWriter(byte(messageType))
+	if err == nil {
+		_, err = w.Write(data)
+		w.Close()
+	}
+	if stop() && err != nil {
+		err = ctx.Err()
+	}
+	return err
+}
+
+// ReadFrame reads the next frame from ws and returns its opcode, whether
+// it is the final fragment of its message, and its unmasked payload,
+// without reassembling messages or handling control frames: continuation
+// frames are returned with opcode ContinuationFrame, and Ping, Pong and
+// Close frames are returned like the others rather than being answered.
+// It allows proxies to forward frames verbatim, for instance with
+// WriteFrame. Any data of a frame partially read by Read is discarded.
+//
+// The payload size is limited by ws.MaxPayloadBytes; a larger frame is
+// reported as ErrFrameTooLarge and discarded by the next read.
+// ReadFrame returns ErrNotSupported if the connection negotiated
+// compression, since the payloads of compressed frames are meaningless
+// on their own.
+func (ws *Conn) ReadFrame() (opcode byte, final bool, payload []byte, err error) {
+	ws.rio.Lock()
+	defer ws.rio.Unlock()
+	if h, ok := ws.frameHandler.(*hybiFrameHandler); !ok || h.decompressor != nil {
+		return 0, false, nil, ErrNotSupported
+	}
+	if ws.frameReader != nil {
+		if _, err = io.Copy(ioutil.Discard, ws.frameReader); err != nil {
+			return 0, false, nil, err
+		}
+		ws.frameReader = nil
+	}
+	frame, err := ws.frameReaderFactory.NewFrameReader()
+	if err != nil {
+		return 0, false, nil, err
+	}
+	hf := frame.(*hybiFrameReader)
+	// Clients must mask the frames they send, and servers must not.
+	if (hf.header.MaskingKey == nil) == ws.IsServerConn() {
+		ws.frameHandler.WriteClose(closeStatusProtocolError)
+		return 0, false, nil, io.EOF
+	}
+	if hf.header.Length > int64(ws.maxPayloadBytes()) {
+		ws.frameReader = frame
+		return 0, false, nil, ErrFrameTooLarge
+	}
+	if payload, err = ioutil.ReadAll(frame); err != nil {
+		return 0, false, nil, err
+	}
+	return hf.header.OpCode, hf.header.Fin, payload, nil
+}
+
+// WriteFrame writes a single frame with the given opcode, final fragment
+// flag and payload to ws, masking it if ws is a client connection.
+// Unlike Write and WriteMessage, it allows writing fragmented messages,
+// as a first frame with a TextFrame or BinaryFrame opcode followed by
+// frames with the ContinuationFrame opcode, the last of which is final.
+// The caller is responsible for the validity of the sequence of frames.
+// Control frames must be final, and their payload at most 125 bytes long.
+//
+// WriteFrame returns ErrNotSupported if the connection negotiated
+// compression.
+func (ws *Conn) WriteFrame(opcode byte, final bool, payload []byte) error {
+	if opcode >= CloseFrame {
+		if !final {
+			return ErrFragmentedControl
+		}
+		if len(payload) > maxControlFramePayloadLength {
+			return ErrControlFrameTooLarge
+		}
+	}
+	ws.wio.Lock()
+	defer ws.wio.Unlock()
+	f, ok := ws.frameWriterFactory.(hybiFrameWriterFactory)
+	if !ok || f.compressor != nil {
+		return ErrNotSupported
+	}
+	w, err := f.NewFrameWriter(opcode)
+	if err != nil {
+		return err
+	}
+	w.(*hybiFrameWriter).header.Fin = final
+	_, err = w.Write(payload)
+	w.Close()
+	return err
+}
+
+// aLongTimeAgo is a non-zero time, far in the past, used for immediate
+// cancellation of network operations.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// watchContext arranges for setDeadline to be called with a time in the
+// past when ctx is done, interrupting a blocked read or write. The
+// returned stop function must be called once the operation completes; it
+// reports whether the operation was interrupted, in which case the
+// deadline has been reset to zero.
+func watchContext(ctx context.Context, setDeadline func(time.Time) error) (stop func() bool) {
+	if ctx.Done() == nil {
+		return func() bool { return false }
+	}
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	interrupted := false
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			interrupted = true
+			setDeadline(aLongTimeAgo)
+		case <-done:
+		}
+	}()
+	return func() bool {
+		close(done)
+		<-exited
+		if interrupted {
+			setDeadline(time.Time{})
+		}
+		return interrupted
+	}
+}
+
+// WritePing sends a Ping control frame carrying data, which must be at
+// most 125 bytes long.
+func (ws *Conn) WritePing(data []byte) error {
+	return ws.writeControl(PingFrame, data)
+}
+
+// WritePong sends a Pong control frame carrying data, which must be at
+// most 125 bytes long. An unsolicited Pong serves as a unidirectional
+// heartbeat.
+func (ws *Conn) WritePong(data []byte) error {
+	return ws.writeControl(PongFrame, data)
+}
+
+func (ws *Conn) writeControl(payloadType byte, data []byte) error {
+	if len(data) > maxControlFramePayloadLength {
+		return ErrControlFrameTooLarge
+	}
+	ws.wio.Lock()
+	defer ws.wio.Unlock()
+	w, err := ws.frameWriterFactory.NewFrameWriter(payloadType)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	w.Close()
+	return err
+}
+
+// SetPingHandler sets the function called with the application data of
+// each Ping frame received on ws. If h is nil, the default handler, which
+// replies with a Pong carrying the same data, is restored. A handler that
+// replaces the default is responsible for calling WritePong if a reply is
+// wanted.
+//
+// Handlers are called by the goroutine reading from ws, before the read
+// returns; an error returned by a handler is returned by the read.
+// SetPingHandler should not be called concurrently with reads.
+func (ws *Conn) SetPingHandler(h func(data []byte) error) {
+	ws.pingHandler = h
+}
+
+// SetPongHandler sets the function called with the application data of
+// each Pong frame received on ws. By default, and if h is nil, Pong frames
+// are ignored. Handlers are called as described for SetPingHandler.
+func (ws *Conn) SetPongHandler(h func(data []byte) error) {
+	ws.pongHandler = h
+}
+
 // Close implements the io.Closer interface.
 func (ws *Conn) Close() error {
 	err := ws.frameHandler.WriteClose(ws.defaultCloseStatus)
@@ -291,9 +579,20 @@ func (ws *Conn) SetWriteDeadline(t time.Time) error {
 	return errSetDeadline
 }
 
+func (ws *Conn) maxPayloadBytes() int {
+	if ws.MaxPayloadBytes == 0 {
+		return DefaultMaxPayloadBytes
+	}
+	return ws.MaxPayloadBytes
+}
+
 // Config returns the WebSocket config.
 func (ws *Conn) Config() *Config { return ws.config }
 
+// Subprotocol returns the subprotocol selected by the server in the
+// opening handshake, or the empty string if there is none.
+func (ws *Conn) Subprotocol() string { return ws.subprotocol }
+
 // Request returns the http request upgraded to the WebSocket.
 // It is nil for client side.
 func (ws *Conn) Request() *http.Request { return ws.request }
@@ -349,11 +648,7 @@ again:
 	if frame == nil {
 		goto again
 	}
-	maxPayloadBytes := ws.MaxPayloadBytes
-	if maxPayloadBytes == 0 {
-		maxPayloadBytes = DefaultMaxPayloadBytes
-	}
-	if hf, ok := frame.(*hybiFrameReader); ok && hf.header.Length > int64(maxPayloadBytes) {
+	if hf, ok := frame.(*hybiFrameReader); ok && hf.header.Length > int64(ws.maxPayloadBytes()) {
 		// payload size exceeds limit, no need to call Unmarshal
 		//
 		// set frameReader to current oversized frame so that
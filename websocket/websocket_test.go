@@ -6,6 +6,7 @@ package websocket
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"fmt"
 	"io"
@@ -126,6 +127,11 @@ func startServer() {
 		Handler:   Handler(subProtoServer),
 	}
 	http.Handle("/subproto", subproto)
+	negotiate := Server{
+		Config:  Config{Protocol: []string{"v2", "v1"}, ProtocolRequired: true},
+		Handler: func(ws *Conn) { io.WriteString(ws, ws.Subprotocol()) },
+	}
+	http.Handle("/negotiate", negotiate)
 	server := httptest.NewServer(nil)
 	serverAddr = server.Listener.Addr().String()
 	log.Print("Test WebSocket server listening on ", serverAddr)
@@ -307,6 +313,47 @@ func TestWithBadProtocol(t *testing.T) {
 	}
 }
 
+func TestNegotiateProtocol(t *testing.T) {
+	once.Do(startServer)
+
+	for _, tt := range []struct {
+		offered []string
+		want    string
+		err     error
+	}{
+		{[]string{"v1", "v2"}, "v2", nil},
+		{[]string{"v1", "v0"}, "v1", nil},
+		{[]string{"v3"}, "", ErrBadStatus},
+	} {
+		client, err := net.Dial("tcp", serverAddr)
+		if err != nil {
+			t.Fatal("dialing", err)
+		}
+		config := newConfig(t, "/negotiate")
+		config.Protocol = tt.offered
+		ws, err := NewClient(config, client)
+		if err != tt.err {
+			t.Errorf("offered %q: NewClient error %v, want %v", tt.offered, err, tt.err)
+		}
+		if err != nil {
+			client.Close()
+			continue
+		}
+		if got := ws.Subprotocol(); got != tt.want {
+			t.Errorf("offered %q: client Subprotocol() = %q, want %q", tt.offered, got, tt.want)
+		}
+		msg := make([]byte, 16)
+		n, err := ws.Read(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(msg[:n]); got != tt.want {
+			t.Errorf("offered %q: server Subprotocol() = %q, want %q", tt.offered, got, tt.want)
+		}
+		ws.Close()
+	}
+}
+
 func TestHTTP(t *testing.T) {
 	once.Do(startServer)
 
@@ -663,3 +710,143 @@ func TestCodec_ReceiveLimited(t *testing.T) {
 	}
 	<-handlerDone
 }
+
+func TestCompression(t *testing.T) {
+	server := httptest.NewServer(Server{
+		Config:  Config{EnableCompression: true},
+		Handler: echoServer,
+	})
+	defer server.Close()
+	config, err := NewConfig("ws://"+server.Listener.Addr().String()+"/", "http://localhost/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.EnableCompression = true
+	ws, err := DialConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	if ws.frameHandler.(*hybiFrameHandler).decompressor == nil {
+		t.Fatal("permessage-deflate not negotiated")
+	}
+	for i := 0; i < 10; i++ {
+		sent := Count{S: strings.Repeat("telemetry", i), N: i}
+		if err := JSON.Send(ws, sent); err != nil {
+			t.Fatal(err)
+		}
+		var got Count
+		if err := JSON.Receive(ws, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got != sent {
+			t.Fatalf("#%d: got %+v; want %+v", i, got, sent)
+		}
+	}
+}
+
+func TestReadWriteMessageContext(t *testing.T) {
+	server := httptest.NewServer(Handler(echoServer))
+	defer server.Close()
+	ws, err := Dial("ws://"+server.Listener.Addr().String()+"/", "", "http://localhost/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := ws.ReadMessage(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("ReadMessage with idle connection: got %v; want %v", err, context.DeadlineExceeded)
+	}
+
+	// The connection remains usable after the cancelled read.
+	if err := ws.WriteMessage(context.Background(), TextFrame, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	typ, p, err := ws.ReadMessage(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ != TextFrame || string(p) != "hello" {
+		t.Errorf("ReadMessage = %d, %q; want %d, %q", typ, p, TextFrame, "hello")
+	}
+
+	if err := ws.WriteMessage(context.Background(), PingFrame, nil); err != ErrNotSupported {
+		t.Errorf("WriteMessage(PingFrame) = %v; want %v", err, ErrNotSupported)
+	}
+	if err := ws.WriteMessage(ctx, TextFrame, []byte("late")); err != context.DeadlineExceeded {
+		t.Errorf("WriteMessage with expired context = %v; want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestPingPong(t *testing.T) {
+	server := httptest.NewServer(Handler(echoServer))
+	defer server.Close()
+	ws, err := Dial("ws://"+server.Listener.Addr().String()+"/", "", "http://localhost/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	var pong string
+	ws.SetPongHandler(func(data []byte) error {
+		pong = string(data)
+		return nil
+	})
+	if err := ws.WritePing([]byte("keepalive")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.WriteMessage(context.Background(), TextFrame, []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ws.ReadMessage(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if pong != "keepalive" {
+		t.Errorf("pong handler got %q; want %q", pong, "keepalive")
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	server := httptest.NewServer(Server{
+		Config: Config{
+			CheckOrigin: func(config *Config, req *http.Request) bool {
+				origin, err := Origin(config, req)
+				return err == nil && origin != nil && origin.Host == "good.example.com"
+			},
+		},
+		Handler: echoServer,
+	})
+	defer server.Close()
+	url := "ws://" + server.Listener.Addr().String() + "/"
+
+	ws, err := Dial(url, "", "http://good.example.com/")
+	if err != nil {
+		t.Fatalf("Dial with allowed origin: %v", err)
+	}
+	ws.Close()
+
+	_, err = Dial(url, "", "http://evil.example.com/")
+	if de, ok := err.(*DialError); !ok || de.Err != ErrBadStatus {
+		t.Fatalf("Dial with rejected origin: got %v; want %v", err, ErrBadStatus)
+	}
+
+	req, err := http.NewRequest("GET", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Origin", "http://evil.example.com")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("status = %d; want %d", resp.StatusCode, http.StatusForbidden)
+	}
+}
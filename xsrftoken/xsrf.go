@@ -16,8 +16,9 @@ import (
 	"time"
 )
 
-// Timeout is the duration for which XSRF tokens are valid.
+// Timeout is the duration for which XSRF tokens are valid by default.
 // It is exported so clients may set cookie timeouts that match generated tokens.
+// Use ValidFor to validate tokens with a different lifetime.
 const Timeout = 24 * time.Hour
 
 // clean sanitizes a string for inclusion in a token by replacing all ":" with "::".
@@ -25,7 +26,9 @@ func clean(s string) string {
 	return strings.Replace(s, `:`, `::`, -1)
 }
 
-// Generate returns a URL-safe secure XSRF token that expires in 24 hours.
+// Generate returns a URL-safe secure XSRF token for the user and action.
+// The token records only the time it was issued, so its lifetime is chosen
+// when it is validated: Valid uses Timeout, and ValidFor any other duration.
 //
 // key is a secret key for your application; it must be non-empty.
 // userID is an optional unique identifier for the user.
@@ -65,6 +68,35 @@ func ValidFor(token, key, userID, actionID string, timeout time.Duration) bool {
 	return validTokenAtTime(token, key, userID, actionID, time.Now(), timeout)
 }
 
+// ValidMulti is like Valid, but accepts a token generated with any of keys.
+// It allows the key to be rotated: during a transition period, tokens are
+// generated with the new key and validated with both the new and the old one.
+func ValidMulti(token string, keys []string, userID, actionID string) bool {
+	return validTokenAtTimeMulti(token, keys, userID, actionID, time.Now(), Timeout)
+}
+
+// ValidMultiFor is like ValidFor, but accepts a token generated with any of keys.
+func ValidMultiFor(token string, keys []string, userID, actionID string, timeout time.Duration) bool {
+	return validTokenAtTimeMulti(token, keys, userID, actionID, time.Now(), timeout)
+}
+
+// validTokenAtTimeMulti reports whether a token generated with one of keys
+// is valid at the given time.
+func validTokenAtTimeMulti(token string, keys []string, userID, actionID string, now time.Time, timeout time.Duration) bool {
+	if len(keys) == 0 {
+		panic("no xsrf secret keys")
+	}
+	// Check every key, so that the time taken does not reveal which one
+	// the token was generated with.
+	valid := false
+	for _, key := range keys {
+		if validTokenAtTime(token, key, userID, actionID, now, timeout) {
+			valid = true
+		}
+	}
+	return valid
+}
+
 // validTokenAtTime reports whether a token is valid at the given time.
 func validTokenAtTime(token, key, userID, actionID string, now time.Time, timeout time.Duration) bool {
 	if len(key) == 0 {
@@ -96,6 +96,24 @@ func TestInvalidToken(t *testing.T) {
 	}
 }
 
+func TestValidTokenMulti(t *testing.T) {
+	const oldKey, newKey = "old", "new"
+	keys := []string{newKey, oldKey}
+	for _, k := range keys {
+		tok := generateTokenAtTime(k, userID, actionID, now)
+		if !validTokenAtTimeMulti(tok, keys, userID, actionID, oneMinuteFromNow, Timeout) {
+			t.Errorf("Token generated with %q: Expected token to be valid", k)
+		}
+		if validTokenAtTimeMulti(tok, keys, userID, actionID, now.Add(Timeout+1*time.Millisecond), Timeout) {
+			t.Errorf("Token generated with %q: Expected expired token to be invalid", k)
+		}
+	}
+	tok := generateTokenAtTime("retired", userID, actionID, now)
+	if validTokenAtTimeMulti(tok, keys, userID, actionID, oneMinuteFromNow, Timeout) {
+		t.Error("Token generated with a retired key: Expected token to be invalid")
+	}
+}
+
 // TestValidateBadData primarily tests that no unexpected panics are triggered
 // during parsing
 func TestValidateBadData(t *testing.T) {